@@ -5,17 +5,20 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"kincart/internal/database"
 	"kincart/internal/models"
+	"kincart/internal/utils"
 
+	"github.com/google/uuid"
 	coremodels "github.com/ya-breeze/kin-core/models"
 	"golang.org/x/crypto/bcrypt"
 )
 
 func main() {
 	if len(os.Args) < 2 {
-		fmt.Println("expected 'add-family', 'add-user', or 'seed-categories' subcommands")
+		fmt.Println("expected 'add-family', 'add-user', 'set-role', 'reset-password-token', or 'seed-categories' subcommands")
 		os.Exit(1)
 	}
 
@@ -49,6 +52,8 @@ func main() {
 		userFamily := addUserCmd.String("family", "", "Family name")
 		username := addUserCmd.String("username", "", "Username")
 		password := addUserCmd.String("password", "", "Password")
+		email := addUserCmd.String("email", "", "Email for notifications (optional)")
+		role := addUserCmd.String("role", "", "Role: admin, manager or shopper (default manager for new users)")
 		if err := addUserCmd.Parse(os.Args[2:]); err != nil {
 			log.Fatalf("Failed to parse arguments: %v", err)
 		}
@@ -56,6 +61,9 @@ func main() {
 		if *userFamily == "" || *username == "" || *password == "" {
 			log.Fatal("Family, username, and password are required")
 		}
+		if *role != "" && !models.ValidRole(*role) {
+			log.Fatalf("Invalid role '%s': expected admin, manager or shopper", *role)
+		}
 
 		var family models.Family
 		if err := database.DB.Where("name = ?", *userFamily).First(&family).Error; err != nil {
@@ -72,6 +80,12 @@ func main() {
 			// Update existing user
 			user.PasswordHash = string(hash)
 			user.FamilyID = family.ID
+			if *email != "" {
+				user.Email = *email
+			}
+			if *role != "" {
+				user.Role = *role
+			}
 			if err := database.DB.Save(&user).Error; err != nil {
 				log.Fatalf("Failed to update user: %v", err)
 			}
@@ -84,6 +98,11 @@ func main() {
 					PasswordHash: string(hash),
 					FamilyID:     family.ID,
 				},
+				Email: *email,
+				Role:  *role,
+			}
+			if user.Role == "" {
+				user.Role = models.RoleManager
 			}
 			if err := database.DB.Create(&user).Error; err != nil {
 				log.Fatalf("Failed to create user: %v", err)
@@ -91,6 +110,60 @@ func main() {
 			fmt.Printf("User '%s' added to family '%s'\n", user.Username, family.Name)
 		}
 
+	case "set-role":
+		setRoleCmd := flag.NewFlagSet("set-role", flag.ExitOnError)
+		username := setRoleCmd.String("username", "", "Username")
+		role := setRoleCmd.String("role", "", "Role: admin, manager or shopper")
+		if err := setRoleCmd.Parse(os.Args[2:]); err != nil {
+			log.Fatalf("Failed to parse arguments: %v", err)
+		}
+
+		if *username == "" || !models.ValidRole(*role) {
+			log.Fatal("Username and a role (admin, manager or shopper) are required")
+		}
+
+		res := database.DB.Model(&models.User{}).Where("username = ?", *username).Update("role", *role)
+		if res.Error != nil {
+			log.Fatalf("Failed to update role: %v", res.Error)
+		}
+		if res.RowsAffected == 0 {
+			log.Fatalf("User '%s' not found", *username)
+		}
+		fmt.Printf("User '%s' is now %s\n", *username, *role)
+
+	case "reset-password-token":
+		resetCmd := flag.NewFlagSet("reset-password-token", flag.ExitOnError)
+		username := resetCmd.String("username", "", "Username")
+		ttl := resetCmd.Duration("ttl", 24*time.Hour, "How long the token stays valid")
+		if err := resetCmd.Parse(os.Args[2:]); err != nil {
+			log.Fatalf("Failed to parse arguments: %v", err)
+		}
+
+		if *username == "" {
+			log.Fatal("Username is required")
+		}
+
+		var user models.User
+		if err := database.DB.Where("username = ?", *username).First(&user).Error; err != nil {
+			log.Fatalf("User '%s' not found", *username)
+		}
+
+		token, hash, err := utils.NewSecretToken()
+		if err != nil {
+			log.Fatalf("Failed to generate token: %v", err)
+		}
+		rt := models.PasswordResetToken{
+			ID:        uuid.New(),
+			UserID:    user.ID,
+			TokenHash: hash,
+			ExpiresAt: time.Now().Add(*ttl),
+		}
+		if err := database.DB.Create(&rt).Error; err != nil {
+			log.Fatalf("Failed to store reset token: %v", err)
+		}
+		fmt.Printf("Reset token for '%s' (valid until %s, single use):\n%s\n",
+			user.Username, rt.ExpiresAt.Format(time.RFC3339), token)
+
 	case "seed-categories":
 		if len(os.Args) < 3 {
 			log.Fatal("Family name is required")
@@ -123,7 +196,7 @@ func main() {
 		fmt.Printf("Seeded %d categories for family '%s'\n", len(categories), family.Name)
 
 	default:
-		fmt.Println("expected 'add-family', 'add-user', or 'seed-categories' subcommands")
+		fmt.Println("expected 'add-family', 'add-user', 'set-role', 'reset-password-token', or 'seed-categories' subcommands")
 		os.Exit(1)
 	}
 }
@@ -11,6 +11,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"kincart/internal/ai"
 	"kincart/internal/flyers"
 	"kincart/internal/models"
 
@@ -53,14 +54,9 @@ func main() {
 		log.Fatal("-shop is mandatory when parsing a file")
 	}
 
-	apiKey := os.Getenv("GEMINI_API_KEY")
-	if apiKey == "" {
-		log.Fatal("GEMINI_API_KEY must be set in .env")
-	}
-
-	parser, err := flyers.NewParser(apiKey)
+	parser, err := ai.NewProvider(context.Background())
 	if err != nil {
-		log.Fatalf("failed to create parser: %v", err)
+		log.Fatalf("failed to create parser (configure GEMINI_API_KEY or AI_PROVIDER in .env): %v", err)
 	}
 
 	manager := flyers.NewManager(db, parser)
@@ -72,7 +68,7 @@ func main() {
 	startServer(db, port)
 }
 
-func parseLocalFile(manager *flyers.Manager, parser *flyers.Parser, path string, shopName string) {
+func parseLocalFile(manager *flyers.Manager, parser flyers.Parser, path string, shopName string) {
 	fmt.Printf("Parsing local file: %s\n", path)
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -7,6 +7,8 @@ import (
 	"strings"
 
 	"github.com/subosito/gotenv"
+
+	"kincart/internal/mailbox"
 )
 
 type stringSlice []string
@@ -39,17 +41,22 @@ func main() {
 		log.Fatal("IMAP_SERVER, IMAP_USER, and IMAP_PASSWORD must be set")
 	}
 
-	fetcher := NewFetcher(server, user, password)
+	fetcher := mailbox.NewFetcher(server, user, password)
 
 	log.Printf("Connecting and fetching from %s [%s]...", server, folder)
-	flyers, err := fetcher.FetchRecentFlyers(folder, fetchAttachments, subjects)
+	_, flyers, err := fetcher.Fetch(mailbox.Query{
+		Folder:      folder,
+		Subjects:    subjects,
+		Limit:       10,
+		Attachments: fetchAttachments,
+	})
 	if err != nil {
 		log.Fatalf("Failed to fetch: %v", err)
 	}
 
 	log.Printf("Found %d flyers:", len(flyers))
 	for i, f := range flyers {
-		log.Printf("[%d] %s (From: %s)", i+1, f.Subject, f.From)
+		log.Printf("[%d] %s (From: %s <%s>)", i+1, f.Subject, f.FromName, f.From)
 		if len(f.Attachments) > 0 {
 			log.Printf("    Attachments:")
 			for _, att := range f.Attachments {
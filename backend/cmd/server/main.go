@@ -7,6 +7,7 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -16,7 +17,10 @@ import (
 	"kincart/internal/database"
 	"kincart/internal/flyers"
 	"kincart/internal/handlers"
+	"kincart/internal/jobs"
+	"kincart/internal/mailbox"
 	"kincart/internal/middleware"
+	"kincart/internal/models"
 	"kincart/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -38,6 +42,9 @@ func run() error {
 
 	_ = gotenv.Load() // .env file is optional
 	database.InitDB()
+	if err := flyers.SeedRetailers(database.DB); err != nil {
+		slog.Error("Failed to seed flyer retailers", "error", err)
+	}
 
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
@@ -64,54 +71,121 @@ func run() error {
 	// Start token cleanup routine (blacklist + refresh tokens)
 	middleware.CleanupTokens(database.DB)
 
-	// Initialize Flyer Manager and start scheduler (disabled only if ENABLE_FLYER_SCHEDULER=false)
-	geminiKey := os.Getenv("GEMINI_API_KEY")
-	if os.Getenv("ENABLE_FLYER_SCHEDULER") != "false" {
-		if geminiKey == "" {
-			slog.Info("Flyer scheduler skipped — GEMINI_API_KEY not set")
-		} else {
-			parser, err := flyers.NewParser(geminiKey)
-			if err != nil {
-				slog.Error("Failed to initialize flyer parser", "error", err)
-			} else {
-				manager := flyers.NewManager(database.DB, parser)
-				manager.OutputDir = flyerItemsPath
-				flyers.StartScheduler(database.DB, manager)
+	// Background work runs on a persistent job queue. Receipts and flyers get
+	// separate queues so a batch of flyer pages never holds up a receipt the
+	// user is waiting for.
+	receiptQueue := jobs.NewQueue(database.DB)
+	receiptQueue.Workers = 2
+	if n, err := strconv.Atoi(os.Getenv("RECEIPT_WORKERS")); err == nil && n > 0 {
+		receiptQueue.Workers = n
+	}
+	flyerQueue := jobs.NewQueue(database.DB)
+
+	// Select the AI backend (AI_PROVIDER; see README). Flyer work needs one;
+	// receipts from known retailers can be parsed without it.
+	provider, err := ai.NewProvider(ctx)
+	if err != nil {
+		slog.Info("AI workers skipped", "reason", err)
+	}
+
+	var parser services.ReceiptParser
+	if provider != nil {
+		parser = provider
+	}
+	fileStorage := services.NewFileStorageService(dataPath)
+	receiptSvc := services.NewReceiptService(database.DB, parser, fileStorage, dataPath)
+	receiptQueue.Register(services.JobReceiptParse, receiptSvc.ProcessReceiptJob)
+
+	if provider != nil {
+		manager := flyers.NewManager(database.DB, provider)
+		manager.OutputDir = flyerItemsPath
+		manager.OnNewItems = services.WatchlistHook(database.DB)
+		if path := os.Getenv("FLYER_IMAP_CONFIG"); path != "" {
+			if manager.EmailAccounts, err = flyers.LoadEmailAccounts(path); err != nil {
+				slog.Error("Flyer emails skipped", "error", err)
 			}
 		}
-	}
+		flyers.RegisterJobs(flyerQueue, manager)
 
-	// Start Receipt Processing Scheduler every 10 minutes (disabled only if ENABLE_RECEIPT_SCHEDULER=false)
-	if os.Getenv("ENABLE_RECEIPT_SCHEDULER") != "false" {
-		if geminiKey == "" {
-			slog.Info("Receipt scheduler skipped — GEMINI_API_KEY not set")
-		} else {
+		// Queue flyer downloads daily (disabled only if ENABLE_FLYER_SCHEDULER=false)
+		if os.Getenv("ENABLE_FLYER_SCHEDULER") != "false" {
+			flyers.StartScheduler(ctx, database.DB, manager)
+		}
+
+		// Sweep 'new' receipts into the queue every 10 minutes (disabled only if ENABLE_RECEIPT_SCHEDULER=false)
+		if os.Getenv("ENABLE_RECEIPT_SCHEDULER") != "false" {
 			go func() {
 				ticker := time.NewTicker(10 * time.Minute)
 				defer ticker.Stop()
 
 				for {
+					if err := receiptSvc.EnqueuePendingReceipts(ctx); err != nil {
+						slog.Error("Failed to queue pending receipts", "error", err)
+					}
+
 					select {
 					case <-ticker.C:
 					case <-ctx.Done():
 						return
 					}
+				}
+			}()
+		}
+	}
 
-					gemClient, err := ai.NewGeminiClient(ctx)
-					if err != nil {
-						slog.Error("Failed to init Gemini for background receipts", "error", err)
-						continue
-					}
+	// Turn mail to the receipt inbox into receipts every 5 minutes (only if RECEIPT_IMAP_SERVER is set)
+	if server := os.Getenv("RECEIPT_IMAP_SERVER"); server != "" {
+		address := os.Getenv("RECEIPT_IMAP_ADDRESS")
+		if address == "" {
+			address = os.Getenv("RECEIPT_IMAP_USER")
+		}
+		inbox := services.NewReceiptInbox(receiptSvc, mailbox.Account{
+			Server:   server,
+			User:     os.Getenv("RECEIPT_IMAP_USER"),
+			Password: os.Getenv("RECEIPT_IMAP_PASSWORD"),
+			Folder:   os.Getenv("RECEIPT_IMAP_FOLDER"),
+		}, address)
+		inbox.AuthServID = os.Getenv("RECEIPT_IMAP_AUTHSERV_ID")
+		go func() {
+			ticker := time.NewTicker(5 * time.Minute)
+			defer ticker.Stop()
+
+			for {
+				if err := inbox.Fetch(ctx); err != nil {
+					slog.Error("Failed to fetch receipt emails", "error", err)
+				}
 
-					fileStorage := services.NewFileStorageService(dataPath)
-					svc := services.NewReceiptService(database.DB, gemClient, fileStorage, dataPath)
+				select {
+				case <-ticker.C:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
 
-					if err := svc.ProcessPendingReceipts(ctx); err != nil {
-						slog.Error("Background receipt processing error", "error", err)
-					}
+	receiptQueue.Start(ctx)
+	flyerQueue.Start(ctx)
+
+	// Create lists from recurring templates (disabled only if ENABLE_TEMPLATE_SCHEDULER=false).
+	// Hourly is plenty: a template's schedule has day granularity.
+	if os.Getenv("ENABLE_TEMPLATE_SCHEDULER") != "false" {
+		go func() {
+			ticker := time.NewTicker(time.Hour)
+			defer ticker.Stop()
+
+			for {
+				if _, err := services.ProcessDueTemplates(ctx, database.DB, time.Now()); err != nil {
+					slog.Error("Background template processing error", "error", err)
 				}
-			}()
-		}
+
+				select {
+				case <-ticker.C:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
 	}
 
 	r := gin.Default()
@@ -126,62 +200,54 @@ func run() error {
 	{
 		api.POST("/auth/login", middleware.LoginRateLimiter(), handlers.Login)
 		api.POST("/auth/refresh", handlers.Refresh)
+		api.POST("/auth/signup", middleware.LoginRateLimiter(), handlers.Signup)
+		api.POST("/auth/password/reset", middleware.LoginRateLimiter(), handlers.ResetPassword)
 
-		// Protected routes
+		// Protected routes. Every member (shoppers included) can read, tick
+		// items bought and complete a list; UpdateItem and UpdateList limit
+		// shoppers to the in-store fields.
 		protected := api.Group("/")
-		protected.Use(middleware.AuthMiddleware(database.DB))
+		protected.Use(middleware.AuthMiddleware(database.DB), middleware.RequireRole(database.DB, models.RoleShopper))
 		{
 			protected.GET("/auth/me", handlers.GetMe)
 			protected.POST("/auth/logout", handlers.Logout)
+			protected.POST("/auth/password", handlers.ChangePassword)
+			protected.GET("/auth/sessions", handlers.GetSessions)
+			protected.DELETE("/auth/sessions/:id", handlers.RevokeSession)
+
+			protected.GET("/notifications", handlers.GetNotifications)
+			protected.POST("/notifications/read-all", handlers.MarkAllNotificationsRead)
+			protected.POST("/notifications/:id/read", handlers.MarkNotificationRead)
 
 			protected.GET("/lists", handlers.GetLists)
 			protected.GET("/lists/:id", handlers.GetList)
-			protected.POST("/lists", handlers.CreateList)
-			protected.PATCH("/lists/:id", handlers.UpdateList)
-			protected.POST("/lists/:id/duplicate", handlers.DuplicateList)
-			protected.DELETE("/lists/:id", handlers.DeleteList)
+			protected.GET("/lists/:id/events", handlers.StreamListEvents)
+
+			protected.GET("/templates", handlers.GetTemplates)
+			protected.GET("/templates/:id", handlers.GetTemplate)
 
-			protected.POST("/lists/:id/items", handlers.AddItemToList)
-			protected.POST("/lists/:id/parse-text", handlers.ParseListText)
-			protected.POST("/lists/:id/items/bulk", handlers.BulkAddItems)
-			protected.POST("/lists/:id/receipts", handlers.UploadReceipt)
+			protected.GET("/receipts", handlers.GetReceipts)
+			protected.GET("/receipts/:id", handlers.GetReceipt)
 			protected.GET("/receipts/:id/file", handlers.GetReceiptFile)
 			protected.GET("/receipts/:id/matches", handlers.GetReceiptMatches)
-			protected.PATCH("/receipts/:id/matches/:receipt_item_id", handlers.ConfirmReceiptItemMatch)
-			protected.POST("/receipts/:id/matches/:receipt_item_id/dismiss", handlers.DismissReceiptItem)
-			protected.POST("/receipts/:id/matches/confirm-all", handlers.ConfirmAllMatches)
-			protected.POST("/items/link-alias", handlers.LinkItemAsAlias)
-			protected.PATCH("/items/:id", handlers.UpdateItem)
-			protected.DELETE("/items/:id", handlers.DeleteItem)
+			protected.GET("/receipts/:id/versions", handlers.GetReceiptVersions)
 
-			protected.POST("/items/:id/photo", handlers.AddItemPhoto)
+			protected.PATCH("/lists/:id", handlers.UpdateList)
+			protected.PATCH("/items/:id", handlers.UpdateItem)
 
 			protected.GET("/categories", handlers.GetCategories)
-			protected.POST("/categories", handlers.CreateCategory)
-			protected.PATCH("/categories/:id", handlers.UpdateCategory)
-			protected.DELETE("/categories/:id", handlers.DeleteCategory)
-			protected.PATCH("/categories/reorder", handlers.ReorderCategories)
 
 			protected.GET("/family/config", handlers.GetFamilyConfig)
-			protected.PATCH("/family/config", handlers.UpdateFamilyConfig)
 			protected.GET("/family/frequent-items", handlers.GetFrequentItems)
 			protected.GET("/family/frequent-items/hidden", handlers.GetHiddenFrequentItems)
-			protected.DELETE("/family/frequent-items/:id", handlers.DeleteFrequentItem)
-			protected.PATCH("/family/frequent-items/:id/restore", handlers.RestoreFrequentItem)
 			protected.GET("/family/item-suggestions", handlers.GetItemSuggestions)
 			protected.GET("/family/aliases", handlers.GetAliases)
-			protected.POST("/family/aliases", handlers.CreateAlias)
-			protected.PATCH("/family/aliases/groups/:name", handlers.RenameAliasGroup)
-			protected.DELETE("/family/aliases/groups/:name", handlers.DeleteAliasGroup)
-			protected.PATCH("/family/aliases/:id", handlers.UpdateAlias)
-			protected.DELETE("/family/aliases/:id", handlers.DeleteAlias)
+			protected.GET("/family/analytics/spending", handlers.GetSpendingAnalytics)
+			protected.GET("/family/analytics/price-trend", handlers.GetPriceTrendAnalytics)
+			protected.GET("/family/analytics/basket", handlers.GetBasketAnalytics)
 
 			protected.GET("/shops", handlers.GetShops)
-			protected.POST("/shops", handlers.CreateShop)
-			protected.PATCH("/shops/:id", handlers.UpdateShop)
-			protected.DELETE("/shops/:id", handlers.DeleteShop)
 			protected.GET("/shops/:id/order", handlers.GetShopCategoryOrder)
-			protected.PATCH("/shops/:id/order", handlers.SetShopCategoryOrder)
 
 			protected.GET("/flyers/items", handlers.GetFlyerItems)
 			protected.GET("/flyers/items/history", handlers.GetFlyerItemHistory)
@@ -192,6 +258,95 @@ func run() error {
 			protected.GET("/flyers/pages", handlers.GetFlyerPages)
 			protected.GET("/flyers/activity", handlers.GetFlyerActivity)
 			protected.GET("/flyers/items-detailed", handlers.GetFlyerItemsDetailed)
+
+			protected.GET("/budgets", handlers.GetBudgets)
+			protected.GET("/budgets/status", handlers.GetBudgetStatus)
+			protected.GET("/lists/:id/budget-check", handlers.CheckListBudget)
+
+			protected.GET("/watchlist", handlers.GetWatchlist)
+			protected.GET("/watchlist/matches", handlers.GetWatchlistMatches)
+
+			// Planning: creating, editing and deleting needs a manager.
+			planning := protected.Group("/")
+			planning.Use(middleware.RequireRole(database.DB, models.RoleManager))
+			{
+				planning.POST("/lists", handlers.CreateList)
+				planning.POST("/lists/:id/duplicate", handlers.DuplicateList)
+				planning.DELETE("/lists/:id", handlers.DeleteList)
+
+				planning.POST("/templates", handlers.CreateTemplate)
+				planning.PATCH("/templates/:id", handlers.UpdateTemplate)
+				planning.DELETE("/templates/:id", handlers.DeleteTemplate)
+				planning.POST("/templates/:id/instantiate", handlers.InstantiateTemplate)
+
+				planning.POST("/lists/:id/items", handlers.AddItemToList)
+				planning.POST("/lists/:id/parse-text", handlers.ParseListText)
+				planning.POST("/lists/:id/items/bulk", handlers.BulkAddItems)
+				planning.POST("/lists/:id/receipts", handlers.UploadReceipt)
+				planning.POST("/receipts", handlers.UploadStandaloneReceipt)
+				planning.POST("/receipts/:id/attach", handlers.AttachReceipt)
+				planning.POST("/receipts/:id/reprocess", handlers.ReprocessReceipt)
+				planning.POST("/receipts/:id/not-duplicate", handlers.MarkReceiptNotDuplicate)
+				planning.POST("/receipts/:id/versions/:version/restore", handlers.RestoreReceiptVersion)
+				planning.PATCH("/receipts/:id/matches/:receipt_item_id", handlers.ConfirmReceiptItemMatch)
+				planning.POST("/receipts/:id/matches/:receipt_item_id/dismiss", handlers.DismissReceiptItem)
+				planning.POST("/receipts/:id/matches/confirm-all", handlers.ConfirmAllMatches)
+				planning.POST("/items/link-alias", handlers.LinkItemAsAlias)
+				planning.DELETE("/items/:id", handlers.DeleteItem)
+
+				planning.POST("/items/:id/photo", handlers.AddItemPhoto)
+
+				planning.POST("/categories", handlers.CreateCategory)
+				planning.PATCH("/categories/:id", handlers.UpdateCategory)
+				planning.DELETE("/categories/:id", handlers.DeleteCategory)
+				planning.PATCH("/categories/reorder", handlers.ReorderCategories)
+
+				planning.PATCH("/family/config", handlers.UpdateFamilyConfig)
+				planning.DELETE("/family/frequent-items/:id", handlers.DeleteFrequentItem)
+				planning.PATCH("/family/frequent-items/:id/restore", handlers.RestoreFrequentItem)
+				planning.POST("/family/aliases", handlers.CreateAlias)
+				planning.PATCH("/family/aliases/groups/:name", handlers.RenameAliasGroup)
+				planning.DELETE("/family/aliases/groups/:name", handlers.DeleteAliasGroup)
+				planning.PATCH("/family/aliases/:id", handlers.UpdateAlias)
+				planning.DELETE("/family/aliases/:id", handlers.DeleteAlias)
+
+				planning.POST("/shops", handlers.CreateShop)
+				planning.PATCH("/shops/:id", handlers.UpdateShop)
+				planning.DELETE("/shops/:id", handlers.DeleteShop)
+				planning.PATCH("/shops/:id/order", handlers.SetShopCategoryOrder)
+
+				planning.POST("/budgets", handlers.SetBudget)
+				planning.PATCH("/budgets/:id", handlers.UpdateBudget)
+				planning.DELETE("/budgets/:id", handlers.DeleteBudget)
+
+				planning.POST("/flyers/upload", handlers.UploadFlyer)
+
+				planning.POST("/watchlist", handlers.CreateWatchlistItem)
+				planning.PATCH("/watchlist/:id", handlers.UpdateWatchlistItem)
+				planning.DELETE("/watchlist/:id", handlers.DeleteWatchlistItem)
+				planning.POST("/watchlist/matches/:id/add-to-list", handlers.AddWatchlistMatchToList)
+				planning.POST("/watchlist/matches/:id/dismiss", handlers.DismissWatchlistMatch)
+			}
+
+			// Membership: invites and member management are for admins.
+			admin := protected.Group("/")
+			admin.Use(middleware.RequireRole(database.DB, models.RoleAdmin))
+			{
+				admin.GET("/family/members", handlers.GetFamilyMembers)
+				admin.PATCH("/family/members/:id", handlers.UpdateFamilyMember)
+				admin.DELETE("/family/members/:id", handlers.RemoveFamilyMember)
+				admin.POST("/family/members/:id/revoke-sessions", handlers.RevokeFamilyMemberSessions)
+				admin.GET("/family/invites", handlers.GetFamilyInvites)
+				admin.POST("/family/invites", handlers.CreateFamilyInvite)
+				admin.DELETE("/family/invites/:id", handlers.RevokeFamilyInvite)
+				admin.GET("/family/receipt-address", handlers.GetReceiptAddress)
+				admin.POST("/family/receipt-address", handlers.CreateReceiptAddress)
+				admin.PATCH("/family/receipt-address", handlers.UpdateReceiptAddress)
+				admin.DELETE("/family/receipt-address", handlers.DeleteReceiptAddress)
+
+				admin.GET("/jobs", handlers.GetJobs)
+				admin.POST("/jobs/:id/retry", handlers.RetryJob)
+			}
 		}
 
 		// Internal routes (blocked by Nginx)
@@ -199,6 +354,12 @@ func run() error {
 		{
 			internal.POST("/flyers/parse", handlers.ParseFlyer)
 			internal.POST("/flyers/download", handlers.DownloadFlyers)
+			internal.GET("/flyers/retailers", handlers.GetFlyerRetailers)
+			internal.POST("/flyers/retailers", handlers.CreateFlyerRetailer)
+			internal.PATCH("/flyers/retailers/:id", handlers.UpdateFlyerRetailer)
+			internal.DELETE("/flyers/retailers/:id", handlers.DeleteFlyerRetailer)
+			internal.GET("/jobs", handlers.GetAllJobs)
+			internal.POST("/jobs/:id/retry", handlers.RetryAnyJob)
 		}
 	}
 
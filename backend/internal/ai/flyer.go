@@ -0,0 +1,125 @@
+package ai
+
+import (
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+type ParsedFlyer struct {
+	StartDate string       `json:"start_date"` // YYYY-MM-DD
+	EndDate   string       `json:"end_date"`   // YYYY-MM-DD
+	Items     []ParsedItem `json:"items"`
+}
+
+type ParsedItem struct {
+	Name          string    `json:"name"`
+	Price         float64   `json:"price"`
+	OriginalPrice *float64  `json:"original_price"` // Pointer to handle null from LLM
+	Quantity      string    `json:"quantity"`       // kg, 100g, pcs, pack, etc.
+	StartDate     string    `json:"start_date"`     // YYYY-MM-DD
+	EndDate       string    `json:"end_date"`       // YYYY-MM-DD
+	BoundingBox   []float64 `json:"bounding_box"`   // [ymin, xmin, ymax, xmax]
+	Categories    []string  `json:"categories"`     // English categories
+	Keywords      []string  `json:"keywords"`       // English keywords
+}
+
+const flyerPrompt = `
+Extract information from this flyer.
+For each item, provide a "bounding_box" that encompasses the entire area relevant to that item, which MUST include:
+1. The image of the item.
+2. The name/description text of the item.
+3. The price tag.
+
+Include the following for each item:
+1. a list of "categories" (e.g., fruits, tools, selfcare, toys, meat, etc.). MUST be in English.
+2. a list of "keywords" (e.g., beer, toothpaste, cafe, meat, chicken, lego, cheese, etc.). MUST be in English.
+3. original price if available.
+4. "start_date" and "end_date" (YYYY-MM-DD) if different from the whole flyer validity; otherwise use the flyer's dates for the item too.
+
+Return JSON in the following format:
+{
+  "start_date": "YYYY-MM-DD or empty if not found",
+  "end_date": "YYYY-MM-DD or empty if not found",
+  "items": [
+    {
+      "name": "Item name",
+      "price": 12.34,
+      "original_price": 15.99,
+      "quantity": "kg, 100g, pcs, pack, etc.",
+      "start_date": "YYYY-MM-DD",
+      "end_date": "YYYY-MM-DD",
+      "bounding_box": [ymin, xmin, ymax, xmax],
+      "categories": ["category 1", "category 2"],
+      "keywords": ["keyword1", "keyword2"]
+    }
+  ]
+}
+Return ONLY valid JSON. Do not include any text before or after the JSON block. Do not include comments or trailing commas. Ensure all strings are properly escaped.
+Keep bounding box coordinates as normalized values [0, 1000].
+The bounding box should be generous enough to capture all the mentioned elements without cutting them off.
+`
+
+// buildFlyerSchema returns the JSON schema for flyer parsing responses.
+func buildFlyerSchema() *genai.Schema {
+	return &genai.Schema{
+		Type: genai.TypeObject,
+		Properties: map[string]*genai.Schema{
+			"start_date": {Type: genai.TypeString, Description: "YYYY-MM-DD or empty if not found"},
+			"end_date":   {Type: genai.TypeString, Description: "YYYY-MM-DD or empty if not found"},
+			"items": {
+				Type: genai.TypeArray,
+				Items: &genai.Schema{
+					Type: genai.TypeObject,
+					Properties: map[string]*genai.Schema{
+						"name":           {Type: genai.TypeString},
+						"price":          {Type: genai.TypeNumber},
+						"original_price": {Type: genai.TypeNumber, Nullable: ptrBool(true)},
+						"quantity":       {Type: genai.TypeString},
+						"start_date":     {Type: genai.TypeString},
+						"end_date":       {Type: genai.TypeString},
+						"bounding_box": {
+							Type:  genai.TypeArray,
+							Items: &genai.Schema{Type: genai.TypeNumber},
+						},
+						"categories": {
+							Type:  genai.TypeArray,
+							Items: &genai.Schema{Type: genai.TypeString},
+						},
+						"keywords": {
+							Type:  genai.TypeArray,
+							Items: &genai.Schema{Type: genai.TypeString},
+						},
+					},
+					Required: []string{"name", "price", "quantity", "start_date", "end_date", "bounding_box", "categories", "keywords"},
+				},
+			},
+		},
+		Required: []string{"start_date", "end_date", "items"},
+	}
+}
+
+// flyerParts keeps the attachments a model can read: images and PDFs.
+func flyerParts(attachments []Attachment) []Attachment {
+	var out []Attachment
+	for _, att := range attachments {
+		if isImageOrPDF(att.ContentType) {
+			out = append(out, att)
+		}
+	}
+	return out
+}
+
+func isImageOrPDF(contentType string) bool {
+	return strings.HasPrefix(contentType, "image/") || contentType == "application/pdf"
+}
+
+func ptrBool(b bool) *bool {
+	return &b
+}
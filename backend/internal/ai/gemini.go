@@ -7,6 +7,7 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"google.golang.org/genai"
@@ -17,52 +18,14 @@ import (
 // AI features. Override with the GEMINI_MODEL env var.
 const defaultGeminiModel = "gemini-flash-latest"
 
-type GeminiClient struct {
-	client *genai.Client
-	model  string
-}
+// defaultFlyerModel is a stable rolling alias (see ADR-001). Override with the
+// GEMINI_FLYER_MODEL env var, e.g. to pin a stronger vision model.
+const defaultFlyerModel = "gemini-flash-latest"
 
-type ParsedReceipt struct {
-	StoreName string              `json:"store_name"`
-	Date      string              `json:"date"`
-	Total     float64             `json:"total"`
-	Items     []ParsedReceiptItem `json:"items"`
-}
-
-type ParsedReceiptItem struct {
-	Name       string  `json:"name"`
-	Quantity   float64 `json:"quantity"`
-	Unit       string  `json:"unit"`
-	Price      float64 `json:"price"`
-	TotalPrice float64 `json:"total_price"`
-}
-
-type MatchResult struct {
-	Suggestions []MatchSuggestion `json:"suggestions"`
-}
-
-type MatchSuggestion struct {
-	ReceiptItemName string           `json:"receipt_item_name"`
-	Matches         []MatchCandidate `json:"matches"`
-}
-
-type MatchCandidate struct {
-	PlannedItemName string `json:"planned_item_name"`
-	Confidence      int    `json:"confidence"` // 0-100
-}
-
-type ParsedShoppingItem struct {
-	Name     string  `json:"name"`
-	Quantity float64 `json:"quantity"`
-	Unit     string  `json:"unit"`
-	// Category is one of the family's own category names, or empty when the model
-	// had no confident pick. Never a name the family does not already have — the
-	// schema constrains it to an enum of their categories.
-	Category string `json:"category,omitempty"`
-}
-
-type parsedShoppingListResponse struct {
-	Items []ParsedShoppingItem `json:"items"`
+type GeminiClient struct {
+	client     *genai.Client
+	model      string
+	flyerModel string
 }
 
 // buildShoppingListSchema returns the parse schema, optionally constraining each
@@ -106,34 +69,33 @@ func buildShoppingListSchema(categories []string) *genai.Schema {
 	}
 }
 
-// categoryPromptSuffix tells the model how to use the category enum, when there is one.
-func categoryPromptSuffix(categories []string) string {
-	if len(categories) == 0 {
-		return ""
+// buildItemDefaultsSchema returns the schema for SuggestItemDefaults, with the
+// category constrained to the family's own names when there are any.
+func buildItemDefaultsSchema(categories []string) *genai.Schema {
+	props := map[string]*genai.Schema{
+		"unit": {
+			Type:        genai.TypeString,
+			Description: "The unit this item is normally bought in. One of: pcs, kg, g, 100g, l, ml, pack",
+		},
+	}
+	if len(categories) > 0 {
+		props["category"] = &genai.Schema{
+			Type: genai.TypeString,
+			Enum: categories,
+			Description: "The single best-fitting category, chosen from the listed values. " +
+				"Omit entirely if none clearly fits — do not guess.",
+		}
+	}
+	return &genai.Schema{
+		Type:       genai.TypeObject,
+		Properties: props,
+		Required:   []string{"unit"},
 	}
-	return "\n- Assign each item the best-fitting category from this list, using the name exactly as written: " +
-		strings.Join(categories, ", ") +
-		"\n- These are the user's own categories and may be in any language; do not translate them" +
-		"\n- If no category clearly fits an item, omit the category field for that item rather than guessing"
 }
 
 func (c *GeminiClient) ParseShoppingText(ctx context.Context, text string, categories []string) ([]ParsedShoppingItem, error) {
-	prompt := `You are a shopping list parser. Parse the following shopping list text into structured items.
-
-Rules:
-- Items are separated by commas or newlines
-- Quantity can appear before the item name ("2 йогурта") or after ("минералка 5")
-- If a quantity looks like "X+Y" (e.g. "кефир 4+2"), this is a retail promotion — split into TWO separate items with the same name: one with quantity X and one with quantity Y
-- Normalize item names to nominative case (e.g. "йогурта" → "йогурт", "творога" → "творог")
-- Return short generic names without quantity or unit in the name
-- Infer unit from context: кг/kg → "kg"; г/g → "g"; мл/ml → "ml"; л/l → "l"; otherwise → "pcs"
-- If no quantity is specified, assume 1` + categoryPromptSuffix(categories) + `
-
-Shopping list:
-` + text
-
 	content := &genai.Content{
-		Parts: []*genai.Part{{Text: prompt}},
+		Parts: []*genai.Part{{Text: shoppingTextPrompt(text, categories)}},
 	}
 
 	resp, err := c.client.Models.GenerateContent(ctx, c.model, []*genai.Content{content}, &genai.GenerateContentConfig{
@@ -144,15 +106,9 @@ Shopping list:
 		return nil, fmt.Errorf("gemini parsing error: %w", err)
 	}
 
-	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil || len(resp.Candidates[0].Content.Parts) == 0 {
-		return nil, fmt.Errorf("no candidates returned")
-	}
-
-	responseText := ""
-	for _, part := range resp.Candidates[0].Content.Parts {
-		if part.Text != "" {
-			responseText += part.Text
-		}
+	responseText, err := geminiResponseText(resp)
+	if err != nil {
+		return nil, err
 	}
 
 	var parsed parsedShoppingListResponse
@@ -163,12 +119,6 @@ Shopping list:
 	return parsed.Items, nil
 }
 
-// SuggestedItemDefaults is a common-sense guess for an item with no purchase history.
-type SuggestedItemDefaults struct {
-	Unit     string `json:"unit"`
-	Category string `json:"category,omitempty"`
-}
-
 // SuggestItemDefaults asks for a common-sense unit and category for a single item
 // name. Used on the receipt path, where items arrive one at a time and there is no
 // parse call to piggyback on.
@@ -176,48 +126,19 @@ type SuggestedItemDefaults struct {
 // Category is constrained to the family's own names, same as the batch path; with no
 // categories supplied it returns a unit only.
 func (c *GeminiClient) SuggestItemDefaults(ctx context.Context, name string, categories []string) (SuggestedItemDefaults, error) {
-	props := map[string]*genai.Schema{
-		"unit": {
-			Type:        genai.TypeString,
-			Description: "The unit this item is normally bought in. One of: pcs, kg, g, 100g, l, ml, pack",
-		},
-	}
-	if len(categories) > 0 {
-		props["category"] = &genai.Schema{
-			Type: genai.TypeString,
-			Enum: categories,
-			Description: "The single best-fitting category, chosen from the listed values. " +
-				"Omit entirely if none clearly fits — do not guess.",
-		}
-	}
-
-	prompt := "What unit is the grocery item \"" + name + "\" normally bought in?" +
-		categoryPromptSuffix(categories) +
-		"\n\nAnswer for this one item only."
-
 	resp, err := c.client.Models.GenerateContent(ctx, c.model,
-		[]*genai.Content{{Parts: []*genai.Part{{Text: prompt}}}},
+		[]*genai.Content{{Parts: []*genai.Part{{Text: itemDefaultsPrompt(name, categories)}}}},
 		&genai.GenerateContentConfig{
 			ResponseMIMEType: "application/json",
-			ResponseSchema: &genai.Schema{
-				Type:       genai.TypeObject,
-				Properties: props,
-				Required:   []string{"unit"},
-			},
+			ResponseSchema:   buildItemDefaultsSchema(categories),
 		})
 	if err != nil {
 		return SuggestedItemDefaults{}, fmt.Errorf("gemini categorize error: %w", err)
 	}
 
-	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil || len(resp.Candidates[0].Content.Parts) == 0 {
-		return SuggestedItemDefaults{}, fmt.Errorf("no candidates returned")
-	}
-
-	responseText := ""
-	for _, part := range resp.Candidates[0].Content.Parts {
-		if part.Text != "" {
-			responseText += part.Text
-		}
+	responseText, err := geminiResponseText(resp)
+	if err != nil {
+		return SuggestedItemDefaults{}, err
 	}
 
 	var out SuggestedItemDefaults
@@ -244,8 +165,9 @@ func NewGeminiClient(ctx context.Context) (*GeminiClient, error) {
 	slog.Debug("Gemini client initialized", "model", model)
 
 	return &GeminiClient{
-		client: client,
-		model:  model,
+		client:     client,
+		model:      model,
+		flyerModel: ResolveModel("GEMINI_FLYER_MODEL", defaultFlyerModel),
 	}, nil
 }
 
@@ -256,7 +178,7 @@ func resolveGeminiModel() string {
 }
 
 // ResolveModel returns the model named by envVar, or fallback when it is unset.
-// Shared by every Gemini call site so no path pins a specific (retirable) model.
+// Shared by every model call site so no path pins a specific (retirable) model.
 func ResolveModel(envVar, fallback string) string {
 	if m := os.Getenv(envVar); m != "" {
 		return m
@@ -282,6 +204,7 @@ func buildReceiptSchema() *genai.Schema {
 						"unit":        {Type: genai.TypeString},
 						"price":       {Type: genai.TypeNumber},
 						"total_price": {Type: genai.TypeNumber},
+						"page":        {Type: genai.TypeInteger, Description: "1-based photo or page the line was read from"},
 					},
 					Required: []string{"name", "price", "total_price"},
 				},
@@ -291,10 +214,10 @@ func buildReceiptSchema() *genai.Schema {
 	}
 }
 
-// parseGeminiResponse extracts and decodes the ParsedReceipt from a Gemini response.
-func parseGeminiResponse(resp *genai.GenerateContentResponse) (*ParsedReceipt, error) {
+// geminiResponseText joins the text parts of the first candidate.
+func geminiResponseText(resp *genai.GenerateContentResponse) (string, error) {
 	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil || len(resp.Candidates[0].Content.Parts) == 0 {
-		return nil, fmt.Errorf("no candidates returned")
+		return "", fmt.Errorf("no candidates returned")
 	}
 
 	responseText := ""
@@ -303,12 +226,12 @@ func parseGeminiResponse(resp *genai.GenerateContentResponse) (*ParsedReceipt, e
 			responseText += part.Text
 		}
 	}
+	return responseText, nil
+}
 
-	// Strip markdown fences if present
-	responseText = strings.TrimPrefix(responseText, "```json")
-	responseText = strings.TrimPrefix(responseText, "```")
-	responseText = strings.TrimSuffix(responseText, "```")
-	responseText = strings.TrimSpace(responseText)
+// decodeParsedReceipt decodes a receipt response, tolerating markdown fences.
+func decodeParsedReceipt(responseText string) (*ParsedReceipt, error) {
+	responseText = cleanJSON(responseText)
 
 	var parsed ParsedReceipt
 	if err := json.Unmarshal([]byte(responseText), &parsed); err != nil {
@@ -318,37 +241,47 @@ func parseGeminiResponse(resp *genai.GenerateContentResponse) (*ParsedReceipt, e
 	return &parsed, nil
 }
 
-func (c *GeminiClient) ParseReceipt(ctx context.Context, imagePath string, knownItems []string) (*ParsedReceipt, error) {
-	imgData, err := os.ReadFile(imagePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read receipt image: %w", err)
+// readReceiptFiles loads receipt photos or PDFs with their MIME types.
+func readReceiptFiles(paths []string) ([]Attachment, error) {
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no receipt images to parse")
 	}
+	files := make([]Attachment, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read receipt image: %w", err)
+		}
+		files = append(files, Attachment{Filename: filepath.Base(path), ContentType: receiptMIMEType(path, data), Data: data})
+	}
+	return files, nil
+}
 
-	prompt := fmt.Sprintf(`
-You are a receipt parser. Parse the following receipt image.
-Extract the store name, date (YYYY-MM-DD), total amount, and all items.
-For each item, extract the name, quantity, unit, price per unit, and total price.
-If the unit is not explicitly stated but can be inferred (e.g. kg, pieces), use pieces as default or infer from context.
-The context list of known items is: %s. Use this to help match naming conventions, but priority is what's on receipt.
-
-Price rules:
-- Always use the price the customer actually pays — including all taxes (VAT/DPH). Never use pre-tax prices.
-- If a product is sold as a multi-pack (e.g. "6×150g", "3-pack", "4+2", "10ks"), treat the whole pack as 1 unit: set quantity=1 and price=total_price for that line. Do not split packs into individual pieces.
-
-Return strict JSON.
-`, strings.Join(knownItems, ", "))
+// receiptMIMEType detects the MIME type of a receipt file. PDFs are recognised
+// by extension as well, since sniffing does not always catch them.
+func receiptMIMEType(path string, data []byte) string {
+	if strings.HasSuffix(strings.ToLower(path), ".pdf") {
+		return "application/pdf"
+	}
+	return http.DetectContentType(data)
+}
 
-	// Detect MIME type
-	mimeType := http.DetectContentType(imgData)
-	if strings.HasSuffix(strings.ToLower(imagePath), ".pdf") {
-		mimeType = "application/pdf"
+// ParseReceipt parses a receipt from one or more photos or PDFs, in order.
+// They are sent in a single request so the model sees the whole receipt.
+func (c *GeminiClient) ParseReceipt(ctx context.Context, imagePaths []string, knownItems []string) (*ParsedReceipt, error) {
+	files, err := readReceiptFiles(imagePaths)
+	if err != nil {
+		return nil, err
 	}
 
 	content := &genai.Content{
-		Parts: []*genai.Part{
-			{Text: prompt},
-			{InlineData: &genai.Blob{MIMEType: mimeType, Data: imgData}},
-		},
+		Parts: make([]*genai.Part, 0, len(files)+1),
+	}
+	content.Parts = append(content.Parts, &genai.Part{Text: receiptImagePrompt(knownItems, len(files))})
+	for _, f := range files {
+		content.Parts = append(content.Parts, &genai.Part{
+			InlineData: &genai.Blob{MIMEType: f.ContentType, Data: f.Data},
+		})
 	}
 
 	resp, err := c.client.Models.GenerateContent(ctx, c.model, []*genai.Content{content}, &genai.GenerateContentConfig{
@@ -359,40 +292,19 @@ Return strict JSON.
 		return nil, fmt.Errorf("gemini generation error: %w", err)
 	}
 
-	return parseGeminiResponse(resp)
+	responseText, err := geminiResponseText(resp)
+	if err != nil {
+		return nil, err
+	}
+	return decodeParsedReceipt(responseText)
 }
 
 // ParseReceiptText parses a plain-text receipt using Gemini.
 // The text is normalized (BOM stripped, line endings unified) before sending.
 func (c *GeminiClient) ParseReceiptText(ctx context.Context, receiptText string, knownItems []string) (*ParsedReceipt, error) {
-	// Normalize: strip UTF-8 BOM, unify line endings, trim outer whitespace
-	receiptText = strings.TrimPrefix(receiptText, "\xef\xbb\xbf")
-	receiptText = strings.ReplaceAll(receiptText, "\r\n", "\n")
-	receiptText = strings.TrimSpace(receiptText)
-
-	prompt := fmt.Sprintf(`
-You are a receipt parser. Parse the following receipt text.
-Extract the store name, date (YYYY-MM-DD), total amount, and all items.
-For each item, extract the name, quantity, unit, price per unit, and total price.
-If the unit is not explicitly stated but can be inferred (e.g. kg, pieces), use pieces as default or infer from context.
-Note: prices may use European decimal notation (comma as decimal separator, e.g. "1,99" means 1.99).
-The context list of known items is: %s. Use this to help match naming conventions, but priority is what's on the receipt.
-
-Price rules:
-- Always use the price the customer actually pays — including all taxes (VAT/DPH). Never use pre-tax prices.
-- If a product is sold as a multi-pack (e.g. "6×150g", "3-pack", "4+2", "10ks"), treat the whole pack as 1 unit: set quantity=1 and price=total_price for that line. Do not split packs into individual pieces.
-
-Receipt text:
----
-%s
----
-
-Return strict JSON.
-`, strings.Join(knownItems, ", "), receiptText)
-
 	content := &genai.Content{
 		Parts: []*genai.Part{
-			{Text: prompt},
+			{Text: receiptTextPrompt(receiptText, knownItems)},
 		},
 	}
 
@@ -404,7 +316,11 @@ Return strict JSON.
 		return nil, fmt.Errorf("gemini generation error: %w", err)
 	}
 
-	return parseGeminiResponse(resp)
+	responseText, err := geminiResponseText(resp)
+	if err != nil {
+		return nil, err
+	}
+	return decodeParsedReceipt(responseText)
 }
 
 // buildMatchSchema returns the strict JSON schema for MatchReceiptItems responses.
@@ -443,32 +359,11 @@ func buildMatchSchema() *genai.Schema {
 // matches is empty). Uses strict structured output — no free-form parsing.
 func (c *GeminiClient) MatchReceiptItems(ctx context.Context, receiptItems []string, plannedItems []string) (*MatchResult, error) {
 	if len(receiptItems) == 0 || len(plannedItems) == 0 {
-		suggestions := make([]MatchSuggestion, len(receiptItems))
-		for i, name := range receiptItems {
-			suggestions[i] = MatchSuggestion{ReceiptItemName: name, Matches: []MatchCandidate{}}
-		}
-		return &MatchResult{Suggestions: suggestions}, nil
+		return emptyMatchResult(receiptItems), nil
 	}
 
-	prompt := fmt.Sprintf(`You are a shopping item matcher. Given receipt items and a planned shopping list, determine which receipt items correspond to which planned items.
-
-Rules:
-- A receipt item may match 0 or 1 planned items
-- A planned item may match 0 or 1 receipt items
-- Return confidence as integer percentage (0-100)
-- Consider that planned items are often short/generic (e.g. "jogurt") while receipt items are specific (e.g. "selský jogurt 2%%")
-- Items in different languages or with brand names can still match
-- If no good match exists, return empty matches array
-- You MUST return exactly one suggestion entry per receipt item, even if matches is empty
-
-Receipt items: %s
-Planned items: %s`,
-		strings.Join(receiptItems, ", "),
-		strings.Join(plannedItems, ", "),
-	)
-
 	content := &genai.Content{
-		Parts: []*genai.Part{{Text: prompt}},
+		Parts: []*genai.Part{{Text: matchPrompt(receiptItems, plannedItems)}},
 	}
 
 	resp, err := c.client.Models.GenerateContent(ctx, c.model, []*genai.Content{content}, &genai.GenerateContentConfig{
@@ -479,17 +374,11 @@ Planned items: %s`,
 		return nil, fmt.Errorf("gemini matching error: %w", err)
 	}
 
-	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil || len(resp.Candidates[0].Content.Parts) == 0 {
+	responseText, err := geminiResponseText(resp)
+	if err != nil {
 		return nil, fmt.Errorf("no candidates returned from match call")
 	}
 
-	responseText := ""
-	for _, part := range resp.Candidates[0].Content.Parts {
-		if part.Text != "" {
-			responseText += part.Text
-		}
-	}
-
 	var result MatchResult
 	if err := json.Unmarshal([]byte(responseText), &result); err != nil {
 		return nil, fmt.Errorf("failed to decode match response: %w, response: %s", err, responseText)
@@ -497,3 +386,55 @@ Planned items: %s`,
 
 	return &result, nil
 }
+
+// ParseFlyer extracts the discounted items from flyer pages (images or PDFs).
+// It uses the flyer model (GEMINI_FLYER_MODEL), not the receipt one.
+func (c *GeminiClient) ParseFlyer(ctx context.Context, attachments []Attachment) (*ParsedFlyer, error) {
+	if len(attachments) == 0 {
+		return nil, fmt.Errorf("no attachments to parse")
+	}
+
+	parts := flyerParts(attachments)
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("no supported attachments (images) found for parsing")
+	}
+
+	slog.Info("Sending flyer to Gemini for parsing", "attachment_count", len(attachments))
+
+	content := &genai.Content{
+		Parts: make([]*genai.Part, 0, len(parts)+1),
+	}
+	content.Parts = append(content.Parts, &genai.Part{Text: flyerPrompt})
+	for _, att := range parts {
+		content.Parts = append(content.Parts, &genai.Part{
+			InlineData: &genai.Blob{MIMEType: att.ContentType, Data: att.Data},
+		})
+	}
+
+	resp, err := c.client.Models.GenerateContent(ctx, c.flyerModel, []*genai.Content{content}, &genai.GenerateContentConfig{
+		ResponseMIMEType: "application/json",
+		ResponseSchema:   buildFlyerSchema(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate content: %w", err)
+	}
+
+	jsonStr, err := geminiResponseText(resp)
+	if err != nil {
+		return nil, fmt.Errorf("empty response from Gemini")
+	}
+
+	return decodeParsedFlyer(jsonStr)
+}
+
+// decodeParsedFlyer decodes a flyer response, tolerating markdown fences.
+func decodeParsedFlyer(jsonStr string) (*ParsedFlyer, error) {
+	jsonStr = cleanJSON(jsonStr)
+
+	var parsed ParsedFlyer
+	if err := json.Unmarshal([]byte(jsonStr), &parsed); err != nil {
+		slog.Error("Failed to unmarshal flyer JSON", "raw", jsonStr, "error", err)
+		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+	return &parsed, nil
+}
@@ -0,0 +1,183 @@
+package ai
+
+import (
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"kincart/internal/utils"
+)
+
+var (
+	reMatchSeparators = regexp.MustCompile(`[^\p{L}\p{N}%]+`)
+	// reMatchQuantity catches sizes and counts: "1l", "1,5kg", "150g", "2%", "6x", "6x150g", "10ks".
+	reMatchQuantity = regexp.MustCompile(`^(?:\d+x)?\d+(?:[.,]\d+)?(?:x|ks|kg|g|dkg|l|ml|cl|%)?$`)
+)
+
+// matchUnitWords are unit and packaging tokens that say nothing about the product.
+var matchUnitWords = map[string]bool{
+	"ks": true, "kg": true, "g": true, "dkg": true, "l": true, "ml": true, "cl": true,
+	"pcs": true, "pack": true, "bal": true, "baleni": true, "pet": true, "plech": true,
+	"x": true, "%": true,
+}
+
+// matchStopWords are short Czech connectives that appear in product names.
+var matchStopWords = map[string]bool{
+	"a": true, "s": true, "se": true, "v": true, "ve": true, "na": true, "z": true, "ze": true, "bez": true, "do": true,
+}
+
+// matchAbbreviations expands shorthand printed on receipts that is not simply a
+// truncated word (truncation is handled by prefix similarity). Keys and values
+// are already normalized.
+var matchAbbreviations = map[string]string{
+	"mlk":   "mleko",
+	"mlko":  "mleko",
+	"jgt":   "jogurt",
+	"jog":   "jogurt",
+	"pltc":  "polotucne",
+	"pltcn": "polotucne",
+	"pln":   "plnotucne",
+	"trv":   "trvanlive",
+	"cerst": "cerstve",
+	"kur":   "kureci",
+	"vep":   "veprove",
+	"hov":   "hovezi",
+	"uz":    "uzene",
+	"brb":   "brambory",
+	"rohl":  "rohlik",
+	"chl":   "chleb",
+	"min":   "mineralni",
+	"sm":    "smetana",
+	"zmrz":  "zmrzlina",
+	"cok":   "cokolada",
+	"choc":  "cokolada",
+}
+
+const (
+	// minLocalConfidence drops candidates too weak to be worth showing.
+	minLocalConfidence = 50
+	// maxLocalCandidates caps the suggestions per receipt item, like the AI does in practice.
+	maxLocalCandidates = 3
+	// coverageWeight favours planned words found on the receipt line over extra
+	// receipt words (brands, fat content), since planned names are short and generic.
+	coverageWeight = 0.8
+)
+
+// MatchReceiptItemsFallback scores receipt items against planned items without
+// AI, using diacritic-insensitive token similarity. Confidences are on the same
+// 0-100 scale as MatchReceiptItems: only an exact or near-exact token match of
+// every planned word reaches the auto-match range. It returns one suggestion per
+// receipt item, best candidates first.
+func MatchReceiptItemsFallback(receiptItems []string, plannedItems []string) *MatchResult {
+	planned := make([][]string, len(plannedItems))
+	for i, name := range plannedItems {
+		planned[i] = matchTokens(name)
+	}
+
+	result := emptyMatchResult(receiptItems)
+	for i, name := range receiptItems {
+		rt := matchTokens(name)
+		var candidates []MatchCandidate
+		for j, pt := range planned {
+			confidence := int(math.Round(matchScore(rt, pt) * 100))
+			if confidence >= minLocalConfidence {
+				candidates = append(candidates, MatchCandidate{PlannedItemName: plannedItems[j], Confidence: confidence})
+			}
+		}
+		sort.SliceStable(candidates, func(a, b int) bool {
+			return candidates[a].Confidence > candidates[b].Confidence
+		})
+		if len(candidates) > maxLocalCandidates {
+			candidates = candidates[:maxLocalCandidates]
+		}
+		if candidates != nil {
+			result.Suggestions[i].Matches = candidates
+		}
+	}
+	return result
+}
+
+// matchTokens normalizes a product name into comparable words: no diacritics,
+// no sizes or units, abbreviations expanded.
+func matchTokens(s string) []string {
+	s = utils.NormalizeSearchText(s)
+	var tokens []string
+	for _, tok := range reMatchSeparators.Split(s, -1) {
+		if tok == "" || matchUnitWords[tok] || matchStopWords[tok] || reMatchQuantity.MatchString(tok) {
+			continue
+		}
+		if full, ok := matchAbbreviations[tok]; ok {
+			tok = full
+		}
+		tokens = append(tokens, tok)
+	}
+	return tokens
+}
+
+// matchScore rates how well receipt tokens rt describe planned tokens pt, 0..1.
+func matchScore(rt, pt []string) float64 {
+	if len(rt) == 0 || len(pt) == 0 {
+		return 0
+	}
+	coverage := bestTokenAverage(pt, rt)
+	precision := bestTokenAverage(rt, pt)
+	return coverageWeight*coverage + (1-coverageWeight)*precision
+}
+
+// bestTokenAverage averages, over tokens in from, the best similarity to any token in to.
+func bestTokenAverage(from, to []string) float64 {
+	var sum float64
+	for _, a := range from {
+		best := 0.0
+		for _, b := range to {
+			if s := tokenSimilarity(a, b); s > best {
+				best = s
+			}
+		}
+		sum += best
+	}
+	return sum / float64(len(from))
+}
+
+// tokenSimilarity is 1 for equal words. A word that is a prefix of the other
+// (receipt truncation, Czech endings) scores by how much of the longer word it
+// covers; otherwise close spellings score by edit distance.
+func tokenSimilarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	ra, rb := []rune(a), []rune(b)
+	short, long := ra, rb
+	if len(short) > len(long) {
+		short, long = long, short
+	}
+	if len(short) >= 3 && strings.HasPrefix(string(long), string(short)) {
+		return 0.7 + 0.3*float64(len(short))/float64(len(long))
+	}
+	sim := 1 - float64(levenshtein(ra, rb))/float64(len(long))
+	if sim < 0.75 {
+		return 0
+	}
+	return sim * 0.9
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,66 @@
+package ai
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchReceiptItemsFallback(t *testing.T) {
+	planned := []string{"Mléko", "jogurt", "Rohlíky", "Máslo", "Kuřecí prsa", "Banány"}
+
+	tests := []struct {
+		receipt     string
+		wantBest    string // empty: no candidate at all
+		minConf     int
+		maxConf     int
+		notExpected string
+	}{
+		{receipt: "MLEKO POLOTUC. 1L", wantBest: "Mléko", minConf: 90, maxConf: 100},
+		{receipt: "Selský jogurt 2%", wantBest: "jogurt", minConf: 90, maxConf: 100},
+		{receipt: "JGT BILY 150G", wantBest: "jogurt", minConf: 90, maxConf: 100},
+		{receipt: "KUR. PRSA CERST.", wantBest: "Kuřecí prsa", minConf: 90, maxConf: 100},
+		{receipt: "Rohlík tukový 43g", wantBest: "Rohlíky", minConf: 60, maxConf: 89},
+		{receipt: "Máslová sušenka", wantBest: "Máslo", minConf: 50, maxConf: 89},
+		{receipt: "BANANY", wantBest: "Banány", minConf: 100, maxConf: 100},
+		{receipt: "Baterie AA 4ks", wantBest: ""},
+	}
+
+	receipts := make([]string, len(tests))
+	for i, tt := range tests {
+		receipts[i] = tt.receipt
+	}
+	result := MatchReceiptItemsFallback(receipts, planned)
+	require.Len(t, result.Suggestions, len(tests))
+
+	for i, tt := range tests {
+		t.Run(tt.receipt, func(t *testing.T) {
+			sug := result.Suggestions[i]
+			assert.Equal(t, tt.receipt, sug.ReceiptItemName)
+			require.NotNil(t, sug.Matches)
+			if tt.wantBest == "" {
+				assert.Empty(t, sug.Matches)
+				return
+			}
+			require.NotEmpty(t, sug.Matches)
+			best := sug.Matches[0]
+			assert.Equal(t, tt.wantBest, best.PlannedItemName)
+			assert.GreaterOrEqual(t, best.Confidence, tt.minConf)
+			assert.LessOrEqual(t, best.Confidence, tt.maxConf)
+			assert.LessOrEqual(t, len(sug.Matches), maxLocalCandidates)
+		})
+	}
+
+	t.Run("nothing planned", func(t *testing.T) {
+		result := MatchReceiptItemsFallback([]string{"Chléb"}, nil)
+		require.Len(t, result.Suggestions, 1)
+		assert.Empty(t, result.Suggestions[0].Matches)
+	})
+}
+
+func TestMatchTokens(t *testing.T) {
+	assert.Equal(t, []string{"mleko", "polotuc"}, matchTokens("MLÉKO POLOTUČ. 1,5% 1L"))
+	assert.Equal(t, []string{"jogurt", "bily"}, matchTokens("JGT bílý 6x150g"))
+	assert.Equal(t, []string{"chleb", "kminem"}, matchTokens("Chléb s kmínem 500 g"))
+}
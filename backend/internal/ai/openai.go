@@ -0,0 +1,339 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gen2brain/go-fitz"
+	"google.golang.org/genai"
+)
+
+// defaultOpenAIBaseURL is Ollama's OpenAI-compatible endpoint; llama.cpp's
+// server, vLLM and LM Studio expose the same API under their own /v1.
+const defaultOpenAIBaseURL = "http://localhost:11434/v1"
+
+// openAITimeout bounds one completion. Local models on modest hardware can take
+// minutes over a receipt photo, so this is generous.
+const openAITimeout = 5 * time.Minute
+
+// OpenAIClient talks to any server implementing the OpenAI chat completions
+// API, typically a local LLM. Image inputs need a vision-capable model.
+type OpenAIClient struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	flyerModel string
+	httpClient *http.Client
+}
+
+// NewOpenAIClient configures the client from the environment: OPENAI_BASE_URL
+// (defaults to a local Ollama), OPENAI_API_KEY (optional for local servers),
+// OPENAI_MODEL (required) and OPENAI_FLYER_MODEL (defaults to OPENAI_MODEL).
+func NewOpenAIClient() (*OpenAIClient, error) {
+	model := os.Getenv("OPENAI_MODEL")
+	if model == "" {
+		return nil, fmt.Errorf("OPENAI_MODEL not set")
+	}
+	baseURL := os.Getenv("OPENAI_BASE_URL")
+	if baseURL == "" {
+		baseURL = defaultOpenAIBaseURL
+	}
+	baseURL = strings.TrimRight(baseURL, "/")
+
+	slog.Debug("OpenAI-compatible client initialized", "base_url", baseURL, "model", model)
+	return &OpenAIClient{
+		baseURL:    baseURL,
+		apiKey:     os.Getenv("OPENAI_API_KEY"),
+		model:      model,
+		flyerModel: ResolveModel("OPENAI_FLYER_MODEL", model),
+		httpClient: &http.Client{Timeout: openAITimeout},
+	}, nil
+}
+
+type chatContentPart struct {
+	Type     string        `json:"type"`
+	Text     string        `json:"text,omitempty"`
+	ImageURL *chatImageURL `json:"image_url,omitempty"`
+}
+
+type chatImageURL struct {
+	URL string `json:"url"`
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content any    `json:"content"` // string, or []chatContentPart with images
+}
+
+type chatRequest struct {
+	Model          string         `json:"model"`
+	Messages       []chatMessage  `json:"messages"`
+	Temperature    float64        `json:"temperature"`
+	ResponseFormat map[string]any `json:"response_format,omitempty"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// complete sends one user message (prompt plus optional images) and returns the
+// reply text. The reply is constrained to schema via response_format.
+func (c *OpenAIClient) complete(ctx context.Context, model, schemaName string, schema *genai.Schema, prompt string, images []Attachment) (string, error) {
+	var content any = prompt
+	if len(images) > 0 {
+		parts := []chatContentPart{{Type: "text", Text: prompt}}
+		for _, img := range images {
+			url := "data:" + img.ContentType + ";base64," + base64.StdEncoding.EncodeToString(img.Data)
+			parts = append(parts, chatContentPart{Type: "image_url", ImageURL: &chatImageURL{URL: url}})
+		}
+		content = parts
+	}
+
+	body, err := json.Marshal(chatRequest{
+		Model:    model,
+		Messages: []chatMessage{{Role: "user", Content: content}},
+		ResponseFormat: map[string]any{
+			"type": "json_schema",
+			"json_schema": map[string]any{
+				"name":   schemaName,
+				"schema": jsonSchema(schema),
+			},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode chat request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("chat completion request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 10<<20))
+	if err != nil {
+		return "", fmt.Errorf("failed to read chat completion response: %w", err)
+	}
+
+	var parsed chatResponse
+	decodeErr := json.Unmarshal(respBody, &parsed)
+	if resp.StatusCode != http.StatusOK {
+		if decodeErr == nil && parsed.Error != nil && parsed.Error.Message != "" {
+			return "", fmt.Errorf("chat completion failed (%d): %s", resp.StatusCode, parsed.Error.Message)
+		}
+		return "", fmt.Errorf("chat completion failed (%d): %s", resp.StatusCode, truncate(string(respBody), 500))
+	}
+	if decodeErr != nil {
+		return "", fmt.Errorf("failed to decode chat completion response: %w", decodeErr)
+	}
+	if len(parsed.Choices) == 0 || parsed.Choices[0].Message.Content == "" {
+		return "", fmt.Errorf("no choices returned")
+	}
+	return parsed.Choices[0].Message.Content, nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+// jsonSchema converts a Gemini schema to plain JSON Schema, so both providers
+// share one definition of every response shape.
+func jsonSchema(s *genai.Schema) map[string]any {
+	if s == nil {
+		return nil
+	}
+	out := map[string]any{}
+	if s.Type != "" {
+		t := strings.ToLower(string(s.Type))
+		if s.Nullable != nil && *s.Nullable {
+			out["type"] = []string{t, "null"}
+		} else {
+			out["type"] = t
+		}
+	}
+	if s.Description != "" {
+		out["description"] = s.Description
+	}
+	if len(s.Enum) > 0 {
+		out["enum"] = s.Enum
+	}
+	if s.Items != nil {
+		out["items"] = jsonSchema(s.Items)
+	}
+	if len(s.Properties) > 0 {
+		props := make(map[string]any, len(s.Properties))
+		for name, p := range s.Properties {
+			props[name] = jsonSchema(p)
+		}
+		out["properties"] = props
+	}
+	if len(s.Required) > 0 {
+		out["required"] = s.Required
+	}
+	return out
+}
+
+// imageInputs turns receipt or flyer files into images a chat model accepts,
+// which takes images, not PDFs: every page of a PDF is rendered, so the lines
+// on later pages still add up to the total.
+func imageInputs(attachments []Attachment) ([]Attachment, error) {
+	var out []Attachment
+	for _, att := range attachments {
+		if att.ContentType != "application/pdf" {
+			out = append(out, att)
+			continue
+		}
+		pages, err := renderPDF(att.Data)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, pages...)
+	}
+	return out, nil
+}
+
+func renderPDF(data []byte) ([]Attachment, error) {
+	doc, err := fitz.NewFromMemory(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pdf: %w", err)
+	}
+	defer doc.Close()
+
+	var pages []Attachment
+	for i := 0; i < doc.NumPage(); i++ {
+		img, err := doc.Image(i)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render page %d: %w", i, err)
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, fmt.Errorf("failed to encode page %d: %w", i, err)
+		}
+		pages = append(pages, Attachment{
+			Filename:    fmt.Sprintf("page_%d.png", i+1),
+			ContentType: "image/png",
+			Data:        buf.Bytes(),
+		})
+	}
+	return pages, nil
+}
+
+func (c *OpenAIClient) ParseReceipt(ctx context.Context, imagePaths []string, knownItems []string) (*ParsedReceipt, error) {
+	files, err := readReceiptFiles(imagePaths)
+	if err != nil {
+		return nil, err
+	}
+
+	images, err := imageInputs(files)
+	if err != nil {
+		return nil, err
+	}
+
+	responseText, err := c.complete(ctx, c.model, "receipt", buildReceiptSchema(), receiptImagePrompt(knownItems, len(images)), images)
+	if err != nil {
+		return nil, err
+	}
+	return decodeParsedReceipt(responseText)
+}
+
+func (c *OpenAIClient) ParseReceiptText(ctx context.Context, receiptText string, knownItems []string) (*ParsedReceipt, error) {
+	responseText, err := c.complete(ctx, c.model, "receipt", buildReceiptSchema(), receiptTextPrompt(receiptText, knownItems), nil)
+	if err != nil {
+		return nil, err
+	}
+	return decodeParsedReceipt(responseText)
+}
+
+func (c *OpenAIClient) MatchReceiptItems(ctx context.Context, receiptItems []string, plannedItems []string) (*MatchResult, error) {
+	if len(receiptItems) == 0 || len(plannedItems) == 0 {
+		return emptyMatchResult(receiptItems), nil
+	}
+
+	responseText, err := c.complete(ctx, c.model, "matches", buildMatchSchema(), matchPrompt(receiptItems, plannedItems), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var result MatchResult
+	if err := json.Unmarshal([]byte(cleanJSON(responseText)), &result); err != nil {
+		return nil, fmt.Errorf("failed to decode match response: %w, response: %s", err, responseText)
+	}
+	return &result, nil
+}
+
+func (c *OpenAIClient) ParseShoppingText(ctx context.Context, text string, categories []string) ([]ParsedShoppingItem, error) {
+	responseText, err := c.complete(ctx, c.model, "shopping_list", buildShoppingListSchema(categories), shoppingTextPrompt(text, categories), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed parsedShoppingListResponse
+	if err := json.Unmarshal([]byte(cleanJSON(responseText)), &parsed); err != nil {
+		return nil, fmt.Errorf("failed to decode shopping list response: %w, response: %s", err, responseText)
+	}
+	return parsed.Items, nil
+}
+
+func (c *OpenAIClient) SuggestItemDefaults(ctx context.Context, name string, categories []string) (SuggestedItemDefaults, error) {
+	responseText, err := c.complete(ctx, c.model, "item_defaults", buildItemDefaultsSchema(categories), itemDefaultsPrompt(name, categories), nil)
+	if err != nil {
+		return SuggestedItemDefaults{}, err
+	}
+
+	var out SuggestedItemDefaults
+	if err := json.Unmarshal([]byte(cleanJSON(responseText)), &out); err != nil {
+		return SuggestedItemDefaults{}, fmt.Errorf("failed to decode categorize response: %w, response: %s", err, responseText)
+	}
+	return out, nil
+}
+
+func (c *OpenAIClient) ParseFlyer(ctx context.Context, attachments []Attachment) (*ParsedFlyer, error) {
+	if len(attachments) == 0 {
+		return nil, fmt.Errorf("no attachments to parse")
+	}
+
+	parts := flyerParts(attachments)
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("no supported attachments (images) found for parsing")
+	}
+	images, err := imageInputs(parts)
+	if err != nil {
+		return nil, err
+	}
+
+	slog.Info("Sending flyer to OpenAI-compatible server for parsing", "attachment_count", len(attachments))
+	responseText, err := c.complete(ctx, c.flyerModel, "flyer", buildFlyerSchema(), flyerPrompt, images)
+	if err != nil {
+		return nil, err
+	}
+	return decodeParsedFlyer(responseText)
+}
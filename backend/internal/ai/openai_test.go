@@ -0,0 +1,257 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeChatServer answers every completion with reply and records the last request.
+func fakeChatServer(t *testing.T, status int, reply string) (*httptest.Server, *map[string]any) {
+	t.Helper()
+	var last map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/chat/completions", r.URL.Path)
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&last))
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		if status != http.StatusOK {
+			_, _ = w.Write([]byte(`{"error":{"message":"model not found"}}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"choices": []any{map[string]any{"message": map[string]any{"role": "assistant", "content": reply}}},
+		})
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &last
+}
+
+func newTestOpenAIClient(t *testing.T, baseURL string) *OpenAIClient {
+	t.Helper()
+	t.Setenv("OPENAI_BASE_URL", baseURL+"/v1/")
+	t.Setenv("OPENAI_API_KEY", "secret")
+	t.Setenv("OPENAI_MODEL", "local-model")
+	t.Setenv("OPENAI_FLYER_MODEL", "")
+	c, err := NewOpenAIClient()
+	require.NoError(t, err)
+	return c
+}
+
+func TestOpenAIClient(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("receipt text with schema", func(t *testing.T) {
+		srv, last := fakeChatServer(t, http.StatusOK,
+			"```json\n{\"store_name\":\"Lidl\",\"date\":\"2026-03-01\",\"total\":42,\"items\":[{\"name\":\"Rohlík\",\"quantity\":10,\"price\":4.2,\"total_price\":42}]}\n```")
+		c := newTestOpenAIClient(t, srv.URL)
+
+		parsed, err := c.ParseReceiptText(ctx, "\xef\xbb\xbfLIDL\r\nRohlík 10x4,20", []string{"rohlík"})
+		require.NoError(t, err)
+		assert.Equal(t, "Lidl", parsed.StoreName)
+		require.Len(t, parsed.Items, 1)
+		assert.Equal(t, 42.0, parsed.Items[0].TotalPrice)
+
+		req := *last
+		assert.Equal(t, "local-model", req["model"])
+		msg := req["messages"].([]any)[0].(map[string]any)
+		assert.Contains(t, msg["content"], "LIDL\nRohlík")
+		format := req["response_format"].(map[string]any)
+		assert.Equal(t, "json_schema", format["type"])
+		schema := format["json_schema"].(map[string]any)["schema"].(map[string]any)
+		assert.Equal(t, "object", schema["type"])
+		assert.ElementsMatch(t, []any{"store_name", "date", "total", "items"}, schema["required"])
+	})
+
+	t.Run("receipt image is sent as a data URL", func(t *testing.T) {
+		srv, last := fakeChatServer(t, http.StatusOK, `{"store_name":"Albert","date":"2026-03-02","total":10,"items":[]}`)
+		c := newTestOpenAIClient(t, srv.URL)
+
+		path := filepath.Join(t.TempDir(), "receipt.png")
+		require.NoError(t, os.WriteFile(path, []byte("\x89PNG\r\n\x1a\nfake"), 0o600))
+
+		parsed, err := c.ParseReceipt(ctx, []string{path}, nil)
+		require.NoError(t, err)
+		assert.Equal(t, "Albert", parsed.StoreName)
+
+		parts := (*last)["messages"].([]any)[0].(map[string]any)["content"].([]any)
+		require.Len(t, parts, 2)
+		assert.Equal(t, "text", parts[0].(map[string]any)["type"])
+		url := parts[1].(map[string]any)["image_url"].(map[string]any)["url"].(string)
+		assert.True(t, strings.HasPrefix(url, "data:image/png;base64,"), url)
+	})
+
+	t.Run("receipt photos go out in one request", func(t *testing.T) {
+		srv, last := fakeChatServer(t, http.StatusOK, `{"store_name":"Albert","date":"2026-03-02","total":10,"items":[]}`)
+		c := newTestOpenAIClient(t, srv.URL)
+
+		var paths []string
+		for _, name := range []string{"top.png", "bottom.png"} {
+			path := filepath.Join(t.TempDir(), name)
+			require.NoError(t, os.WriteFile(path, []byte("\x89PNG\r\n\x1a\nfake"), 0o600))
+			paths = append(paths, path)
+		}
+
+		_, err := c.ParseReceipt(ctx, paths, nil)
+		require.NoError(t, err)
+
+		parts := (*last)["messages"].([]any)[0].(map[string]any)["content"].([]any)
+		require.Len(t, parts, 3)
+		assert.Contains(t, parts[0].(map[string]any)["text"], "photographed in 2 parts")
+	})
+
+	t.Run("shopping text keeps the category enum", func(t *testing.T) {
+		srv, last := fakeChatServer(t, http.StatusOK, `{"items":[{"name":"mléko","quantity":2,"unit":"l","category":"Mléčné"}]}`)
+		c := newTestOpenAIClient(t, srv.URL)
+
+		items, err := c.ParseShoppingText(ctx, "mléko 2l", []string{"Mléčné", "Pečivo"})
+		require.NoError(t, err)
+		assert.Equal(t, []ParsedShoppingItem{{Name: "mléko", Quantity: 2, Unit: "l", Category: "Mléčné"}}, items)
+
+		schema := (*last)["response_format"].(map[string]any)["json_schema"].(map[string]any)["schema"].(map[string]any)
+		item := schema["properties"].(map[string]any)["items"].(map[string]any)["items"].(map[string]any)
+		category := item["properties"].(map[string]any)["category"].(map[string]any)
+		assert.Equal(t, []any{"Mléčné", "Pečivo"}, category["enum"])
+	})
+
+	t.Run("matching with nothing planned skips the call", func(t *testing.T) {
+		c := newTestOpenAIClient(t, "http://127.0.0.1:0")
+		result, err := c.MatchReceiptItems(ctx, []string{"Rohlík"}, nil)
+		require.NoError(t, err)
+		require.Len(t, result.Suggestions, 1)
+		assert.Empty(t, result.Suggestions[0].Matches)
+	})
+
+	t.Run("flyer uses the flyer model and a nullable original price", func(t *testing.T) {
+		srv, last := fakeChatServer(t, http.StatusOK,
+			`{"start_date":"2026-03-01","end_date":"2026-03-07","items":[{"name":"Pivo","price":19.9,"original_price":null,"quantity":"pcs","start_date":"2026-03-01","end_date":"2026-03-07","bounding_box":[0,0,10,10],"categories":["drinks"],"keywords":["beer"]}]}`)
+		c := newTestOpenAIClient(t, srv.URL)
+		c.flyerModel = "vision-model"
+
+		parsed, err := c.ParseFlyer(ctx, []Attachment{{Filename: "p1.jpg", ContentType: "image/jpeg", Data: []byte("jpeg")}})
+		require.NoError(t, err)
+		require.Len(t, parsed.Items, 1)
+		assert.Nil(t, parsed.Items[0].OriginalPrice)
+		assert.Equal(t, "vision-model", (*last)["model"])
+
+		schema := (*last)["response_format"].(map[string]any)["json_schema"].(map[string]any)["schema"].(map[string]any)
+		item := schema["properties"].(map[string]any)["items"].(map[string]any)["items"].(map[string]any)
+		original := item["properties"].(map[string]any)["original_price"].(map[string]any)
+		assert.Equal(t, []any{"number", "null"}, original["type"])
+	})
+
+	t.Run("server error is surfaced", func(t *testing.T) {
+		srv, _ := fakeChatServer(t, http.StatusNotFound, "")
+		c := newTestOpenAIClient(t, srv.URL)
+
+		_, err := c.SuggestItemDefaults(ctx, "mléko", nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "model not found")
+	})
+}
+
+func TestNewProvider(t *testing.T) {
+	ctx := context.Background()
+	unset := func(t *testing.T) {
+		for _, k := range []string{"AI_PROVIDER", "GEMINI_API_KEY", "OPENAI_BASE_URL", "OPENAI_MODEL"} {
+			t.Setenv(k, "")
+		}
+	}
+
+	t.Run("nothing configured", func(t *testing.T) {
+		unset(t)
+		_, err := NewProvider(ctx)
+		assert.ErrorIs(t, err, ErrNoProvider)
+	})
+
+	t.Run("openai inferred from base URL", func(t *testing.T) {
+		unset(t)
+		t.Setenv("OPENAI_BASE_URL", "http://llm.local:8080/v1")
+		t.Setenv("OPENAI_MODEL", "qwen")
+		p, err := NewProvider(ctx)
+		require.NoError(t, err)
+		require.IsType(t, &OpenAIClient{}, p)
+		assert.Equal(t, "http://llm.local:8080/v1", p.(*OpenAIClient).baseURL)
+	})
+
+	t.Run("explicit openai defaults to local Ollama", func(t *testing.T) {
+		unset(t)
+		t.Setenv("AI_PROVIDER", "OpenAI")
+		t.Setenv("GEMINI_API_KEY", "ignored")
+		t.Setenv("OPENAI_MODEL", "qwen")
+		p, err := NewProvider(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, defaultOpenAIBaseURL, p.(*OpenAIClient).baseURL)
+	})
+
+	t.Run("openai needs a model", func(t *testing.T) {
+		unset(t)
+		t.Setenv("AI_PROVIDER", "openai")
+		_, err := NewProvider(ctx)
+		assert.Error(t, err)
+	})
+
+	t.Run("unknown provider", func(t *testing.T) {
+		unset(t)
+		t.Setenv("AI_PROVIDER", "mystery")
+		_, err := NewProvider(ctx)
+		assert.ErrorContains(t, err, "unknown AI_PROVIDER")
+	})
+
+	t.Run("explicit provider and model override the environment", func(t *testing.T) {
+		unset(t)
+		t.Setenv("AI_PROVIDER", "gemini")
+		t.Setenv("OPENAI_MODEL", "qwen")
+		p, err := NewProviderWith(ctx, "openai", "qwen2.5vl:32b")
+		require.NoError(t, err)
+		client := p.(*OpenAIClient)
+		assert.Equal(t, "qwen2.5vl:32b", client.model)
+		assert.Equal(t, "qwen", client.flyerModel, "only receipts use the override")
+	})
+}
+
+// blankPDF builds a PDF of blank pages.
+func blankPDF(pages int) []byte {
+	objects := []string{"<< /Type /Catalog /Pages 2 0 R >>", ""}
+	kids := ""
+	for i := 0; i < pages; i++ {
+		kids += fmt.Sprintf("%d 0 R ", 3+i)
+		objects = append(objects, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 20 20] >>")
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids, pages)
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+func TestImageInputs_RendersEveryPDFPage(t *testing.T) {
+	images, err := imageInputs([]Attachment{{Filename: "receipt.pdf", ContentType: "application/pdf", Data: blankPDF(6)}})
+	require.NoError(t, err)
+	require.Len(t, images, 6, "a long receipt's last pages are not dropped")
+	assert.Equal(t, "page_6.png", images[5].Filename)
+	assert.Equal(t, "image/png", images[5].ContentType)
+}
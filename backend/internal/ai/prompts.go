@@ -0,0 +1,143 @@
+package ai
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Prompts shared by every provider. Only the transport and the way the response
+// schema is attached differ between backends.
+
+// categoryPromptSuffix tells the model how to use the category enum, when there is one.
+func categoryPromptSuffix(categories []string) string {
+	if len(categories) == 0 {
+		return ""
+	}
+	return "\n- Assign each item the best-fitting category from this list, using the name exactly as written: " +
+		strings.Join(categories, ", ") +
+		"\n- These are the user's own categories and may be in any language; do not translate them" +
+		"\n- If no category clearly fits an item, omit the category field for that item rather than guessing"
+}
+
+func shoppingTextPrompt(text string, categories []string) string {
+	return `You are a shopping list parser. Parse the following shopping list text into structured items.
+
+Rules:
+- Items are separated by commas or newlines
+- Quantity can appear before the item name ("2 йогурта") or after ("минералка 5")
+- If a quantity looks like "X+Y" (e.g. "кефир 4+2"), this is a retail promotion — split into TWO separate items with the same name: one with quantity X and one with quantity Y
+- Normalize item names to nominative case (e.g. "йогурта" → "йогурт", "творога" → "творог")
+- Return short generic names without quantity or unit in the name
+- Infer unit from context: кг/kg → "kg"; г/g → "g"; мл/ml → "ml"; л/l → "l"; otherwise → "pcs"
+- If no quantity is specified, assume 1` + categoryPromptSuffix(categories) + `
+
+Shopping list:
+` + text
+}
+
+func itemDefaultsPrompt(name string, categories []string) string {
+	return "What unit is the grocery item \"" + name + "\" normally bought in?" +
+		categoryPromptSuffix(categories) +
+		"\n\nAnswer for this one item only."
+}
+
+func receiptImagePrompt(knownItems []string, images int) string {
+	return fmt.Sprintf(`
+You are a receipt parser. Parse the following receipt image.%s
+Extract the store name, date (YYYY-MM-DD), total amount, and all items.
+For each item, extract the name, quantity, unit, price per unit, and total price.
+If the unit is not explicitly stated but can be inferred (e.g. kg, pieces), use pieces as default or infer from context.
+The context list of known items is: %s. Use this to help match naming conventions, but priority is what's on receipt.
+
+Price rules:
+- Always use the price the customer actually pays — including all taxes (VAT/DPH). Never use pre-tax prices.
+- If a product is sold as a multi-pack (e.g. "6×150g", "3-pack", "4+2", "10ks"), treat the whole pack as 1 unit: set quantity=1 and price=total_price for that line. Do not split packs into individual pieces.
+
+Return strict JSON.
+`, receiptPartsNote(images), strings.Join(knownItems, ", "))
+}
+
+// receiptPartsNote explains a receipt photographed in several parts. The page
+// numbers it asks for let the caller drop lines the model still repeats where
+// the photos overlap.
+func receiptPartsNote(images int) string {
+	if images < 2 {
+		return ""
+	}
+	return fmt.Sprintf(`
+The receipt was photographed in %d parts, given in order from top to bottom; together they are ONE receipt.
+Consecutive parts usually overlap: a line at the bottom of one part that appears again at the top of the next is the same line — list it once.
+For each item, set page to the number of the part (1-based) it was read from.`, images)
+}
+
+// normalizeReceiptText strips a UTF-8 BOM, unifies line endings and trims
+// outer whitespace.
+func normalizeReceiptText(receiptText string) string {
+	receiptText = strings.TrimPrefix(receiptText, "\xef\xbb\xbf")
+	receiptText = strings.ReplaceAll(receiptText, "\r\n", "\n")
+	return strings.TrimSpace(receiptText)
+}
+
+func receiptTextPrompt(receiptText string, knownItems []string) string {
+	return fmt.Sprintf(`
+You are a receipt parser. Parse the following receipt text.
+Extract the store name, date (YYYY-MM-DD), total amount, and all items.
+For each item, extract the name, quantity, unit, price per unit, and total price.
+If the unit is not explicitly stated but can be inferred (e.g. kg, pieces), use pieces as default or infer from context.
+Note: prices may use European decimal notation (comma as decimal separator, e.g. "1,99" means 1.99).
+The context list of known items is: %s. Use this to help match naming conventions, but priority is what's on the receipt.
+
+Price rules:
+- Always use the price the customer actually pays — including all taxes (VAT/DPH). Never use pre-tax prices.
+- If a product is sold as a multi-pack (e.g. "6×150g", "3-pack", "4+2", "10ks"), treat the whole pack as 1 unit: set quantity=1 and price=total_price for that line. Do not split packs into individual pieces.
+
+Receipt text:
+---
+%s
+---
+
+Return strict JSON.
+`, strings.Join(knownItems, ", "), normalizeReceiptText(receiptText))
+}
+
+func matchPrompt(receiptItems, plannedItems []string) string {
+	return fmt.Sprintf(`You are a shopping item matcher. Given receipt items and a planned shopping list, determine which receipt items correspond to which planned items.
+
+Rules:
+- A receipt item may match 0 or 1 planned items
+- A planned item may match 0 or 1 receipt items
+- Return confidence as integer percentage (0-100)
+- Consider that planned items are often short/generic (e.g. "jogurt") while receipt items are specific (e.g. "selský jogurt 2%%")
+- Items in different languages or with brand names can still match
+- If no good match exists, return empty matches array
+- You MUST return exactly one suggestion entry per receipt item, even if matches is empty
+
+Receipt items: %s
+Planned items: %s`,
+		strings.Join(receiptItems, ", "),
+		strings.Join(plannedItems, ", "),
+	)
+}
+
+// emptyMatchResult is the answer when there is nothing to match against: one
+// suggestion per receipt item, each without matches. No model call needed.
+func emptyMatchResult(receiptItems []string) *MatchResult {
+	suggestions := make([]MatchSuggestion, len(receiptItems))
+	for i, name := range receiptItems {
+		suggestions[i] = MatchSuggestion{ReceiptItemName: name, Matches: []MatchCandidate{}}
+	}
+	return &MatchResult{Suggestions: suggestions}
+}
+
+// cleanJSON strips the markdown code fences models sometimes wrap JSON in.
+func cleanJSON(s string) string {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "```json") {
+		s = strings.TrimPrefix(s, "```json")
+		s = strings.TrimSuffix(s, "```")
+	} else if strings.HasPrefix(s, "```") {
+		s = strings.TrimPrefix(s, "```")
+		s = strings.TrimSuffix(s, "```")
+	}
+	return strings.TrimSpace(s)
+}
@@ -1,11 +1,10 @@
-package flyers
+package ai
 
 import (
 	"testing"
 )
 
 func TestCleanJSON(t *testing.T) {
-	p := &Parser{}
 	tests := []struct {
 		name     string
 		input    string
@@ -35,7 +34,7 @@ func TestCleanJSON(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := p.cleanJSON(tt.input)
+			result := cleanJSON(tt.input)
 			if result != tt.expected {
 				t.Errorf("cleanJSON() = %v, want %v", result, tt.expected)
 			}
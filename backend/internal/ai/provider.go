@@ -0,0 +1,133 @@
+package ai
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Provider is everything the app asks of a model backend. Pick one with
+// NewProvider; the rest of the code should not care which it gets.
+type Provider interface {
+	ParseReceipt(ctx context.Context, imagePaths []string, knownItems []string) (*ParsedReceipt, error)
+	ParseReceiptText(ctx context.Context, receiptText string, knownItems []string) (*ParsedReceipt, error)
+	MatchReceiptItems(ctx context.Context, receiptItems []string, plannedItems []string) (*MatchResult, error)
+	ParseShoppingText(ctx context.Context, text string, categories []string) ([]ParsedShoppingItem, error)
+	SuggestItemDefaults(ctx context.Context, name string, categories []string) (SuggestedItemDefaults, error)
+	ParseFlyer(ctx context.Context, attachments []Attachment) (*ParsedFlyer, error)
+}
+
+// Values of AI_PROVIDER.
+const (
+	ProviderGemini = "gemini"
+	ProviderOpenAI = "openai"
+)
+
+// ErrNoProvider means no AI backend is configured. Callers fall back to their
+// non-AI paths (or leave work queued) exactly as before providers existed.
+var ErrNoProvider = errors.New("no AI provider configured (set GEMINI_API_KEY, or AI_PROVIDER=openai)")
+
+// NewProvider returns the backend selected by AI_PROVIDER. When unset, Gemini
+// is used if GEMINI_API_KEY is set, else an OpenAI-compatible server if
+// OPENAI_BASE_URL is set, else ErrNoProvider.
+func NewProvider(ctx context.Context) (Provider, error) {
+	return NewProviderWith(ctx, os.Getenv("AI_PROVIDER"), "")
+}
+
+// NewProviderWith is NewProvider for an explicit backend name and receipt
+// model, e.g. to reprocess a receipt the configured model misread. An empty
+// name picks the backend as NewProvider does; an empty model keeps the
+// configured one. Credentials still come from the environment.
+func NewProviderWith(ctx context.Context, name, model string) (Provider, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	model = strings.TrimSpace(model)
+	if name == "" {
+		switch {
+		case os.Getenv("GEMINI_API_KEY") != "":
+			name = ProviderGemini
+		case os.Getenv("OPENAI_BASE_URL") != "":
+			name = ProviderOpenAI
+		default:
+			return nil, ErrNoProvider
+		}
+	}
+
+	var (
+		p   Provider
+		err error
+	)
+	switch name {
+	case ProviderGemini:
+		var c *GeminiClient
+		if c, err = NewGeminiClient(ctx); err == nil && model != "" {
+			c.model = model
+		}
+		p = c
+	case ProviderOpenAI:
+		var c *OpenAIClient
+		if c, err = NewOpenAIClient(); err == nil && model != "" {
+			c.model = model
+		}
+		p = c
+	default:
+		return nil, fmt.Errorf("unknown AI_PROVIDER %q (want %q or %q)", name, ProviderGemini, ProviderOpenAI)
+	}
+	if err != nil {
+		return nil, err
+	}
+	slog.Debug("AI provider selected", "provider", name, "model", model)
+	return p, nil
+}
+
+type ParsedReceipt struct {
+	StoreName string              `json:"store_name"`
+	Date      string              `json:"date"`
+	Total     float64             `json:"total"`
+	Items     []ParsedReceiptItem `json:"items"`
+}
+
+type ParsedReceiptItem struct {
+	Name       string  `json:"name"`
+	Quantity   float64 `json:"quantity"`
+	Unit       string  `json:"unit"`
+	Price      float64 `json:"price"`
+	TotalPrice float64 `json:"total_price"`
+	Page       int     `json:"page,omitempty"` // 1-based photo or PDF page the line was read from
+}
+
+type MatchResult struct {
+	Suggestions []MatchSuggestion `json:"suggestions"`
+}
+
+type MatchSuggestion struct {
+	ReceiptItemName string           `json:"receipt_item_name"`
+	Matches         []MatchCandidate `json:"matches"`
+}
+
+type MatchCandidate struct {
+	PlannedItemName string `json:"planned_item_name"`
+	Confidence      int    `json:"confidence"` // 0-100
+}
+
+type ParsedShoppingItem struct {
+	Name     string  `json:"name"`
+	Quantity float64 `json:"quantity"`
+	Unit     string  `json:"unit"`
+	// Category is one of the family's own category names, or empty when the model
+	// had no confident pick. Never a name the family does not already have — the
+	// schema constrains it to an enum of their categories.
+	Category string `json:"category,omitempty"`
+}
+
+type parsedShoppingListResponse struct {
+	Items []ParsedShoppingItem `json:"items"`
+}
+
+// SuggestedItemDefaults is a common-sense guess for an item with no purchase history.
+type SuggestedItemDefaults struct {
+	Unit     string `json:"unit"`
+	Category string `json:"category,omitempty"`
+}
@@ -0,0 +1,60 @@
+package ai
+
+import (
+	"math"
+	"strings"
+
+	"kincart/internal/utils"
+)
+
+// DropOverlappingLines removes lines repeated where consecutive photos of a
+// long receipt overlap. Photos are taken top to bottom, so an overlap is the
+// longest run of lines that ends one page and starts the next; only that run
+// is dropped, and only from the later page. Two identical lines elsewhere (the
+// same roll scanned twice) are real purchases and stay.
+//
+// Items must carry Page; without it there is nothing to go by and the items
+// are returned as they are.
+func DropOverlappingLines(items []ParsedReceiptItem) []ParsedReceiptItem {
+	var pages [][]ParsedReceiptItem
+	for _, item := range items {
+		if item.Page < 1 {
+			return items
+		}
+		if len(pages) == 0 || pages[len(pages)-1][0].Page != item.Page {
+			pages = append(pages, nil)
+		}
+		pages[len(pages)-1] = append(pages[len(pages)-1], item)
+	}
+	if len(pages) < 2 {
+		return items
+	}
+
+	out := append([]ParsedReceiptItem(nil), pages[0]...)
+	for i, page := range pages[1:] {
+		out = append(out, page[overlapLen(pages[i], page):]...)
+	}
+	return out
+}
+
+// overlapLen is the length of the longest run of lines ending prev that also starts next.
+func overlapLen(prev, next []ParsedReceiptItem) int {
+	for n := min(len(prev), len(next)); n > 0; n-- {
+		same := true
+		for i := 0; i < n; i++ {
+			if !sameReceiptLine(prev[len(prev)-n+i], next[i]) {
+				same = false
+				break
+			}
+		}
+		if same {
+			return n
+		}
+	}
+	return 0
+}
+
+func sameReceiptLine(a, b ParsedReceiptItem) bool {
+	return utils.NormalizeSearchText(strings.TrimSpace(a.Name)) == utils.NormalizeSearchText(strings.TrimSpace(b.Name)) &&
+		math.Abs(a.TotalPrice-b.TotalPrice) < 0.005
+}
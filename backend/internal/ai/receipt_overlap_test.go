@@ -0,0 +1,64 @@
+package ai
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDropOverlappingLines(t *testing.T) {
+	line := func(page int, name string, total float64) ParsedReceiptItem {
+		return ParsedReceiptItem{Name: name, Quantity: 1, Price: total, TotalPrice: total, Page: page}
+	}
+	names := func(items []ParsedReceiptItem) []string {
+		out := make([]string, len(items))
+		for i, it := range items {
+			out[i] = it.Name
+		}
+		return out
+	}
+
+	tests := []struct {
+		name  string
+		items []ParsedReceiptItem
+		want  []string
+	}{
+		{
+			name: "overlap between pages is dropped once",
+			items: []ParsedReceiptItem{
+				line(1, "Mléko", 24.9), line(1, "Rohlík", 3.9), line(1, "Máslo", 59.9),
+				line(2, "Rohlík", 3.9), line(2, "MASLO", 59.9), line(2, "Jogurt", 12.9),
+				line(3, "Jogurt", 12.9), line(3, "Banány", 32.5),
+			},
+			want: []string{"Mléko", "Rohlík", "Máslo", "Jogurt", "Banány"},
+		},
+		{
+			name: "repeated purchase within a page stays",
+			items: []ParsedReceiptItem{
+				line(1, "Rohlík", 3.9), line(1, "Rohlík", 3.9),
+				line(2, "Mléko", 24.9),
+			},
+			want: []string{"Rohlík", "Rohlík", "Mléko"},
+		},
+		{
+			name: "same name at another price is not an overlap",
+			items: []ParsedReceiptItem{
+				line(1, "Banány", 32.5),
+				line(2, "Banány", 28.1),
+			},
+			want: []string{"Banány", "Banány"},
+		},
+		{
+			name: "lines without pages are left alone",
+			items: []ParsedReceiptItem{
+				line(0, "Rohlík", 3.9), line(0, "Rohlík", 3.9),
+			},
+			want: []string{"Rohlík", "Rohlík"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, names(DropOverlappingLines(tt.items)))
+		})
+	}
+}
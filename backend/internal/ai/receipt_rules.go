@@ -0,0 +1,247 @@
+package ai
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Czech e-receipts print one item per line with the amount paid at the end,
+// optionally followed by a VAT letter. A quantity is printed either inline
+// ("ROHLIK 10 ks x 2,90  29,00 A") or on its own line below or above the
+// amount ("  1,234 kg x 29,90 Kč/kg"), in price-x-quantity order at some shops.
+const (
+	amountExpr = `(-?\d+[.,]\d{2})(?:\s*Kč)?`
+	vatExpr    = `(?:\s+[A-DZ*]{1,2})?`
+	numUnit    = `(\d+(?:[.,]\d{1,3})?)\s*(ks|kg|g|l)?`
+	qtyExpr    = numUnit + `\s*[x×*]\s*` + numUnit + `(?:\s*Kč)?(?:\s*/\s*(ks|kg|l))?`
+)
+
+var (
+	reRuleAmountLine = regexp.MustCompile(`^(.+?)\s+` + amountExpr + vatExpr + `$`)
+	reRuleInlineQty  = regexp.MustCompile(`^(.+?)\s+` + qtyExpr + `\s+` + amountExpr + vatExpr + `$`)
+	reRuleQtyLine    = regexp.MustCompile(`^` + qtyExpr + `(?:\s+` + amountExpr + vatExpr + `)?$`)
+	reRuleTotal      = regexp.MustCompile(`(?i)^(?:celkem(?:\s+k\s+úhradě)?|k\s+platbě|k\s+úhradě|celková\s+částka|suma)(?:\s+kč)?\s*:?\s+(-?\d{1,3}(?:[ \x{00a0}]\d{3})*[.,]\d{2}|-?\d+[.,]\d{2})(?:\s*kč)?$`)
+	reRuleSkip       = regexp.MustCompile(`(?i)^(?:mezisoučet|mezisoucet|počet\s+položek|pocet\s+polozek)\b`)
+	reRuleDiscount   = regexp.MustCompile(`(?i)sleva|zlevn|kupón|kupon|akce`)
+	reRuleRounding   = regexp.MustCompile(`(?i)zaokrouhl`)
+	reRuleDateCZ     = regexp.MustCompile(`\b(\d{1,2})\.\s?(\d{1,2})\.\s?(\d{4})\b`)
+	reRuleDateISO    = regexp.MustCompile(`\b(\d{4})-(\d{2})-(\d{2})\b`)
+	reRuleSpaces     = regexp.MustCompile(`\s+`)
+)
+
+// ruleRetailers are the shops whose receipts the rules understand, matched
+// against the receipt header.
+var ruleRetailers = []struct {
+	re   *regexp.Regexp
+	name string
+}{
+	{regexp.MustCompile(`(?i)\blidl\b`), "Lidl"},
+	{regexp.MustCompile(`(?i)\balbert\b`), "Albert"},
+	{regexp.MustCompile(`(?i)\bbilla\b`), "Billa"},
+	{regexp.MustCompile(`(?i)\bkaufland\b`), "Kaufland"},
+	{regexp.MustCompile(`(?i)\bpenny\b`), "Penny"},
+	{regexp.MustCompile(`(?i)\btesco\b`), "Tesco"},
+	{regexp.MustCompile(`(?i)\bglobus\b`), "Globus"},
+}
+
+// ruleTotalTolerance absorbs cash rounding to whole crowns when the receipt
+// does not print a rounding line.
+const ruleTotalTolerance = 0.5
+
+var ruleUnits = map[string]string{"ks": "pcs", "kg": "kg", "g": "g", "l": "l"}
+
+// ParseReceiptTextRules parses a pasted e-receipt from a known Czech retailer
+// without AI. It only accepts a receipt it fully understands — a known store, a
+// date, a total line, and items adding up to that total — and returns false
+// otherwise, so the caller can fall back to ParseReceiptText.
+func ParseReceiptTextRules(text string) (*ParsedReceipt, bool) {
+	text = normalizeReceiptText(text)
+
+	var (
+		items       []ParsedReceiptItem
+		header      []string
+		pending     string // a name line waiting for its quantity line
+		adjustments float64
+		total       float64
+		foundTotal  bool
+	)
+
+	for _, raw := range strings.Split(text, "\n") {
+		line := strings.TrimSpace(reRuleSpaces.ReplaceAllString(raw, " "))
+		if line == "" {
+			continue
+		}
+		if m := reRuleTotal.FindStringSubmatch(line); m != nil {
+			total, foundTotal = parseCzechAmount(m[1]), true
+			break // payment and VAT recap follow; none of it is items
+		}
+		if reRuleSkip.MatchString(line) {
+			continue
+		}
+
+		if m := reRuleQtyLine.FindStringSubmatch(line); m != nil {
+			qty, unit, price := ruleQuantity(m[1], m[2], m[3], m[4], m[5])
+			switch {
+			case pending != "" && m[6] != "":
+				item, ok := ruleItem(pending, qty, unit, price, parseCzechAmount(m[6]))
+				if !ok {
+					return nil, false
+				}
+				items = append(items, item)
+				pending = ""
+			case len(items) > 0 && m[6] == "":
+				last := &items[len(items)-1]
+				if !amountsMatch(qty*price, last.TotalPrice) {
+					return nil, false
+				}
+				last.Quantity, last.Unit, last.Price = qty, unit, price
+			default:
+				return nil, false
+			}
+			continue
+		}
+
+		if m := reRuleInlineQty.FindStringSubmatch(line); m != nil {
+			qty, unit, price := ruleQuantity(m[2], m[3], m[4], m[5], m[6])
+			item, ok := ruleItem(m[1], qty, unit, price, parseCzechAmount(m[7]))
+			if !ok {
+				return nil, false
+			}
+			items = append(items, item)
+			pending = ""
+			continue
+		}
+
+		if m := reRuleAmountLine.FindStringSubmatch(line); m != nil {
+			name, amount := m[1], parseCzechAmount(m[2])
+			switch {
+			case reRuleRounding.MatchString(name):
+				adjustments += amount
+			case amount < 0 && reRuleDiscount.MatchString(name) && len(items) > 0:
+				// Discounts belong to the item above: record what was actually paid.
+				last := &items[len(items)-1]
+				last.TotalPrice = round2(last.TotalPrice + amount)
+				last.Price = round2(last.TotalPrice / last.Quantity)
+			case amount < 0:
+				// Returned bottles and the like: they lower the total but are not purchases.
+				adjustments += amount
+			default:
+				items = append(items, ParsedReceiptItem{Name: name, Quantity: 1, Unit: "pcs", Price: amount, TotalPrice: amount})
+			}
+			pending = ""
+			continue
+		}
+
+		if len(items) == 0 {
+			header = append(header, line)
+		}
+		pending = line
+	}
+
+	if !foundTotal || len(items) == 0 {
+		return nil, false
+	}
+
+	store := ""
+	for _, r := range ruleRetailers {
+		if r.re.MatchString(strings.Join(header, "\n")) {
+			store = r.name
+			break
+		}
+	}
+	date := ruleDate(text)
+	if store == "" || date == "" {
+		return nil, false
+	}
+
+	sum := adjustments
+	for _, item := range items {
+		sum += item.TotalPrice
+	}
+	if math.Abs(sum-total) > ruleTotalTolerance {
+		return nil, false
+	}
+
+	return &ParsedReceipt{StoreName: store, Date: date, Total: total, Items: items}, true
+}
+
+// ruleQuantity tells quantity from unit price in "a x b". A unit next to a
+// number marks the quantity, as does "Kč/kg" after the price; otherwise the
+// number with exactly two decimals is the price.
+func ruleQuantity(a, unitA, b, unitB, per string) (qty float64, unit string, price float64) {
+	qtyStr, priceStr := a, b
+	switch {
+	case unitA != "":
+		unit = unitA
+	case unitB != "":
+		qtyStr, priceStr, unit = b, a, unitB
+	case per != "":
+		unit = per
+	case decimals(a) == 2 && decimals(b) != 2:
+		qtyStr, priceStr = b, a
+	}
+
+	qty = parseCzechAmount(qtyStr)
+	price = parseCzechAmount(priceStr)
+	if unit == "" && decimals(qtyStr) == 3 {
+		unit = "kg"
+	}
+	if mapped, ok := ruleUnits[unit]; ok {
+		unit = mapped
+	} else {
+		unit = "pcs"
+	}
+	return qty, unit, price
+}
+
+func ruleItem(name string, qty float64, unit string, price, total float64) (ParsedReceiptItem, bool) {
+	if qty <= 0 || !amountsMatch(qty*price, total) {
+		return ParsedReceiptItem{}, false
+	}
+	return ParsedReceiptItem{Name: strings.TrimSpace(name), Quantity: qty, Unit: unit, Price: price, TotalPrice: total}, true
+}
+
+// amountsMatch compares a computed line amount with the printed one; weighed
+// goods are rounded to the haléř, so a small slack is needed.
+func amountsMatch(computed, printed float64) bool {
+	return math.Abs(computed-printed) <= 0.05
+}
+
+func ruleDate(text string) string {
+	for _, m := range reRuleDateCZ.FindAllStringSubmatch(text, -1) {
+		day, _ := strconv.Atoi(m[1])
+		month, _ := strconv.Atoi(m[2])
+		year, _ := strconv.Atoi(m[3])
+		d := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+		if d.Day() == day && int(d.Month()) == month {
+			return d.Format("2006-01-02")
+		}
+	}
+	for _, m := range reRuleDateISO.FindAllString(text, -1) {
+		if _, err := time.Parse("2006-01-02", m); err == nil {
+			return m
+		}
+	}
+	return ""
+}
+
+// parseCzechAmount parses "1 234,50" or "12.90".
+func parseCzechAmount(s string) float64 {
+	s = strings.NewReplacer(" ", "", " ", "", ",", ".").Replace(s)
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
+
+func decimals(s string) int {
+	if i := strings.IndexAny(s, ".,"); i >= 0 {
+		return len(s) - i - 1
+	}
+	return 0
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
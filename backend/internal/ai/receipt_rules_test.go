@@ -0,0 +1,111 @@
+package ai
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReceiptTextRules(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want *ParsedReceipt
+	}{
+		{
+			name: "lidl with weighed item and discount",
+			text: `Lidl Česká republika v.o.s.
+Nárožní 1359/11, Praha 5
+DIČ: CZ26178541
+Rohlík tukový          4,20 x 10     42,00 A
+Mléko polotučné 1,5%              21,90 A
+Banány
+  1,234 kg x 29,90 Kč/kg          36,90 A
+Máslo 250g                        54,90 A
+Lidl Plus sleva                  -10,00
+K PLATBĚ                         145,70
+Karta                            145,70
+30.01.2026 18:42`,
+			want: &ParsedReceipt{StoreName: "Lidl", Date: "2026-01-30", Total: 145.70, Items: []ParsedReceiptItem{
+				{Name: "Rohlík tukový", Quantity: 10, Unit: "pcs", Price: 4.2, TotalPrice: 42},
+				{Name: "Mléko polotučné 1,5%", Quantity: 1, Unit: "pcs", Price: 21.9, TotalPrice: 21.9},
+				{Name: "Banány", Quantity: 1.234, Unit: "kg", Price: 29.9, TotalPrice: 36.9},
+				{Name: "Máslo 250g", Quantity: 1, Unit: "pcs", Price: 44.9, TotalPrice: 44.9},
+			}},
+		},
+		{
+			name: "albert with inline quantities and cash rounding",
+			text: `Albert Česká republika, s.r.o.
+Radlická 117, Praha 5
+Datum: 2.3.2026
+ROHLIK TUKOVY 43G        10 ks x 2,90     29,00
+MLEKO POLOTUC. 1L                         19,90
+BANANY   0,856 kg x 34,90 Kč/kg           29,87
+Zaokrouhlení                               0,23
+CELKEM                                    79,00`,
+			want: &ParsedReceipt{StoreName: "Albert", Date: "2026-03-02", Total: 79, Items: []ParsedReceiptItem{
+				{Name: "ROHLIK TUKOVY 43G", Quantity: 10, Unit: "pcs", Price: 2.9, TotalPrice: 29},
+				{Name: "MLEKO POLOTUC. 1L", Quantity: 1, Unit: "pcs", Price: 19.9, TotalPrice: 19.9},
+				{Name: "BANANY", Quantity: 0.856, Unit: "kg", Price: 34.9, TotalPrice: 29.87},
+			}},
+		},
+		{
+			name: "billa with quantity below the item and a bottle return",
+			text: "\xef\xbb\xbfBILLA, spol. s r. o.\r\n" +
+				"2026-02-14 09:12\r\n" +
+				"Rohlík                      4,50 A\r\n" +
+				"  5 x 0,90\r\n" +
+				"Pivo Plzeň 0,5l            23,90 B\r\n" +
+				"Vratné obaly               -3,00\r\n" +
+				"Celkem Kč                  25,40\r\n",
+			want: &ParsedReceipt{StoreName: "Billa", Date: "2026-02-14", Total: 25.4, Items: []ParsedReceiptItem{
+				{Name: "Rohlík", Quantity: 5, Unit: "pcs", Price: 0.9, TotalPrice: 4.5},
+				{Name: "Pivo Plzeň 0,5l", Quantity: 1, Unit: "pcs", Price: 23.9, TotalPrice: 23.9},
+			}},
+		},
+		{
+			name: "unknown store",
+			text: "Večerka U Nováka\n1.2.2026\nChleba 39,90\nCELKEM 39,90",
+		},
+		{
+			name: "no Czech total line",
+			text: "Store: Lidl\nMilk 1,99\nBread 2,49\nTotal 4,48",
+		},
+		{
+			name: "items do not add up",
+			text: "Lidl\n1.2.2026\nChleba 39,90\nMáslo 54,90\nK PLATBĚ 150,00",
+		},
+		{
+			name: "no date",
+			text: "Lidl\nChleba 39,90\nK PLATBĚ 39,90",
+		},
+		{
+			name: "quantity that contradicts the amount",
+			text: "Lidl\n1.2.2026\nRohlík 4,20 x 10 50,00\nK PLATBĚ 50,00",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseReceiptTextRules(tt.text)
+			if tt.want == nil {
+				assert.False(t, ok)
+				assert.Nil(t, got)
+				return
+			}
+			require.True(t, ok)
+			assert.Equal(t, tt.want.StoreName, got.StoreName)
+			assert.Equal(t, tt.want.Date, got.Date)
+			assert.InDelta(t, tt.want.Total, got.Total, 0.001)
+			require.Len(t, got.Items, len(tt.want.Items))
+			for i, want := range tt.want.Items {
+				assert.Equal(t, want.Name, got.Items[i].Name)
+				assert.InDelta(t, want.Quantity, got.Items[i].Quantity, 0.0001, want.Name)
+				assert.Equal(t, want.Unit, got.Items[i].Unit, want.Name)
+				assert.InDelta(t, want.Price, got.Items[i].Price, 0.001, want.Name)
+				assert.InDelta(t, want.TotalPrice, got.Items[i].TotalPrice, 0.001, want.Name)
+			}
+		})
+	}
+}
@@ -51,10 +51,24 @@ func InitDB() {
 		&models.Flyer{},
 		&models.FlyerPage{},
 		&models.FlyerItem{},
-		&models.JobStatus{},
+		&models.FlyerRetailer{},
+		&models.Job{},
+		&models.ProcessedEmail{},
 		&models.Receipt{},
 		&models.ReceiptItem{},
+		&models.ReceiptImage{},
+		&models.ReceiptVersion{},
+		&models.ReceiptAddress{},
 		&models.ItemAlias{},
+		&models.ListTemplate{},
+		&models.ListTemplateItem{},
+		&models.WatchlistItem{},
+		&models.WatchlistMatch{},
+		&models.Notification{},
+		&models.Budget{},
+		&models.FamilyInvite{},
+		&models.PasswordResetToken{},
+		&models.Session{},
 		&authdb.RefreshToken{},
 		&authdb.BlacklistedToken{},
 	)
@@ -136,6 +150,35 @@ func InitDB() {
 		slog.Info("Finished backfilling SearchText")
 	}
 
+	// Backfill SearchText for receipt items parsed before receipt search existed
+	var receiptItems []models.ReceiptItem
+	DB.Where("search_text = ? OR search_text IS NULL", "").Find(&receiptItems)
+	if len(receiptItems) > 0 {
+		slog.Info("Backfilling SearchText for receipt items", "count", len(receiptItems))
+		for _, item := range receiptItems {
+			DB.Model(&item).Update("search_text", utils.NormalizeSearchText(item.Name))
+		}
+	}
+
+	// Fingerprint receipts parsed before duplicate detection, or before the
+	// fingerprint covered the lines, so a re-upload of one of them is caught too
+	var unfingerprinted []models.Receipt
+	DB.Where("(fingerprint = ? OR fingerprint IS NULL OR fingerprint NOT LIKE ?) AND status IN ?", "", "%|%|%|%|%", []string{"parsed", "pending_review"}).Find(&unfingerprinted)
+	if len(unfingerprinted) > 0 {
+		slog.Info("Backfilling receipt fingerprints", "count", len(unfingerprinted))
+		for _, r := range unfingerprinted {
+			var lines []models.ReceiptItem
+			DB.Select("name", "price").Where("receipt_id = ?", r.ID).Find(&lines)
+			DB.Model(&r).Update("fingerprint", models.ReceiptFingerprint(r.ShopID, r.Date, r.Total, lines))
+		}
+	}
+
+	// Matched receipt lines from before reprocessing existed were counted in the
+	// purchase history under the name of the list item they are linked to
+	DB.Exec(`UPDATE receipt_items SET history_name = (SELECT name FROM items WHERE items.receipt_item_id = receipt_items.id LIMIT 1)
+		WHERE (history_name = '' OR history_name IS NULL) AND match_status IN ('auto', 'confirmed')
+		AND EXISTS (SELECT 1 FROM items WHERE items.receipt_item_id = receipt_items.id)`)
+
 	slog.Info("Database initialized and migrated")
 
 	seedFromEnv()
@@ -0,0 +1,129 @@
+// Package events fans shopping-list changes out to the family members who have
+// the list open, so a shopper in the store sees an urgent item without reloading.
+package events
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Type names an event on the wire. It doubles as the SSE "event:" field, so a
+// browser EventSource can addEventListener per type.
+type Type string
+
+const (
+	ItemCreated Type = "item.created"
+	ItemUpdated Type = "item.updated"
+	ItemDeleted Type = "item.deleted"
+	ListUpdated Type = "list.updated"
+)
+
+// subscriberBuffer is how many events a subscriber may lag behind before it is
+// dropped. A phone on a bad connection must not stall the handlers publishing.
+const subscriberBuffer = 32
+
+// Event is one change to a list. Data carries the item or list as the handler
+// returned it to the client that made the change.
+type Event struct {
+	Type     Type        `json:"type"`
+	ListID   uuid.UUID   `json:"list_id"`
+	FamilyID uuid.UUID   `json:"-"`
+	Data     interface{} `json:"data,omitempty"`
+	At       time.Time   `json:"at"`
+}
+
+// Subscription is one open stream. C is closed when the subscription ends,
+// either by Unsubscribe or because the subscriber fell too far behind.
+type Subscription struct {
+	C        <-chan Event
+	ch       chan Event
+	familyID uuid.UUID
+	listID   uuid.UUID
+}
+
+// Broker is an in-process pub/sub keyed by list. Every event also carries the
+// family it belongs to and is only delivered to subscribers of that family, so
+// a list ID guessed or leaked across tenants yields nothing.
+type Broker struct {
+	mu   sync.Mutex
+	subs map[uuid.UUID]map[*Subscription]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{subs: make(map[uuid.UUID]map[*Subscription]struct{})}
+}
+
+// Default is the broker the HTTP handlers publish to and stream from.
+var Default = NewBroker()
+
+// Subscribe registers interest in one list of one family. The caller must
+// Unsubscribe when done.
+func (b *Broker) Subscribe(familyID, listID uuid.UUID) *Subscription {
+	ch := make(chan Event, subscriberBuffer)
+	sub := &Subscription{C: ch, ch: ch, familyID: familyID, listID: listID}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subs[listID] == nil {
+		b.subs[listID] = make(map[*Subscription]struct{})
+	}
+	b.subs[listID][sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe removes the subscription and closes its channel. Safe to call
+// for a subscription the broker already dropped.
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.removeLocked(sub)
+}
+
+func (b *Broker) removeLocked(sub *Subscription) {
+	set, ok := b.subs[sub.listID]
+	if !ok {
+		return
+	}
+	if _, ok := set[sub]; !ok {
+		return
+	}
+	delete(set, sub)
+	close(sub.ch)
+	if len(set) == 0 {
+		delete(b.subs, sub.listID)
+	}
+}
+
+// Publish delivers the event to every subscriber of its list and family without
+// blocking. A subscriber whose buffer is full is dropped rather than sent a gap:
+// its stream ends, the client reconnects and reloads the list, which is cheaper
+// than reconciling missed events.
+func (b *Broker) Publish(ev Event) {
+	if ev.At.IsZero() {
+		ev.At = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs[ev.ListID] {
+		if sub.familyID != ev.FamilyID {
+			continue
+		}
+		select {
+		case sub.ch <- ev:
+		default:
+			slog.Warn("Dropping slow list event subscriber", "list_id", ev.ListID)
+			b.removeLocked(sub)
+		}
+	}
+}
+
+// SubscriberCount reports how many streams are open for a list.
+func (b *Broker) SubscriberCount(listID uuid.UUID) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs[listID])
+}
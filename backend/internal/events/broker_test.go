@@ -0,0 +1,54 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBroker_DeliversOnlyToSameFamilyAndList(t *testing.T) {
+	b := NewBroker()
+	familyA, familyB := uuid.New(), uuid.New()
+	listID, otherList := uuid.New(), uuid.New()
+
+	subA := b.Subscribe(familyA, listID)
+	defer b.Unsubscribe(subA)
+	subB := b.Subscribe(familyB, listID)
+	defer b.Unsubscribe(subB)
+	subOther := b.Subscribe(familyA, otherList)
+	defer b.Unsubscribe(subOther)
+
+	b.Publish(Event{Type: ItemCreated, ListID: listID, FamilyID: familyA, Data: "bread"})
+
+	select {
+	case ev := <-subA.C:
+		assert.Equal(t, ItemCreated, ev.Type)
+		assert.Equal(t, "bread", ev.Data)
+		assert.False(t, ev.At.IsZero())
+	default:
+		t.Fatal("expected event for same family and list")
+	}
+	assert.Len(t, subB.C, 0, "another family must not see the event")
+	assert.Len(t, subOther.C, 0, "another list must not see the event")
+}
+
+func TestBroker_DropsSlowSubscriber(t *testing.T) {
+	b := NewBroker()
+	familyID, listID := uuid.New(), uuid.New()
+	sub := b.Subscribe(familyID, listID)
+
+	for i := 0; i < subscriberBuffer+1; i++ {
+		b.Publish(Event{Type: ItemUpdated, ListID: listID, FamilyID: familyID})
+	}
+
+	assert.Equal(t, 0, b.SubscriberCount(listID))
+	drained := 0
+	for range sub.C {
+		drained++
+	}
+	assert.Equal(t, subscriberBuffer, drained, "buffered events are still readable before the close")
+
+	// Unsubscribing an already-dropped subscription must not panic on double close.
+	b.Unsubscribe(sub)
+}
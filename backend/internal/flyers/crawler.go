@@ -4,47 +4,15 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
-	"path/filepath"
 	"regexp"
 	"strings"
 	"time"
+
+	"kincart/internal/models"
 )
 
 const BaseURL = "https://www.akcniceny.cz"
 
-type RetailerConfig struct {
-	URL    string
-	Filter func(string) bool
-}
-
-var Retailers = map[string]RetailerConfig{
-	"albert": {
-		URL:    fmt.Sprintf("%s/letaky/albert/kraj-praha/praha/", BaseURL),
-		Filter: func(title string) bool { return strings.Contains(strings.ToLower(title), "hypermarket") },
-	},
-	"billa": {
-		URL:    fmt.Sprintf("%s/letaky/billa/", BaseURL),
-		Filter: func(title string) bool { return !strings.Contains(strings.ToLower(title), "malý leták") },
-	},
-	"tesco": {
-		URL:    fmt.Sprintf("%s/letaky/tesco/", BaseURL),
-		Filter: func(title string) bool { return strings.Contains(strings.ToLower(title), "hypermarkety") },
-	},
-	"kaufland": {
-		URL:    fmt.Sprintf("%s/letaky/kaufland/kraj-praha/praha/kaufland-praha-5-stodulky-pod-hranici-1304-17/", BaseURL),
-		Filter: func(title string) bool { return !strings.Contains(strings.ToLower(title), "spotřební zboží") },
-	},
-	"globus": {
-		URL:    fmt.Sprintf("%s/letaky/globus/kraj-praha/praha/globus-hypermarket-a-baumarkt-praha-zlicin-sarska-5133-praha-5/", BaseURL),
-		Filter: func(_ string) bool { return true },
-	},
-	"lidl": {
-		URL:    fmt.Sprintf("%s/letaky/lidl/", BaseURL),
-		Filter: func(_ string) bool { return true },
-	},
-}
-
 type FlyerInfo struct {
 	ID    string
 	URL   string
@@ -63,13 +31,10 @@ func NewCrawler() *Crawler {
 	}
 }
 
-func (c *Crawler) FetchFlyerURLs(retailerName string) ([]FlyerInfo, error) {
-	config, ok := Retailers[retailerName]
-	if !ok {
-		return nil, fmt.Errorf("unknown retailer: %s", retailerName)
-	}
-
-	resp, err := c.client.Get(config.URL)
+// FetchFlyerURLs lists the flyers on the retailer's page whose titles pass its
+// include and exclude patterns.
+func (c *Crawler) FetchFlyerURLs(retailer models.FlyerRetailer) ([]FlyerInfo, error) {
+	resp, err := c.client.Get(retailer.URL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch retailer page: %w", err)
 	}
@@ -108,7 +73,7 @@ func (c *Crawler) FetchFlyerURLs(retailerName string) ([]FlyerInfo, error) {
 			idPart := parts[len(parts)-1]
 			id := strings.Split(idPart, "/")[0]
 
-			if config.Filter(title) {
+			if TitleAllowed(retailer, title) {
 				flyers = append(flyers, FlyerInfo{
 					ID:    id,
 					URL:   fullURL,
@@ -176,27 +141,21 @@ func (c *Crawler) FetchFlyerImages(flyerURL string, delay time.Duration) ([]stri
 	return images, nil
 }
 
-func (c *Crawler) DownloadImage(url, destPath string) error {
+// FetchImage downloads an image into memory.
+func (c *Crawler) FetchImage(url string) ([]byte, error) {
 	resp, err := c.client.Get(url)
 	if err != nil {
-		return fmt.Errorf("failed to download image: %w", err)
+		return nil, fmt.Errorf("failed to download image: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("bad status code: %d", resp.StatusCode)
-	}
-
-	if err = os.MkdirAll(filepath.Dir(destPath), 0755); err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
+		return nil, fmt.Errorf("bad status code: %d", resp.StatusCode)
 	}
 
-	out, err := os.Create(destPath)
+	data, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return fmt.Errorf("failed to create file: %w", err)
+		return nil, fmt.Errorf("failed to read image: %w", err)
 	}
-	defer out.Close()
-
-	_, err = io.Copy(out, resp.Body)
-	return err
+	return data, nil
 }
@@ -0,0 +1,208 @@
+package flyers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"kincart/internal/mailbox"
+	"kincart/internal/models"
+)
+
+// emailKind marks the messages FetchEmailFlyers handled in ProcessedEmail.
+const emailKind = "flyer"
+
+// emailLookback bounds how far back FetchEmailFlyers looks, so a newly added
+// account does not have years of newsletters parsed.
+const emailLookback = 30 * 24 * time.Hour
+
+// minFlyerImageSize keeps logos and tracking pixels in newsletters away from
+// the AI; a flyer page is far bigger.
+const minFlyerImageSize = 50 * 1024
+
+// EmailAccount is an IMAP account flyer newsletters arrive in. A message is
+// taken for the first shop whose filters it passes; the rest are left alone.
+type EmailAccount struct {
+	mailbox.Account
+	Shops []EmailShop `json:"shops"`
+}
+
+// EmailShop claims a shop's newsletters: the sender address contains one of
+// From and the subject one of Subjects, ignoring case. Either list may be
+// empty, not both.
+type EmailShop struct {
+	Shop     string   `json:"shop"`
+	From     []string `json:"from"`
+	Subjects []string `json:"subjects"`
+}
+
+// LoadEmailAccounts reads the JSON list of accounts at path (FLYER_IMAP_CONFIG).
+func LoadEmailAccounts(path string) ([]EmailAccount, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read IMAP config: %w", err)
+	}
+	var accounts []EmailAccount
+	if err := json.Unmarshal(data, &accounts); err != nil {
+		return nil, fmt.Errorf("failed to parse IMAP config: %w", err)
+	}
+	for i, a := range accounts {
+		if a.Server == "" || a.User == "" {
+			return nil, fmt.Errorf("IMAP account %d: server and user are required", i+1)
+		}
+		if len(a.Shops) == 0 {
+			return nil, fmt.Errorf("IMAP account %s: no shops configured", a.User)
+		}
+		for _, s := range a.Shops {
+			if strings.TrimSpace(s.Shop) == "" {
+				return nil, fmt.Errorf("IMAP account %s: shop name is required", a.User)
+			}
+			if len(s.From) == 0 && len(s.Subjects) == 0 {
+				return nil, fmt.Errorf("IMAP account %s: shop %s needs a from or subjects filter", a.User, s.Shop)
+			}
+		}
+	}
+	return accounts, nil
+}
+
+// shopFor returns the shop claiming msg, or "".
+func (a EmailAccount) shopFor(msg *mailbox.Message) string {
+	for _, s := range a.Shops {
+		if len(s.From) > 0 && !matchesAny(msg.From, s.From) {
+			continue
+		}
+		if len(s.Subjects) > 0 && !matchesAny(msg.Subject, s.Subjects) {
+			continue
+		}
+		return strings.TrimSpace(s.Shop)
+	}
+	return ""
+}
+
+// matchesAny reports whether s contains one of the patterns, ignoring case.
+func matchesAny(s string, patterns []string) bool {
+	s = strings.ToLower(s)
+	for _, p := range patterns {
+		if p = strings.ToLower(strings.TrimSpace(p)); p != "" && strings.Contains(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// FetchEmailFlyers stores the PDF and image flyers attached to new newsletter
+// messages in EmailAccounts as the pages of a flyer per message, and queues
+// them for the page parser, PDFs to be split first. A message is recorded in
+// ProcessedEmail once its pages are stored and not looked at again; one whose
+// pages could not be stored is tried again next time. An account that cannot
+// be read is logged and skipped.
+func (m *Manager) FetchEmailFlyers(ctx context.Context) error {
+	for _, account := range m.EmailAccounts {
+		if err := m.fetchAccountFlyers(ctx, account); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			slog.Error("Failed to fetch flyer emails", "mailbox", account.Name(), "error", err)
+		}
+	}
+	return nil
+}
+
+func (m *Manager) fetchAccountFlyers(ctx context.Context, account EmailAccount) error {
+	mbox := account.Name()
+
+	var done []models.ProcessedEmail
+	if err := m.db.Select("uid_validity", "uid").Where("kind = ? AND mailbox = ?", emailKind, mbox).
+		Find(&done).Error; err != nil {
+		return fmt.Errorf("failed to load processed emails: %w", err)
+	}
+	seen := make(map[[2]uint32]bool, len(done))
+	for _, d := range done {
+		seen[[2]uint32{d.UIDValidity, d.UID}] = true
+	}
+
+	fetch := account.Fetch
+	if m.fetchMail != nil {
+		fetch = m.fetchMail
+	}
+	validity, msgs, err := fetch(mailbox.Query{
+		Since:       time.Now().Add(-emailLookback),
+		Skip:        func(validity, uid uint32) bool { return seen[[2]uint32{validity, uid}] },
+		Match:       func(msg *mailbox.Message) bool { return account.shopFor(msg) != "" },
+		Attachments: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, msg := range msgs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		shop := account.shopFor(&msg)
+
+		var pages []SourcePage
+		for _, a := range msg.Attachments {
+			if !isPDF(a.Data) && (!strings.HasPrefix(a.ContentType, "image/") || len(a.Data) < minFlyerImageSize) {
+				continue
+			}
+			pages = append(pages, SourcePage{Data: a.Data})
+		}
+		if len(pages) > 0 {
+			if err := m.storeEmailFlyer(shop, account.MessageURL(validity, msg.UID), pages); err != nil {
+				slog.Error("Failed to store flyer email", "subject", msg.Subject, "error", err)
+				continue
+			}
+		}
+
+		if err := m.db.Create(&models.ProcessedEmail{
+			Kind:        emailKind,
+			Mailbox:     mbox,
+			UIDValidity: validity,
+			UID:         msg.UID,
+			Subject:     msg.Subject,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to record processed email: %w", err)
+		}
+		slog.Info("Processed flyer email", "shop", shop, "subject", msg.Subject, "pages", len(pages))
+	}
+	return nil
+}
+
+// storeEmailFlyer stores the pages of a newsletter as the flyer at url and
+// queues them for parsing. Pages stored by an earlier run that could not
+// record the message are queued rather than stored again; a run that fails
+// halfway removes what it stored, so the next one starts over.
+func (m *Manager) storeEmailFlyer(shop, url string, pages []SourcePage) error {
+	var flyer models.Flyer
+	err := m.db.Where("url = ?", url).First(&flyer).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		flyer = models.Flyer{ShopName: shop, URL: url, ParsedAt: time.Now()}
+		err = m.db.Create(&flyer).Error
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store flyer: %w", err)
+	}
+
+	var stored []models.FlyerPage
+	if err := m.db.Where("flyer_id = ?", flyer.ID).Find(&stored).Error; err != nil {
+		return fmt.Errorf("failed to load flyer pages: %w", err)
+	}
+	if len(stored) == 0 {
+		if stored, err = m.storePages(flyer, pages); err != nil {
+			for _, p := range stored {
+				m.db.Delete(&p)
+				os.Remove(p.LocalPath)
+			}
+			return err
+		}
+	}
+	return m.enqueuePages(stored)
+}
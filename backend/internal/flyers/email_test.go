@@ -0,0 +1,121 @@
+package flyers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"kincart/internal/mailbox"
+	"kincart/internal/models"
+)
+
+type countingParser struct{ calls []string }
+
+func (p *countingParser) ParseFlyer(ctx context.Context, attachments []Attachment) (*ParsedFlyer, error) {
+	p.calls = append(p.calls, attachments[0].Filename)
+	return &ParsedFlyer{
+		StartDate: "2026-03-02",
+		EndDate:   "2026-03-08",
+		Items:     []ParsedItem{{Name: "Máslo 250g", Price: 39.9}},
+	}, nil
+}
+
+// fakeInbox serves messages the way mailbox.Fetcher does, honouring Skip and Match.
+func fakeInbox(msgs ...mailbox.Message) func(mailbox.Query) (uint32, []mailbox.Message, error) {
+	return func(q mailbox.Query) (uint32, []mailbox.Message, error) {
+		var out []mailbox.Message
+		for _, msg := range msgs {
+			if q.Skip != nil && q.Skip(7, msg.UID) {
+				continue
+			}
+			if q.Match != nil && !q.Match(&msg) {
+				continue
+			}
+			out = append(out, msg)
+		}
+		return 7, out, nil
+	}
+}
+
+func TestFetchEmailFlyers(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Flyer{}, &models.FlyerPage{}, &models.FlyerItem{}, &models.ProcessedEmail{}, &models.Job{}))
+	t.Setenv("UPLOADS_PATH", t.TempDir())
+
+	parser := &countingParser{}
+	m := NewManager(db, parser)
+	m.OutputDir = t.TempDir()
+	m.EmailAccounts = []EmailAccount{{
+		Account: mailbox.Account{Server: "imap.example.test:993", User: "flyers@example.test"},
+		Shops: []EmailShop{
+			{Shop: "lidl", From: []string{"@lidl.cz"}},
+			{Shop: "billa", Subjects: []string{"Billa leták"}},
+		},
+	}}
+	page := bytes.Repeat([]byte{0xff}, minFlyerImageSize)
+	m.fetchMail = fakeInbox(
+		mailbox.Message{UID: 1, From: "newsletter@lidl.cz", Subject: "Nový leták", Attachments: []mailbox.Attachment{
+			{Filename: "page1.jpg", ContentType: "image/jpeg", Data: page},
+			{Filename: "logo.png", ContentType: "image/png", Data: []byte("tiny")},
+			{Filename: "terms.txt", ContentType: "text/plain", Data: page},
+		}},
+		mailbox.Message{UID: 2, From: "news@example.test", Subject: "Something else", Attachments: []mailbox.Attachment{
+			{Filename: "other.jpg", ContentType: "image/jpeg", Data: page},
+		}},
+		mailbox.Message{UID: 3, From: "info@billa.cz", Subject: "Billa leták od pondělí"},
+	)
+
+	ctx := context.Background()
+	require.NoError(t, m.FetchEmailFlyers(ctx))
+	assert.Empty(t, parser.calls, "pages are parsed by their jobs")
+
+	var flyer models.Flyer
+	require.NoError(t, db.Preload("Pages").Where("shop_name = ?", "lidl").First(&flyer).Error)
+	assert.Equal(t, "imap://flyers@example.test@imap.example.test:993/INBOX;UIDVALIDITY=7/;UID=1", flyer.URL)
+	require.Len(t, flyer.Pages, 1, "only the flyer page of a claimed message is stored")
+
+	var processed []models.ProcessedEmail
+	require.NoError(t, db.Order("uid").Find(&processed).Error)
+	require.Len(t, processed, 2, "a claimed message without attachments is done too")
+	assert.Equal(t, uint32(1), processed[0].UID)
+	assert.Equal(t, uint32(3), processed[1].UID)
+
+	var job models.Job
+	require.NoError(t, db.Where("kind = ? AND ref = ?", JobFlyerPage, fmt.Sprint(flyer.Pages[0].ID)).First(&job).Error)
+	require.NoError(t, m.ProcessPageJob(ctx, job))
+	assert.Len(t, parser.calls, 1)
+	var items int64
+	db.Model(&models.FlyerItem{}).Where("flyer_id = ?", flyer.ID).Count(&items)
+	assert.Equal(t, int64(1), items)
+
+	t.Run("nothing is stored twice", func(t *testing.T) {
+		require.NoError(t, m.FetchEmailFlyers(ctx))
+		var pages int64
+		db.Model(&models.FlyerPage{}).Count(&pages)
+		assert.Equal(t, int64(1), pages)
+	})
+}
+
+func TestLoadEmailAccounts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "imap.json")
+
+	require.NoError(t, os.WriteFile(path, []byte(`[{"server":"imap.example.test:993","user":"u","password":"p",
+		"shops":[{"shop":"lidl","from":["lidl.cz"]}]}]`), 0600))
+	accounts, err := LoadEmailAccounts(path)
+	require.NoError(t, err)
+	require.Len(t, accounts, 1)
+	assert.Equal(t, "u@imap.example.test:993/INBOX", accounts[0].Name())
+
+	require.NoError(t, os.WriteFile(path, []byte(`[{"server":"imap.example.test:993","user":"u","shops":[{"shop":"lidl"}]}]`), 0600))
+	_, err = LoadEmailAccounts(path)
+	assert.ErrorContains(t, err, "needs a from or subjects filter")
+}
@@ -4,11 +4,15 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	"kincart/internal/jobs"
+	"kincart/internal/mailbox"
 	"kincart/internal/models"
 	"kincart/internal/utils"
 
@@ -17,11 +21,18 @@ import (
 
 type Manager struct {
 	db        *gorm.DB
-	parser    *Parser
+	parser    Parser
 	OutputDir string
+	// OnNewItems, when set, is called with the items stored for each parsed
+	// page, ShopName filled in. The server hooks the discount watchlist here.
+	OnNewItems func(ctx context.Context, items []models.FlyerItem)
+	// EmailAccounts are the IMAP accounts FetchEmailFlyers reads.
+	EmailAccounts []EmailAccount
+
+	fetchMail func(q mailbox.Query) (uint32, []mailbox.Message, error) // replaces the IMAP fetch in tests
 }
 
-func NewManager(db *gorm.DB, parser *Parser) *Manager {
+func NewManager(db *gorm.DB, parser Parser) *Manager {
 	return &Manager{
 		db:        db,
 		parser:    parser,
@@ -68,37 +79,57 @@ func (m *Manager) ProcessAttachment(ctx context.Context, att Attachment, shopNam
 			continue
 		}
 
-		if err := m.SaveParsedFlyer(parsed, a.Data, shopName, "", "", 0); err != nil {
+		saved, err := m.saveParsedFlyer(parsed, a.Data, shopName, "", "", 0)
+		if err != nil {
 			slog.Error("Failed to save flyer", "shop", shopName, "error", err)
 			continue
 		}
+		m.notifyNewItems(ctx, saved)
 	}
 
 	return nil
 }
 
-func (m *Manager) FetchAndProcessFlyers(ctx context.Context) error {
-	if err := m.DownloadNewFlyers(ctx); err != nil {
-		slog.Error("Failed to download new flyers", "error", err)
+// DownloadNewFlyers downloads new flyers from every configured source: the
+// enabled retailers in the registry and, when FLYER_HOT_FOLDER is set, the
+// hot folder.
+func (m *Manager) DownloadNewFlyers(ctx context.Context) error {
+	retailers, err := EnabledRetailers(m.db, os.Getenv("FLYER_REGION"))
+	if err != nil {
+		return fmt.Errorf("failed to load flyer retailers: %w", err)
 	}
-	return m.ProcessPendingPages(ctx)
-}
 
-func (m *Manager) DownloadNewFlyers(ctx context.Context) error {
 	crawler := NewCrawler()
-	uploadsPath := os.Getenv("UPLOADS_PATH")
-	if uploadsPath == "" {
-		uploadsPath = "./uploads"
+	var sources []FlyerSource
+	for _, retailer := range retailers {
+		if retailer.Source == RetailerSourcePDF {
+			sources = append(sources, NewPDFSource(retailer))
+		} else {
+			sources = append(sources, NewAggregatorSource(crawler, retailer))
+		}
+	}
+	if dir := os.Getenv("FLYER_HOT_FOLDER"); dir != "" {
+		sources = append(sources, NewHotFolderSource(dir))
+	}
+	if len(sources) == 0 {
+		slog.Warn("No flyer sources configured, nothing to download")
 	}
-	baseDir := filepath.Join(uploadsPath, "flyer_pages")
 
-	delay := 500 * time.Millisecond
+	return m.DownloadFrom(ctx, sources...)
+}
 
-	for shopName := range Retailers {
-		slog.Info("Fetching flyer URLs", "shop", shopName)
-		flyersList, err := crawler.FetchFlyerURLs(shopName)
+// DownloadFrom stores the flyers the sources list that are not stored yet and
+// saves their pages under UPLOADS_PATH/flyer_pages as FlyerPage rows, ready
+// for the page parser. A source that fails is logged and skipped.
+func (m *Manager) DownloadFrom(ctx context.Context, sources ...FlyerSource) error {
+	for _, source := range sources {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		slog.Info("Fetching flyer URLs", "source", source.Name())
+		flyersList, err := source.ListFlyers(ctx)
 		if err != nil {
-			slog.Error("Failed to fetch flyer URLs", "shop", shopName, "error", err)
+			slog.Error("Failed to fetch flyer URLs", "source", source.Name(), "error", err)
 			continue
 		}
 
@@ -112,7 +143,7 @@ func (m *Manager) DownloadNewFlyers(ctx context.Context) error {
 			if err == gorm.ErrRecordNotFound {
 				slog.Info("Found new flyer", "title", f.Title, "url", f.URL)
 				flyer = models.Flyer{
-					ShopName: shopName,
+					ShopName: f.ShopName,
 					URL:      f.URL,
 					ParsedAt: time.Now(), // This will be updated later when items are added
 				}
@@ -129,100 +160,246 @@ func (m *Manager) DownloadNewFlyers(ctx context.Context) error {
 				continue
 			}
 
-			slog.Info("Downloading pages for flyer", "shop", shopName, "url", f.URL)
-			images, err := crawler.FetchFlyerImages(f.URL, delay)
+			slog.Info("Downloading pages for flyer", "shop", flyer.ShopName, "url", f.URL)
+			pages, err := source.FetchPages(ctx, f)
 			if err != nil {
 				slog.Error("Failed to fetch flyer images", "url", f.URL, "error", err)
 				continue
 			}
 
-			for i, imgURL := range images {
-				localFilename := fmt.Sprintf("%d_page_%d.jpg", flyer.ID, i+1)
-				shopDir := utils.GetShardDirFromID(filepath.Join(baseDir, shopName), flyer.ID)
-				localPath := filepath.Join(shopDir, localFilename)
+			if _, err := m.storePages(flyer, pages); err != nil {
+				slog.Error("Failed to store flyer pages", "url", f.URL, "error", err)
+			}
+		}
+	}
+	return nil
+}
 
-				if err := os.MkdirAll(shopDir, 0755); err != nil {
-					slog.Error("Failed to create shop directory", "dir", shopDir, "error", err)
-					continue
-				}
+// storePages saves a flyer's pages under UPLOADS_PATH/flyer_pages as
+// FlyerPage rows, ready for the page parser. A page that is a whole PDF is
+// stored as such and split by its page job. It stops at the first page it
+// cannot store, returning the ones stored so far.
+func (m *Manager) storePages(flyer models.Flyer, pages []SourcePage) ([]models.FlyerPage, error) {
+	uploadsPath := os.Getenv("UPLOADS_PATH")
+	if uploadsPath == "" {
+		uploadsPath = "./uploads"
+	}
+	shopDir := utils.GetShardDirFromID(filepath.Join(uploadsPath, "flyer_pages", flyer.ShopName), flyer.ID)
+	if err := os.MkdirAll(shopDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create shop directory: %w", err)
+	}
 
-				if err := crawler.DownloadImage(imgURL, localPath); err != nil {
-					slog.Error("Failed to download page image", "url", imgURL, "error", err)
-					continue
-				}
+	stored := make([]models.FlyerPage, 0, len(pages))
+	for i, p := range pages {
+		ext := ".jpg"
+		switch {
+		case isPDF(p.Data):
+			ext = ".pdf"
+		case http.DetectContentType(p.Data) == "image/png":
+			ext = ".png"
+		}
+		localPath := filepath.Join(shopDir, fmt.Sprintf("%d_page_%d%s", flyer.ID, i+1, ext))
+		if err := os.WriteFile(localPath, p.Data, 0644); err != nil {
+			return stored, fmt.Errorf("failed to save page image: %w", err)
+		}
 
-				page := models.FlyerPage{
-					FlyerID:   flyer.ID,
-					SourceURL: imgURL,
-					LocalPath: localPath,
-				}
-				if err := m.db.Create(&page).Error; err != nil {
-					slog.Error("Failed to save page record", "error", err)
-				}
-			}
+		page := models.FlyerPage{
+			FlyerID:   flyer.ID,
+			SourceURL: p.SourceURL,
+			LocalPath: localPath,
+		}
+		if err := m.db.Create(&page).Error; err != nil {
+			return stored, fmt.Errorf("failed to save page record: %w", err)
 		}
+		stored = append(stored, page)
 	}
-	return nil
+	return stored, nil
 }
 
-func (m *Manager) ProcessPendingPages(ctx context.Context) error {
+// DuplicateFlyerError is returned by StoreUpload when the same flyer was
+// uploaded before.
+type DuplicateFlyerError struct {
+	ExistingID uint
+}
+
+func (e *DuplicateFlyerError) Error() string {
+	return fmt.Sprintf("flyer already uploaded as %d", e.ExistingID)
+}
+
+// StoreUpload stores an uploaded flyer through DownloadFrom and queues its
+// pages for parsing. It returns the flyer and how many pages it has.
+func (m *Manager) StoreUpload(ctx context.Context, source *UploadSource) (*models.Flyer, int, error) {
+	var flyer models.Flyer
+	err := m.db.Where("url = ?", source.URL()).First(&flyer).Error
+	if err == nil {
+		var pageCount int64
+		m.db.Model(&models.FlyerPage{}).Where("flyer_id = ?", flyer.ID).Count(&pageCount)
+		if pageCount > 0 {
+			return nil, 0, &DuplicateFlyerError{ExistingID: flyer.ID}
+		}
+	} else if err != gorm.ErrRecordNotFound {
+		return nil, 0, fmt.Errorf("failed to check existing flyer: %w", err)
+	}
+
+	if err := m.DownloadFrom(ctx, source); err != nil {
+		return nil, 0, err
+	}
+
+	if err := m.db.Where("url = ?", source.URL()).First(&flyer).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to store flyer: %w", err)
+	}
 	var pages []models.FlyerPage
-	// Process pages that are not parsed and have less than 3 retries
-	err := m.db.Where("is_parsed = ? AND retries < ?", false, 3).Find(&pages).Error
+	if err := m.db.Where("flyer_id = ?", flyer.ID).Order("id").Find(&pages).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to load flyer pages: %w", err)
+	}
+	if len(pages) == 0 {
+		return nil, 0, fmt.Errorf("no pages could be read from the flyer")
+	}
+	if err := m.enqueuePages(pages); err != nil {
+		return nil, 0, err
+	}
+	return &flyer, len(pages), nil
+}
+
+// EnqueuePendingPages queues a parse job for every downloaded page that is
+// not parsed yet and has attempts left.
+func (m *Manager) EnqueuePendingPages(ctx context.Context) error {
+	var pages []models.FlyerPage
+	err := m.db.WithContext(ctx).Where("is_parsed = ? AND retries < ?", false, maxPageAttempts).Find(&pages).Error
 	if err != nil {
 		return fmt.Errorf("failed to fetch pending pages: %w", err)
 	}
 
-	if len(pages) == 0 {
-		return nil
+	if err := m.enqueuePages(pages); err != nil {
+		return err
 	}
+	if len(pages) > 0 {
+		slog.Info("Queued pending flyer pages", "count", len(pages))
+	}
+	return nil
+}
 
-	slog.Info("Processing pending flyer pages", "count", len(pages))
-
+// enqueuePages queues a parse job for each page.
+func (m *Manager) enqueuePages(pages []models.FlyerPage) error {
 	for _, page := range pages {
-		var flyer models.Flyer
-		if err := m.db.First(&flyer, page.FlyerID).Error; err != nil {
-			slog.Error("Flyer not found for page", "page_id", page.ID, "flyer_id", page.FlyerID)
-			continue
+		if _, err := jobs.Enqueue(m.db, JobFlyerPage, strconv.FormatUint(uint64(page.ID), 10), jobs.Options{MaxAttempts: maxPageAttempts}); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		slog.Info("Parsing flyer page", "page_id", page.ID, "path", page.LocalPath)
-		data, err := os.ReadFile(page.LocalPath)
-		if err != nil {
-			slog.Error("Failed to read page file", "path", page.LocalPath, "error", err)
-			continue
-		}
+// ProcessPageJob is the JobFlyerPage worker: it parses one downloaded page and
+// stores its items. Failures are also recorded on the page for the flyer admin views.
+func (m *Manager) ProcessPageJob(ctx context.Context, job models.Job) error {
+	id, err := strconv.ParseUint(job.Ref, 10, 64)
+	if err != nil {
+		return jobs.Permanent(fmt.Errorf("invalid page id %q", job.Ref))
+	}
 
-		att := Attachment{
-			Filename:    filepath.Base(page.LocalPath),
-			ContentType: "image/jpeg",
-			Data:        data,
-		}
+	var page models.FlyerPage
+	if err := m.db.First(&page, id).Error; err != nil {
+		return jobs.Permanent(fmt.Errorf("page not found: %w", err))
+	}
+	if page.IsParsed {
+		return nil
+	}
 
-		parsed, err := m.parser.ParseFlyer(ctx, []Attachment{att})
-		if err != nil {
-			slog.Error("Failed to parse flyer page", "page_id", page.ID, "error", err)
-			m.db.Model(&page).Updates(map[string]interface{}{
-				"retries":    page.Retries + 1,
-				"last_error": err.Error(),
-			})
-			continue
-		}
+	var flyer models.Flyer
+	if err := m.db.First(&flyer, page.FlyerID).Error; err != nil {
+		return jobs.Permanent(fmt.Errorf("flyer %d not found for page: %w", page.FlyerID, err))
+	}
 
-		if err := m.SaveParsedFlyer(parsed, data, flyer.ShopName, flyer.URL, page.SourceURL, page.ID); err != nil {
-			slog.Error("Failed to save flyer items", "page_id", page.ID, "error", err)
-			m.db.Model(&page).Update("last_error", err.Error())
-			continue
-		}
+	slog.Info("Parsing flyer page", "page_id", page.ID, "path", page.LocalPath)
+	data, err := os.ReadFile(page.LocalPath)
+	if err != nil {
+		return jobs.Permanent(fmt.Errorf("failed to read page file: %w", err))
+	}
 
-		// Mark as parsed
-		m.db.Model(&page).Update("is_parsed", true)
+	if isPDF(data) {
+		return m.splitPage(page, data)
 	}
 
+	att := Attachment{
+		Filename:    filepath.Base(page.LocalPath),
+		ContentType: http.DetectContentType(data),
+		Data:        data,
+	}
+
+	parsed, err := m.parser.ParseFlyer(ctx, []Attachment{att})
+	if err != nil {
+		m.db.Model(&page).Updates(map[string]interface{}{
+			"retries":    page.Retries + 1,
+			"last_error": err.Error(),
+		})
+		return fmt.Errorf("failed to parse flyer page: %w", err)
+	}
+
+	saved, err := m.saveParsedFlyer(parsed, data, flyer.ShopName, flyer.URL, page.SourceURL, page.ID)
+	if err != nil {
+		m.db.Model(&page).Update("last_error", err.Error())
+		return fmt.Errorf("failed to save flyer items: %w", err)
+	}
+
+	// Mark as parsed
+	m.db.Model(&page).Update("is_parsed", true)
+	m.notifyNewItems(ctx, saved)
 	return nil
 }
 
+// splitPage replaces a page holding a whole PDF with a page per PDF page,
+// stored next to it, and queues those for parsing.
+func (m *Manager) splitPage(page models.FlyerPage, data []byte) error {
+	slog.Info("Splitting flyer PDF", "page_id", page.ID, "path", page.LocalPath)
+	split, err := pdfPages(data, page.SourceURL)
+	if err != nil {
+		m.db.Model(&page).Updates(map[string]interface{}{
+			"retries":    page.Retries + 1,
+			"last_error": err.Error(),
+		})
+		return fmt.Errorf("failed to split flyer PDF: %w", err)
+	}
+
+	base := strings.TrimSuffix(page.LocalPath, filepath.Ext(page.LocalPath))
+	pages := make([]models.FlyerPage, 0, len(split))
+	for i, p := range split {
+		localPath := fmt.Sprintf("%s_%d.png", base, i+1)
+		if err := os.WriteFile(localPath, p.Data, 0644); err != nil {
+			return fmt.Errorf("failed to save page image: %w", err)
+		}
+		pages = append(pages, models.FlyerPage{FlyerID: page.FlyerID, SourceURL: p.SourceURL, LocalPath: localPath})
+	}
+
+	err = m.db.Transaction(func(tx *gorm.DB) error {
+		if len(pages) > 0 {
+			if err := tx.Create(&pages).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&page).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save split pages: %w", err)
+	}
+	os.Remove(page.LocalPath)
+	return m.enqueuePages(pages)
+}
+
+// notifyNewItems hands freshly stored items to OnNewItems, if anyone listens.
+func (m *Manager) notifyNewItems(ctx context.Context, items []models.FlyerItem) {
+	if m.OnNewItems == nil || len(items) == 0 {
+		return
+	}
+	m.OnNewItems(ctx, items)
+}
+
 func (m *Manager) SaveParsedFlyer(parsed *ParsedFlyer, imageData []byte, shopName string, flyerURL string, photoURL string, pageID uint) error {
+	_, err := m.saveParsedFlyer(parsed, imageData, shopName, flyerURL, photoURL, pageID)
+	return err
+}
+
+// saveParsedFlyer stores the parsed items and returns the rows it created.
+func (m *Manager) saveParsedFlyer(parsed *ParsedFlyer, imageData []byte, shopName string, flyerURL string, photoURL string, pageID uint) ([]models.FlyerItem, error) {
 	// Parse dates
 	layout := "2006-01-02"
 	startDate, _ := time.Parse(layout, parsed.StartDate)
@@ -231,7 +408,7 @@ func (m *Manager) SaveParsedFlyer(parsed *ParsedFlyer, imageData []byte, shopNam
 	var flyer models.Flyer
 	err := m.db.Where("url = ?", flyerURL).First(&flyer).Error
 	if err != nil && err != gorm.ErrRecordNotFound {
-		return fmt.Errorf("failed to check existing flyer: %w", err)
+		return nil, fmt.Errorf("failed to check existing flyer: %w", err)
 	}
 
 	if err == gorm.ErrRecordNotFound {
@@ -243,7 +420,7 @@ func (m *Manager) SaveParsedFlyer(parsed *ParsedFlyer, imageData []byte, shopNam
 			ParsedAt:  time.Now(),
 		}
 		if err := m.db.Create(&flyer).Error; err != nil {
-			return fmt.Errorf("failed to create flyer: %w", err)
+			return nil, fmt.Errorf("failed to create flyer: %w", err)
 		}
 	} else {
 		// Update dates if they were not set (e.g. created by DownloadNewFlyers without dates)
@@ -259,6 +436,7 @@ func (m *Manager) SaveParsedFlyer(parsed *ParsedFlyer, imageData []byte, shopNam
 		}
 	}
 
+	saved := make([]models.FlyerItem, 0, len(parsed.Items))
 	outputDir := m.OutputDir
 	if outputDir == "" {
 		outputDir = "data/flyer_items"
@@ -302,9 +480,13 @@ func (m *Manager) SaveParsedFlyer(parsed *ParsedFlyer, imageData []byte, shopNam
 
 		if err := m.db.Create(&flyerItem).Error; err != nil {
 			slog.Error("Failed to save flyer item", "name", pi.Name, "error", err)
+			continue
 		}
+		// Read-only column, normally filled by a join; set so listeners need not query.
+		flyerItem.ShopName = flyer.ShopName
+		saved = append(saved, flyerItem)
 	}
 
 	slog.Info("Processed flyer items", "shop", flyer.ShopName, "items", len(parsed.Items))
-	return nil
+	return saved, nil
 }
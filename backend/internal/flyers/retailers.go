@@ -0,0 +1,110 @@
+package flyers
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"kincart/internal/models"
+)
+
+// DefaultRetailers seed the registry of a new installation: the Prague shops
+// the download has always covered.
+var DefaultRetailers = []models.FlyerRetailer{
+	{
+		Name:    "albert",
+		URL:     fmt.Sprintf("%s/letaky/albert/kraj-praha/praha/", BaseURL),
+		Region:  "Praha",
+		Include: "hypermarket",
+	},
+	{
+		Name:    "billa",
+		URL:     fmt.Sprintf("%s/letaky/billa/", BaseURL),
+		Exclude: "malý leták",
+	},
+	{
+		Name:    "tesco",
+		URL:     fmt.Sprintf("%s/letaky/tesco/", BaseURL),
+		Include: "hypermarkety",
+	},
+	{
+		Name:    "kaufland",
+		URL:     fmt.Sprintf("%s/letaky/kaufland/kraj-praha/praha/kaufland-praha-5-stodulky-pod-hranici-1304-17/", BaseURL),
+		Region:  "Praha",
+		Exclude: "spotřební zboží",
+	},
+	{
+		Name:   "globus",
+		URL:    fmt.Sprintf("%s/letaky/globus/kraj-praha/praha/globus-hypermarket-a-baumarkt-praha-zlicin-sarska-5133-praha-5/", BaseURL),
+		Region: "Praha",
+	},
+	{
+		Name: "lidl",
+		URL:  fmt.Sprintf("%s/letaky/lidl/", BaseURL),
+	},
+}
+
+// SeedRetailers fills an empty registry with DefaultRetailers, all enabled.
+// Retailers an admin deleted count as present, so they are not brought back.
+func SeedRetailers(db *gorm.DB) error {
+	var count int64
+	if err := db.Unscoped().Model(&models.FlyerRetailer{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	retailers := make([]models.FlyerRetailer, len(DefaultRetailers))
+	copy(retailers, DefaultRetailers)
+	for i := range retailers {
+		retailers[i].Enabled = true
+	}
+	return db.Create(&retailers).Error
+}
+
+// EnabledRetailers returns the retailers the download crawls, by name. With a
+// region (FLYER_REGION), only the retailers of that region, ignoring case, and
+// those without a region, such as nationwide chains, are crawled.
+func EnabledRetailers(db *gorm.DB, region string) ([]models.FlyerRetailer, error) {
+	q := db.Where("enabled = ?", true)
+	if region = strings.TrimSpace(region); region != "" {
+		q = q.Where("region = '' OR region IS NULL OR LOWER(region) = LOWER(?)", region)
+	}
+	var retailers []models.FlyerRetailer
+	err := q.Order("name").Find(&retailers).Error
+	return retailers, err
+}
+
+// TitleAllowed reports whether a flyer titled title passes the retailer's
+// include and exclude patterns.
+func TitleAllowed(retailer models.FlyerRetailer, title string) bool {
+	title = strings.ToLower(title)
+	include := splitPatterns(retailer.Include)
+	if len(include) > 0 && !containsAny(title, include) {
+		return false
+	}
+	return !containsAny(title, splitPatterns(retailer.Exclude))
+}
+
+// splitPatterns turns a stored comma-separated pattern list into its lower-case
+// patterns, skipping empty ones.
+func splitPatterns(patterns string) []string {
+	var out []string
+	for _, p := range strings.Split(patterns, ",") {
+		if p = strings.ToLower(strings.TrimSpace(p)); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+func containsAny(s string, patterns []string) bool {
+	for _, p := range patterns {
+		if strings.Contains(s, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -6,31 +6,74 @@ import (
 	"os"
 	"time"
 
+	"kincart/internal/jobs"
 	"kincart/internal/models"
 
 	"gorm.io/gorm"
 )
 
-const FlyerDownloadJobName = "flyer_download"
+// Job kinds run by the flyer workers. A download job's ref is the day it was
+// queued; a page job's ref is the FlyerPage ID.
+const (
+	JobFlyerDownload = "flyer_download"
+	JobFlyerPage     = "flyer_page"
+)
+
+// maxPageAttempts is how often a page is sent to the AI before it is given up on.
+const maxPageAttempts = 3
+
+// RegisterJobs runs flyer downloads (web sources, then newsletter emails) and
+// page parsing on q with manager.
+func RegisterJobs(q *jobs.Queue, manager *Manager) {
+	q.Register(JobFlyerDownload, func(ctx context.Context, job models.Job) error {
+		if err := manager.DownloadNewFlyers(ctx); err != nil {
+			return err
+		}
+		if err := manager.FetchEmailFlyers(ctx); err != nil {
+			return err
+		}
+		return manager.EnqueuePendingPages(ctx)
+	})
+	q.Register(JobFlyerPage, manager.ProcessPageJob)
+}
 
-func StartScheduler(db *gorm.DB, manager *Manager) {
+// EnqueueDownload queues a flyer download for today. A download that already
+// died today is retried rather than left blocking the day.
+func EnqueueDownload(db *gorm.DB) (*models.Job, error) {
+	job, err := jobs.Enqueue(db, JobFlyerDownload, time.Now().Format("2006-01-02"), jobs.Options{MaxAttempts: 3})
+	if err != nil {
+		return nil, err
+	}
+	if job.State == jobs.StateDead {
+		return jobs.Retry(db, job.ID, nil)
+	}
+	return job, nil
+}
+
+// StartScheduler queues a flyer download once a day, respecting the
+// FLYER_FETCH_DELAY_HOURS cooldown, and sweeps unparsed pages into the queue.
+// The work itself runs on the jobs queue.
+func StartScheduler(ctx context.Context, db *gorm.DB, manager *Manager) {
 	go func() {
 		// First run check
-		checkAndRun(db, manager)
+		checkAndRun(ctx, db, manager)
 
 		// Daily rotation
 		ticker := time.NewTicker(24 * time.Hour)
 		defer ticker.Stop()
 
-		for range ticker.C {
-			checkAndRun(db, manager)
+		for {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+			checkAndRun(ctx, db, manager)
 		}
 	}()
 }
 
-func checkAndRun(db *gorm.DB, manager *Manager) {
-	ctx := context.Background()
-
+func checkAndRun(ctx context.Context, db *gorm.DB, manager *Manager) {
 	// 1. Try to download new flyers, but respect cooldown
 	fetchDelay := 12 * time.Hour
 	if delayEnv := os.Getenv("FLYER_FETCH_DELAY_HOURS"); delayEnv != "" {
@@ -39,46 +82,18 @@ func checkAndRun(db *gorm.DB, manager *Manager) {
 		}
 	}
 
-	var status models.JobStatus
-	err := db.Where("name = ?", FlyerDownloadJobName).First(&status).Error
+	var last models.Job
+	err := db.Where("kind = ?", JobFlyerDownload).Order("created_at DESC").First(&last).Error
 	if err != nil && err != gorm.ErrRecordNotFound {
-		slog.Error("Failed to check job status", "error", err)
-	} else {
-		canDownload := true
-		if err == nil {
-			// Check if it was started earlier than configured delay ago
-			if time.Since(status.LastRun) < fetchDelay {
-				slog.Info("Flyer download job ran recently, skipping download", "last_run", status.LastRun, "delay", fetchDelay)
-				canDownload = false
-			}
-		}
-
-		if canDownload {
-			slog.Info("Starting scheduled flyer download job")
-			UpdateJobStatus(db, FlyerDownloadJobName)
-			if err := manager.DownloadNewFlyers(ctx); err != nil {
-				slog.Error("Scheduled flyer download failed", "error", err)
-			}
-		}
+		slog.Error("Failed to check last flyer download", "error", err)
+	} else if err == nil && time.Since(last.CreatedAt) < fetchDelay {
+		slog.Info("Flyer download job ran recently, skipping download", "last_run", last.CreatedAt, "delay", fetchDelay)
+	} else if _, err := EnqueueDownload(db); err != nil {
+		slog.Error("Failed to queue flyer download", "error", err)
 	}
 
-	// 2. Always process pending pages (no cooldown for parsing)
-	slog.Info("Checking for pending flyer pages to process")
-	if err := manager.ProcessPendingPages(ctx); err != nil {
-		slog.Error("Scheduled flyer processing failed", "error", err)
-	}
-}
-
-func UpdateJobStatus(db *gorm.DB, name string) {
-	var status models.JobStatus
-	err := db.Where("name = ?", name).First(&status).Error
-	if err == gorm.ErrRecordNotFound {
-		status = models.JobStatus{
-			Name:    name,
-			LastRun: time.Now(),
-		}
-		db.Create(&status)
-	} else if err == nil {
-		db.Model(&status).Update("last_run", time.Now())
+	// 2. Always queue pending pages (no cooldown for parsing)
+	if err := manager.EnqueuePendingPages(ctx); err != nil {
+		slog.Error("Failed to queue pending flyer pages", "error", err)
 	}
 }
@@ -0,0 +1,344 @@
+package flyers
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"kincart/internal/models"
+)
+
+// Values of FlyerRetailer.Source: how the retailer's URL is read.
+const (
+	RetailerSourceAggregator = "akcniceny" // an akcniceny.cz listing page
+	RetailerSourcePDF        = "pdf"       // the retailer's own flyer PDF
+)
+
+// FlyerSource is somewhere flyers come from. Manager.DownloadFrom stores the
+// flyers a source lists and turns their pages into FlyerPage rows for the page
+// parser.
+type FlyerSource interface {
+	// Name identifies the source in logs.
+	Name() string
+	// ListFlyers returns the flyers the source offers now. The URL identifies a
+	// flyer: one already stored is not downloaded again.
+	ListFlyers(ctx context.Context) ([]SourceFlyer, error)
+	// FetchPages returns the page images of a flyer ListFlyers returned, in order.
+	FetchPages(ctx context.Context, flyer SourceFlyer) ([]SourcePage, error)
+}
+
+// SourceFlyer is one flyer a FlyerSource offers.
+type SourceFlyer struct {
+	ShopName string
+	URL      string
+	Title    string
+}
+
+// SourcePage is one page image of a flyer, JPEG or PNG.
+type SourcePage struct {
+	SourceURL string // where the page came from; kept on the FlyerPage
+	Data      []byte
+}
+
+// AggregatorSource reads a retailer's flyers from its akcniceny.cz page.
+type AggregatorSource struct {
+	crawler  *Crawler
+	retailer models.FlyerRetailer
+	// Delay is the pause between two page requests, to go easy on the site.
+	Delay time.Duration
+}
+
+func NewAggregatorSource(crawler *Crawler, retailer models.FlyerRetailer) *AggregatorSource {
+	return &AggregatorSource{crawler: crawler, retailer: retailer, Delay: 500 * time.Millisecond}
+}
+
+func (s *AggregatorSource) Name() string { return "akcniceny:" + s.retailer.Name }
+
+func (s *AggregatorSource) ListFlyers(ctx context.Context) ([]SourceFlyer, error) {
+	infos, err := s.crawler.FetchFlyerURLs(s.retailer)
+	if err != nil {
+		return nil, err
+	}
+	flyers := make([]SourceFlyer, len(infos))
+	for i, f := range infos {
+		flyers[i] = SourceFlyer{ShopName: s.retailer.Name, URL: f.URL, Title: f.Title}
+	}
+	return flyers, nil
+}
+
+// FetchPages downloads the flyer's page images. A page that fails to download
+// is skipped rather than losing the whole flyer.
+func (s *AggregatorSource) FetchPages(ctx context.Context, flyer SourceFlyer) ([]SourcePage, error) {
+	images, err := s.crawler.FetchFlyerImages(flyer.URL, s.Delay)
+	if err != nil {
+		return nil, err
+	}
+	var pages []SourcePage
+	for _, imgURL := range images {
+		data, err := s.crawler.FetchImage(imgURL)
+		if err != nil {
+			slog.Error("Failed to download page image", "url", imgURL, "error", err)
+			continue
+		}
+		pages = append(pages, SourcePage{SourceURL: imgURL, Data: data})
+	}
+	return pages, nil
+}
+
+// PDFSource reads a retailer that publishes its flyer as a PDF at a fixed URL.
+// Retailers tend to replace the file in place, so the flyer is known by the
+// URL and a hash of the content: a new PDF at the same URL is a new flyer.
+type PDFSource struct {
+	client   *http.Client
+	retailer models.FlyerRetailer
+	pdfs     map[string][]byte // by SourceFlyer.URL, from the last ListFlyers
+}
+
+func NewPDFSource(retailer models.FlyerRetailer) *PDFSource {
+	return &PDFSource{
+		client:   &http.Client{Timeout: time.Minute},
+		retailer: retailer,
+		pdfs:     map[string][]byte{},
+	}
+}
+
+func (s *PDFSource) Name() string { return "pdf:" + s.retailer.Name }
+
+func (s *PDFSource) ListFlyers(ctx context.Context) ([]SourceFlyer, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.retailer.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch flyer PDF: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bad status code: %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read flyer PDF: %w", err)
+	}
+	if !isPDF(data) {
+		return nil, fmt.Errorf("%s is not a PDF", s.retailer.URL)
+	}
+
+	url := fmt.Sprintf("%s#sha256=%x", s.retailer.URL, sha256.Sum256(data))
+	s.pdfs[url] = data
+	return []SourceFlyer{{ShopName: s.retailer.Name, URL: url, Title: s.retailer.Name}}, nil
+}
+
+func (s *PDFSource) FetchPages(ctx context.Context, flyer SourceFlyer) ([]SourcePage, error) {
+	data, ok := s.pdfs[flyer.URL]
+	if !ok {
+		return nil, fmt.Errorf("flyer %s was not listed by this source", flyer.URL)
+	}
+	return pdfPages(data, s.retailer.URL)
+}
+
+// HotFolderSource picks up flyers dropped into a folder. A PDF or image in
+// Dir/<shop>/ is a flyer of that shop, and so is a folder Dir/<shop>/<name>/
+// whose PDFs and images, in name order, are its pages. Flyers are known by
+// their content, so files may stay where they are; the same flyer dropped
+// again, under any name, is stored once.
+type HotFolderSource struct {
+	Dir   string
+	files map[string][]string // by SourceFlyer.URL, from the last ListFlyers
+}
+
+func NewHotFolderSource(dir string) *HotFolderSource {
+	return &HotFolderSource{Dir: dir, files: map[string][]string{}}
+}
+
+func (s *HotFolderSource) Name() string { return "folder:" + s.Dir }
+
+func (s *HotFolderSource) ListFlyers(ctx context.Context) ([]SourceFlyer, error) {
+	shops, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hot folder: %w", err)
+	}
+
+	var flyers []SourceFlyer
+	for _, shop := range shops {
+		if !shop.IsDir() || strings.HasPrefix(shop.Name(), ".") {
+			continue
+		}
+		shopDir := filepath.Join(s.Dir, shop.Name())
+		entries, err := os.ReadDir(shopDir)
+		if err != nil {
+			slog.Error("Failed to read hot folder shop", "dir", shopDir, "error", err)
+			continue
+		}
+		for _, entry := range entries {
+			path := filepath.Join(shopDir, entry.Name())
+			var files []string
+			switch {
+			case strings.HasPrefix(entry.Name(), "."):
+				continue
+			case entry.IsDir():
+				files = flyerFiles(path)
+			case isFlyerFile(entry.Name()):
+				files = []string{path}
+			}
+			if len(files) == 0 {
+				continue
+			}
+
+			hash := sha256.New()
+			for _, f := range files {
+				// A file changed in the last minute may still be being copied;
+				// the next run picks it up.
+				info, err := os.Stat(f)
+				if err != nil || time.Since(info.ModTime()) < time.Minute {
+					files = nil
+					break
+				}
+				data, err := os.ReadFile(f)
+				if err != nil {
+					slog.Warn("Skipping unreadable hot folder file", "path", f, "error", err)
+					files = nil
+					break
+				}
+				hash.Write(data)
+			}
+			if files == nil {
+				continue
+			}
+
+			url := fmt.Sprintf("folder:%s#sha256=%x", shop.Name(), hash.Sum(nil))
+			s.files[url] = files
+			flyers = append(flyers, SourceFlyer{ShopName: shop.Name(), URL: url, Title: entry.Name()})
+		}
+	}
+	return flyers, nil
+}
+
+func (s *HotFolderSource) FetchPages(ctx context.Context, flyer SourceFlyer) ([]SourcePage, error) {
+	files, ok := s.files[flyer.URL]
+	if !ok {
+		return nil, fmt.Errorf("flyer %s was not listed by this source", flyer.URL)
+	}
+
+	var pages []SourcePage
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", f, err)
+		}
+		filePages, err := flyerFilePages(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split %s: %w", f, err)
+		}
+		pages = append(pages, filePages...)
+	}
+	return pages, nil
+}
+
+// UploadSource is a flyer uploaded for a shop: PDFs and page images, in
+// order. Like the hot folder, it is known by its content. Its PDFs are stored
+// whole and split into pages by the page job, so the upload does not wait for
+// the rendering.
+type UploadSource struct {
+	shop  string
+	url   string
+	files [][]byte
+}
+
+func NewUploadSource(shop string, files [][]byte) *UploadSource {
+	hash := sha256.New()
+	for _, f := range files {
+		hash.Write(f)
+	}
+	return &UploadSource{
+		shop:  shop,
+		url:   fmt.Sprintf("upload:%s#sha256=%x", shop, hash.Sum(nil)),
+		files: files,
+	}
+}
+
+func (s *UploadSource) Name() string { return "upload:" + s.shop }
+
+// URL is the URL the uploaded flyer is stored under.
+func (s *UploadSource) URL() string { return s.url }
+
+func (s *UploadSource) ListFlyers(ctx context.Context) ([]SourceFlyer, error) {
+	return []SourceFlyer{{ShopName: s.shop, URL: s.url, Title: "upload"}}, nil
+}
+
+func (s *UploadSource) FetchPages(ctx context.Context, flyer SourceFlyer) ([]SourcePage, error) {
+	pages := make([]SourcePage, 0, len(s.files))
+	for _, data := range s.files {
+		pages = append(pages, SourcePage{Data: data})
+	}
+	return pages, nil
+}
+
+// flyerFiles lists the PDFs and images directly in dir, by name.
+func flyerFiles(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && !strings.HasPrefix(e.Name(), ".") && isFlyerFile(e.Name()) {
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+func isFlyerFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".pdf", ".jpg", ".jpeg", ".png":
+		return true
+	}
+	return false
+}
+
+func isPDF(data []byte) bool {
+	return len(data) > 4 && string(data[:4]) == "%PDF"
+}
+
+// flyerFilePages returns the pages of a flyer file: each page of a PDF, or
+// the file itself for an image.
+func flyerFilePages(data []byte) ([]SourcePage, error) {
+	if !isPDF(data) {
+		return []SourcePage{{Data: data}}, nil
+	}
+	return pdfPages(data, "")
+}
+
+// pdfPages renders each page of a PDF to a PNG page.
+func pdfPages(data []byte, sourceURL string) ([]SourcePage, error) {
+	tempDir, err := os.MkdirTemp("", "flyer-pdf-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	files, err := SplitPDF(data, tempDir)
+	if err != nil {
+		return nil, err
+	}
+	pages := make([]SourcePage, 0, len(files))
+	for _, f := range files {
+		png, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, SourcePage{SourceURL: sourceURL, Data: png})
+	}
+	return pages, nil
+}
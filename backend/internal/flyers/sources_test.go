@@ -0,0 +1,148 @@
+package flyers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"kincart/internal/models"
+)
+
+func pngBytes(t *testing.T, width int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, 1))))
+	return buf.Bytes()
+}
+
+// dropFile writes a file into the hot folder as if it had been copied there a
+// while ago.
+func dropFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.NoError(t, os.WriteFile(path, data, 0644))
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(path, old, old))
+}
+
+func TestDownloadFromHotFolder(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Flyer{}, &models.FlyerPage{}))
+	t.Setenv("UPLOADS_PATH", t.TempDir())
+	m := NewManager(db, nil)
+	ctx := context.Background()
+
+	hot := t.TempDir()
+	dropFile(t, filepath.Join(hot, "penny", "weekend.png"), pngBytes(t, 1))
+	dropFile(t, filepath.Join(hot, "penny", "week-12", "02.png"), pngBytes(t, 3))
+	dropFile(t, filepath.Join(hot, "penny", "week-12", "01.png"), pngBytes(t, 2))
+	dropFile(t, filepath.Join(hot, "penny", "notes.txt"), []byte("not a flyer"))
+	dropFile(t, filepath.Join(hot, "stray.png"), pngBytes(t, 4))
+	// Still being copied.
+	require.NoError(t, os.WriteFile(filepath.Join(hot, "penny", "fresh.png"), pngBytes(t, 5), 0644))
+
+	require.NoError(t, m.DownloadFrom(ctx, NewHotFolderSource(hot)))
+
+	var flyers []models.Flyer
+	require.NoError(t, db.Preload("Pages").Order("id").Find(&flyers).Error)
+	require.Len(t, flyers, 2)
+	for _, f := range flyers {
+		assert.Equal(t, "penny", f.ShopName)
+	}
+
+	folder, single := flyers[0], flyers[1]
+	if len(folder.Pages) < len(single.Pages) {
+		folder, single = single, folder
+	}
+	require.Len(t, folder.Pages, 2)
+	first, err := os.ReadFile(folder.Pages[0].LocalPath)
+	require.NoError(t, err)
+	assert.Equal(t, pngBytes(t, 2), first, "pages of a flyer folder are taken in name order")
+	assert.Equal(t, ".png", filepath.Ext(folder.Pages[0].LocalPath))
+	assert.Len(t, single.Pages, 1)
+
+	t.Run("the same content is stored once", func(t *testing.T) {
+		dropFile(t, filepath.Join(hot, "penny", "weekend-copy.png"), pngBytes(t, 1))
+		require.NoError(t, m.DownloadFrom(ctx, NewHotFolderSource(hot)))
+
+		var count int64
+		db.Model(&models.Flyer{}).Count(&count)
+		assert.Equal(t, int64(2), count, "a renamed copy is the same flyer")
+		db.Model(&models.FlyerPage{}).Count(&count)
+		assert.Equal(t, int64(3), count)
+	})
+}
+
+// pdfBytes builds a PDF of blank pages.
+func pdfBytes(t *testing.T, pages int) []byte {
+	t.Helper()
+	objects := []string{"<< /Type /Catalog /Pages 2 0 R >>", ""}
+	kids := ""
+	for i := 0; i < pages; i++ {
+		kids += fmt.Sprintf("%d 0 R ", 3+i)
+		objects = append(objects, "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 20 20] >>")
+	}
+	objects[1] = fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids, pages)
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+func TestStoreUploadSplitsPDFInPageJob(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Flyer{}, &models.FlyerPage{}, &models.Job{}))
+	t.Setenv("UPLOADS_PATH", t.TempDir())
+	m := NewManager(db, nil)
+	ctx := context.Background()
+
+	flyer, pages, err := m.StoreUpload(ctx, NewUploadSource("penny", [][]byte{pdfBytes(t, 2)}))
+	require.NoError(t, err)
+	assert.Equal(t, 1, pages, "the PDF is stored whole")
+
+	var pdfPage models.FlyerPage
+	require.NoError(t, db.Where("flyer_id = ?", flyer.ID).First(&pdfPage).Error)
+	assert.Equal(t, ".pdf", filepath.Ext(pdfPage.LocalPath))
+	var job models.Job
+	require.NoError(t, db.Where("kind = ? AND ref = ?", JobFlyerPage, fmt.Sprint(pdfPage.ID)).First(&job).Error)
+
+	require.NoError(t, m.ProcessPageJob(ctx, job))
+
+	var split []models.FlyerPage
+	require.NoError(t, db.Where("flyer_id = ?", flyer.ID).Order("id").Find(&split).Error)
+	require.Len(t, split, 2, "the page job splits the PDF into its pages")
+	for _, p := range split {
+		assert.Equal(t, ".png", filepath.Ext(p.LocalPath))
+		assert.FileExists(t, p.LocalPath)
+	}
+	assert.NoFileExists(t, pdfPage.LocalPath)
+
+	var queued int64
+	db.Model(&models.Job{}).Where("kind = ? AND ref IN ?", JobFlyerPage,
+		[]string{fmt.Sprint(split[0].ID), fmt.Sprint(split[1].ID)}).Count(&queued)
+	assert.Equal(t, int64(2), queued, "the new pages are queued for parsing")
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"kincart/internal/database"
+	"kincart/internal/services"
+)
+
+// defaultAnalyticsMonths is how far back reports reach without ?from.
+const defaultAnalyticsMonths = 12
+
+// parseAnalyticsRange reads ?from and ?to (YYYY-MM-DD, both inclusive). The
+// default is the last twelve calendar months up to today.
+func parseAnalyticsRange(c *gin.Context) (services.AnalyticsRange, bool) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	r := services.AnalyticsRange{
+		From: time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -(defaultAnalyticsMonths - 1), 0),
+		To:   today.AddDate(0, 0, 1),
+	}
+
+	if s := c.Query("from"); s != "" {
+		from, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date, expected YYYY-MM-DD"})
+			return r, false
+		}
+		r.From = from
+	}
+	if s := c.Query("to"); s != "" {
+		to, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date, expected YYYY-MM-DD"})
+			return r, false
+		}
+		r.To = to.AddDate(0, 0, 1)
+	}
+	if !r.From.Before(r.To) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must not be after to"})
+		return r, false
+	}
+	return r, true
+}
+
+// GetSpendingAnalytics reports spend per month, shop and category.
+// GET /api/family/analytics/spending?from=YYYY-MM-DD&to=YYYY-MM-DD
+func GetSpendingAnalytics(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+	r, ok := parseAnalyticsRange(c)
+	if !ok {
+		return
+	}
+
+	report, err := services.SpendingAnalytics(c.Request.Context(), database.DB, familyID, r)
+	if err != nil {
+		slog.Error("Failed to compute spending analytics", "family_id", familyID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute analytics"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// GetPriceTrendAnalytics reports the unit prices paid for a planned item name.
+// GET /api/family/analytics/price-trend?name=jogurt&from=...&to=...
+func GetPriceTrendAnalytics(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+	name := c.Query("name")
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name parameter is required"})
+		return
+	}
+	r, ok := parseAnalyticsRange(c)
+	if !ok {
+		return
+	}
+
+	trend, err := services.PriceTrendAnalytics(c.Request.Context(), database.DB, familyID, name, r)
+	if err != nil {
+		slog.Error("Failed to compute price trend", "family_id", familyID, "name", name, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute analytics"})
+		return
+	}
+	c.JSON(http.StatusOK, trend)
+}
+
+// GetBasketAnalytics reports the average receipt total and line count.
+// GET /api/family/analytics/basket?from=...&to=...
+func GetBasketAnalytics(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+	r, ok := parseAnalyticsRange(c)
+	if !ok {
+		return
+	}
+
+	report, err := services.BasketAnalytics(c.Request.Context(), database.DB, familyID, r)
+	if err != nil {
+		slog.Error("Failed to compute basket analytics", "family_id", familyID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute analytics"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
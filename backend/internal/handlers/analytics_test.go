@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"kincart/internal/database"
+	"kincart/internal/models"
+	"kincart/internal/services"
+)
+
+func TestAnalyticsHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var err error
+	database.DB, err = gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, database.DB.AutoMigrate(&models.Receipt{}, &models.ReceiptItem{}, &models.Shop{},
+		&models.Item{}, &models.ItemAlias{}, &models.Category{}))
+
+	familyID := uuid.New()
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("family_id", familyID)
+		c.Next()
+	})
+	r.GET("/family/analytics/spending", GetSpendingAnalytics)
+	r.GET("/family/analytics/price-trend", GetPriceTrendAnalytics)
+	r.GET("/family/analytics/basket", GetBasketAnalytics)
+
+	tests := []struct {
+		name           string
+		url            string
+		expectedStatus int
+	}{
+		{name: "spending with default range", url: "/family/analytics/spending", expectedStatus: http.StatusOK},
+		{name: "spending with range", url: "/family/analytics/spending?from=2026-01-01&to=2026-03-31", expectedStatus: http.StatusOK},
+		{name: "bad from date", url: "/family/analytics/spending?from=01/01/2026", expectedStatus: http.StatusBadRequest},
+		{name: "from after to", url: "/family/analytics/basket?from=2026-03-01&to=2026-01-01", expectedStatus: http.StatusBadRequest},
+		{name: "price trend needs a name", url: "/family/analytics/price-trend", expectedStatus: http.StatusBadRequest},
+		{name: "price trend", url: "/family/analytics/price-trend?name=jogurt", expectedStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedStatus, w.Code, w.Body.String())
+		})
+	}
+
+	t.Run("to is inclusive", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/family/analytics/spending?from=2026-01-01&to=2026-01-31", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var report services.SpendingReport
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+		assert.Equal(t, "2026-02-01", report.To.Format("2006-01-02"))
+	})
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
 	"time"
@@ -8,12 +9,14 @@ import (
 	"kincart/internal/database"
 	"kincart/internal/middleware"
 	"kincart/internal/models"
+	"kincart/internal/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/ya-breeze/kin-core/auth"
 	"github.com/ya-breeze/kin-core/authdb"
 	"github.com/ya-breeze/kin-core/cookies"
+	"gorm.io/gorm"
 )
 
 const (
@@ -48,32 +51,47 @@ func Login(c *gin.Context) {
 		return
 	}
 
+	if !startSession(c, &user) {
+		return
+	}
+
+	slog.Info("Successful login", "username", req.Username, "ip", c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{"user": sessionUser(&user)})
+}
+
+// startSession issues the access and refresh cookies for user. On failure it
+// writes the error response and returns false.
+func startSession(c *gin.Context, user *models.User) bool {
 	familyID := user.FamilyID
 	accessToken, err := auth.GenerateAccessToken(user.ID, &familyID, middleware.JWTSecret, accessTokenTTL)
 	if err != nil {
-		slog.Error("Failed to generate access token", "username", req.Username, "error", err)
+		slog.Error("Failed to generate access token", "username", user.Username, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-		return
+		return false
 	}
 
 	rt, err := authdb.CreateRefreshToken(database.DB, user.ID, refreshTokenTTL)
 	if err != nil {
-		slog.Error("Failed to create refresh token", "username", req.Username, "error", err)
+		slog.Error("Failed to create refresh token", "username", user.Username, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
-		return
+		return false
 	}
 
+	recordSession(c, rt)
+
 	cookies.SetAccessCookie(c.Writer, accessToken, int(accessTokenTTL.Seconds()), middleware.CookieConfig)
 	cookies.SetRefreshCookie(c.Writer, rt.Token, int(refreshTokenTTL.Seconds()), middleware.CookieConfig)
+	return true
+}
 
-	slog.Info("Successful login", "username", req.Username, "ip", c.ClientIP())
-	c.JSON(http.StatusOK, gin.H{
-		"user": gin.H{
-			"id":        user.ID,
-			"username":  user.Username,
-			"family_id": user.FamilyID,
-		},
-	})
+// sessionUser is the user summary returned by Login and Signup.
+func sessionUser(user *models.User) gin.H {
+	return gin.H{
+		"id":        user.ID,
+		"username":  user.Username,
+		"family_id": user.FamilyID,
+		"role":      user.Role,
+	}
 }
 
 func Logout(c *gin.Context) {
@@ -92,6 +110,7 @@ func Logout(c *gin.Context) {
 	// Revoke the refresh token
 	refreshToken := cookies.GetRefreshToken(c.Request)
 	if refreshToken != "" {
+		endSession(refreshToken)
 		if err := authdb.RevokeRefreshToken(database.DB, refreshToken); err != nil {
 			slog.Warn("Failed to revoke refresh token", "error", err)
 		}
@@ -136,6 +155,8 @@ func Refresh(c *gin.Context) {
 		return
 	}
 
+	rotateSession(c, tokenString, newRT)
+
 	cookies.SetAccessCookie(c.Writer, accessToken, int(accessTokenTTL.Seconds()), middleware.CookieConfig)
 	cookies.SetRefreshCookie(c.Writer, newRT.Token, int(refreshTokenTTL.Seconds()), middleware.CookieConfig)
 
@@ -153,3 +174,131 @@ func GetMe(c *gin.Context) {
 
 	c.JSON(http.StatusOK, user)
 }
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// ChangePassword replaces the caller's password and signs out every other
+// session; the refresh token of the session making the change is kept.
+// POST /api/auth/password
+func ChangePassword(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.NewPassword) < minPasswordLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password must be at least 8 characters"})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.First(&user, "id = ?", userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	if !auth.VerifyPassword(req.CurrentPassword, user.PasswordHash) {
+		slog.Warn("Password change with wrong current password", "username", user.Username, "ip", c.ClientIP())
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Current password is incorrect"})
+		return
+	}
+
+	hash, err := auth.HashPassword(req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	current := cookies.GetRefreshToken(c.Request)
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("password_hash", hash).Error; err != nil {
+			return err
+		}
+		return tx.Model(&authdb.RefreshToken{}).
+			Where("user_id = ? AND is_revoked = ? AND token <> ?", user.ID, false, current).
+			Update("is_revoked", true).Error
+	})
+	if err != nil {
+		slog.Error("Failed to change password", "username", user.Username, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to change password"})
+		return
+	}
+
+	slog.Info("Password changed", "username", user.Username, "ip", c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed"})
+}
+
+var errResetTokenInvalid = errors.New("invalid or expired reset token")
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// ResetPassword sets a new password using a reset token from the admin CLI.
+// The token works once, and every existing session of the user is revoked.
+// POST /api/auth/password/reset
+func ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(req.NewPassword) < minPasswordLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password must be at least 8 characters"})
+		return
+	}
+
+	hash, err := auth.HashPassword(req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	var userID uuid.UUID
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var rt models.PasswordResetToken
+		if err := tx.Where("token_hash = ?", utils.HashSecretToken(req.Token)).First(&rt).Error; err != nil {
+			return errResetTokenInvalid
+		}
+
+		now := time.Now()
+		// Conditional claim, so two concurrent resets cannot both use the token.
+		res := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL AND expires_at > ?", rt.ID, now).
+			Update("used_at", now)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errResetTokenInvalid
+		}
+
+		upd := tx.Model(&models.User{}).Where("id = ?", rt.UserID).Update("password_hash", hash)
+		if upd.Error != nil {
+			return upd.Error
+		}
+		if upd.RowsAffected == 0 {
+			return errResetTokenInvalid
+		}
+		userID = rt.UserID
+		return authdb.RevokeAllUserTokens(tx, rt.UserID)
+	})
+	if errors.Is(err, errResetTokenInvalid) {
+		slog.Warn("Password reset with invalid token", "ip", c.ClientIP())
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired reset token"})
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to reset password", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset password"})
+		return
+	}
+
+	slog.Info("Password reset", "user_id", userID, "ip", c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset"})
+}
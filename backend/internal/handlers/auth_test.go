@@ -10,6 +10,7 @@ import (
 
 	"kincart/internal/database"
 	"kincart/internal/models"
+	"kincart/internal/utils"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -30,7 +31,7 @@ func TestLogin(t *testing.T) {
 		t.Fatalf("Failed to connect to database: %v", err)
 	}
 
-	err = database.DB.AutoMigrate(&models.Family{}, &models.User{}, &authdb.RefreshToken{})
+	err = database.DB.AutoMigrate(&models.Family{}, &models.User{}, &authdb.RefreshToken{}, &models.Session{})
 	if err != nil {
 		t.Fatalf("Failed to migrate database: %v", err)
 	}
@@ -122,7 +123,7 @@ func TestRefresh(t *testing.T) {
 		t.Fatalf("Failed to connect to database: %v", err)
 	}
 
-	database.DB.AutoMigrate(&models.Family{}, &models.User{}, &authdb.RefreshToken{})
+	database.DB.AutoMigrate(&models.Family{}, &models.User{}, &authdb.RefreshToken{}, &models.Session{})
 
 	family := models.Family{Family: coremodels.Family{ID: uuid.New(), Name: "TestFamily"}}
 	database.DB.Create(&family)
@@ -215,3 +216,110 @@ func TestRefresh(t *testing.T) {
 		})
 	}
 }
+
+func TestChangePassword(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var err error
+	database.DB, err = gorm.Open(sqlite.Open("file:testchangepw?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	database.DB.AutoMigrate(&models.Family{}, &models.User{}, &authdb.RefreshToken{}, &models.Session{})
+
+	hash, _ := auth.HashPassword("oldpassword")
+	user := models.User{User: coremodels.User{ID: uuid.New(), Username: "pwuser", PasswordHash: hash, FamilyID: uuid.New()}}
+	database.DB.Create(&user)
+	for _, tok := range []string{"this-device", "lost-phone"} {
+		database.DB.Create(&authdb.RefreshToken{ID: uuid.New(), UserID: user.ID, Token: tok, ExpiresAt: time.Now().Add(time.Hour)})
+	}
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user_id", user.ID)
+		c.Next()
+	})
+	r.POST("/auth/password", ChangePassword)
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{name: "wrong current password", body: `{"current_password":"nope","new_password":"newpassword"}`, expectedStatus: http.StatusUnauthorized},
+		{name: "new password too short", body: `{"current_password":"oldpassword","new_password":"short"}`, expectedStatus: http.StatusBadRequest},
+		{name: "success", body: `{"current_password":"oldpassword","new_password":"newpassword"}`, expectedStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, "/auth/password", bytes.NewBufferString(tt.body))
+			req.AddCookie(&http.Cookie{Name: "kin_refresh", Value: "this-device"})
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+
+	var stored models.User
+	database.DB.First(&stored, "id = ?", user.ID)
+	assert.True(t, auth.VerifyPassword("newpassword", stored.PasswordHash))
+
+	var kept, lost authdb.RefreshToken
+	database.DB.Where("token = ?", "this-device").First(&kept)
+	database.DB.Where("token = ?", "lost-phone").First(&lost)
+	assert.False(t, kept.IsRevoked, "the session changing the password stays signed in")
+	assert.True(t, lost.IsRevoked, "other sessions are signed out")
+}
+
+func TestResetPassword(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var err error
+	database.DB, err = gorm.Open(sqlite.Open("file:testresetpw?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+	database.DB.AutoMigrate(&models.Family{}, &models.User{}, &models.PasswordResetToken{}, &authdb.RefreshToken{})
+
+	user := models.User{User: coremodels.User{ID: uuid.New(), Username: "forgetful", FamilyID: uuid.New()}}
+	database.DB.Create(&user)
+	database.DB.Create(&authdb.RefreshToken{ID: uuid.New(), UserID: user.ID, Token: "old-session", ExpiresAt: time.Now().Add(time.Hour)})
+
+	token, tokenHash, _ := utils.NewSecretToken()
+	database.DB.Create(&models.PasswordResetToken{ID: uuid.New(), UserID: user.ID, TokenHash: tokenHash, ExpiresAt: time.Now().Add(time.Hour)})
+	expired, expiredHash, _ := utils.NewSecretToken()
+	database.DB.Create(&models.PasswordResetToken{ID: uuid.New(), UserID: user.ID, TokenHash: expiredHash, ExpiresAt: time.Now().Add(-time.Hour)})
+
+	r := gin.New()
+	r.POST("/auth/password/reset", ResetPassword)
+
+	tests := []struct {
+		name           string
+		token          string
+		expectedStatus int
+	}{
+		{name: "unknown token", token: "nope", expectedStatus: http.StatusBadRequest},
+		{name: "expired token", token: expired, expectedStatus: http.StatusBadRequest},
+		{name: "valid token", token: token, expectedStatus: http.StatusOK},
+		{name: "token already used", token: token, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(ResetPasswordRequest{Token: tt.token, NewPassword: "brandnewpassword"})
+			req, _ := http.NewRequest(http.MethodPost, "/auth/password/reset", bytes.NewBuffer(body))
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+
+	var stored models.User
+	database.DB.First(&stored, "id = ?", user.ID)
+	assert.True(t, auth.VerifyPassword("brandnewpassword", stored.PasswordHash))
+
+	var session authdb.RefreshToken
+	database.DB.Where("token = ?", "old-session").First(&session)
+	assert.True(t, session.IsRevoked, "a reset signs the user out everywhere")
+}
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	coremodels "github.com/ya-breeze/kin-core/models"
+	"gorm.io/gorm"
+
+	"kincart/internal/database"
+	"kincart/internal/models"
+	"kincart/internal/notify"
+	"kincart/internal/services"
+)
+
+type budgetRequest struct {
+	Month      string     `json:"month"`
+	CategoryID *uuid.UUID `json:"category_id"`
+	Amount     float64    `json:"amount"`
+}
+
+// GetBudgets lists the family's budgets, standing ones (empty month) first.
+// GET /api/budgets
+func GetBudgets(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+
+	var budgets []models.Budget
+	if err := database.DB.Preload("Category").Where("family_id = ?", familyID).
+		Order("month ASC, created_at ASC").Find(&budgets).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch budgets"})
+		return
+	}
+	c.JSON(http.StatusOK, budgets)
+}
+
+// SetBudget creates the budget for a month (or every month, when month is
+// empty) and scope (overall, or category_id), replacing the amount if one
+// already exists.
+// POST /api/budgets
+func SetBudget(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+
+	var req budgetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Month = strings.TrimSpace(req.Month)
+	if req.Month != "" {
+		if _, err := services.ParseBudgetMonth(req.Month); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if req.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount must be positive"})
+		return
+	}
+	if req.CategoryID != nil {
+		var cat models.Category
+		if err := database.DB.Where("id = ? AND family_id = ?", *req.CategoryID, familyID).First(&cat).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid category ID"})
+			return
+		}
+	}
+
+	q := database.DB.Where("family_id = ? AND month = ?", familyID, req.Month)
+	if req.CategoryID == nil {
+		q = q.Where("category_id IS NULL")
+	} else {
+		q = q.Where("category_id = ?", *req.CategoryID)
+	}
+
+	var budget models.Budget
+	err := q.First(&budget).Error
+	switch {
+	case err == nil:
+		if err := database.DB.Model(&budget).Update("amount", req.Amount).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update budget"})
+			return
+		}
+		c.JSON(http.StatusOK, budget)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		budget = models.Budget{
+			TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID},
+			Month:       req.Month,
+			CategoryID:  req.CategoryID,
+			Amount:      req.Amount,
+		}
+		if err := database.DB.Create(&budget).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create budget"})
+			return
+		}
+		c.JSON(http.StatusCreated, budget)
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch budget"})
+	}
+}
+
+// UpdateBudget changes a budget's amount.
+// PATCH /api/budgets/:id
+func UpdateBudget(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+
+	var req struct {
+		Amount float64 `json:"amount"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Amount <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "amount must be positive"})
+		return
+	}
+
+	var budget models.Budget
+	if err := database.DB.Where("id = ? AND family_id = ?", c.Param("id"), familyID).First(&budget).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Budget not found"})
+		return
+	}
+	if err := database.DB.Model(&budget).Update("amount", req.Amount).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update budget"})
+		return
+	}
+	c.JSON(http.StatusOK, budget)
+}
+
+// DeleteBudget removes a budget.
+// DELETE /api/budgets/:id
+func DeleteBudget(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+
+	res := database.DB.Where("id = ? AND family_id = ?", c.Param("id"), familyID).Delete(&models.Budget{})
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete budget"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Budget not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Budget deleted"})
+}
+
+// GetBudgetStatus reports spent, remaining and projected overspend for every
+// budget in force. ?month=YYYY-MM defaults to the current month.
+// GET /api/budgets/status
+func GetBudgetStatus(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+
+	now := time.Now()
+	month := now
+	if m := c.Query("month"); m != "" {
+		parsed, err := services.ParseBudgetMonth(m)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		month = parsed
+	}
+
+	status, err := services.ComputeBudgetStatus(c.Request.Context(), database.DB, familyID, month, now)
+	if err != nil {
+		slog.Error("Failed to compute budget status", "family_id", familyID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compute budget status"})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// CheckListBudget tells whether the list's estimate fits what is left of this
+// month's budgets.
+// GET /api/lists/:id/budget-check
+func CheckListBudget(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+	listID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid list ID"})
+		return
+	}
+
+	check, err := services.CheckListBudget(c.Request.Context(), database.DB, familyID, listID, time.Now())
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "List not found"})
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to check list budget", "list_id", listID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check budget"})
+		return
+	}
+	c.JSON(http.StatusOK, check)
+}
+
+// warnIfOverBudget notifies the whole family, the shopper included, when a list
+// about to be shopped would exceed what is left of this month's budgets.
+func warnIfOverBudget(c *gin.Context, familyID uuid.UUID, list *models.ShoppingList) {
+	check, err := services.CheckListBudget(c.Request.Context(), database.DB, familyID, list.ID, time.Now())
+	if err != nil {
+		slog.Warn("Failed to check list budget", "list_id", list.ID, "error", err)
+		return
+	}
+	if check.Fits {
+		return
+	}
+
+	var parts []string
+	for _, l := range check.Lines {
+		if l.Over <= 0 {
+			continue
+		}
+		scope := "overall budget"
+		if l.CategoryName != "" {
+			scope = l.CategoryName
+		}
+		parts = append(parts, fmt.Sprintf("%s over by %.2f", scope, l.Over))
+	}
+
+	msg := notify.Message{
+		Kind:  notify.KindBudgetWarning,
+		Title: fmt.Sprintf("\"%s\" is over budget", list.Title),
+		Body:  strings.Join(parts, "; "),
+		Link:  "/lists/" + list.ID.String(),
+	}
+	if _, err := notify.For(database.DB).NotifyFamily(c.Request.Context(), familyID, msg, nil); err != nil {
+		slog.Warn("Failed to notify about budget", "list_id", list.ID, "error", err)
+	}
+}
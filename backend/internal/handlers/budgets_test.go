@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coremodels "github.com/ya-breeze/kin-core/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"kincart/internal/database"
+	"kincart/internal/models"
+	"kincart/internal/notify"
+	"kincart/internal/services"
+)
+
+func TestBudgetHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var err error
+	database.DB, err = gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, database.DB.AutoMigrate(&models.ShoppingList{}, &models.Item{}, &models.Category{},
+		&models.Receipt{}, &models.ReceiptItem{}, &models.Shop{}, &models.ItemAlias{},
+		&models.User{}, &models.Notification{}, &models.Budget{}))
+
+	familyID := uuid.New()
+	user := models.User{User: coremodels.User{ID: uuid.New(), Username: "mum", FamilyID: familyID}}
+	database.DB.Create(&user)
+	dairy := models.Category{TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID}, Name: "Dairy"}
+	database.DB.Create(&dairy)
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("family_id", familyID)
+		c.Set("user_id", user.ID)
+		c.Next()
+	})
+	r.GET("/budgets", GetBudgets)
+	r.POST("/budgets", SetBudget)
+	r.PATCH("/budgets/:id", UpdateBudget)
+	r.DELETE("/budgets/:id", DeleteBudget)
+	r.GET("/budgets/status", GetBudgetStatus)
+	r.GET("/lists/:id/budget-check", CheckListBudget)
+	r.PATCH("/lists/:id", UpdateList)
+
+	t.Run("validation", func(t *testing.T) {
+		tests := []struct {
+			name string
+			body string
+		}{
+			{name: "bad month", body: `{"month":"03/2026","amount":100}`},
+			{name: "zero amount", body: `{"amount":0}`},
+			{name: "foreign category", body: fmt.Sprintf(`{"category_id":"%s","amount":100}`, uuid.New())},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				w := doJSON(r, http.MethodPost, "/budgets", tt.body)
+				assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+			})
+		}
+	})
+
+	t.Run("set is an upsert per month and scope", func(t *testing.T) {
+		w := doJSON(r, http.MethodPost, "/budgets", `{"amount":1000}`)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var first models.Budget
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &first))
+
+		w = doJSON(r, http.MethodPost, "/budgets", `{"amount":50}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var second models.Budget
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &second))
+		assert.Equal(t, first.ID, second.ID)
+		assert.Equal(t, 50.0, second.Amount)
+
+		w = doJSON(r, http.MethodPost, "/budgets", fmt.Sprintf(`{"category_id":"%s","amount":20}`, dairy.ID))
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var budgets []models.Budget
+		w = doJSON(r, http.MethodGet, "/budgets", "")
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &budgets))
+		assert.Len(t, budgets, 2)
+	})
+
+	t.Run("status", func(t *testing.T) {
+		w := doJSON(r, http.MethodGet, "/budgets/status?month=2026-13", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = doJSON(r, http.MethodGet, "/budgets/status", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var status services.BudgetStatus
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+		require.NotNil(t, status.Overall)
+		assert.Equal(t, 50.0, status.Overall.Amount)
+		assert.Len(t, status.Categories, 1)
+	})
+
+	list := models.ShoppingList{
+		TenantModel:     coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID},
+		Title:           "Big shop",
+		Status:          "preparing",
+		EstimatedAmount: 80,
+	}
+	database.DB.Create(&list)
+
+	t.Run("list check", func(t *testing.T) {
+		w := doJSON(r, http.MethodGet, "/lists/"+uuid.New().String()+"/budget-check", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = doJSON(r, http.MethodGet, "/lists/"+list.ID.String()+"/budget-check", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var check services.ListBudgetCheck
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &check))
+		assert.False(t, check.Fits)
+		require.Len(t, check.Lines, 1)
+		assert.Equal(t, 30.0, check.Lines[0].Over)
+	})
+
+	t.Run("marking a list ready warns when over budget", func(t *testing.T) {
+		w := doJSON(r, http.MethodPatch, "/lists/"+list.ID.String(), `{"status":"ready for shopping"}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var notes []models.Notification
+		database.DB.Where("user_id = ? AND kind = ?", user.ID, notify.KindBudgetWarning).Find(&notes)
+		require.Len(t, notes, 1)
+		assert.Contains(t, notes[0].Body, "over by 30.00")
+
+		// Saving it again in the same status does not warn twice.
+		w = doJSON(r, http.MethodPatch, "/lists/"+list.ID.String(), `{"status":"ready for shopping"}`)
+		require.Equal(t, http.StatusOK, w.Code)
+		database.DB.Where("user_id = ? AND kind = ?", user.ID, notify.KindBudgetWarning).Find(&notes)
+		assert.Len(t, notes, 1)
+	})
+
+	t.Run("update and delete", func(t *testing.T) {
+		var b models.Budget
+		require.NoError(t, database.DB.Where("family_id = ? AND category_id IS NULL", familyID).First(&b).Error)
+
+		w := doJSON(r, http.MethodPatch, "/budgets/"+b.ID.String(), `{"amount":-1}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w = doJSON(r, http.MethodPatch, "/budgets/"+b.ID.String(), `{"amount":500}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = doJSON(r, http.MethodDelete, "/budgets/"+b.ID.String(), "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = doJSON(r, http.MethodDelete, "/budgets/"+b.ID.String(), "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"kincart/internal/database"
+	"kincart/internal/flyers"
+	"kincart/internal/models"
+)
+
+// flyerRetailerRequest creates a retailer or, with only some fields set,
+// changes those fields of an existing one.
+type flyerRetailerRequest struct {
+	Name    *string  `json:"name"`
+	URL     *string  `json:"url"`
+	Source  *string  `json:"source"`
+	Region  *string  `json:"region"`
+	Include []string `json:"include"`
+	Exclude []string `json:"exclude"`
+	Enabled *bool    `json:"enabled"`
+}
+
+// joinPatterns joins title patterns into the stored comma-separated form.
+func joinPatterns(patterns []string) string {
+	cleaned := make([]string, 0, len(patterns))
+	for _, p := range patterns {
+		if p = strings.TrimSpace(p); p != "" {
+			cleaned = append(cleaned, p)
+		}
+	}
+	return strings.Join(cleaned, ",")
+}
+
+func (r *flyerRetailerRequest) validate() error {
+	if r.Name != nil && strings.TrimSpace(*r.Name) == "" {
+		return fmt.Errorf("name must not be empty")
+	}
+	if r.URL != nil {
+		u, err := url.Parse(strings.TrimSpace(*r.URL))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("url must be an absolute http(s) URL")
+		}
+	}
+	if r.Source != nil && *r.Source != flyers.RetailerSourceAggregator && *r.Source != flyers.RetailerSourcePDF {
+		return fmt.Errorf("source must be %q or %q", flyers.RetailerSourceAggregator, flyers.RetailerSourcePDF)
+	}
+	for _, patterns := range [][]string{r.Include, r.Exclude} {
+		for _, p := range patterns {
+			if strings.Contains(p, ",") {
+				return fmt.Errorf("patterns must not contain commas")
+			}
+		}
+	}
+	return nil
+}
+
+// updates returns the columns the request sets.
+func (r *flyerRetailerRequest) updates() map[string]interface{} {
+	updates := map[string]interface{}{}
+	if r.Name != nil {
+		updates["name"] = strings.TrimSpace(*r.Name)
+	}
+	if r.URL != nil {
+		updates["url"] = strings.TrimSpace(*r.URL)
+	}
+	if r.Source != nil {
+		updates["source"] = *r.Source
+	}
+	if r.Region != nil {
+		updates["region"] = strings.TrimSpace(*r.Region)
+	}
+	if r.Include != nil {
+		updates["include"] = joinPatterns(r.Include)
+	}
+	if r.Exclude != nil {
+		updates["exclude"] = joinPatterns(r.Exclude)
+	}
+	if r.Enabled != nil {
+		updates["enabled"] = *r.Enabled
+	}
+	return updates
+}
+
+// retailerNameTaken reports whether another retailer already uses name. The
+// name ends up on the flyers, so two retailers sharing it would merge shops.
+func retailerNameTaken(name string, exceptID uint) (bool, error) {
+	var count int64
+	err := database.DB.Model(&models.FlyerRetailer{}).
+		Where("LOWER(name) = ? AND id <> ?", strings.ToLower(strings.TrimSpace(name)), exceptID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func GetFlyerRetailers(c *gin.Context) {
+	var retailers []models.FlyerRetailer
+	if err := database.DB.Order("name ASC").Find(&retailers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch flyer retailers"})
+		return
+	}
+
+	c.JSON(http.StatusOK, retailers)
+}
+
+// CreateFlyerRetailer adds a retailer page to the flyer download. It is
+// enabled unless the request says otherwise.
+func CreateFlyerRetailer(c *gin.Context) {
+	var req flyerRetailerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Name == nil || req.URL == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name and url are required"})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if taken, err := retailerNameTaken(*req.Name, 0); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create flyer retailer"})
+		return
+	} else if taken {
+		c.JSON(http.StatusConflict, gin.H{"error": "A retailer with this name already exists"})
+		return
+	}
+
+	retailer := models.FlyerRetailer{Source: flyers.RetailerSourceAggregator, Enabled: true}
+	if req.Source != nil {
+		retailer.Source = *req.Source
+	}
+	if req.Region != nil {
+		retailer.Region = strings.TrimSpace(*req.Region)
+	}
+	if req.Enabled != nil {
+		retailer.Enabled = *req.Enabled
+	}
+	retailer.Name = strings.TrimSpace(*req.Name)
+	retailer.URL = strings.TrimSpace(*req.URL)
+	retailer.Include = joinPatterns(req.Include)
+	retailer.Exclude = joinPatterns(req.Exclude)
+	if err := database.DB.Create(&retailer).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create flyer retailer"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, retailer)
+}
+
+// UpdateFlyerRetailer changes the fields present in the request, e.g.
+// {"enabled": false} to stop downloading a retailer's flyers. Flyers already
+// downloaded keep the shop name they were stored with.
+func UpdateFlyerRetailer(c *gin.Context) {
+	retailer, ok := loadFlyerRetailer(c)
+	if !ok {
+		return
+	}
+
+	var req flyerRetailerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Name != nil {
+		if taken, err := retailerNameTaken(*req.Name, retailer.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update flyer retailer"})
+			return
+		} else if taken {
+			c.JSON(http.StatusConflict, gin.H{"error": "A retailer with this name already exists"})
+			return
+		}
+	}
+
+	if updates := req.updates(); len(updates) > 0 {
+		if err := database.DB.Model(&models.FlyerRetailer{}).Where("id = ?", retailer.ID).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update flyer retailer"})
+			return
+		}
+	}
+	if err := database.DB.First(retailer, retailer.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update flyer retailer"})
+		return
+	}
+
+	c.JSON(http.StatusOK, retailer)
+}
+
+// DeleteFlyerRetailer removes a retailer from the download. Its flyers stay.
+func DeleteFlyerRetailer(c *gin.Context) {
+	retailer, ok := loadFlyerRetailer(c)
+	if !ok {
+		return
+	}
+
+	if err := database.DB.Delete(retailer).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete flyer retailer"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func loadFlyerRetailer(c *gin.Context) (*models.FlyerRetailer, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid id"})
+		return nil, false
+	}
+
+	var retailer models.FlyerRetailer
+	if err := database.DB.First(&retailer, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Flyer retailer not found"})
+		return nil, false
+	}
+	return &retailer, true
+}
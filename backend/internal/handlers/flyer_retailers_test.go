@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"kincart/internal/database"
+	"kincart/internal/flyers"
+	"kincart/internal/models"
+)
+
+func TestFlyerRetailerHandlers(t *testing.T) {
+	var err error
+	database.DB, err = gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, database.DB.AutoMigrate(&models.FlyerRetailer{}))
+	require.NoError(t, flyers.SeedRetailers(database.DB))
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/internal/flyers/retailers", GetFlyerRetailers)
+	r.POST("/internal/flyers/retailers", CreateFlyerRetailer)
+	r.PATCH("/internal/flyers/retailers/:id", UpdateFlyerRetailer)
+	r.DELETE("/internal/flyers/retailers/:id", DeleteFlyerRetailer)
+
+	enabledNames := func(region ...string) []string {
+		retailers, err := flyers.EnabledRetailers(database.DB, strings.Join(region, ""))
+		require.NoError(t, err)
+		names := make([]string, len(retailers))
+		for i, rt := range retailers {
+			names[i] = rt.Name
+		}
+		return names
+	}
+
+	t.Run("defaults are seeded once", func(t *testing.T) {
+		assert.Equal(t, []string{"albert", "billa", "globus", "kaufland", "lidl", "tesco"}, enabledNames())
+
+		var albert models.FlyerRetailer
+		require.NoError(t, database.DB.Where("name = ?", "albert").First(&albert).Error)
+		assert.True(t, flyers.TitleAllowed(albert, "Albert Hypermarket leták"))
+		assert.False(t, flyers.TitleAllowed(albert, "Albert Supermarket leták"))
+	})
+
+	var created models.FlyerRetailer
+	t.Run("create", func(t *testing.T) {
+		w := doJSON(r, http.MethodPost, "/internal/flyers/retailers",
+			`{"name":" penny ","url":"https://www.akcniceny.cz/letaky/penny/","region":"Brno","include":["Týdenní"],"exclude":["Non-food", " "]}`)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+		assert.Equal(t, "penny", created.Name)
+		assert.Equal(t, "Týdenní", created.Include)
+		assert.Equal(t, "Non-food", created.Exclude)
+		assert.True(t, created.Enabled)
+		assert.Equal(t, flyers.RetailerSourceAggregator, created.Source)
+
+		assert.True(t, flyers.TitleAllowed(created, "PENNY týdenní leták"))
+		assert.False(t, flyers.TitleAllowed(created, "Týdenní non-food nabídka"))
+		assert.False(t, flyers.TitleAllowed(created, "Víkendový leták"))
+
+		assert.Equal(t, http.StatusConflict, doJSON(r, http.MethodPost, "/internal/flyers/retailers",
+			`{"name":"Penny","url":"https://example.test/"}`).Code)
+		assert.Equal(t, http.StatusBadRequest, doJSON(r, http.MethodPost, "/internal/flyers/retailers",
+			`{"name":"coop","url":"/letaky/coop/"}`).Code)
+		assert.Equal(t, http.StatusBadRequest, doJSON(r, http.MethodPost, "/internal/flyers/retailers",
+			`{"url":"https://example.test/"}`).Code)
+		assert.Equal(t, http.StatusBadRequest, doJSON(r, http.MethodPost, "/internal/flyers/retailers",
+			`{"name":"coop","url":"https://example.test/","source":"rss"}`).Code)
+		assert.Equal(t, http.StatusBadRequest, doJSON(r, http.MethodPost, "/internal/flyers/retailers",
+			`{"name":"coop","url":"https://example.test/","include":["a,b"]}`).Code)
+	})
+
+	t.Run("region picks the retailers", func(t *testing.T) {
+		assert.Equal(t, []string{"billa", "lidl", "penny", "tesco"}, enabledNames("brno"),
+			"Brno's retailers and the nationwide ones")
+		assert.Equal(t, []string{"albert", "billa", "globus", "kaufland", "lidl", "tesco"}, enabledNames(" Praha "))
+		assert.Len(t, enabledNames(), 7, "no region, every retailer")
+	})
+
+	t.Run("disable and update", func(t *testing.T) {
+		path := fmt.Sprintf("/internal/flyers/retailers/%d", created.ID)
+		w := doJSON(r, http.MethodPatch, path, `{"enabled":false,"exclude":[]}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var got models.FlyerRetailer
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		assert.False(t, got.Enabled)
+		assert.Equal(t, "", got.Exclude)
+		assert.Equal(t, "Týdenní", got.Include, "fields not in the request are kept")
+		assert.NotContains(t, enabledNames(), "penny")
+
+		assert.Equal(t, http.StatusConflict, doJSON(r, http.MethodPatch, path, `{"name":"LIDL"}`).Code)
+		assert.Equal(t, http.StatusNotFound, doJSON(r, http.MethodPatch, "/internal/flyers/retailers/999", `{}`).Code)
+		assert.Equal(t, http.StatusBadRequest, doJSON(r, http.MethodPatch, "/internal/flyers/retailers/abc", `{}`).Code)
+	})
+
+	t.Run("delete stays deleted", func(t *testing.T) {
+		var lidl models.FlyerRetailer
+		require.NoError(t, database.DB.Where("name = ?", "lidl").First(&lidl).Error)
+		assert.Equal(t, http.StatusNoContent, doJSON(r, http.MethodDelete, fmt.Sprintf("/internal/flyers/retailers/%d", lidl.ID), "").Code)
+
+		require.NoError(t, flyers.SeedRetailers(database.DB))
+		assert.NotContains(t, enabledNames(), "lidl", "seeding does not bring back a deleted default")
+
+		w := doJSON(r, http.MethodGet, "/internal/flyers/retailers", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var all []models.FlyerRetailer
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &all))
+		assert.Len(t, all, 6, "five defaults and penny")
+	})
+}
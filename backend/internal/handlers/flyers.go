@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
@@ -14,9 +16,11 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"kincart/internal/ai"
 	"kincart/internal/database"
 	"kincart/internal/flyers"
 	"kincart/internal/models"
+	"kincart/internal/services"
 	"kincart/internal/utils"
 )
 
@@ -60,38 +64,129 @@ func ParseFlyer(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Flyer processing completed"})
 }
 
-func DownloadFlyers(c *gin.Context) {
-	manager := getFlyerManager(c)
-	if manager == nil {
+// maxFlyerUploadFiles caps the files of one uploaded flyer.
+const maxFlyerUploadFiles = 60
+
+// maxFlyerUploadFileSize caps each uploaded file; a flyer PDF of a few dozen
+// pages stays well below it.
+const maxFlyerUploadFileSize = 50 << 20 // 50 MB
+
+// maxFlyerUploadSize caps the whole upload request.
+const maxFlyerUploadSize = 200 << 20 // 200 MB
+
+// UploadFlyer stores a flyer a user uploads for a shop: multipart "flyer"
+// fields holding a PDF or the page images in order, and a "shop" field. The
+// pages are queued for parsing, PDFs to be split into pages first; GET
+// /api/flyers/pages?flyer_id= shows how far it got.
+// POST /api/flyers/upload
+func UploadFlyer(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxFlyerUploadSize)
+
+	form, err := c.MultipartForm()
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Upload larger than %d MB", maxFlyerUploadSize>>20)})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid multipart form"})
+		return
+	}
+
+	var shop string
+	if values := form.Value["shop"]; len(values) > 0 {
+		shop = strings.ToLower(strings.TrimSpace(values[0]))
+	}
+	if shop == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "shop is required"})
+		return
+	}
+	// The shop names the directory the pages are stored in.
+	if strings.ContainsAny(shop, `/\`) || shop == "." || shop == ".." {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid shop name"})
+		return
+	}
+
+	files := form.File["flyer"]
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
+		return
+	}
+	if len(files) > maxFlyerUploadFiles {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d files per flyer", maxFlyerUploadFiles)})
 		return
 	}
 
-	// Start background task
-	go func() {
-		ctx := context.Background()
-		flyers.UpdateJobStatus(database.DB, flyers.FlyerDownloadJobName)
-		if err := manager.FetchAndProcessFlyers(ctx); err != nil {
-			slog.Error("Background flyer download failed", "error", err)
+	data := make([][]byte, 0, len(files))
+	for _, file := range files {
+		if file.Size > maxFlyerUploadFileSize {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("Files must be smaller than %d MB", maxFlyerUploadFileSize>>20), "file": file.Filename})
+			return
+		}
+		src, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+			return
+		}
+		b, err := io.ReadAll(src)
+		src.Close()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
+			return
+		}
+		switch http.DetectContentType(b) {
+		case "application/pdf", "image/jpeg", "image/png":
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Only PDF, JPEG and PNG files are accepted", "file": file.Filename})
+			return
 		}
-	}()
+		data = append(data, b)
+	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Flyer download task started in background"})
+	manager := flyers.NewManager(database.DB, nil)
+	flyer, pages, err := manager.StoreUpload(c.Request.Context(), flyers.NewUploadSource(shop, data))
+	if err != nil {
+		var dup *flyers.DuplicateFlyerError
+		if errors.As(err, &dup) {
+			c.JSON(http.StatusConflict, gin.H{"error": "This flyer has already been uploaded", "existing_flyer_id": dup.ExistingID})
+			return
+		}
+		slog.Error("Failed to store uploaded flyer", "shop", shop, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store flyer"})
+		return
+	}
+
+	slog.Info("Flyer uploaded", "shop", shop, "flyer_id", flyer.ID, "pages", pages, "family_id", c.MustGet("family_id"))
+	c.JSON(http.StatusAccepted, gin.H{"message": "Flyer queued for parsing", "flyer_id": flyer.ID, "pages": pages})
+}
+
+// DownloadFlyers queues a flyer download; the jobs queue runs it, then parses
+// the new pages.
+// POST /api/internal/flyers/download
+func DownloadFlyers(c *gin.Context) {
+	job, err := flyers.EnqueueDownload(database.DB)
+	if err != nil {
+		slog.Error("Failed to queue flyer download", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue flyer download"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Flyer download queued", "job_id": job.ID})
 }
 
 func getFlyerManager(c *gin.Context) *flyers.Manager {
-	geminiKey := os.Getenv("GEMINI_API_KEY")
-	if geminiKey == "" {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gemini API key not configured"})
+	parser, err := ai.NewProvider(c.Request.Context())
+	if errors.Is(err, ai.ErrNoProvider) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "AI provider not configured"})
 		return nil
 	}
-
-	parser, err := flyers.NewParser(geminiKey)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to initialize parser", "details": err.Error()})
 		return nil
 	}
 
 	manager := flyers.NewManager(database.DB, parser)
+	manager.OnNewItems = services.WatchlistHook(database.DB)
 
 	// Set output directory for cropped images
 	flyerItemsPath := os.Getenv("FLYER_ITEMS_PATH")
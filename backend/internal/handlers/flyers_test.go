@@ -1,18 +1,27 @@
 package handlers
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
 	"kincart/internal/database"
+	"kincart/internal/flyers"
 	"kincart/internal/models"
 	"kincart/internal/utils"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
@@ -99,3 +108,116 @@ func TestGetFlyerItemsSearch(t *testing.T) {
 		})
 	}
 }
+
+func flyerUploadRequest(t *testing.T, shop string, files ...[]byte) *http.Request {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	writer := multipart.NewWriter(buf)
+	require.NoError(t, writer.WriteField("shop", shop))
+	for _, f := range files {
+		part, err := writer.CreateFormFile("flyer", "page.png")
+		require.NoError(t, err)
+		_, err = part.Write(f)
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	req, _ := http.NewRequest(http.MethodPost, "/flyers/upload", buf)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestUploadFlyer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupFlyerTestDB()
+	require.NoError(t, database.DB.AutoMigrate(&models.Job{}))
+	t.Setenv("UPLOADS_PATH", t.TempDir())
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("family_id", uuid.New())
+		c.Next()
+	})
+	r.POST("/flyers/upload", UploadFlyer)
+	r.GET("/flyers/pages", GetFlyerPages)
+
+	page := func(width int) []byte {
+		var buf bytes.Buffer
+		require.NoError(t, png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, 1))))
+		return buf.Bytes()
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, flyerUploadRequest(t, " Penny ", page(1), page(2)))
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var resp struct {
+		FlyerID uint `json:"flyer_id"`
+		Pages   int  `json:"pages"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 2, resp.Pages)
+
+	var flyer models.Flyer
+	require.NoError(t, database.DB.First(&flyer, resp.FlyerID).Error)
+	assert.Equal(t, "penny", flyer.ShopName)
+	var queued int64
+	database.DB.Model(&models.Job{}).Where("kind = ?", flyers.JobFlyerPage).Count(&queued)
+	assert.Equal(t, int64(2), queued, "the pages are queued for parsing")
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/flyers/pages?flyer_id=%d&is_parsed=false", resp.FlyerID), nil))
+	var pages []models.FlyerPage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &pages))
+	assert.Len(t, pages, 2)
+
+	t.Run("the same flyer is refused", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, flyerUploadRequest(t, "penny", page(1), page(2)))
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), "existing_flyer_id")
+	})
+
+	t.Run("an oversized upload is refused", func(t *testing.T) {
+		// Streamed, so the test does not hold the whole body in memory.
+		body, pw := io.Pipe()
+		writer := multipart.NewWriter(pw)
+		go func() {
+			writer.WriteField("shop", "penny")
+			part, err := writer.CreateFormFile("flyer", "huge.pdf")
+			if err == nil {
+				_, err = io.CopyN(part, zeroReader{}, maxFlyerUploadSize+1)
+			}
+			if err == nil {
+				err = writer.Close()
+			}
+			pw.CloseWithError(err)
+		}()
+		req, _ := http.NewRequest(http.MethodPost, "/flyers/upload", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		body.Close()
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code, w.Body.String())
+	})
+
+	t.Run("bad requests", func(t *testing.T) {
+		for name, req := range map[string]*http.Request{
+			"no shop":      flyerUploadRequest(t, " ", page(3)),
+			"path in shop": flyerUploadRequest(t, "../penny", page(3)),
+			"no files":     flyerUploadRequest(t, "penny"),
+			"not a flyer":  flyerUploadRequest(t, "penny", []byte("just text")),
+		} {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusBadRequest, w.Code, name)
+		}
+	})
+}
+
+// zeroReader reads endless zero bytes.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
@@ -19,6 +19,7 @@ import (
 
 	"kincart/internal/ai"
 	"kincart/internal/database"
+	"kincart/internal/events"
 	"kincart/internal/models"
 	"kincart/internal/services"
 	"kincart/internal/utils"
@@ -80,6 +81,24 @@ func applyRememberedDefaults(ctx context.Context, items []models.Item, familyID
 	}
 }
 
+// bumpItemFrequency counts one more add of name towards the frequent-items list.
+func bumpItemFrequency(familyID uuid.UUID, name string) {
+	var freq models.ItemFrequency
+	result := database.DB.Where("family_id = ? AND LOWER(item_name) = LOWER(?)", familyID, name).First(&freq)
+	if result.Error != nil {
+		// New item
+		freq = models.ItemFrequency{
+			FamilyID:  familyID,
+			ItemName:  name,
+			Frequency: 1,
+		}
+		database.DB.Create(&freq)
+	} else if !freq.IsHidden {
+		// Update existing (skip if user has hidden this item)
+		database.DB.Model(&freq).Update("frequency", freq.Frequency+1)
+	}
+}
+
 func AddItemToList(c *gin.Context) {
 	listID := c.Param("id")
 	familyID := c.MustGet("family_id").(uuid.UUID)
@@ -119,25 +138,16 @@ func AddItemToList(c *gin.Context) {
 		return
 	}
 
-	// Increment frequency
-	var freq models.ItemFrequency
-	result := database.DB.Where("family_id = ? AND LOWER(item_name) = LOWER(?)", familyID, item.Name).First(&freq)
-	if result.Error != nil {
-		// New item
-		freq = models.ItemFrequency{
-			FamilyID:  familyID,
-			ItemName:  item.Name,
-			Frequency: 1,
-		}
-		database.DB.Create(&freq)
-	} else if !freq.IsHidden {
-		// Update existing (skip if user has hidden this item)
-		database.DB.Model(&freq).Update("frequency", freq.Frequency+1)
-	}
+	bumpItemFrequency(familyID, item.Name)
 
+	publishListEvent(familyID, list.ID, events.ItemCreated, item)
+	notifyUrgentItems(c, familyID, &list, []models.Item{item})
 	c.JSON(http.StatusCreated, item)
 }
 
+// shopperItemFields are the item fields a shopper may change.
+var shopperItemFields = map[string]bool{"is_bought": true, "is_absent": true}
+
 func UpdateItem(c *gin.Context) {
 	itemID := c.Param("id")
 	familyID := c.MustGet("family_id").(uuid.UUID)
@@ -156,6 +166,17 @@ func UpdateItem(c *gin.Context) {
 		return
 	}
 
+	// Shoppers work through the list in the store; they can tick items off but
+	// not rewrite what was planned.
+	if c.GetString("role") == models.RoleShopper {
+		for field := range updateData {
+			if !shopperItemFields[field] {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Shoppers can only mark items bought or absent"})
+				return
+			}
+		}
+	}
+
 	// Validate CategoryID if present in update
 	if val, ok := updateData["category_id"]; ok && val != nil {
 		catIDStr, isStr := val.(string)
@@ -242,6 +263,7 @@ func UpdateItem(c *gin.Context) {
 		return
 	}
 
+	publishListEvent(familyID, item.ListID, events.ItemUpdated, item)
 	c.JSON(http.StatusOK, item)
 }
 
@@ -259,6 +281,7 @@ func DeleteItem(c *gin.Context) {
 
 	// Use explicit WHERE string to avoid GORM skipping zero UUID primary key
 	database.DB.Where("id = ?", itemID).Delete(&models.Item{})
+	publishListEvent(familyID, item.ListID, events.ItemDeleted, gin.H{"id": item.ID})
 	c.Status(http.StatusNoContent)
 }
 
@@ -710,14 +733,14 @@ func ParseListText(c *gin.Context) {
 	categoryNames := services.CategoryNames(categories)
 
 	var parsedItems []ai.ParsedShoppingItem
-	geminiClient, err := ai.NewGeminiClient(c.Request.Context())
+	provider, err := ai.NewProvider(c.Request.Context())
 	if err != nil {
-		slog.Info("Gemini unavailable, using fallback parser", "reason", err)
+		slog.Info("AI unavailable, using fallback parser", "reason", err)
 		parsedItems = ai.ParseShoppingTextFallback(req.Text)
 	} else {
-		parsedItems, err = geminiClient.ParseShoppingText(c.Request.Context(), req.Text, categoryNames)
+		parsedItems, err = provider.ParseShoppingText(c.Request.Context(), req.Text, categoryNames)
 		if err != nil {
-			slog.Warn("Gemini parsing failed, using fallback parser", "error", err)
+			slog.Warn("AI parsing failed, using fallback parser", "error", err)
 			parsedItems = ai.ParseShoppingTextFallback(req.Text)
 		}
 	}
@@ -807,16 +830,13 @@ func BulkAddItems(c *gin.Context) {
 	}
 
 	for _, item := range items {
-		var freq models.ItemFrequency
-		result := database.DB.Where("family_id = ? AND LOWER(item_name) = LOWER(?)", familyID, item.Name).First(&freq)
-		if result.Error != nil {
-			freq = models.ItemFrequency{FamilyID: familyID, ItemName: item.Name, Frequency: 1}
-			database.DB.Create(&freq)
-		} else if !freq.IsHidden {
-			database.DB.Model(&freq).Update("frequency", freq.Frequency+1)
-		}
+		bumpItemFrequency(familyID, item.Name)
 	}
 
+	for _, item := range items {
+		publishListEvent(familyID, list.ID, events.ItemCreated, item)
+	}
+	notifyUrgentItems(c, familyID, &list, items)
 	c.JSON(http.StatusCreated, gin.H{"created": len(items), "items": items})
 }
 
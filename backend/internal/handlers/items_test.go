@@ -67,6 +67,45 @@ func TestItemsHandlers(t *testing.T) {
 		assert.Equal(t, 1, freq.Frequency)
 	})
 
+	t.Run("UpdateItem as shopper", func(t *testing.T) {
+		setupItemTestDBIsolated()
+		familyID := uuid.New()
+		list := models.ShoppingList{
+			TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID},
+			Title:       "Test List",
+		}
+		database.DB.Create(&list)
+		item := models.Item{
+			TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID},
+			ListID:      list.ID,
+			Name:        "Milk",
+		}
+		database.DB.Create(&item)
+
+		r := gin.New()
+		r.Use(func(c *gin.Context) {
+			c.Set("family_id", familyID)
+			c.Set("role", models.RoleShopper)
+			c.Next()
+		})
+		r.PATCH("/items/:id", UpdateItem)
+
+		req, _ := http.NewRequest(http.MethodPatch, "/items/"+item.ID.String(), bytes.NewBufferString(`{"is_bought":true}`))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		req, _ = http.NewRequest(http.MethodPatch, "/items/"+item.ID.String(), bytes.NewBufferString(`{"is_bought":false,"name":"Beer"}`))
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		var stored models.Item
+		database.DB.First(&stored, "id = ?", item.ID)
+		assert.True(t, stored.IsBought)
+		assert.Equal(t, "Milk", stored.Name)
+	})
+
 	t.Run("UpdateItem", func(t *testing.T) {
 		setupItemTestDBIsolated()
 		family := models.Family{Family: coremodels.Family{ID: uuid.New(), Name: "Test Family"}}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"kincart/internal/database"
+	"kincart/internal/jobs"
+	"kincart/internal/models"
+)
+
+// GetJobs lists the family's background jobs (receipt parsing), newest first.
+// ?state= and ?kind= filter, ?limit= caps the result (default 50, max 200).
+// GET /api/jobs
+func GetJobs(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+	listJobs(c, &familyID)
+}
+
+// RetryJob puts one of the family's failed or dead jobs back in the queue.
+// POST /api/jobs/:id/retry
+func RetryJob(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+	retryJob(c, &familyID)
+}
+
+// GetAllJobs lists jobs of every family plus the shared flyer jobs.
+// GET /api/internal/jobs
+func GetAllJobs(c *gin.Context) {
+	listJobs(c, nil)
+}
+
+// RetryAnyJob retries any failed or dead job, including flyer jobs.
+// POST /api/internal/jobs/:id/retry
+func RetryAnyJob(c *gin.Context) {
+	retryJob(c, nil)
+}
+
+func listJobs(c *gin.Context, familyID *uuid.UUID) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	q := database.DB.Model(&models.Job{})
+	if familyID != nil {
+		q = q.Where("family_id = ?", *familyID)
+	}
+	if state := c.Query("state"); state != "" {
+		q = q.Where("state = ?", state)
+	}
+	if kind := c.Query("kind"); kind != "" {
+		q = q.Where("kind = ?", kind)
+	}
+
+	var list []models.Job
+	if err := q.Order("created_at DESC, id DESC").Limit(limit).Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch jobs"})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+func retryJob(c *gin.Context, familyID *uuid.UUID) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+
+	job, err := jobs.Retry(database.DB, uint(id), familyID)
+	switch {
+	case errors.Is(err, jobs.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+	case errors.Is(err, jobs.ErrNotRetryable):
+		c.JSON(http.StatusConflict, gin.H{"error": "Only failed or dead jobs can be retried"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retry job"})
+	default:
+		c.JSON(http.StatusOK, job)
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"kincart/internal/database"
+	"kincart/internal/jobs"
+	"kincart/internal/models"
+)
+
+func TestJobHandlers(t *testing.T) {
+	var err error
+	database.DB, err = gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, database.DB.AutoMigrate(&models.Job{}))
+
+	familyID, otherFamily := uuid.New(), uuid.New()
+	dead, _ := jobs.Enqueue(database.DB, "receipt_parse", uuid.NewString(), jobs.Options{FamilyID: &familyID})
+	database.DB.Model(dead).Updates(map[string]interface{}{"state": jobs.StateDead, "attempts": 5, "last_error": "quota exceeded"})
+	pending, _ := jobs.Enqueue(database.DB, "receipt_parse", uuid.NewString(), jobs.Options{FamilyID: &familyID})
+	foreign, _ := jobs.Enqueue(database.DB, "receipt_parse", uuid.NewString(), jobs.Options{FamilyID: &otherFamily})
+	flyer, _ := jobs.Enqueue(database.DB, "flyer_page", "7", jobs.Options{})
+	database.DB.Model(flyer).Update("state", jobs.StateDead)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	admin := r.Group("/")
+	admin.Use(func(c *gin.Context) {
+		c.Set("family_id", familyID)
+		c.Next()
+	})
+	admin.GET("/jobs", GetJobs)
+	admin.POST("/jobs/:id/retry", RetryJob)
+	r.GET("/internal/jobs", GetAllJobs)
+	r.POST("/internal/jobs/:id/retry", RetryAnyJob)
+
+	listIDs := func(path string) []uint {
+		w := doJSON(r, http.MethodGet, path, "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var list []models.Job
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+		ids := make([]uint, len(list))
+		for i, j := range list {
+			ids[i] = j.ID
+		}
+		return ids
+	}
+
+	t.Run("list is scoped to the family", func(t *testing.T) {
+		assert.Equal(t, []uint{pending.ID, dead.ID}, listIDs("/jobs"))
+		assert.Equal(t, []uint{dead.ID}, listIDs("/jobs?state=dead"))
+		assert.ElementsMatch(t, []uint{dead.ID, pending.ID, foreign.ID, flyer.ID}, listIDs("/internal/jobs"))
+		assert.Equal(t, []uint{flyer.ID}, listIDs("/internal/jobs?kind=flyer_page"))
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		w := doJSON(r, http.MethodPost, fmt.Sprintf("/jobs/%d/retry", dead.ID), "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var job models.Job
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &job))
+		assert.Equal(t, jobs.StatePending, job.State)
+		assert.Equal(t, 0, job.Attempts)
+
+		assert.Equal(t, http.StatusConflict, doJSON(r, http.MethodPost, fmt.Sprintf("/jobs/%d/retry", pending.ID), "").Code)
+		assert.Equal(t, http.StatusNotFound, doJSON(r, http.MethodPost, fmt.Sprintf("/jobs/%d/retry", foreign.ID), "").Code)
+		assert.Equal(t, http.StatusNotFound, doJSON(r, http.MethodPost, fmt.Sprintf("/jobs/%d/retry", flyer.ID), "").Code)
+		assert.Equal(t, http.StatusBadRequest, doJSON(r, http.MethodPost, "/jobs/abc/retry", "").Code)
+
+		assert.Equal(t, http.StatusOK, doJSON(r, http.MethodPost, fmt.Sprintf("/internal/jobs/%d/retry", flyer.ID), "").Code)
+	})
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"kincart/internal/database"
+	"kincart/internal/events"
+	"kincart/internal/models"
+)
+
+// listEventsKeepAlive must stay below the reverse proxy's read timeout (60s in
+// nginx.conf), otherwise an idle stream is cut and the client reconnects forever.
+var listEventsKeepAlive = 25 * time.Second
+
+// publishListEvent tells everyone watching the list about a change. Called after
+// the write succeeded, so a subscriber never hears about a change that rolled back.
+func publishListEvent(familyID, listID uuid.UUID, eventType events.Type, data interface{}) {
+	events.Default.Publish(events.Event{
+		Type:     eventType,
+		ListID:   listID,
+		FamilyID: familyID,
+		Data:     data,
+	})
+}
+
+// StreamListEvents streams changes to one list as Server-Sent Events.
+// GET /api/lists/:id/events
+//
+// SSE rather than WebSocket: the traffic is one-way, EventSource reconnects by
+// itself and sends the auth cookies like any other same-origin request. The first
+// event is "ready"; clients should (re)load the list on it, since anything that
+// happened while disconnected is not replayed.
+func StreamListEvents(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+	listID := c.Param("id")
+
+	var list models.ShoppingList
+	if err := database.DB.Where("id = ? AND family_id = ?", listID, familyID).First(&list).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "List not found"})
+		return
+	}
+
+	sub := events.Default.Subscribe(familyID, list.ID)
+	defer events.Default.Unsubscribe(sub)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// Tell nginx not to buffer the stream.
+	c.Header("X-Accel-Buffering", "no")
+
+	c.SSEvent("ready", gin.H{"list_id": list.ID})
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(listEventsKeepAlive)
+	defer keepAlive.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case ev, ok := <-sub.C:
+			if !ok {
+				// Dropped as a slow subscriber; ending the stream makes the client
+				// reconnect and reload.
+				return false
+			}
+			c.SSEvent(string(ev.Type), ev)
+			return true
+		case <-keepAlive.C:
+			// SSE comment line: ignored by EventSource, keeps proxies from timing out.
+			_, err := fmt.Fprint(w, ": keep-alive\n\n")
+			return err == nil
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coremodels "github.com/ya-breeze/kin-core/models"
+
+	"kincart/internal/database"
+	"kincart/internal/events"
+	"kincart/internal/models"
+)
+
+func setupListEventsRouter(familyID uuid.UUID) *gin.Engine {
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("family_id", familyID)
+		c.Next()
+	})
+	r.GET("/lists/:id/events", StreamListEvents)
+	r.POST("/lists/:id/items", AddItemToList)
+	return r
+}
+
+// readSSEEvent returns the next "event:" name from the stream, skipping data and
+// keep-alive lines.
+func readSSEEvent(t *testing.T, reader *bufio.Reader) (string, string) {
+	t.Helper()
+	var name string
+	for {
+		line, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			name = strings.TrimPrefix(line, "event:")
+		case strings.HasPrefix(line, "data:") && name != "":
+			return name, strings.TrimPrefix(line, "data:")
+		}
+	}
+}
+
+func TestStreamListEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("pushes item created to a subscriber", func(t *testing.T) {
+		setupItemTestDBIsolated()
+		familyID := uuid.New()
+		list := models.ShoppingList{
+			TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID},
+			Title:       "Shared",
+		}
+		database.DB.Create(&list)
+
+		srv := httptest.NewServer(setupListEventsRouter(familyID))
+		defer srv.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/lists/%s/events", srv.URL, list.ID), nil)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.True(t, strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream"))
+
+		reader := bufio.NewReader(resp.Body)
+		name, _ := readSSEEvent(t, reader)
+		assert.Equal(t, "ready", name)
+
+		body := bytes.NewBufferString(`{"name":"Milk","is_urgent":true}`)
+		addResp, err := http.Post(fmt.Sprintf("%s/lists/%s/items", srv.URL, list.ID), "application/json", body)
+		require.NoError(t, err)
+		addResp.Body.Close()
+		assert.Equal(t, http.StatusCreated, addResp.StatusCode)
+
+		name, data := readSSEEvent(t, reader)
+		assert.Equal(t, string(events.ItemCreated), name)
+		assert.Contains(t, data, `"name":"Milk"`)
+	})
+
+	t.Run("another family's list is not found", func(t *testing.T) {
+		setupItemTestDBIsolated()
+		list := models.ShoppingList{
+			TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: uuid.New()},
+			Title:       "Not yours",
+		}
+		database.DB.Create(&list)
+
+		r := setupListEventsRouter(uuid.New())
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/lists/%s/events", list.ID), nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, 0, events.Default.SubscriberCount(list.ID))
+	})
+}
@@ -5,9 +5,11 @@ import (
 	"time"
 
 	"kincart/internal/database"
+	"kincart/internal/events"
 	"kincart/internal/models"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
 	"github.com/google/uuid"
 	"github.com/ya-breeze/kin-core/db"
 	coremodels "github.com/ya-breeze/kin-core/models"
@@ -70,6 +72,9 @@ func CreateList(c *gin.Context) {
 	c.JSON(http.StatusCreated, list)
 }
 
+// shopperListFields are the list fields a shopper may change.
+var shopperListFields = map[string]bool{"status": true}
+
 func UpdateList(c *gin.Context) {
 	familyID := c.MustGet("family_id").(uuid.UUID)
 	listID := c.Param("id")
@@ -84,8 +89,25 @@ func UpdateList(c *gin.Context) {
 	// client sends id/family_id in the body, which must not be allowed.
 	tenantID := list.TenantModel.ID
 	tenantFamilyID := list.TenantModel.FamilyID
+	prevStatus := list.Status
+
+	// Shoppers move a list along, e.g. complete it after shopping, but do not
+	// rewrite the plan.
+	if c.GetString("role") == models.RoleShopper {
+		var fields map[string]interface{}
+		if err := c.ShouldBindBodyWith(&fields, binding.JSON); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		for field := range fields {
+			if !shopperListFields[field] {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Shoppers can only change the list status"})
+				return
+			}
+		}
+	}
 
-	if err := c.ShouldBindJSON(&list); err != nil {
+	if err := c.ShouldBindBodyWith(&list, binding.JSON); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -115,6 +137,10 @@ func UpdateList(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update list"})
 		return
 	}
+	publishListEvent(familyID, list.ID, events.ListUpdated, list)
+	if list.Status == "ready for shopping" && prevStatus != "ready for shopping" {
+		warnIfOverBudget(c, familyID, &list)
+	}
 	c.JSON(http.StatusOK, list)
 }
 
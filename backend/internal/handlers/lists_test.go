@@ -259,3 +259,41 @@ func TestListShopAssociation(t *testing.T) {
 		assert.Equal(t, "shopping", stored.Status)
 	})
 }
+
+func TestUpdateList_AsShopper(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupListTestDBIsolated()
+	database.DB.AutoMigrate(&models.Budget{})
+
+	familyID := uuid.New()
+	list := models.ShoppingList{
+		TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID},
+		Title:       "Weekly",
+		Status:      "ready for shopping",
+	}
+	database.DB.Create(&list)
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("family_id", familyID)
+		c.Set("role", models.RoleShopper)
+		c.Next()
+	})
+	r.PATCH("/lists/:id", UpdateList)
+
+	req, _ := http.NewRequest(http.MethodPatch, "/lists/"+list.ID.String(), bytes.NewBufferString(`{"status":"completed"}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	req, _ = http.NewRequest(http.MethodPatch, "/lists/"+list.ID.String(), bytes.NewBufferString(`{"status":"preparing","title":"Party"}`))
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	var stored models.ShoppingList
+	database.DB.First(&stored, "id = ?", list.ID)
+	assert.Equal(t, "completed", stored.Status)
+	assert.NotNil(t, stored.CompletedAt)
+	assert.Equal(t, "Weekly", stored.Title)
+}
@@ -0,0 +1,354 @@
+package handlers
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ya-breeze/kin-core/auth"
+	"github.com/ya-breeze/kin-core/authdb"
+	coremodels "github.com/ya-breeze/kin-core/models"
+	"gorm.io/gorm"
+
+	"kincart/internal/database"
+	"kincart/internal/models"
+	"kincart/internal/utils"
+)
+
+const (
+	defaultInviteTTL  = 72 * time.Hour
+	maxInviteTTL      = 30 * 24 * time.Hour
+	minPasswordLength = 8
+)
+
+var (
+	errInviteInvalid = errors.New("invalid or expired invite")
+	errUsernameTaken = errors.New("username already taken")
+)
+
+type familyMember struct {
+	ID        uuid.UUID `json:"id"`
+	Username  string    `json:"username"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func toFamilyMember(u models.User) familyMember {
+	return familyMember{ID: u.ID, Username: u.Username, Email: u.Email, Role: u.Role, CreatedAt: u.CreatedAt}
+}
+
+// GetFamilyMembers lists the users in the caller's family.
+// GET /api/family/members
+func GetFamilyMembers(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+
+	var users []models.User
+	if err := database.DB.Where("family_id = ?", familyID).Order("created_at ASC").Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch members"})
+		return
+	}
+
+	members := make([]familyMember, len(users))
+	for i, u := range users {
+		members[i] = toFamilyMember(u)
+	}
+	c.JSON(http.StatusOK, members)
+}
+
+// UpdateFamilyMember changes a member's role. Admins cannot change their own
+// role, so a family always keeps the admin who is doing the managing.
+// PATCH /api/family/members/:id
+func UpdateFamilyMember(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+	callerID := c.MustGet("user_id").(uuid.UUID)
+
+	var req struct {
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !models.ValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+
+	var user models.User
+	if err := database.DB.Where("id = ? AND family_id = ?", c.Param("id"), familyID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+	if user.ID == callerID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot change your own role"})
+		return
+	}
+
+	if err := database.DB.Model(&user).Update("role", req.Role).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update member"})
+		return
+	}
+
+	c.JSON(http.StatusOK, toFamilyMember(user))
+}
+
+// RemoveFamilyMember deletes a member and revokes their refresh tokens. Their
+// current access token stops working at once, since RequireRole no longer
+// finds the user. The username becomes free for a new signup.
+// DELETE /api/family/members/:id
+func RemoveFamilyMember(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+	callerID := c.MustGet("user_id").(uuid.UUID)
+
+	var user models.User
+	if err := database.DB.Where("id = ? AND family_id = ?", c.Param("id"), familyID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+	if user.ID == callerID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot remove yourself"})
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.Notification{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("id = ?", user.ID).Delete(&models.User{}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+		return
+	}
+
+	if err := authdb.RevokeAllUserTokens(database.DB, user.ID); err != nil {
+		slog.Warn("Failed to revoke tokens of removed member", "user_id", user.ID, "error", err)
+	}
+
+	slog.Info("Family member removed", "user_id", user.ID, "username", user.Username, "by", callerID)
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed"})
+}
+
+// RevokeFamilyMemberSessions signs a member out everywhere by revoking their
+// refresh tokens; access tokens already issued run out within accessTokenTTL.
+// POST /api/family/members/:id/revoke-sessions
+func RevokeFamilyMemberSessions(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+
+	var user models.User
+	if err := database.DB.Where("id = ? AND family_id = ?", c.Param("id"), familyID).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	}
+
+	if err := authdb.RevokeAllUserTokens(database.DB, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Sessions revoked"})
+}
+
+// GetFamilyInvites lists the invites that can still be redeemed.
+// GET /api/family/invites
+func GetFamilyInvites(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+
+	var invites []models.FamilyInvite
+	if err := database.DB.
+		Where("family_id = ? AND expires_at > ? AND (single_use = ? OR use_count = 0)", familyID, time.Now(), false).
+		Order("created_at DESC").Find(&invites).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch invites"})
+		return
+	}
+
+	c.JSON(http.StatusOK, invites)
+}
+
+// CreateFamilyInvite creates an invite and returns its code. The code is only
+// returned here; the server keeps a hash.
+// POST /api/family/invites
+func CreateFamilyInvite(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+	callerID := c.MustGet("user_id").(uuid.UUID)
+
+	var req struct {
+		Role           string `json:"role"`
+		ExpiresInHours int    `json:"expires_in_hours"`
+		SingleUse      *bool  `json:"single_use"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Role == "" {
+		req.Role = models.RoleManager
+	}
+	if !models.ValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role"})
+		return
+	}
+	ttl := defaultInviteTTL
+	if req.ExpiresInHours != 0 {
+		ttl = time.Duration(req.ExpiresInHours) * time.Hour
+	}
+	if ttl <= 0 || ttl > maxInviteTTL {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in_hours must be between 1 and 720"})
+		return
+	}
+	singleUse := true
+	if req.SingleUse != nil {
+		singleUse = *req.SingleUse
+	}
+
+	code, hash, err := utils.NewSecretToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invite"})
+		return
+	}
+
+	invite := models.FamilyInvite{
+		TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID},
+		CodeHash:    hash,
+		Role:        req.Role,
+		ExpiresAt:   time.Now().Add(ttl),
+		SingleUse:   singleUse,
+		CreatedByID: callerID,
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&invite).Error; err != nil {
+			return err
+		}
+		// Create leaves a false SingleUse to the column default (true).
+		if !singleUse {
+			return tx.Model(&invite).Update("single_use", false).Error
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invite"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"invite": invite, "code": code})
+}
+
+// RevokeFamilyInvite withdraws an invite before it is used.
+// DELETE /api/family/invites/:id
+func RevokeFamilyInvite(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+
+	res := database.DB.Where("id = ? AND family_id = ?", c.Param("id"), familyID).Delete(&models.FamilyInvite{})
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke invite"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invite not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invite revoked"})
+}
+
+type SignupRequest struct {
+	Code     string `json:"code" binding:"required"`
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+	Email    string `json:"email"`
+}
+
+// Signup redeems an invite: it creates the user in the inviting family with the
+// invite's role and logs them in.
+// POST /api/auth/signup
+func Signup(c *gin.Context) {
+	var req SignupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Username = strings.TrimSpace(req.Username)
+	if req.Username == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Username is required"})
+		return
+	}
+	if len(req.Password) < minPasswordLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password must be at least 8 characters"})
+		return
+	}
+
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	var user models.User
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		var invite models.FamilyInvite
+		if err := tx.Where("code_hash = ?", utils.HashSecretToken(req.Code)).First(&invite).Error; err != nil {
+			return errInviteInvalid
+		}
+		if time.Now().After(invite.ExpiresAt) {
+			return errInviteInvalid
+		}
+
+		// Claim the invite; the condition makes a concurrent second redemption
+		// of a single-use code update nothing.
+		res := tx.Model(&models.FamilyInvite{}).
+			Where("id = ? AND (single_use = ? OR use_count = 0)", invite.ID, false).
+			Update("use_count", gorm.Expr("use_count + 1"))
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errInviteInvalid
+		}
+
+		var taken int64
+		if err := tx.Model(&models.User{}).Unscoped().Where("username = ?", req.Username).Count(&taken).Error; err != nil {
+			return err
+		}
+		if taken > 0 {
+			return errUsernameTaken
+		}
+
+		user = models.User{
+			User: coremodels.User{
+				ID:           uuid.New(),
+				Username:     req.Username,
+				PasswordHash: hash,
+				FamilyID:     invite.FamilyID,
+			},
+			Email: strings.TrimSpace(req.Email),
+			Role:  invite.Role,
+		}
+		return tx.Create(&user).Error
+	})
+	switch {
+	case errors.Is(err, errInviteInvalid):
+		slog.Warn("Signup with invalid invite", "ip", c.ClientIP())
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired invite"})
+		return
+	case errors.Is(err, errUsernameTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": "Username already taken"})
+		return
+	case err != nil:
+		slog.Error("Signup failed", "username", req.Username, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	if !startSession(c, &user) {
+		return
+	}
+
+	slog.Info("User signed up", "username", user.Username, "family_id", user.FamilyID, "ip", c.ClientIP())
+	c.JSON(http.StatusCreated, gin.H{"user": sessionUser(&user)})
+}
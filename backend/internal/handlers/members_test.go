@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ya-breeze/kin-core/authdb"
+	coremodels "github.com/ya-breeze/kin-core/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"kincart/internal/database"
+	"kincart/internal/models"
+	"kincart/internal/utils"
+)
+
+func setupMembersTestDB(t *testing.T) {
+	t.Helper()
+	var err error
+	database.DB, err = gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, database.DB.AutoMigrate(&models.Family{}, &models.User{}, &models.FamilyInvite{},
+		&models.Notification{}, &authdb.RefreshToken{}, &models.Session{}))
+}
+
+func setupMembersRouter(familyID, userID uuid.UUID) *gin.Engine {
+	r := gin.New()
+	r.POST("/auth/signup", Signup)
+	admin := r.Group("/")
+	admin.Use(func(c *gin.Context) {
+		c.Set("family_id", familyID)
+		c.Set("user_id", userID)
+		c.Next()
+	})
+	admin.GET("/family/members", GetFamilyMembers)
+	admin.PATCH("/family/members/:id", UpdateFamilyMember)
+	admin.DELETE("/family/members/:id", RemoveFamilyMember)
+	admin.GET("/family/invites", GetFamilyInvites)
+	admin.POST("/family/invites", CreateFamilyInvite)
+	admin.DELETE("/family/invites/:id", RevokeFamilyInvite)
+	return r
+}
+
+func doJSON(r *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestFamilyInvitesAndSignup(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setupMembersTestDB(t)
+
+	family := models.Family{Family: coremodels.Family{ID: uuid.New(), Name: "Smiths"}}
+	database.DB.Create(&family)
+	admin := models.User{User: coremodels.User{ID: uuid.New(), Username: "dad", FamilyID: family.ID}, Role: models.RoleAdmin}
+	database.DB.Create(&admin)
+	r := setupMembersRouter(family.ID, admin.ID)
+
+	w := doJSON(r, http.MethodPost, "/family/invites", `{"role":"shopper","expires_in_hours":24}`)
+	require.Equal(t, http.StatusCreated, w.Code)
+	var created struct {
+		Invite models.FamilyInvite `json:"invite"`
+		Code   string              `json:"code"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	require.NotEmpty(t, created.Code)
+	assert.True(t, created.Invite.SingleUse)
+	assert.NotContains(t, w.Body.String(), utils.HashSecretToken(created.Code), "the hash is never exposed")
+
+	t.Run("signup redeems the invite", func(t *testing.T) {
+		w := doJSON(r, http.MethodPost, "/auth/signup", `{"code":"`+created.Code+`","username":"kid","password":"longenough"}`)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		assert.NotEmpty(t, w.Result().Cookies(), "the new member is logged in")
+
+		var kid models.User
+		require.NoError(t, database.DB.Where("username = ?", "kid").First(&kid).Error)
+		assert.Equal(t, family.ID, kid.FamilyID)
+		assert.Equal(t, models.RoleShopper, kid.Role)
+	})
+
+	t.Run("single-use invite cannot be redeemed twice", func(t *testing.T) {
+		w := doJSON(r, http.MethodPost, "/auth/signup", `{"code":"`+created.Code+`","username":"kid2","password":"longenough"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = doJSON(r, http.MethodGet, "/family/invites", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `[]`, w.Body.String())
+	})
+
+	t.Run("expired invite is rejected", func(t *testing.T) {
+		w := doJSON(r, http.MethodPost, "/family/invites", `{"single_use":false}`)
+		require.Equal(t, http.StatusCreated, w.Code)
+		var inv struct {
+			Invite models.FamilyInvite `json:"invite"`
+			Code   string              `json:"code"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &inv))
+		assert.False(t, inv.Invite.SingleUse)
+		database.DB.Model(&models.FamilyInvite{}).Where("id = ?", inv.Invite.ID).Update("expires_at", time.Now().Add(-time.Minute))
+
+		w = doJSON(r, http.MethodPost, "/auth/signup", `{"code":"`+inv.Code+`","username":"late","password":"longenough"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("username must be free", func(t *testing.T) {
+		w := doJSON(r, http.MethodPost, "/family/invites", `{}`)
+		require.Equal(t, http.StatusCreated, w.Code)
+		var inv struct {
+			Code string `json:"code"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &inv))
+
+		w = doJSON(r, http.MethodPost, "/auth/signup", `{"code":"`+inv.Code+`","username":"dad","password":"longenough"}`)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	t.Run("admin lists and removes members", func(t *testing.T) {
+		w := doJSON(r, http.MethodGet, "/family/members", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		var members []familyMember
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &members))
+		require.Len(t, members, 2)
+		assert.Equal(t, "dad", members[0].Username)
+		kidID := members[1].ID
+
+		w = doJSON(r, http.MethodDelete, "/family/members/"+admin.ID.String(), "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, "admins cannot remove themselves")
+
+		w = doJSON(r, http.MethodDelete, "/family/members/"+kidID.String(), "")
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var active int64
+		database.DB.Model(&authdb.RefreshToken{}).Where("user_id = ? AND is_revoked = ?", kidID, false).Count(&active)
+		assert.Zero(t, active, "the removed member's sessions are revoked")
+		var left int64
+		database.DB.Unscoped().Model(&models.User{}).Where("id = ?", kidID).Count(&left)
+		assert.Zero(t, left)
+	})
+
+	t.Run("another family's member is not found", func(t *testing.T) {
+		stranger := models.User{User: coremodels.User{ID: uuid.New(), Username: "neighbour", FamilyID: uuid.New()}}
+		database.DB.Create(&stranger)
+
+		w := doJSON(r, http.MethodDelete, "/family/members/"+stranger.ID.String(), "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		w = doJSON(r, http.MethodPatch, "/family/members/"+stranger.ID.String(), `{"role":"admin"}`)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"kincart/internal/database"
+	"kincart/internal/models"
+	"kincart/internal/notify"
+)
+
+// notifyUrgentItems tells the rest of the family about urgent additions — the
+// case that matters most, since the shopper may already be in the store. The
+// person who added them is not notified.
+func notifyUrgentItems(c *gin.Context, familyID uuid.UUID, list *models.ShoppingList, items []models.Item) {
+	var names []string
+	for _, item := range items {
+		if item.IsUrgent {
+			names = append(names, item.Name)
+		}
+	}
+	if len(names) == 0 {
+		return
+	}
+
+	var exclude *uuid.UUID
+	if v, ok := c.Get("user_id"); ok {
+		if userID, ok := v.(uuid.UUID); ok {
+			exclude = &userID
+		}
+	}
+
+	title := fmt.Sprintf("Urgent: %s", names[0])
+	if len(names) > 1 {
+		title = fmt.Sprintf("%d urgent items added", len(names))
+	}
+	msg := notify.Message{
+		Kind:  notify.KindUrgentItem,
+		Title: title,
+		Body:  fmt.Sprintf("Added to \"%s\": %s", list.Title, strings.Join(names, ", ")),
+		Link:  "/lists/" + list.ID.String(),
+	}
+	if _, err := notify.For(database.DB).NotifyFamily(c.Request.Context(), familyID, msg, exclude); err != nil {
+		slog.Warn("Failed to notify about urgent items", "list_id", list.ID, "error", err)
+	}
+}
+
+// GetNotifications returns the caller's inbox, newest first, with the unread
+// count for the badge. ?unread=true limits it to unread entries.
+// GET /api/notifications
+func GetNotifications(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+	familyID := c.MustGet("family_id").(uuid.UUID)
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	q := database.DB.Where("user_id = ? AND family_id = ?", userID, familyID)
+	if c.Query("unread") == "true" {
+		q = q.Where("read_at IS NULL")
+	}
+
+	var items []models.Notification
+	if err := q.Order("created_at DESC, id DESC").Limit(limit).Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch notifications"})
+		return
+	}
+
+	var unread int64
+	if err := database.DB.Model(&models.Notification{}).
+		Where("user_id = ? AND family_id = ? AND read_at IS NULL", userID, familyID).
+		Count(&unread).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": items, "unread_count": unread})
+}
+
+// MarkNotificationRead marks one of the caller's notifications as read.
+// POST /api/notifications/:id/read
+func MarkNotificationRead(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid id"})
+		return
+	}
+
+	var n models.Notification
+	if err := database.DB.Where("id = ? AND user_id = ?", id, userID).First(&n).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
+		return
+	}
+
+	if n.ReadAt == nil {
+		now := time.Now()
+		if err := database.DB.Model(&n).Update("read_at", now).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notification"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, n)
+}
+
+// MarkAllNotificationsRead clears the caller's unread badge.
+// POST /api/notifications/read-all
+func MarkAllNotificationsRead(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	res := database.DB.Model(&models.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now())
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": res.RowsAffected})
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coremodels "github.com/ya-breeze/kin-core/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"kincart/internal/database"
+	"kincart/internal/models"
+)
+
+func setupNotificationTestDB() {
+	var err error
+	database.DB, err = gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		panic("Failed to connect to database")
+	}
+	database.DB.AutoMigrate(&models.ShoppingList{}, &models.Item{}, &models.Family{}, &models.User{}, &models.Category{},
+		&models.ItemFrequency{}, &models.ItemAlias{}, &models.Notification{})
+}
+
+func setupNotificationRouter(familyID, userID uuid.UUID) *gin.Engine {
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("family_id", familyID)
+		c.Set("user_id", userID)
+		c.Next()
+	})
+	r.GET("/notifications", GetNotifications)
+	r.POST("/notifications/:id/read", MarkNotificationRead)
+	r.POST("/notifications/read-all", MarkAllNotificationsRead)
+	r.POST("/lists/:id/items", AddItemToList)
+	return r
+}
+
+func TestNotificationHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	setupNotificationTestDB()
+	familyID := uuid.New()
+	shopper := models.User{User: coremodels.User{ID: uuid.New(), Username: "shopper", FamilyID: familyID}}
+	planner := models.User{User: coremodels.User{ID: uuid.New(), Username: "planner", FamilyID: familyID}}
+	database.DB.Create(&shopper)
+	database.DB.Create(&planner)
+	list := models.ShoppingList{TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID}, Title: "Saturday"}
+	database.DB.Create(&list)
+
+	// The planner adds an urgent item; the shopper gets notified, the planner does not.
+	plannerRouter := setupNotificationRouter(familyID, planner.ID)
+	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("/lists/%s/items", list.ID), bytes.NewBufferString(`{"name":"Nappies","is_urgent":true}`))
+	w := httptest.NewRecorder()
+	plannerRouter.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	// A non-urgent item notifies nobody.
+	req, _ = http.NewRequest(http.MethodPost, fmt.Sprintf("/lists/%s/items", list.ID), bytes.NewBufferString(`{"name":"Bread"}`))
+	w = httptest.NewRecorder()
+	plannerRouter.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	type inbox struct {
+		Items       []models.Notification `json:"items"`
+		UnreadCount int64                 `json:"unread_count"`
+	}
+	getInbox := func(r *gin.Engine) inbox {
+		req, _ := http.NewRequest(http.MethodGet, "/notifications", nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var got inbox
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		return got
+	}
+
+	assert.Empty(t, getInbox(plannerRouter).Items)
+
+	shopperRouter := setupNotificationRouter(familyID, shopper.ID)
+	got := getInbox(shopperRouter)
+	require.Len(t, got.Items, 1)
+	assert.Equal(t, "urgent_item", got.Items[0].Kind)
+	assert.Equal(t, "Urgent: Nappies", got.Items[0].Title)
+	assert.Equal(t, int64(1), got.UnreadCount)
+
+	t.Run("another user cannot mark it read", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("/notifications/%d/read", got.Items[0].ID), nil)
+		w := httptest.NewRecorder()
+		plannerRouter.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("mark read clears the badge", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("/notifications/%d/read", got.Items[0].ID), nil)
+		w := httptest.NewRecorder()
+		shopperRouter.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		after := getInbox(shopperRouter)
+		assert.Equal(t, int64(0), after.UnreadCount)
+		require.Len(t, after.Items, 1)
+		assert.NotNil(t, after.Items[0].ReadAt)
+	})
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	coremodels "github.com/ya-breeze/kin-core/models"
+	"gorm.io/gorm"
+
+	"kincart/internal/database"
+	"kincart/internal/models"
+	"kincart/internal/services"
+	"kincart/internal/utils"
+)
+
+// receiptInbox returns the receipt inbox's address (RECEIPT_IMAP_ADDRESS,
+// else RECEIPT_IMAP_USER), or "" when there is no inbox.
+func receiptInbox() string {
+	if os.Getenv("RECEIPT_IMAP_SERVER") == "" {
+		return ""
+	}
+	inbox := os.Getenv("RECEIPT_IMAP_ADDRESS")
+	if inbox == "" {
+		inbox = os.Getenv("RECEIPT_IMAP_USER")
+	}
+	if !strings.Contains(inbox, "@") {
+		return ""
+	}
+	return strings.ToLower(inbox)
+}
+
+// GetReceiptAddress tells whether the family has a receipt address. The
+// address itself is only shown when it is created.
+// GET /api/family/receipt-address
+func GetReceiptAddress(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+
+	var addr models.ReceiptAddress
+	if err := database.DB.Where("family_id = ?", familyID).First(&addr).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No receipt address"})
+		return
+	}
+
+	c.JSON(http.StatusOK, addr)
+}
+
+// CreateReceiptAddress gives the family a new receipt address and returns it.
+// An address the family had before stops working. Only a hash of the token
+// in it is kept.
+// POST /api/family/receipt-address
+func CreateReceiptAddress(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+
+	var req struct {
+		AutoAttach bool `json:"auto_attach"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	inbox := receiptInbox()
+	if inbox == "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Receipt inbox is not configured"})
+		return
+	}
+
+	token, hash, err := utils.NewSecretToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create receipt address"})
+		return
+	}
+
+	addr := models.ReceiptAddress{
+		TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID},
+		TokenHash:   hash,
+		AutoAttach:  req.AutoAttach,
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("family_id = ?", familyID).Delete(&models.ReceiptAddress{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&addr).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create receipt address"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"receipt_address": addr, "address": services.ReceiptInboxAddress(inbox, token)})
+}
+
+// UpdateReceiptAddress turns auto-attaching on or off.
+// PATCH /api/family/receipt-address
+func UpdateReceiptAddress(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+
+	var req struct {
+		AutoAttach *bool `json:"auto_attach" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var addr models.ReceiptAddress
+	if err := database.DB.Where("family_id = ?", familyID).First(&addr).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No receipt address"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update receipt address"})
+		return
+	}
+	if err := database.DB.Model(&addr).Update("auto_attach", *req.AutoAttach).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update receipt address"})
+		return
+	}
+
+	c.JSON(http.StatusOK, addr)
+}
+
+// DeleteReceiptAddress stops the family's receipt address from working.
+// DELETE /api/family/receipt-address
+func DeleteReceiptAddress(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+
+	res := database.DB.Unscoped().Where("family_id = ?", familyID).Delete(&models.ReceiptAddress{})
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete receipt address"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No receipt address"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Receipt address deleted"})
+}
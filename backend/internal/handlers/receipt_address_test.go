@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"kincart/internal/database"
+	"kincart/internal/models"
+)
+
+func setupReceiptAddressRouter(familyID uuid.UUID) *gin.Engine {
+	r := gin.New()
+	admin := r.Group("/")
+	admin.Use(func(c *gin.Context) {
+		c.Set("family_id", familyID)
+		c.Next()
+	})
+	admin.GET("/family/receipt-address", GetReceiptAddress)
+	admin.POST("/family/receipt-address", CreateReceiptAddress)
+	admin.PATCH("/family/receipt-address", UpdateReceiptAddress)
+	admin.DELETE("/family/receipt-address", DeleteReceiptAddress)
+	return r
+}
+
+func TestReceiptAddressHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var err error
+	database.DB, err = gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, database.DB.AutoMigrate(&models.ReceiptAddress{}))
+
+	familyID := uuid.New()
+	r := setupReceiptAddressRouter(familyID)
+
+	assert.Equal(t, http.StatusServiceUnavailable, doJSON(r, http.MethodPost, "/family/receipt-address", `{}`).Code,
+		"there is no inbox to give out addresses of")
+
+	t.Setenv("RECEIPT_IMAP_SERVER", "imap.example.test:993")
+	t.Setenv("RECEIPT_IMAP_USER", "Receipts@Example.test")
+
+	assert.Equal(t, http.StatusNotFound, doJSON(r, http.MethodGet, "/family/receipt-address", "").Code)
+
+	create := func() string {
+		w := doJSON(r, http.MethodPost, "/family/receipt-address", `{"auto_attach":true}`)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var resp struct {
+			Address string `json:"address"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		return resp.Address
+	}
+	first := create()
+	assert.True(t, strings.HasPrefix(first, "receipts+"), first)
+	assert.True(t, strings.HasSuffix(first, "@example.test"), first)
+
+	w := doJSON(r, http.MethodGet, "/family/receipt-address", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), strings.TrimSuffix(strings.TrimPrefix(first, "receipts+"), "@example.test"),
+		"the token is only shown once")
+	assert.Contains(t, w.Body.String(), `"auto_attach":true`)
+
+	second := create()
+	assert.NotEqual(t, first, second)
+	var count int64
+	database.DB.Unscoped().Model(&models.ReceiptAddress{}).Count(&count)
+	assert.Equal(t, int64(1), count, "a new address replaces the old one")
+
+	w = doJSON(r, http.MethodPatch, "/family/receipt-address", `{"auto_attach":false}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"auto_attach":false`)
+
+	t.Run("other families cannot see or change it", func(t *testing.T) {
+		other := setupReceiptAddressRouter(uuid.New())
+		assert.Equal(t, http.StatusNotFound, doJSON(other, http.MethodGet, "/family/receipt-address", "").Code)
+		assert.Equal(t, http.StatusNotFound, doJSON(other, http.MethodDelete, "/family/receipt-address", "").Code)
+	})
+
+	require.Equal(t, http.StatusOK, doJSON(r, http.MethodDelete, "/family/receipt-address", "").Code)
+	assert.Equal(t, http.StatusNotFound, doJSON(r, http.MethodGet, "/family/receipt-address", "").Code)
+}
@@ -14,6 +14,7 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
@@ -27,11 +28,13 @@ import (
 
 const maxReceiptTextBytes = 100 * 1024 // 100 KB
 
+// maxReceiptImages caps the photos of one receipt; even a long receipt rarely needs more than three.
+const maxReceiptImages = 10
+
 // receiptSvc is the interface used by the upload handler (enables testing with mocks).
 type receiptSvc interface {
-	CreateReceipt(familyID uuid.UUID, file *multipart.FileHeader) (*models.Receipt, error)
+	CreateReceipt(familyID uuid.UUID, files []*multipart.FileHeader) (*models.Receipt, error)
 	CreateReceiptFromText(familyID uuid.UUID, text string) (*models.Receipt, error)
-	ProcessReceipt(ctx context.Context, receiptID uuid.UUID, listID uuid.UUID) error
 }
 
 // Helper to get service instance (in a real app, use dependency injection)
@@ -43,20 +46,16 @@ func getReceiptService(ctx context.Context) *services.ReceiptService {
 
 	fileStorage := services.NewFileStorageService(dataPath)
 
-	geminiKey := os.Getenv("GEMINI_API_KEY")
-	var geminiClient services.ReceiptParser
-
-	if geminiKey != "" {
-		client, err := ai.NewGeminiClient(ctx)
-		if err != nil {
-			slog.Warn("Failed to init gemini client", "error", err)
-			geminiClient = nil
-		} else {
-			geminiClient = client
-		}
+	var parser services.ReceiptParser
+	provider, err := ai.NewProvider(ctx)
+	switch {
+	case err == nil:
+		parser = provider
+	case !errors.Is(err, ai.ErrNoProvider):
+		slog.Warn("Failed to init AI provider", "error", err)
 	}
 
-	return services.NewReceiptService(database.DB, geminiClient, fileStorage, dataPath)
+	return services.NewReceiptService(database.DB, parser, fileStorage, dataPath)
 }
 
 // UploadReceipt handles the receipt upload request.
@@ -83,6 +82,41 @@ func uploadReceiptWith(c *gin.Context, svc receiptSvc) {
 		return
 	}
 
+	receipt := saveUploadedReceipt(c, svc, list.FamilyID)
+	if receipt == nil {
+		return
+	}
+
+	// Link receipt to the list so the parse job knows where to apply it
+	database.DB.Model(receipt).Updates(map[string]interface{}{"list_id": listID})
+
+	queueReceipt(c, receipt)
+}
+
+// UploadStandaloneReceipt handles a receipt from a trip that had no shopping
+// list. It is parsed into the purchase history and can be attached to a list later.
+// POST /api/receipts
+func UploadStandaloneReceipt(c *gin.Context) {
+	svc := getReceiptService(c.Request.Context())
+	uploadStandaloneReceiptWith(c, svc)
+}
+
+// uploadStandaloneReceiptWith is the testable core of UploadStandaloneReceipt.
+func uploadStandaloneReceiptWith(c *gin.Context, svc receiptSvc) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+	receipt := saveUploadedReceipt(c, svc, familyID)
+	if receipt == nil {
+		return
+	}
+	queueReceipt(c, receipt)
+}
+
+// saveUploadedReceipt stores the pasted text (JSON body) or uploaded files
+// (multipart "receipt" fields: one .txt, or photos/PDFs of one receipt in order)
+// as a new receipt. On failure it writes the error response and returns nil.
+func saveUploadedReceipt(c *gin.Context, svc receiptSvc, familyID uuid.UUID) *models.Receipt {
+	var err error
+
 	// Determine request mode from Content-Type
 	contentType := c.GetHeader("Content-Type")
 	mediaType, _, _ := mime.ParseMediaType(contentType)
@@ -96,108 +130,411 @@ func uploadReceiptWith(c *gin.Context, svc receiptSvc) {
 		}
 		if bindErr := c.ShouldBindJSON(&req); bindErr != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON body"})
-			return
+			return nil
 		}
 
 		if len(req.ReceiptText) > maxReceiptTextBytes {
 			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Receipt text exceeds 100KB limit"})
-			return
+			return nil
 		}
 
 		if strings.TrimSpace(req.ReceiptText) == "" {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "receipt_text is required and must not be empty"})
-			return
+			return nil
 		}
 
-		receipt, err = svc.CreateReceiptFromText(list.FamilyID, req.ReceiptText)
+		receipt, err = svc.CreateReceiptFromText(familyID, req.ReceiptText)
 		if err != nil {
-			slog.Error("Failed to save receipt from text", "error", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save receipt"})
-			return
+			receiptSaveFailed(c, "Failed to save receipt from text", err)
+			return nil
 		}
 
 	} else {
 		// --- Multipart file upload mode ---
-		file, fileErr := c.FormFile("receipt")
-		if fileErr != nil {
+		var files []*multipart.FileHeader
+		if form, formErr := c.MultipartForm(); formErr == nil {
+			files = form.File["receipt"]
+		}
+		if len(files) == 0 {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "No file uploaded"})
-			return
+			return nil
+		}
+		if len(files) > maxReceiptImages {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d images per receipt", maxReceiptImages)})
+			return nil
 		}
+		file := files[0]
 
 		ext := strings.ToLower(filepath.Ext(file.Filename))
 		if ext == ".txt" {
+			if len(files) > 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "A text receipt must be uploaded on its own"})
+				return nil
+			}
+
 			if file.Size > maxReceiptTextBytes {
 				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File exceeds 100KB limit"})
-				return
+				return nil
 			}
 
 			src, openErr := file.Open()
 			if openErr != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
-				return
+				return nil
 			}
 			defer src.Close()
 
 			data, readErr := io.ReadAll(src)
 			if readErr != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file"})
-				return
+				return nil
 			}
 
 			data = bytes.TrimPrefix(data, []byte{0xEF, 0xBB, 0xBF})
 
 			if !utf8.Valid(data) {
 				c.JSON(http.StatusBadRequest, gin.H{"error": "File must be valid UTF-8 encoded text"})
-				return
+				return nil
 			}
 
 			if strings.TrimSpace(string(data)) == "" {
 				c.JSON(http.StatusBadRequest, gin.H{"error": "Receipt text is empty"})
-				return
+				return nil
 			}
 
-			receipt, err = svc.CreateReceiptFromText(list.FamilyID, string(data))
+			receipt, err = svc.CreateReceiptFromText(familyID, string(data))
 			if err != nil {
-				slog.Error("Failed to save receipt from text file", "error", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save receipt"})
-				return
+				receiptSaveFailed(c, "Failed to save receipt from text file", err)
+				return nil
 			}
 
 		} else {
-			receipt, err = svc.CreateReceipt(list.FamilyID, file)
+			for _, f := range files[1:] {
+				if strings.EqualFold(filepath.Ext(f.Filename), ".txt") {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "A text receipt must be uploaded on its own"})
+					return nil
+				}
+			}
+			receipt, err = svc.CreateReceipt(familyID, files)
 			if err != nil {
-				slog.Error("Failed to save receipt from file upload", "error", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save receipt"})
-				return
+				receiptSaveFailed(c, "Failed to save receipt from file upload", err)
+				return nil
 			}
 		}
 	}
 
-	// Link receipt to the list so the background ticker can find it
-	database.DB.Model(receipt).Updates(map[string]interface{}{"list_id": listID})
+	return receipt
+}
+
+// receiptSaveFailed answers a failed save: 409 pointing at the existing receipt
+// when it is a repeat upload, 500 otherwise.
+func receiptSaveFailed(c *gin.Context, logMsg string, err error) {
+	var dup *services.DuplicateReceiptError
+	if errors.As(err, &dup) {
+		c.JSON(http.StatusConflict, gin.H{"error": "This receipt has already been uploaded", "existing_receipt_id": dup.ExistingID})
+		return
+	}
+	slog.Error(logMsg, "error", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save receipt"})
+}
+
+// queueReceipt hands a saved receipt to the parse queue and answers 202; the
+// client polls GET /api/receipts/:id.
+func queueReceipt(c *gin.Context, receipt *models.Receipt) {
+	job, err := services.EnqueueReceiptJob(database.DB, receipt)
+	if err != nil {
+		slog.Error("Failed to queue receipt for parsing", "receipt_id", receipt.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue receipt"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Receipt queued for parsing", "receipt_id": receipt.ID, "job_id": job.ID, "status": "queued"})
+}
+
+// AttachReceipt links a parsed standalone receipt to a shopping list and
+// matches its items against the list.
+// POST /api/receipts/:id/attach
+// Body: {"list_id": "uuid"}
+func AttachReceipt(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+	receiptID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid receipt ID"})
+		return
+	}
+
+	var body struct {
+		ListID uuid.UUID `json:"list_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "list_id is required"})
+		return
+	}
+
+	svc := getReceiptService(c.Request.Context())
+	if err := svc.AttachReceiptToList(c.Request.Context(), receiptID, body.ListID, familyID); err != nil {
+		switch {
+		case errors.Is(err, services.ErrReceiptNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Receipt not found"})
+		case errors.Is(err, services.ErrListNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "List not found"})
+		case errors.Is(err, services.ErrReceiptAttached):
+			c.JSON(http.StatusConflict, gin.H{"error": "Receipt is already attached to a list"})
+		case errors.Is(err, services.ErrReceiptNotParsed):
+			c.JSON(http.StatusConflict, gin.H{"error": "Receipt has not been parsed yet"})
+		default:
+			slog.Error("Failed to attach receipt", "receipt_id", receiptID, "list_id", body.ListID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to attach receipt"})
+		}
+		return
+	}
+
+	progress, err := services.GetReceiptProgress(database.DB, familyID, receiptID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch receipt"})
+		return
+	}
+	c.JSON(http.StatusOK, progress)
+}
 
-	// Process (synchronous; gracefully handles missing Gemini key)
-	if err := svc.ProcessReceipt(c.Request.Context(), receipt.ID, listID); err != nil {
-		if errors.Is(err, services.ErrGeminiUnavailable) {
-			c.JSON(http.StatusOK, gin.H{"message": "Receipt saved (queued for parsing)", "receipt_id": receipt.ID, "status": "queued"})
+// ReprocessReceipt parses a receipt again, e.g. after the AI misread it,
+// optionally with another provider or model. The current result is kept as a
+// version and what it changed on the list and in the purchase history is
+// undone. Answers 202 like an upload; poll GET /api/receipts/:id.
+// POST /api/receipts/:id/reprocess
+// Body (optional): {"provider": "openai", "model": "qwen2.5vl"}
+func ReprocessReceipt(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+	receiptID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid receipt ID"})
+		return
+	}
+
+	var body struct {
+		Provider string `json:"provider"`
+		Model    string `json:"model"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+	provider := strings.ToLower(strings.TrimSpace(body.Provider))
+	model := strings.TrimSpace(body.Model)
+	if provider != "" || model != "" {
+		// Fail now rather than in the background job.
+		if _, err := ai.NewProviderWith(c.Request.Context(), provider, model); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("AI provider not available: %v", err)})
 			return
 		}
+	}
 
-		slog.Error("Receipt parsing failed", "receipt_id", receipt.ID, "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Parsing failed"})
+	svc := getReceiptService(c.Request.Context())
+	job, err := svc.ReprocessReceipt(c.Request.Context(), receiptID, familyID, provider, model)
+	switch {
+	case errors.Is(err, services.ErrReceiptNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Receipt not found"})
+	case errors.Is(err, services.ErrReceiptNotParsed):
+		c.JSON(http.StatusConflict, gin.H{"error": "Receipt is still being processed"})
+	case err != nil:
+		slog.Error("Failed to reprocess receipt", "receipt_id", receiptID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reprocess receipt"})
+	default:
+		c.JSON(http.StatusAccepted, gin.H{"message": "Receipt queued for reprocessing", "receipt_id": receiptID, "job_id": job.ID, "status": "queued"})
+	}
+}
+
+// MarkReceiptNotDuplicate tells a receipt taken for a duplicate of another
+// that it is a purchase of its own. It is parsed and applied again, and not
+// taken for a duplicate any more. Answers 202 like an upload.
+// POST /api/receipts/:id/not-duplicate
+func MarkReceiptNotDuplicate(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+	receiptID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid receipt ID"})
+		return
+	}
+
+	svc := getReceiptService(c.Request.Context())
+	job, err := svc.MarkNotDuplicate(receiptID, familyID)
+	switch {
+	case errors.Is(err, services.ErrReceiptNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Receipt not found"})
+	case errors.Is(err, services.ErrReceiptNotDuplicate):
+		c.JSON(http.StatusConflict, gin.H{"error": "Receipt is not marked as a duplicate"})
+	case err != nil:
+		slog.Error("Failed to clear duplicate mark", "receipt_id", receiptID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update receipt"})
+	default:
+		c.JSON(http.StatusAccepted, gin.H{"message": "Receipt queued for processing", "receipt_id": receiptID, "job_id": job.ID, "status": "queued"})
+	}
+}
+
+// GetReceiptVersions lists a receipt's earlier parses, newest first, to
+// compare with the current one.
+// GET /api/receipts/:id/versions
+func GetReceiptVersions(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+	receiptID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid receipt ID"})
+		return
+	}
+
+	current, versions, err := services.GetReceiptVersions(database.DB, familyID, receiptID)
+	if errors.Is(err, services.ErrReceiptNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Receipt not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch receipt versions"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"current_version": current, "versions": versions})
+}
+
+// RestoreReceiptVersion makes an earlier parse of a receipt current again; the
+// current one becomes a version in its place.
+// POST /api/receipts/:id/versions/:version/restore
+func RestoreReceiptVersion(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+	receiptID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid receipt ID"})
+		return
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version"})
 		return
 	}
 
-	// Read the actual status set by ProcessReceipt
-	status := "parsed"
-	if receipt.ID != uuid.Nil {
-		var updated models.Receipt
-		if dbErr := database.DB.Select("status").First(&updated, "id = ?", receipt.ID).Error; dbErr == nil {
-			status = updated.Status
+	svc := getReceiptService(c.Request.Context())
+	err = svc.RestoreReceiptVersion(c.Request.Context(), receiptID, familyID, version)
+	switch {
+	case errors.Is(err, services.ErrReceiptNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Receipt not found"})
+		return
+	case errors.Is(err, services.ErrReceiptVersionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		return
+	case errors.Is(err, services.ErrReceiptNotParsed):
+		c.JSON(http.StatusConflict, gin.H{"error": "Receipt is still being processed"})
+		return
+	case err != nil:
+		slog.Error("Failed to restore receipt version", "receipt_id", receiptID, "version", version, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore receipt version"})
+		return
+	}
+
+	progress, err := services.GetReceiptProgress(database.DB, familyID, receiptID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch receipt"})
+		return
+	}
+	c.JSON(http.StatusOK, progress)
+}
+
+// GetReceipts browses the family's receipt archive, newest first.
+// Filters: shop_id, shop (name), from/to (YYYY-MM-DD, inclusive), min_total,
+// max_total, status, item (searches receipt lines; matching lines are
+// returned with each receipt). Paginated with page and limit (default 20, max 100).
+// GET /api/receipts
+func GetReceipts(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	f := services.ReceiptFilter{
+		ShopName: strings.TrimSpace(c.Query("shop")),
+		Status:   c.Query("status"),
+		Item:     c.Query("item"),
+		Limit:    limit,
+		Offset:   (page - 1) * limit,
+	}
+	if s := c.Query("shop_id"); s != "" {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid shop_id"})
+			return
+		}
+		f.ShopID = &id
+	}
+	for _, d := range []struct {
+		param string
+		dst   *time.Time
+	}{{"from", &f.From}, {"to", &f.To}} {
+		if s := c.Query(d.param); s != "" {
+			t, err := time.Parse("2006-01-02", s)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid %s date, expected YYYY-MM-DD", d.param)})
+				return
+			}
+			*d.dst = t
+		}
+	}
+	for _, a := range []struct {
+		param string
+		dst   **float64
+	}{{"min_total", &f.MinTotal}, {"max_total", &f.MaxTotal}} {
+		if s := c.Query(a.param); s != "" {
+			v, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid %s", a.param)})
+				return
+			}
+			*a.dst = &v
 		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Receipt processed", "receipt_id": receipt.ID, "status": status})
+	receipts, total, err := services.SearchReceipts(database.DB, familyID, f)
+	if err != nil {
+		slog.Error("Failed to search receipts", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch receipts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"receipts": receipts,
+		"pagination": gin.H{
+			"page":        page,
+			"limit":       limit,
+			"total":       total,
+			"total_pages": (total + int64(limit) - 1) / int64(limit),
+			"has_more":    f.Offset+len(receipts) < int(total),
+		},
+	})
+}
+
+// GetReceipt returns a receipt with its items and their match state, its
+// processing status and, once it is parsed, a summary of how it matched the list.
+// GET /api/receipts/:id
+func GetReceipt(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+	receiptID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid receipt ID"})
+		return
+	}
+
+	progress, err := services.GetReceiptProgress(database.DB, familyID, receiptID)
+	if errors.Is(err, services.ErrReceiptNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Receipt not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch receipt"})
+		return
+	}
+	c.JSON(http.StatusOK, progress)
 }
 
 // GetReceiptMatches returns the receipt with AI match suggestions for user review.
@@ -297,7 +634,9 @@ func ConfirmAllMatches(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "All matches confirmed"})
 }
 
-// GetReceiptFile serves the raw receipt file (image, PDF, or text).
+// GetReceiptFile serves the raw receipt file (image, PDF, or text). For a
+// receipt photographed in several parts, ?page=N (1-based) picks the part;
+// the first is the default.
 // GET /api/receipts/:id/file
 func GetReceiptFile(c *gin.Context) {
 	dataPath := os.Getenv("KINCART_DATA_PATH")
@@ -324,12 +663,28 @@ func getReceiptFileWith(c *gin.Context, dataPath string) {
 		return
 	}
 
+	relPath, suffix := receipt.ImagePath, ""
+	if p := c.Query("page"); p != "" {
+		page, convErr := strconv.Atoi(p)
+		if convErr != nil || page < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid page"})
+			return
+		}
+		var img models.ReceiptImage
+		if dbErr := database.DB.Where("receipt_id = ? AND position = ?", receipt.ID, page-1).First(&img).Error; dbErr == nil {
+			relPath, suffix = img.Path, fmt.Sprintf("-%d", page)
+		} else if page > 1 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Receipt page not found"})
+			return
+		}
+	}
+
 	rawDataPath, _ := filepath.Abs(dataPath)
 	absDataPath, err := filepath.EvalSymlinks(rawDataPath)
 	if err != nil {
 		absDataPath = rawDataPath
 	}
-	absFilePath := filepath.Join(absDataPath, receipt.ImagePath)
+	absFilePath := filepath.Join(absDataPath, relPath)
 
 	if !strings.HasPrefix(absFilePath, absDataPath+"/") {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file path"})
@@ -347,7 +702,7 @@ func getReceiptFileWith(c *gin.Context, dataPath string) {
 		return
 	}
 
-	ext := strings.TrimPrefix(filepath.Ext(receipt.ImagePath), ".")
+	ext := strings.TrimPrefix(filepath.Ext(relPath), ".")
 	if ext == "" {
 		ext = "bin"
 	}
@@ -355,9 +710,9 @@ func getReceiptFileWith(c *gin.Context, dataPath string) {
 	var filename string
 	if receipt.Shop != nil {
 		shopSlug := sanitizeSlug(receipt.Shop.Name)
-		filename = "receipt-" + date + "-" + shopSlug + "." + ext
+		filename = "receipt-" + date + "-" + shopSlug + suffix + "." + ext
 	} else {
-		filename = fmt.Sprintf("receipt-%s.%s", receipt.ID.String(), ext)
+		filename = fmt.Sprintf("receipt-%s%s.%s", receipt.ID.String(), suffix, ext)
 	}
 
 	c.FileAttachment(absFilePath, filename)
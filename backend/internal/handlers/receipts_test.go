@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"bytes"
-	"context"
 	"encoding/json"
 	"fmt"
 	"mime/multipart"
@@ -12,6 +11,7 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 	"unicode/utf8"
 
 	"kincart/internal/database"
@@ -29,14 +29,13 @@ import (
 
 // mockReceiptSvc is a test double for receiptSvc.
 type mockReceiptSvc struct {
-	createReceiptFunc     func(familyID uuid.UUID, file *multipart.FileHeader) (*models.Receipt, error)
+	createReceiptFunc     func(familyID uuid.UUID, files []*multipart.FileHeader) (*models.Receipt, error)
 	createReceiptTextFunc func(familyID uuid.UUID, text string) (*models.Receipt, error)
-	processReceiptFunc    func(ctx context.Context, receiptID uuid.UUID, listID uuid.UUID) error
 }
 
-func (m *mockReceiptSvc) CreateReceipt(familyID uuid.UUID, file *multipart.FileHeader) (*models.Receipt, error) {
+func (m *mockReceiptSvc) CreateReceipt(familyID uuid.UUID, files []*multipart.FileHeader) (*models.Receipt, error) {
 	if m.createReceiptFunc != nil {
-		return m.createReceiptFunc(familyID, file)
+		return m.createReceiptFunc(familyID, files)
 	}
 	return &models.Receipt{TenantModel: coremodels.TenantModel{ID: uuid.New()}}, nil
 }
@@ -48,20 +47,13 @@ func (m *mockReceiptSvc) CreateReceiptFromText(familyID uuid.UUID, text string)
 	return &models.Receipt{TenantModel: coremodels.TenantModel{ID: uuid.New()}}, nil
 }
 
-func (m *mockReceiptSvc) ProcessReceipt(ctx context.Context, receiptID uuid.UUID, listID uuid.UUID) error {
-	if m.processReceiptFunc != nil {
-		return m.processReceiptFunc(ctx, receiptID, listID)
-	}
-	return nil
-}
-
 func setupReceiptTestDB() uuid.UUID {
 	var err error
 	database.DB, err = gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	if err != nil {
 		panic("Failed to connect to test database")
 	}
-	database.DB.AutoMigrate(&models.ShoppingList{}, &models.Item{}, &models.Family{}, &models.Receipt{}, &models.ReceiptItem{})
+	database.DB.AutoMigrate(&models.ShoppingList{}, &models.Item{}, &models.Family{}, &models.Receipt{}, &models.ReceiptItem{}, &models.ReceiptImage{}, &models.ReceiptVersion{}, &models.Job{}, &models.Shop{})
 
 	family := models.Family{Family: coremodels.Family{ID: uuid.New(), Name: "Test Family"}}
 	database.DB.Create(&family)
@@ -93,9 +85,6 @@ func TestUploadReceipt_JSONSuccess(t *testing.T) {
 		createReceiptTextFunc: func(familyID uuid.UUID, text string) (*models.Receipt, error) {
 			return &models.Receipt{TenantModel: coremodels.TenantModel{ID: uuid.New()}}, nil
 		},
-		processReceiptFunc: func(ctx context.Context, receiptID uuid.UUID, listID uuid.UUID) error {
-			return nil
-		},
 	}
 
 	r := newReceiptRouter(svc)
@@ -107,20 +96,23 @@ func TestUploadReceipt_JSONSuccess(t *testing.T) {
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusAccepted, w.Code)
 	var resp map[string]interface{}
 	json.Unmarshal(w.Body.Bytes(), &resp)
-	assert.Equal(t, "parsed", resp["status"])
+	assert.Equal(t, "queued", resp["status"])
+
+	// Parsing is left to the job queue.
+	var job models.Job
+	assert.NoError(t, database.DB.First(&job).Error)
+	assert.Equal(t, services.JobReceiptParse, job.Kind)
+	assert.Equal(t, resp["receipt_id"], job.Ref)
+	assert.Equal(t, float64(job.ID), resp["job_id"])
 }
 
 func TestUploadReceipt_JSONWithCharset(t *testing.T) {
 	listID := setupReceiptTestDB()
 
-	svc := &mockReceiptSvc{
-		processReceiptFunc: func(ctx context.Context, receiptID uuid.UUID, listID uuid.UUID) error {
-			return nil
-		},
-	}
+	svc := &mockReceiptSvc{}
 
 	r := newReceiptRouter(svc)
 
@@ -131,7 +123,7 @@ func TestUploadReceipt_JSONWithCharset(t *testing.T) {
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusAccepted, w.Code)
 }
 
 func TestUploadReceipt_InvalidJSON(t *testing.T) {
@@ -191,14 +183,22 @@ func TestUploadReceipt_OversizedPastedText(t *testing.T) {
 // --- Multipart file tests ---
 
 func buildMultipartRequest(t *testing.T, listID uuid.UUID, filename string, content []byte) *http.Request {
+	t.Helper()
+	return buildMultipartFilesRequest(t, listID, []string{filename}, content)
+}
+
+// buildMultipartFilesRequest uploads the same content under each filename, in order.
+func buildMultipartFilesRequest(t *testing.T, listID uuid.UUID, filenames []string, content []byte) *http.Request {
 	t.Helper()
 	buf := &bytes.Buffer{}
 	writer := multipart.NewWriter(buf)
-	part, err := writer.CreateFormFile("receipt", filename)
-	assert.NoError(t, err)
-	_, err = part.Write(content)
-	assert.NoError(t, err)
-	err = writer.Close()
+	for _, filename := range filenames {
+		part, err := writer.CreateFormFile("receipt", filename)
+		assert.NoError(t, err)
+		_, err = part.Write(content)
+		assert.NoError(t, err)
+	}
+	err := writer.Close()
 	assert.NoError(t, err)
 
 	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("/lists/%s/receipts", listID.String()), buf)
@@ -216,9 +216,6 @@ func TestUploadReceipt_MultipartTxtSuccess(t *testing.T) {
 			capturedText = text
 			return &models.Receipt{TenantModel: coremodels.TenantModel{ID: uuid.New()}}, nil
 		},
-		processReceiptFunc: func(ctx context.Context, receiptID uuid.UUID, listID uuid.UUID) error {
-			return nil
-		},
 	}
 
 	r := newReceiptRouter(svc)
@@ -228,7 +225,7 @@ func TestUploadReceipt_MultipartTxtSuccess(t *testing.T) {
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusAccepted, w.Code)
 	assert.Equal(t, string(content), capturedText)
 }
 
@@ -258,13 +255,10 @@ func TestUploadReceipt_MultipartImageUnchanged(t *testing.T) {
 
 	var imageCalled bool
 	svc := &mockReceiptSvc{
-		createReceiptFunc: func(familyID uuid.UUID, file *multipart.FileHeader) (*models.Receipt, error) {
+		createReceiptFunc: func(familyID uuid.UUID, files []*multipart.FileHeader) (*models.Receipt, error) {
 			imageCalled = true
 			return &models.Receipt{TenantModel: coremodels.TenantModel{ID: uuid.New()}}, nil
 		},
-		processReceiptFunc: func(ctx context.Context, receiptID uuid.UUID, listID uuid.UUID) error {
-			return nil
-		},
 	}
 
 	r := newReceiptRouter(svc)
@@ -275,10 +269,69 @@ func TestUploadReceipt_MultipartImageUnchanged(t *testing.T) {
 	w := httptest.NewRecorder()
 	r.ServeHTTP(w, req)
 
-	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusAccepted, w.Code)
 	assert.True(t, imageCalled, "expected CreateReceipt (image path) to be called")
 }
 
+func TestUploadReceipt_MultipleImages(t *testing.T) {
+	listID := setupReceiptTestDB()
+
+	var got []string
+	svc := &mockReceiptSvc{
+		createReceiptFunc: func(familyID uuid.UUID, files []*multipart.FileHeader) (*models.Receipt, error) {
+			for _, f := range files {
+				got = append(got, f.Filename)
+			}
+			return &models.Receipt{TenantModel: coremodels.TenantModel{ID: uuid.New()}}, nil
+		},
+	}
+	r := newReceiptRouter(svc)
+	fakeJPEG := []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, buildMultipartFilesRequest(t, listID, []string{"top.jpg", "middle.jpg", "bottom.pdf"}, fakeJPEG))
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, []string{"top.jpg", "middle.jpg", "bottom.pdf"}, got, "order is kept")
+
+	for _, names := range [][]string{{"top.jpg", "rest.txt"}, {"receipt.txt", "photo.jpg"}} {
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, buildMultipartFilesRequest(t, listID, names, fakeJPEG))
+		assert.Equal(t, http.StatusBadRequest, w.Code, names)
+	}
+
+	many := make([]string, maxReceiptImages+1)
+	for i := range many {
+		many[i] = fmt.Sprintf("part%d.jpg", i)
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, buildMultipartFilesRequest(t, listID, many, fakeJPEG))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestUploadReceipt_Duplicate(t *testing.T) {
+	listID := setupReceiptTestDB()
+
+	existing := uuid.New()
+	svc := &mockReceiptSvc{
+		createReceiptFunc: func(familyID uuid.UUID, files []*multipart.FileHeader) (*models.Receipt, error) {
+			return nil, &services.DuplicateReceiptError{ExistingID: existing}
+		},
+	}
+	r := newReceiptRouter(svc)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, buildMultipartRequest(t, listID, "receipt.jpg", []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00}))
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	var resp map[string]string
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, existing.String(), resp["existing_receipt_id"])
+
+	var jobs int64
+	database.DB.Model(&models.Job{}).Count(&jobs)
+	assert.Zero(t, jobs, "nothing is queued for a duplicate")
+}
+
 func TestUploadReceipt_ListNotFound(t *testing.T) {
 	setupReceiptTestDB()
 
@@ -296,28 +349,210 @@ func TestUploadReceipt_ListNotFound(t *testing.T) {
 	assert.Equal(t, http.StatusNotFound, w.Code)
 }
 
-func TestUploadReceipt_GeminiUnavailableQueues(t *testing.T) {
-	listID := setupReceiptTestDB()
+func TestUploadStandaloneReceipt(t *testing.T) {
+	setupReceiptTestDB()
+	familyID := uuid.New()
 
+	var savedFor uuid.UUID
 	svc := &mockReceiptSvc{
-		processReceiptFunc: func(ctx context.Context, receiptID uuid.UUID, listID uuid.UUID) error {
-			return services.ErrGeminiUnavailable
+		createReceiptTextFunc: func(fid uuid.UUID, text string) (*models.Receipt, error) {
+			savedFor = fid
+			r := models.Receipt{TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: fid}, Status: "new"}
+			return &r, database.DB.Create(&r).Error
 		},
 	}
 
-	r := newReceiptRouter(svc)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/receipts", func(c *gin.Context) {
+		c.Set("family_id", familyID)
+		uploadStandaloneReceiptWith(c, svc)
+	})
 
-	body := `{"receipt_text": "Store: Test\nTotal: 5.00"}`
-	req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("/lists/%s/receipts", listID.String()), strings.NewReader(body))
-	req.Header.Set("Content-Type", "application/json")
+	w := doJSON(r, http.MethodPost, "/receipts", `{"receipt_text": "Lidl\nMleko 24,90"}`)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, familyID, savedFor)
 
-	w := httptest.NewRecorder()
-	r.ServeHTTP(w, req)
+	var resp map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	var receipt models.Receipt
+	assert.NoError(t, database.DB.First(&receipt, "id = ?", resp["receipt_id"]).Error)
+	assert.Nil(t, receipt.ListID, "no list is linked")
+
+	var job models.Job
+	assert.NoError(t, database.DB.First(&job).Error)
+	assert.Equal(t, receipt.ID.String(), job.Ref)
+
+	assert.Equal(t, http.StatusBadRequest, doJSON(r, http.MethodPost, "/receipts", `{"receipt_text": "  "}`).Code)
+}
+
+func TestAttachReceipt_Errors(t *testing.T) {
+	listID := setupReceiptTestDB()
+	var list models.ShoppingList
+	database.DB.First(&list, "id = ?", listID)
 
+	queued := models.Receipt{TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: list.FamilyID}, Status: "new"}
+	database.DB.Create(&queued)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.POST("/receipts/:id/attach", func(c *gin.Context) {
+		c.Set("family_id", list.FamilyID)
+		AttachReceipt(c)
+	})
+
+	body := fmt.Sprintf(`{"list_id": %q}`, listID)
+	assert.Equal(t, http.StatusBadRequest, doJSON(r, http.MethodPost, "/receipts/nope/attach", body).Code)
+	assert.Equal(t, http.StatusBadRequest, doJSON(r, http.MethodPost, "/receipts/"+queued.ID.String()+"/attach", `{}`).Code)
+	assert.Equal(t, http.StatusNotFound, doJSON(r, http.MethodPost, "/receipts/"+uuid.NewString()+"/attach", body).Code)
+	assert.Equal(t, http.StatusConflict, doJSON(r, http.MethodPost, "/receipts/"+queued.ID.String()+"/attach", body).Code)
+}
+
+func TestReprocessReceipt(t *testing.T) {
+	listID := setupReceiptTestDB()
+	var list models.ShoppingList
+	database.DB.First(&list, "id = ?", listID)
+
+	receipt := models.Receipt{TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: list.FamilyID}, ListID: &listID, Status: "parsed", Total: 42,
+		Items: []models.ReceiptItem{{Name: "MLEKO", Price: 42, TotalPrice: 42, MatchStatus: "unmatched"}}}
+	database.DB.Create(&receipt)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("family_id", list.FamilyID)
+		c.Next()
+	})
+	r.POST("/receipts/:id/reprocess", ReprocessReceipt)
+	r.GET("/receipts/:id/versions", GetReceiptVersions)
+	path := "/receipts/" + receipt.ID.String()
+
+	assert.Equal(t, http.StatusBadRequest, doJSON(r, http.MethodPost, path+"/reprocess", `{"provider": "bogus"}`).Code)
+	assert.Equal(t, http.StatusNotFound, doJSON(r, http.MethodPost, "/receipts/"+uuid.NewString()+"/reprocess", "").Code)
+
+	w := doJSON(r, http.MethodPost, path+"/reprocess", "")
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, http.StatusConflict, doJSON(r, http.MethodPost, path+"/reprocess", "").Code, "already queued")
+
+	w = doJSON(r, http.MethodGet, path+"/versions", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		CurrentVersion int                     `json:"current_version"`
+		Versions       []models.ReceiptVersion `json:"versions"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 2, resp.CurrentVersion)
+	if assert.Len(t, resp.Versions, 1) {
+		assert.Equal(t, "MLEKO", resp.Versions[0].Items[0].Name)
+	}
+}
+
+func TestMarkReceiptNotDuplicate(t *testing.T) {
+	listID := setupReceiptTestDB()
+	var list models.ShoppingList
+	database.DB.First(&list, "id = ?", listID)
+
+	original := models.Receipt{TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: list.FamilyID}, ListID: &listID, Status: "parsed"}
+	database.DB.Create(&original)
+	copied := models.Receipt{TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: list.FamilyID}, ListID: &listID, Status: "duplicate", DuplicateOfID: &original.ID}
+	database.DB.Create(&copied)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("family_id", list.FamilyID)
+		c.Next()
+	})
+	r.POST("/receipts/:id/not-duplicate", MarkReceiptNotDuplicate)
+
+	assert.Equal(t, http.StatusNotFound, doJSON(r, http.MethodPost, "/receipts/"+uuid.NewString()+"/not-duplicate", "").Code)
+	assert.Equal(t, http.StatusConflict, doJSON(r, http.MethodPost, "/receipts/"+original.ID.String()+"/not-duplicate", "").Code)
+
+	w := doJSON(r, http.MethodPost, "/receipts/"+copied.ID.String()+"/not-duplicate", "")
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	var stored models.Receipt
+	database.DB.First(&stored, "id = ?", copied.ID)
+	assert.Equal(t, "new", stored.Status)
+	assert.True(t, stored.NotDuplicate)
+	assert.Nil(t, stored.DuplicateOfID)
+}
+
+func TestGetReceipt_Progress(t *testing.T) {
+	listID := setupReceiptTestDB()
+	var list models.ShoppingList
+	database.DB.First(&list, "id = ?", listID)
+
+	receipt := models.Receipt{TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: list.FamilyID}, ListID: &listID, Status: "new"}
+	database.DB.Create(&receipt)
+	_, err := services.EnqueueReceiptJob(database.DB, &receipt)
+	assert.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	newRouter := func(familyID uuid.UUID) *gin.Engine {
+		r := gin.New()
+		r.GET("/receipts/:id", func(c *gin.Context) {
+			c.Set("family_id", familyID)
+			GetReceipt(c)
+		})
+		return r
+	}
+
+	w := doJSON(newRouter(list.FamilyID), http.MethodGet, "/receipts/"+receipt.ID.String(), "")
 	assert.Equal(t, http.StatusOK, w.Code)
 	var resp map[string]interface{}
 	json.Unmarshal(w.Body.Bytes(), &resp)
 	assert.Equal(t, "queued", resp["status"])
+
+	assert.Equal(t, http.StatusNotFound, doJSON(newRouter(uuid.New()), http.MethodGet, "/receipts/"+receipt.ID.String(), "").Code)
+	assert.Equal(t, http.StatusBadRequest, doJSON(newRouter(list.FamilyID), http.MethodGet, "/receipts/nope", "").Code)
+}
+
+func TestGetReceipts_Pagination(t *testing.T) {
+	listID := setupReceiptTestDB()
+	var list models.ShoppingList
+	database.DB.First(&list, "id = ?", listID)
+
+	for i := 1; i <= 3; i++ {
+		database.DB.Create(&models.Receipt{
+			TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: list.FamilyID},
+			Date:        time.Date(2026, 1, i, 0, 0, 0, 0, time.UTC),
+			Total:       float64(i * 100),
+			Status:      "parsed",
+		})
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/receipts", func(c *gin.Context) {
+		c.Set("family_id", list.FamilyID)
+		GetReceipts(c)
+	})
+
+	w := doJSON(r, http.MethodGet, "/receipts?limit=2&page=1&min_total=150", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Receipts   []models.Receipt `json:"receipts"`
+		Pagination struct {
+			Total      int  `json:"total"`
+			TotalPages int  `json:"total_pages"`
+			HasMore    bool `json:"has_more"`
+		} `json:"pagination"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Len(t, resp.Receipts, 2)
+	assert.Equal(t, 300.0, resp.Receipts[0].Total)
+	assert.Equal(t, 2, resp.Pagination.Total)
+	assert.Equal(t, 1, resp.Pagination.TotalPages)
+	assert.False(t, resp.Pagination.HasMore)
+
+	w = doJSON(r, http.MethodGet, "/receipts?limit=1&page=2", "")
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 200.0, resp.Receipts[0].Total)
+	assert.True(t, resp.Pagination.HasMore)
+
+	for _, q := range []string{"from=01.01.2026", "to=yesterday", "min_total=lots", "shop_id=lidl"} {
+		assert.Equal(t, http.StatusBadRequest, doJSON(r, http.MethodGet, "/receipts?"+q, "").Code, q)
+	}
 }
 
 // --- GetReceiptFile tests ---
@@ -331,7 +566,7 @@ func setupReceiptFileTestDB(t *testing.T) uuid.UUID {
 	}
 	database.DB.AutoMigrate(
 		&models.Item{}, &models.Family{},
-		&models.Receipt{}, &models.ReceiptItem{}, &models.Shop{},
+		&models.Receipt{}, &models.ReceiptItem{}, &models.ReceiptImage{}, &models.Shop{},
 	)
 
 	family := models.Family{Family: coremodels.Family{ID: uuid.New(), Name: "File Test Family"}}
@@ -379,6 +614,40 @@ func TestGetReceiptFile_Success(t *testing.T) {
 	assert.Equal(t, "fake image data", w.Body.String())
 }
 
+func TestGetReceiptFile_Page(t *testing.T) {
+	familyID := setupReceiptFileTestDB(t)
+
+	tmpDir := t.TempDir()
+	receipt := models.Receipt{
+		TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID},
+		Status:      "parsed",
+	}
+	for i, content := range []string{"top", "bottom"} {
+		path := fmt.Sprintf("families/%s/receipts/2026/03/part%d.jpg", familyID, i)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(tmpDir, path)), 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(tmpDir, path), []byte(content), 0644))
+		receipt.Images = append(receipt.Images, models.ReceiptImage{Position: i, Path: path})
+	}
+	receipt.ImagePath = receipt.Images[0].Path
+	database.DB.Create(&receipt)
+
+	r := newReceiptFileRouterWithFamily(tmpDir, familyID)
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/receipts/%s/file%s", receipt.ID, query), nil)
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, "top", get("").Body.String())
+	w := get("?page=2")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "bottom", w.Body.String())
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "-2.jpg")
+	assert.Equal(t, http.StatusNotFound, get("?page=3").Code)
+	assert.Equal(t, http.StatusBadRequest, get("?page=0").Code)
+}
+
 func TestGetReceiptFile_NotFound(t *testing.T) {
 	familyID := setupReceiptFileTestDB(t)
 
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/ya-breeze/kin-core/authdb"
+	"github.com/ya-breeze/kin-core/cookies"
+
+	"kincart/internal/database"
+	"kincart/internal/models"
+)
+
+// maxUserAgentLength caps what we store from the User-Agent header.
+const maxUserAgentLength = 512
+
+// requestUserAgent returns the User-Agent header, cut to maxUserAgentLength
+// bytes on a character boundary so the stored value stays valid UTF-8.
+func requestUserAgent(c *gin.Context) string {
+	ua := c.Request.UserAgent()
+	if len(ua) > maxUserAgentLength {
+		cut := maxUserAgentLength
+		for cut > 0 && !utf8.RuneStart(ua[cut]) {
+			cut--
+		}
+		ua = ua[:cut]
+	}
+	return ua
+}
+
+// recordSession stores the device metadata for a freshly issued refresh token.
+// Failures are logged, not returned: a missing session row only hides the
+// device from the sessions list.
+func recordSession(c *gin.Context, rt *authdb.RefreshToken) {
+	now := time.Now()
+	session := models.Session{
+		ID:             uuid.New(),
+		UserID:         rt.UserID,
+		RefreshTokenID: rt.ID,
+		UserAgent:      requestUserAgent(c),
+		IP:             c.ClientIP(),
+		LastUsedAt:     now,
+	}
+	if err := database.DB.Create(&session).Error; err != nil {
+		slog.Warn("Failed to record session", "user_id", rt.UserID, "error", err)
+	}
+}
+
+// rotateSession moves the session of oldToken onto its replacement and marks it
+// used. Tokens issued before sessions were tracked get a session on first refresh.
+func rotateSession(c *gin.Context, oldToken string, newRT *authdb.RefreshToken) {
+	var old authdb.RefreshToken
+	if err := database.DB.Unscoped().Select("id").Where("token = ?", oldToken).First(&old).Error; err == nil {
+		res := database.DB.Model(&models.Session{}).Where("refresh_token_id = ?", old.ID).Updates(map[string]interface{}{
+			"refresh_token_id": newRT.ID,
+			"user_agent":       requestUserAgent(c),
+			"ip":               c.ClientIP(),
+			"last_used_at":     time.Now(),
+		})
+		if res.Error != nil {
+			slog.Warn("Failed to update session", "user_id", newRT.UserID, "error", res.Error)
+			return
+		}
+		if res.RowsAffected > 0 {
+			return
+		}
+	}
+	recordSession(c, newRT)
+}
+
+// endSession drops the session of a refresh token that is being revoked.
+func endSession(refreshToken string) {
+	if refreshToken == "" {
+		return
+	}
+	var rt authdb.RefreshToken
+	if err := database.DB.Select("id").Where("token = ?", refreshToken).First(&rt).Error; err != nil {
+		return
+	}
+	if err := database.DB.Where("refresh_token_id = ?", rt.ID).Delete(&models.Session{}).Error; err != nil {
+		slog.Warn("Failed to delete session", "error", err)
+	}
+}
+
+type sessionResponse struct {
+	models.Session
+	Current bool `json:"current"`
+}
+
+// GetSessions lists the caller's signed-in devices, most recently used first.
+// The device making the request is flagged as current.
+// GET /api/auth/sessions
+func GetSessions(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	var sessions []models.Session
+	if err := database.DB.
+		Joins("JOIN refresh_tokens ON refresh_tokens.id = sessions.refresh_token_id").
+		Where("sessions.user_id = ? AND refresh_tokens.is_revoked = ? AND refresh_tokens.expires_at > ? AND refresh_tokens.deleted_at IS NULL",
+			userID, false, time.Now()).
+		Order("sessions.last_used_at DESC").
+		Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sessions"})
+		return
+	}
+
+	var currentTokenID uuid.UUID
+	if token := cookies.GetRefreshToken(c.Request); token != "" {
+		var rt authdb.RefreshToken
+		if err := database.DB.Select("id").Where("token = ?", token).First(&rt).Error; err == nil {
+			currentTokenID = rt.ID
+		}
+	}
+
+	resp := make([]sessionResponse, len(sessions))
+	for i, s := range sessions {
+		resp[i] = sessionResponse{Session: s, Current: s.RefreshTokenID == currentTokenID}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// RevokeSession signs out one of the caller's devices by revoking its refresh
+// token. That device's access token keeps working until it expires
+// (accessTokenTTL).
+// DELETE /api/auth/sessions/:id
+func RevokeSession(c *gin.Context) {
+	userID := c.MustGet("user_id").(uuid.UUID)
+
+	var session models.Session
+	if err := database.DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&session).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	if err := database.DB.Model(&authdb.RefreshToken{}).
+		Where("id = ? AND user_id = ?", session.RefreshTokenID, userID).
+		Update("is_revoked", true).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+	if err := database.DB.Where("id = ?", session.ID).Delete(&models.Session{}).Error; err != nil {
+		slog.Warn("Failed to delete revoked session", "session_id", session.ID, "error", err)
+	}
+
+	slog.Info("Session revoked", "user_id", userID, "session_id", session.ID, "ip", c.ClientIP())
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ya-breeze/kin-core/auth"
+	"github.com/ya-breeze/kin-core/authdb"
+	coremodels "github.com/ya-breeze/kin-core/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"kincart/internal/database"
+	"kincart/internal/models"
+)
+
+func refreshCookie(w *httptest.ResponseRecorder) *http.Cookie {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "kin_refresh" {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestSessions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var err error
+	database.DB, err = gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, database.DB.AutoMigrate(&models.Family{}, &models.User{}, &authdb.RefreshToken{}, &models.Session{}))
+
+	hash, _ := auth.HashPassword("password123")
+	user := models.User{User: coremodels.User{ID: uuid.New(), Username: "mum", PasswordHash: hash, FamilyID: uuid.New()}}
+	database.DB.Create(&user)
+
+	r := gin.New()
+	r.POST("/auth/login", Login)
+	r.POST("/auth/refresh", Refresh)
+	authed := r.Group("/")
+	authed.Use(func(c *gin.Context) {
+		c.Set("user_id", user.ID)
+		c.Next()
+	})
+	authed.GET("/auth/sessions", GetSessions)
+	authed.DELETE("/auth/sessions/:id", RevokeSession)
+
+	login := func(userAgent string) *http.Cookie {
+		req, _ := http.NewRequest(http.MethodPost, "/auth/login", bytes.NewBufferString(`{"username":"mum","password":"password123"}`))
+		req.Header.Set("User-Agent", userAgent)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		return refreshCookie(w)
+	}
+	listSessions := func(cookie *http.Cookie) []sessionResponse {
+		req, _ := http.NewRequest(http.MethodGet, "/auth/sessions", nil)
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var got []sessionResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+		return got
+	}
+
+	laptop := login("Firefox on Linux")
+	phone := login("KinCart Android")
+
+	// Refreshing rotates the token but keeps the same session.
+	req, _ := http.NewRequest(http.MethodPost, "/auth/refresh", nil)
+	req.Header.Set("User-Agent", "Firefox on Linux")
+	req.AddCookie(laptop)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	laptop = refreshCookie(w)
+
+	sessions := listSessions(laptop)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, "Firefox on Linux", sessions[0].UserAgent, "most recently used first")
+	assert.True(t, sessions[0].Current)
+	assert.False(t, sessions[1].Current)
+	assert.False(t, sessions[0].LastUsedAt.Before(sessions[0].CreatedAt))
+
+	t.Run("revoke the lost phone", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodDelete, "/auth/sessions/"+sessions[1].ID.String(), nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		remaining := listSessions(laptop)
+		require.Len(t, remaining, 1)
+		assert.Equal(t, "Firefox on Linux", remaining[0].UserAgent)
+
+		// The phone can no longer refresh.
+		req, _ = http.NewRequest(http.MethodPost, "/auth/refresh", nil)
+		req.AddCookie(phone)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("cannot revoke someone else's session", func(t *testing.T) {
+		other := models.Session{ID: uuid.New(), UserID: uuid.New(), RefreshTokenID: uuid.New()}
+		database.DB.Create(&other)
+
+		req, _ := http.NewRequest(http.MethodDelete, "/auth/sessions/"+other.ID.String(), nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestRequestUserAgent_CutsOnCharacterBoundary(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	// 511 ASCII bytes, then a two-byte "č" straddling the limit.
+	c.Request.Header.Set("User-Agent", strings.Repeat("a", maxUserAgentLength-1)+"čč")
+
+	ua := requestUserAgent(c)
+	assert.True(t, utf8.ValidString(ua))
+	assert.Equal(t, strings.Repeat("a", maxUserAgentLength-1), ua)
+}
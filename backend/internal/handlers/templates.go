@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	coremodels "github.com/ya-breeze/kin-core/models"
+	"gorm.io/gorm"
+
+	"kincart/internal/database"
+	"kincart/internal/models"
+	"kincart/internal/services"
+)
+
+type templateItemRequest struct {
+	Name        string     `json:"name" binding:"required"`
+	Description string     `json:"description"`
+	Quantity    float64    `json:"quantity"`
+	Unit        string     `json:"unit"`
+	CategoryID  *uuid.UUID `json:"category_id"`
+	IsUrgent    bool       `json:"is_urgent"`
+}
+
+type templateRequest struct {
+	Name         string     `json:"name" binding:"required"`
+	ShopID       *uuid.UUID `json:"shop_id"`
+	Recurrence   string     `json:"recurrence"`
+	IntervalDays int        `json:"interval_days"`
+	// StartAt is the first scheduled run. Omitted, the first list is created one
+	// interval from now.
+	StartAt *time.Time `json:"start_at"`
+	// Items replaces the template's items when present; omitted on update, the
+	// existing items are kept.
+	Items []templateItemRequest `json:"items"`
+}
+
+// updateTemplateRequest is a PATCH of a template: only the fields sent are
+// changed. An empty shop_id clears the shop.
+type updateTemplateRequest struct {
+	Name         *string               `json:"name"`
+	ShopID       *string               `json:"shop_id"`
+	Recurrence   *string               `json:"recurrence"`
+	IntervalDays *int                  `json:"interval_days"`
+	StartAt      *time.Time            `json:"start_at"`
+	Items        []templateItemRequest `json:"items"`
+}
+
+// validate checks the request against the family and normalizes the recurrence.
+func (r *templateRequest) validate(familyID uuid.UUID) error {
+	switch r.Recurrence {
+	case "":
+		r.Recurrence = models.RecurrenceNone
+	case models.RecurrenceNone, models.RecurrenceWeekly:
+	case models.RecurrenceEveryDays:
+		if r.IntervalDays < 1 {
+			return fmt.Errorf("interval_days must be at least 1 for every_n_days")
+		}
+	default:
+		return fmt.Errorf("invalid recurrence: %s", r.Recurrence)
+	}
+
+	if err := validateShopFamily(r.ShopID, familyID); err != nil {
+		return err
+	}
+	for _, ti := range r.Items {
+		if ti.CategoryID != nil && *ti.CategoryID != uuid.Nil {
+			var cat models.Category
+			if err := database.DB.Where("id = ? AND family_id = ?", *ti.CategoryID, familyID).First(&cat).Error; err != nil {
+				return fmt.Errorf("invalid category ID: %s", *ti.CategoryID)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *templateRequest) templateItems(templateID uuid.UUID) []models.ListTemplateItem {
+	items := make([]models.ListTemplateItem, 0, len(r.Items))
+	for i, ti := range r.Items {
+		categoryID := ti.CategoryID
+		if categoryID != nil && *categoryID == uuid.Nil {
+			categoryID = nil
+		}
+		items = append(items, models.ListTemplateItem{
+			TemplateID:  templateID,
+			Name:        ti.Name,
+			Description: ti.Description,
+			Quantity:    ti.Quantity,
+			Unit:        ti.Unit,
+			CategoryID:  categoryID,
+			IsUrgent:    ti.IsUrgent,
+			SortOrder:   i,
+		})
+	}
+	return items
+}
+
+// applySchedule sets next_run_at from the request. A non-recurring template has
+// no next run.
+func (r *templateRequest) applySchedule(tmpl *models.ListTemplate, now time.Time) {
+	tmpl.Recurrence = r.Recurrence
+	tmpl.IntervalDays = r.IntervalDays
+	if r.Recurrence == models.RecurrenceWeekly {
+		tmpl.IntervalDays = 7
+	}
+	switch {
+	case services.TemplateInterval(tmpl) == 0:
+		tmpl.NextRunAt = nil
+	case r.StartAt != nil:
+		start := *r.StartAt
+		tmpl.NextRunAt = &start
+	default:
+		next := now.Add(services.TemplateInterval(tmpl))
+		tmpl.NextRunAt = &next
+	}
+}
+
+func loadTemplate(familyID uuid.UUID, id string) (*models.ListTemplate, error) {
+	var tmpl models.ListTemplate
+	err := database.DB.Preload("Items", func(tx *gorm.DB) *gorm.DB {
+		return tx.Order("sort_order ASC, id ASC")
+	}).Where("id = ? AND family_id = ?", id, familyID).First(&tmpl).Error
+	if err != nil {
+		return nil, err
+	}
+	return &tmpl, nil
+}
+
+func GetTemplates(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+
+	var templates []models.ListTemplate
+	if err := database.DB.Preload("Items", func(tx *gorm.DB) *gorm.DB {
+		return tx.Order("sort_order ASC, id ASC")
+	}).Where("family_id = ?", familyID).Order("name ASC").Find(&templates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch templates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, templates)
+}
+
+func GetTemplate(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+
+	tmpl, err := loadTemplate(familyID, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tmpl)
+}
+
+func CreateTemplate(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+
+	var req templateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.validate(familyID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tmpl := models.ListTemplate{
+		TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID},
+		Name:        req.Name,
+		ShopID:      req.ShopID,
+	}
+	req.applySchedule(&tmpl, time.Now())
+	tmpl.Items = req.templateItems(tmpl.ID)
+
+	if err := database.DB.Create(&tmpl).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create template"})
+		return
+	}
+
+	slog.Info("List template created", "template_id", tmpl.ID, "family_id", familyID)
+	c.JSON(http.StatusCreated, tmpl)
+}
+
+func UpdateTemplate(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+
+	tmpl, err := loadTemplate(familyID, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	var patch updateTemplateRequest
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The template as it would be after the patch, checked like a new one.
+	req := templateRequest{
+		Name:         tmpl.Name,
+		ShopID:       tmpl.ShopID,
+		Recurrence:   tmpl.Recurrence,
+		IntervalDays: tmpl.IntervalDays,
+		StartAt:      patch.StartAt,
+		Items:        patch.Items,
+	}
+	if patch.Name != nil {
+		if *patch.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "name must not be empty"})
+			return
+		}
+		req.Name = *patch.Name
+	}
+	if patch.ShopID != nil {
+		req.ShopID = nil
+		if *patch.ShopID != "" {
+			shopID, err := uuid.Parse(*patch.ShopID)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid shop_id"})
+				return
+			}
+			req.ShopID = &shopID
+		}
+	}
+	if patch.Recurrence != nil {
+		req.Recurrence = *patch.Recurrence
+	}
+	if patch.IntervalDays != nil {
+		req.IntervalDays = *patch.IntervalDays
+	}
+	if err := req.validate(familyID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Keep the running schedule unless the rule itself changed or a new start
+	// was given; renaming a template must not push its next run out a week.
+	scheduleChanged := req.Recurrence != tmpl.Recurrence ||
+		(req.Recurrence == models.RecurrenceEveryDays && req.IntervalDays != tmpl.IntervalDays) ||
+		req.StartAt != nil
+
+	tmpl.Name = req.Name
+	tmpl.ShopID = req.ShopID
+	if scheduleChanged {
+		req.applySchedule(tmpl, time.Now())
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.ListTemplate{}).Where("id = ?", tmpl.ID).Updates(map[string]interface{}{
+			"name":          tmpl.Name,
+			"shop_id":       tmpl.ShopID,
+			"recurrence":    tmpl.Recurrence,
+			"interval_days": tmpl.IntervalDays,
+			"next_run_at":   tmpl.NextRunAt,
+		}).Error; err != nil {
+			return err
+		}
+		if req.Items == nil {
+			return nil
+		}
+		if err := tx.Where("template_id = ?", tmpl.ID).Delete(&models.ListTemplateItem{}).Error; err != nil {
+			return err
+		}
+		tmpl.Items = req.templateItems(tmpl.ID)
+		if len(tmpl.Items) == 0 {
+			return nil
+		}
+		return tx.Create(&tmpl.Items).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update template"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tmpl)
+}
+
+func DeleteTemplate(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+
+	tmpl, err := loadTemplate(familyID, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("template_id = ?", tmpl.ID).Delete(&models.ListTemplateItem{}).Error; err != nil {
+			return err
+		}
+		// Use explicit WHERE string to avoid GORM skipping zero UUID primary key
+		return tx.Where("id = ?", tmpl.ID).Delete(&models.ListTemplate{}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete template"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// InstantiateTemplate creates a list from the template right now, independent
+// of its schedule.
+// POST /api/templates/:id/instantiate
+func InstantiateTemplate(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+
+	tmpl, err := loadTemplate(familyID, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		return
+	}
+
+	list, err := services.InstantiateTemplate(c.Request.Context(), database.DB, tmpl, time.Now())
+	if err != nil {
+		slog.Error("Failed to instantiate template", "template_id", tmpl.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create list from template"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, list)
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coremodels "github.com/ya-breeze/kin-core/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"kincart/internal/database"
+	"kincart/internal/models"
+)
+
+func setupTemplateTestDB() {
+	var err error
+	database.DB, err = gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		panic("Failed to connect to database")
+	}
+	database.DB.AutoMigrate(&models.ShoppingList{}, &models.Item{}, &models.Family{}, &models.Category{},
+		&models.Shop{}, &models.ItemAlias{}, &models.ListTemplate{}, &models.ListTemplateItem{})
+}
+
+func setupTemplateRouter(familyID uuid.UUID) *gin.Engine {
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("family_id", familyID)
+		c.Next()
+	})
+	r.GET("/templates/:id", GetTemplate)
+	r.POST("/templates", CreateTemplate)
+	r.PATCH("/templates/:id", UpdateTemplate)
+	r.POST("/templates/:id/instantiate", InstantiateTemplate)
+	return r
+}
+
+func TestTemplateHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("create weekly template and instantiate it", func(t *testing.T) {
+		setupTemplateTestDB()
+		familyID := uuid.New()
+		r := setupTemplateRouter(familyID)
+
+		body := `{"name":"BBQ set","recurrence":"weekly","items":[{"name":"Sausages","quantity":2},{"name":"Charcoal"}]}`
+		req, _ := http.NewRequest(http.MethodPost, "/templates", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		var created models.ListTemplate
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+		assert.Equal(t, 7, created.IntervalDays)
+		assert.NotNil(t, created.NextRunAt)
+		assert.Len(t, created.Items, 2)
+
+		req, _ = http.NewRequest(http.MethodPost, fmt.Sprintf("/templates/%s/instantiate", created.ID), nil)
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		var list models.ShoppingList
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+		assert.Equal(t, familyID, list.FamilyID)
+		assert.Len(t, list.Items, 2)
+	})
+
+	t.Run("every_n_days requires an interval", func(t *testing.T) {
+		setupTemplateTestDB()
+		r := setupTemplateRouter(uuid.New())
+
+		req, _ := http.NewRequest(http.MethodPost, "/templates", bytes.NewBufferString(`{"name":"x","recurrence":"every_n_days"}`))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("foreign category is rejected", func(t *testing.T) {
+		setupTemplateTestDB()
+		foreign := models.Category{TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: uuid.New()}, Name: "Theirs"}
+		database.DB.Create(&foreign)
+		r := setupTemplateRouter(uuid.New())
+
+		body := fmt.Sprintf(`{"name":"x","items":[{"name":"Milk","category_id":"%s"}]}`, foreign.ID)
+		req, _ := http.NewRequest(http.MethodPost, "/templates", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("rename keeps schedule and items", func(t *testing.T) {
+		setupTemplateTestDB()
+		familyID := uuid.New()
+		r := setupTemplateRouter(familyID)
+
+		req, _ := http.NewRequest(http.MethodPost, "/templates",
+			bytes.NewBufferString(`{"name":"Restock","recurrence":"every_n_days","interval_days":3,"items":[{"name":"Eggs"}]}`))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code)
+		var created models.ListTemplate
+		json.Unmarshal(w.Body.Bytes(), &created)
+
+		req, _ = http.NewRequest(http.MethodPatch, fmt.Sprintf("/templates/%s", created.ID),
+			bytes.NewBufferString(`{"name":"Restock v2","recurrence":"every_n_days","interval_days":3}`))
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var updated models.ListTemplate
+		json.Unmarshal(w.Body.Bytes(), &updated)
+		assert.Equal(t, "Restock v2", updated.Name)
+		assert.Len(t, updated.Items, 1)
+		assert.True(t, created.NextRunAt.Equal(*updated.NextRunAt))
+	})
+
+	t.Run("a name-only PATCH keeps recurrence, next run and shop", func(t *testing.T) {
+		setupTemplateTestDB()
+		familyID := uuid.New()
+		shop := models.Shop{TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID}, Name: "Lidl"}
+		database.DB.Create(&shop)
+		r := setupTemplateRouter(familyID)
+
+		req, _ := http.NewRequest(http.MethodPost, "/templates",
+			bytes.NewBufferString(fmt.Sprintf(`{"name":"Weekly shop","recurrence":"weekly","shop_id":"%s"}`, shop.ID)))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code)
+		var created models.ListTemplate
+		json.Unmarshal(w.Body.Bytes(), &created)
+
+		req, _ = http.NewRequest(http.MethodPatch, fmt.Sprintf("/templates/%s", created.ID),
+			bytes.NewBufferString(`{"name":"Big weekly shop"}`))
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var stored models.ListTemplate
+		require.NoError(t, database.DB.First(&stored, "id = ?", created.ID).Error)
+		assert.Equal(t, "Big weekly shop", stored.Name)
+		assert.Equal(t, models.RecurrenceWeekly, stored.Recurrence)
+		require.NotNil(t, stored.NextRunAt)
+		assert.True(t, created.NextRunAt.Equal(*stored.NextRunAt))
+		require.NotNil(t, stored.ShopID)
+		assert.Equal(t, shop.ID, *stored.ShopID)
+
+		req, _ = http.NewRequest(http.MethodPatch, fmt.Sprintf("/templates/%s", created.ID),
+			bytes.NewBufferString(`{"shop_id":""}`))
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		var cleared models.ListTemplate
+		require.NoError(t, database.DB.First(&cleared, "id = ?", created.ID).Error)
+		assert.Nil(t, cleared.ShopID, "an empty shop_id clears the shop")
+		assert.Equal(t, "Big weekly shop", cleared.Name)
+
+		req, _ = http.NewRequest(http.MethodPatch, fmt.Sprintf("/templates/%s", created.ID),
+			bytes.NewBufferString(`{"name":""}`))
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("other family's template is not found", func(t *testing.T) {
+		setupTemplateTestDB()
+		tmpl := models.ListTemplate{TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: uuid.New()}, Name: "Theirs"}
+		database.DB.Create(&tmpl)
+		r := setupTemplateRouter(uuid.New())
+
+		req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("/templates/%s/instantiate", tmpl.ID), nil)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
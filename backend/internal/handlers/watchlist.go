@@ -0,0 +1,282 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	coremodels "github.com/ya-breeze/kin-core/models"
+
+	"kincart/internal/database"
+	"kincart/internal/events"
+	"kincart/internal/models"
+	"kincart/internal/services"
+)
+
+type watchlistRequest struct {
+	Name        string   `json:"name" binding:"required"`
+	TargetPrice *float64 `json:"target_price"`
+	Shops       []string `json:"shops"`
+}
+
+// shops joins the preferred shops into the stored comma-separated form.
+func (r *watchlistRequest) shops() string {
+	cleaned := make([]string, 0, len(r.Shops))
+	for _, s := range r.Shops {
+		if s = strings.TrimSpace(s); s != "" {
+			cleaned = append(cleaned, s)
+		}
+	}
+	return strings.Join(cleaned, ",")
+}
+
+func (r *watchlistRequest) validate() error {
+	if services.NormalizeWatchTerm(r.Name) == "" {
+		return fmt.Errorf("name must not be empty")
+	}
+	if r.TargetPrice != nil && *r.TargetPrice < 0 {
+		return fmt.Errorf("target_price must not be negative")
+	}
+	return nil
+}
+
+func GetWatchlist(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+
+	var watches []models.WatchlistItem
+	if err := database.DB.Where("family_id = ?", familyID).Order("name ASC").Find(&watches).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch watchlist"})
+		return
+	}
+
+	c.JSON(http.StatusOK, watches)
+}
+
+// CreateWatchlistItem adds an entry and immediately checks it against the
+// offers already parsed, so a deal that is running right now is not missed.
+func CreateWatchlistItem(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+
+	var req watchlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	watch := models.WatchlistItem{
+		TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID},
+		Name:        strings.TrimSpace(req.Name),
+		SearchTerm:  services.NormalizeWatchTerm(req.Name),
+		TargetPrice: req.TargetPrice,
+		Shops:       req.shops(),
+	}
+	if err := database.DB.Create(&watch).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create watchlist item"})
+		return
+	}
+
+	matches, err := services.MatchWatchlistEntry(c.Request.Context(), database.DB, &watch)
+	if err != nil {
+		slog.Warn("Could not match new watchlist entry against current flyers", "watch_id", watch.ID, "error", err)
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"item": watch, "matches": len(matches)})
+}
+
+func UpdateWatchlistItem(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+
+	var watch models.WatchlistItem
+	if err := database.DB.Where("id = ? AND family_id = ?", c.Param("id"), familyID).First(&watch).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Watchlist item not found"})
+		return
+	}
+
+	var req watchlistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	watch.Name = strings.TrimSpace(req.Name)
+	watch.SearchTerm = services.NormalizeWatchTerm(req.Name)
+	watch.TargetPrice = req.TargetPrice
+	watch.Shops = req.shops()
+	if err := database.DB.Model(&models.WatchlistItem{}).Where("id = ?", watch.ID).Updates(map[string]interface{}{
+		"name":         watch.Name,
+		"search_term":  watch.SearchTerm,
+		"target_price": watch.TargetPrice,
+		"shops":        watch.Shops,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update watchlist item"})
+		return
+	}
+
+	// Loosened criteria may now match offers the old ones did not.
+	if _, err := services.MatchWatchlistEntry(c.Request.Context(), database.DB, &watch); err != nil {
+		slog.Warn("Could not match updated watchlist entry against current flyers", "watch_id", watch.ID, "error", err)
+	}
+
+	c.JSON(http.StatusOK, watch)
+}
+
+func DeleteWatchlistItem(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+
+	var watch models.WatchlistItem
+	if err := database.DB.Where("id = ? AND family_id = ?", c.Param("id"), familyID).First(&watch).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Watchlist item not found"})
+		return
+	}
+
+	if err := database.DB.Where("watchlist_item_id = ?", watch.ID).Delete(&models.WatchlistMatch{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete watchlist item"})
+		return
+	}
+	// Use explicit WHERE string to avoid GORM skipping zero UUID primary key
+	if err := database.DB.Where("id = ?", watch.ID).Delete(&models.WatchlistItem{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete watchlist item"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetWatchlistMatches lists deal notifications, newest first. Dismissed ones
+// are hidden unless ?include_dismissed=true.
+func GetWatchlistMatches(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+
+	q := database.DB.Preload("WatchlistItem").Preload("FlyerItem").Where("family_id = ?", familyID)
+	if c.Query("include_dismissed") != "true" {
+		q = q.Where("is_dismissed = ?", false)
+	}
+
+	var matches []models.WatchlistMatch
+	if err := q.Order("created_at DESC").Limit(200).Find(&matches).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch watchlist matches"})
+		return
+	}
+
+	// FlyerItem.ShopName is a join-only column; the match stored it.
+	for i := range matches {
+		if matches[i].FlyerItem != nil {
+			matches[i].FlyerItem.ShopName = matches[i].ShopName
+		}
+	}
+
+	c.JSON(http.StatusOK, matches)
+}
+
+func loadWatchlistMatch(c *gin.Context, familyID uuid.UUID) (*models.WatchlistMatch, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid id"})
+		return nil, false
+	}
+
+	var match models.WatchlistMatch
+	if err := database.DB.Preload("FlyerItem").Where("id = ? AND family_id = ?", id, familyID).First(&match).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Match not found"})
+		return nil, false
+	}
+	return &match, true
+}
+
+type addMatchToListRequest struct {
+	ListID uuid.UUID `json:"list_id" binding:"required"`
+}
+
+// AddWatchlistMatchToList is the one-click action on a deal notification: the
+// offer becomes a flyer-linked item on the chosen list, like adding it from the
+// flyer browser, and the notification is marked as handled.
+// POST /api/watchlist/matches/:id/add-to-list
+func AddWatchlistMatchToList(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+
+	match, ok := loadWatchlistMatch(c, familyID)
+	if !ok {
+		return
+	}
+	if match.AddedItemID != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Already added to a list", "item_id": match.AddedItemID})
+		return
+	}
+	if match.FlyerItem == nil {
+		c.JSON(http.StatusGone, gin.H{"error": "Flyer offer no longer exists"})
+		return
+	}
+
+	var req addMatchToListRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var list models.ShoppingList
+	if err := database.DB.Where("id = ? AND family_id = ?", req.ListID, familyID).First(&list).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "List not found"})
+		return
+	}
+
+	offer := match.FlyerItem
+	flyerItemID := offer.ID
+	item := models.Item{
+		TenantModel:    coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID},
+		Name:           offer.Name,
+		Description:    fmt.Sprintf("Deal from %s (%s)", match.ShopName, offer.Quantity),
+		Quantity:       1,
+		Price:          offer.Price,
+		LocalPhotoPath: offer.LocalPhotoPath,
+		ListID:         list.ID,
+		FlyerItemID:    &flyerItemID,
+	}
+	single := []models.Item{item}
+	applyRememberedDefaults(c.Request.Context(), single, familyID, list.ShopID)
+	item = single[0]
+
+	if err := database.DB.Create(&item).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add item"})
+		return
+	}
+	bumpItemFrequency(familyID, item.Name)
+
+	if err := database.DB.Model(&models.WatchlistMatch{}).Where("id = ?", match.ID).Updates(map[string]interface{}{
+		"added_to_list_id": list.ID,
+		"added_item_id":    item.ID,
+		"is_dismissed":     true,
+	}).Error; err != nil {
+		slog.Error("Failed to mark watchlist match as added", "match_id", match.ID, "error", err)
+	}
+
+	publishListEvent(familyID, list.ID, events.ItemCreated, item)
+	c.JSON(http.StatusCreated, item)
+}
+
+func DismissWatchlistMatch(c *gin.Context) {
+	familyID := c.MustGet("family_id").(uuid.UUID)
+
+	match, ok := loadWatchlistMatch(c, familyID)
+	if !ok {
+		return
+	}
+
+	if err := database.DB.Model(&models.WatchlistMatch{}).Where("id = ?", match.ID).Update("is_dismissed", true).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to dismiss match"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coremodels "github.com/ya-breeze/kin-core/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"kincart/internal/database"
+	"kincart/internal/models"
+	"kincart/internal/utils"
+)
+
+func setupWatchlistTestDB() {
+	var err error
+	database.DB, err = gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		panic("Failed to connect to database")
+	}
+	database.DB.AutoMigrate(&models.ShoppingList{}, &models.Item{}, &models.Family{}, &models.Category{},
+		&models.ItemFrequency{}, &models.ItemAlias{}, &models.Flyer{}, &models.FlyerItem{},
+		&models.WatchlistItem{}, &models.WatchlistMatch{})
+}
+
+func setupWatchlistRouter(familyID uuid.UUID) *gin.Engine {
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("family_id", familyID)
+		c.Next()
+	})
+	r.POST("/watchlist", CreateWatchlistItem)
+	r.GET("/watchlist/matches", GetWatchlistMatches)
+	r.POST("/watchlist/matches/:id/add-to-list", AddWatchlistMatchToList)
+	return r
+}
+
+func TestWatchlistHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	setupWatchlistTestDB()
+	familyID := uuid.New()
+	flyer := models.Flyer{ShopName: "Kaufland", URL: "https://example.test/kaufland"}
+	database.DB.Create(&flyer)
+	offer := models.FlyerItem{
+		FlyerID:    flyer.ID,
+		Name:       "Čokoláda mléčná",
+		Price:      24.9,
+		Quantity:   "100g",
+		StartDate:  time.Now().AddDate(0, 0, -1),
+		EndDate:    time.Now().AddDate(0, 0, 3),
+		SearchText: utils.NormalizeSearchText("Čokoláda mléčná"),
+	}
+	database.DB.Create(&offer)
+	list := models.ShoppingList{TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID}, Title: "Weekly"}
+	database.DB.Create(&list)
+
+	r := setupWatchlistRouter(familyID)
+
+	// Creating the entry matches the offer that is already running.
+	req, _ := http.NewRequest(http.MethodPost, "/watchlist", bytes.NewBufferString(`{"name":"cokolada","target_price":30}`))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"matches":1`)
+
+	req, _ = http.NewRequest(http.MethodGet, "/watchlist/matches", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var matches []models.WatchlistMatch
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &matches))
+	require.Len(t, matches, 1)
+	assert.Equal(t, "Kaufland", matches[0].FlyerItem.ShopName)
+
+	t.Run("another family cannot add the match", func(t *testing.T) {
+		other := setupWatchlistRouter(uuid.New())
+		body := fmt.Sprintf(`{"list_id":"%s"}`, list.ID)
+		req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("/watchlist/matches/%d/add-to-list", matches[0].ID), bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		other.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("one-click add creates a flyer-linked item once", func(t *testing.T) {
+		body := fmt.Sprintf(`{"list_id":"%s"}`, list.ID)
+		req, _ := http.NewRequest(http.MethodPost, fmt.Sprintf("/watchlist/matches/%d/add-to-list", matches[0].ID), bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		require.Equal(t, http.StatusCreated, w.Code)
+
+		var item models.Item
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &item))
+		assert.Equal(t, list.ID, item.ListID)
+		assert.Equal(t, 24.9, item.Price)
+		require.NotNil(t, item.FlyerItemID)
+		assert.Equal(t, offer.ID, *item.FlyerItemID)
+
+		req, _ = http.NewRequest(http.MethodPost, fmt.Sprintf("/watchlist/matches/%d/add-to-list", matches[0].ID), bytes.NewBufferString(body))
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+}
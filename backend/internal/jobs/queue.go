@@ -0,0 +1,354 @@
+// Package jobs is a small persistent work queue. Jobs are rows in the database,
+// so work queued before a restart is picked up after it. A failed job is retried
+// with exponential backoff and becomes dead once it runs out of attempts; a dead
+// job stays put until someone retries it.
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"kincart/internal/models"
+)
+
+// Job states.
+const (
+	StatePending   = "pending"
+	StateRunning   = "running"
+	StateFailed    = "failed" // the last attempt failed; another is scheduled at RunAt
+	StateSucceeded = "succeeded"
+	StateDead      = "dead" // out of attempts or failed permanently; only Retry revives it
+)
+
+// DefaultMaxAttempts is used when Enqueue is not given a limit.
+const DefaultMaxAttempts = 5
+
+var (
+	// ErrNotFound is returned when a job does not exist or belongs to another family.
+	ErrNotFound = errors.New("job not found")
+	// ErrNotRetryable is returned when retrying a job that has not failed.
+	ErrNotRetryable = errors.New("only failed or dead jobs can be retried")
+)
+
+// activeStates are the states in which a job still owns its ref: Enqueue returns
+// such a job instead of adding a second one.
+var activeStates = []string{StatePending, StateRunning, StateFailed, StateDead}
+
+// Handler does the work for one job. Returning an error schedules a retry
+// unless it is wrapped with Permanent.
+type Handler func(ctx context.Context, job models.Job) error
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying (e.g. the receipt was deleted):
+// the job goes straight to dead.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+// Options tune a single Enqueue call. The zero value is a job for no family,
+// due now, with DefaultMaxAttempts.
+type Options struct {
+	FamilyID    *uuid.UUID
+	MaxAttempts int
+	RunAt       time.Time
+}
+
+// Enqueue adds a job of kind for ref. If ref already has a job that has not
+// succeeded, that job is returned instead, so periodic sweeps can enqueue
+// everything outstanding without piling up duplicates, and do not revive dead
+// jobs behind the user's back.
+func Enqueue(db *gorm.DB, kind, ref string, opts Options) (*models.Job, error) {
+	var existing models.Job
+	err := db.Where("kind = ? AND ref = ? AND state IN ?", kind, ref, activeStates).First(&existing).Error
+	if err == nil {
+		return &existing, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("failed to check existing job: %w", err)
+	}
+
+	job := models.Job{
+		FamilyID:    opts.FamilyID,
+		Kind:        kind,
+		Ref:         ref,
+		State:       StatePending,
+		MaxAttempts: opts.MaxAttempts,
+		RunAt:       opts.RunAt,
+	}
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = DefaultMaxAttempts
+	}
+	if job.RunAt.IsZero() {
+		job.RunAt = time.Now()
+	}
+	if err := db.Create(&job).Error; err != nil {
+		return nil, fmt.Errorf("failed to enqueue job: %w", err)
+	}
+	wake(kind)
+	return &job, nil
+}
+
+// Retry puts a failed or dead job back in the queue with a fresh set of
+// attempts, due now. A non-nil familyID restricts it to that family's jobs.
+func Retry(db *gorm.DB, id uint, familyID *uuid.UUID) (*models.Job, error) {
+	q := db.Where("id = ?", id)
+	if familyID != nil {
+		q = q.Where("family_id = ?", *familyID)
+	}
+	var job models.Job
+	if err := q.First(&job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if job.State != StateFailed && job.State != StateDead {
+		return nil, ErrNotRetryable
+	}
+
+	res := db.Model(&job).Where("state = ?", job.State).Updates(map[string]interface{}{
+		"state":       StatePending,
+		"attempts":    0,
+		"run_at":      time.Now(),
+		"finished_at": nil,
+	})
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrNotRetryable // picked up by a worker in the meantime
+	}
+	if err := db.First(&job, job.ID).Error; err != nil {
+		return nil, err
+	}
+	wake(job.Kind)
+	return &job, nil
+}
+
+// wakers holds, per kind, the wake channels of the queues that run it, so new
+// work starts right away instead of at the next poll.
+var (
+	wakersMu sync.Mutex
+	wakers   = make(map[string][]chan struct{})
+)
+
+func wake(kind string) {
+	wakersMu.Lock()
+	defer wakersMu.Unlock()
+	for _, ch := range wakers[kind] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Queue runs due jobs for the kinds it has handlers for. Jobs of other kinds
+// wait, so work can be queued before its worker is configured (e.g. flyer pages
+// downloaded while no AI provider is set up). Several queues may share the
+// table as long as they run different kinds.
+type Queue struct {
+	db   *gorm.DB
+	wake chan struct{}
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+
+	// Workers is how many jobs run at once.
+	Workers int
+
+	// The n-th failed attempt is retried after BaseBackoff * 2^(n-1), at most MaxBackoff.
+	BaseBackoff  time.Duration
+	MaxBackoff   time.Duration
+	PollInterval time.Duration
+
+	now func() time.Time
+}
+
+func NewQueue(db *gorm.DB) *Queue {
+	return &Queue{
+		db:           db,
+		wake:         make(chan struct{}, 1),
+		handlers:     make(map[string]Handler),
+		Workers:      1,
+		BaseBackoff:  time.Minute,
+		MaxBackoff:   6 * time.Hour,
+		PollInterval: 30 * time.Second,
+		now:          time.Now,
+	}
+}
+
+// Register makes the queue run jobs of kind with h.
+func (q *Queue) Register(kind string, h Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[kind] = h
+
+	wakersMu.Lock()
+	defer wakersMu.Unlock()
+	wakers[kind] = append(wakers[kind], q.wake)
+}
+
+func (q *Queue) kinds() []string {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	kinds := make([]string, 0, len(q.handlers))
+	for kind := range q.handlers {
+		kinds = append(kinds, kind)
+	}
+	return kinds
+}
+
+// Start requeues this queue's jobs cut off by a restart, then runs Workers
+// workers that pick up due jobs when woken by Enqueue or every PollInterval,
+// until ctx is done.
+func (q *Queue) Start(ctx context.Context) {
+	// A single server runs the queue, so anything of ours still marked running
+	// was interrupted by the previous shutdown.
+	if kinds := q.kinds(); len(kinds) > 0 {
+		if err := q.db.Model(&models.Job{}).Where("state = ? AND kind IN ?", StateRunning, kinds).
+			Update("state", StatePending).Error; err != nil {
+			slog.Error("Failed to requeue interrupted jobs", "error", err)
+		}
+	}
+
+	for range max(q.Workers, 1) {
+		go func() {
+			ticker := time.NewTicker(q.PollInterval)
+			defer ticker.Stop()
+
+			for {
+				q.RunDue(ctx)
+
+				select {
+				case <-ticker.C:
+				case <-q.wake:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+}
+
+// RunDue runs due jobs one after another until none are left, and returns how
+// many ran. Each worker calls it; claiming a job wakes an idle sibling in case
+// more are due.
+func (q *Queue) RunDue(ctx context.Context) int {
+	count := 0
+	for ctx.Err() == nil {
+		job, handler, err := q.claim()
+		if err != nil {
+			slog.Error("Failed to claim job", "error", err)
+			break
+		}
+		if job == nil {
+			break
+		}
+		select {
+		case q.wake <- struct{}{}:
+		default:
+		}
+		q.run(ctx, job, handler)
+		count++
+	}
+	return count
+}
+
+// claim marks the next due job as running. The state check in the UPDATE
+// keeps two workers from taking the same job.
+func (q *Queue) claim() (*models.Job, Handler, error) {
+	kinds := q.kinds()
+	if len(kinds) == 0 {
+		return nil, nil, nil
+	}
+
+	for {
+		var job models.Job
+		err := q.db.Where("kind IN ? AND state IN ? AND run_at <= ?", kinds, []string{StatePending, StateFailed}, q.now()).
+			Order("run_at, id").First(&job).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		res := q.db.Model(&models.Job{}).Where("id = ? AND state = ?", job.ID, job.State).Updates(map[string]interface{}{
+			"state":    StateRunning,
+			"attempts": gorm.Expr("attempts + 1"),
+		})
+		if res.Error != nil {
+			return nil, nil, res.Error
+		}
+		if res.RowsAffected == 0 {
+			continue // someone else got it
+		}
+
+		job.State = StateRunning
+		job.Attempts++
+		q.mu.RLock()
+		h := q.handlers[job.Kind]
+		q.mu.RUnlock()
+		return &job, h, nil
+	}
+}
+
+func (q *Queue) run(ctx context.Context, job *models.Job, h Handler) {
+	err := safeRun(ctx, *job, h)
+	now := q.now()
+
+	var updates map[string]interface{}
+	var permanent permanentError
+	switch {
+	case err == nil:
+		updates = map[string]interface{}{"state": StateSucceeded, "last_error": "", "finished_at": now}
+	case ctx.Err() != nil && !errors.As(err, &permanent):
+		// Shutting down: not the job's fault, so give the attempt back.
+		updates = map[string]interface{}{"state": StatePending, "attempts": job.Attempts - 1}
+	case errors.As(err, &permanent) || job.Attempts >= job.MaxAttempts:
+		slog.Error("Job failed for good", "id", job.ID, "kind", job.Kind, "ref", job.Ref, "attempts", job.Attempts, "error", err)
+		updates = map[string]interface{}{"state": StateDead, "last_error": err.Error(), "finished_at": now}
+	default:
+		delay := q.backoff(job.Attempts)
+		slog.Warn("Job failed, will retry", "id", job.ID, "kind", job.Kind, "ref", job.Ref, "attempt", job.Attempts, "retry_in", delay, "error", err)
+		updates = map[string]interface{}{"state": StateFailed, "last_error": err.Error(), "run_at": now.Add(delay)}
+	}
+
+	if err := q.db.Model(&models.Job{}).Where("id = ?", job.ID).Updates(updates).Error; err != nil {
+		slog.Error("Failed to record job result", "id", job.ID, "error", err)
+	}
+}
+
+// safeRun turns a panicking handler into a failed attempt instead of a dead queue.
+func safeRun(ctx context.Context, job models.Job, h Handler) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return h(ctx, job)
+}
+
+// backoff is the delay before retrying after the given failed attempt.
+func (q *Queue) backoff(attempt int) time.Duration {
+	delay := q.BaseBackoff
+	for i := 1; i < attempt && delay < q.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, q.MaxBackoff)
+}
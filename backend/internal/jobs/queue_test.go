@@ -0,0 +1,226 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"kincart/internal/models"
+)
+
+func setupQueue(t *testing.T) (*gorm.DB, *Queue, *time.Time) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Job{}))
+
+	// Just ahead of the jobs the test enqueues, which are due at time.Now().
+	clock := time.Now().Add(time.Second)
+	q := NewQueue(db)
+	q.now = func() time.Time { return clock }
+	return db, q, &clock
+}
+
+func reload(t *testing.T, db *gorm.DB, id uint) models.Job {
+	t.Helper()
+	var job models.Job
+	require.NoError(t, db.First(&job, id).Error)
+	return job
+}
+
+func TestQueue(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("retries with exponential backoff until dead", func(t *testing.T) {
+		db, q, clock := setupQueue(t)
+		runs := 0
+		q.Register("flaky", func(ctx context.Context, job models.Job) error {
+			runs++
+			return errors.New("upstream down")
+		})
+
+		job, err := Enqueue(db, "flaky", "1", Options{MaxAttempts: 3})
+		require.NoError(t, err)
+
+		assert.Equal(t, 1, q.RunDue(ctx))
+		got := reload(t, db, job.ID)
+		assert.Equal(t, StateFailed, got.State)
+		assert.Equal(t, 1, got.Attempts)
+		assert.Equal(t, "upstream down", got.LastError)
+		assert.WithinDuration(t, clock.Add(time.Minute), got.RunAt, time.Second)
+
+		// Not due yet.
+		assert.Equal(t, 0, q.RunDue(ctx))
+
+		*clock = clock.Add(time.Minute)
+		assert.Equal(t, 1, q.RunDue(ctx))
+		got = reload(t, db, job.ID)
+		assert.Equal(t, StateFailed, got.State)
+		assert.WithinDuration(t, clock.Add(2*time.Minute), got.RunAt, time.Second)
+
+		*clock = clock.Add(2 * time.Minute)
+		assert.Equal(t, 1, q.RunDue(ctx))
+		got = reload(t, db, job.ID)
+		assert.Equal(t, StateDead, got.State)
+		assert.Equal(t, 3, got.Attempts)
+		assert.NotNil(t, got.FinishedAt)
+
+		*clock = clock.Add(24 * time.Hour)
+		assert.Equal(t, 0, q.RunDue(ctx), "dead jobs are not picked up")
+		assert.Equal(t, 3, runs)
+	})
+
+	t.Run("permanent error goes straight to dead", func(t *testing.T) {
+		db, q, _ := setupQueue(t)
+		q.Register("gone", func(ctx context.Context, job models.Job) error {
+			return Permanent(errors.New("receipt deleted"))
+		})
+		job, err := Enqueue(db, "gone", "1", Options{})
+		require.NoError(t, err)
+		assert.Equal(t, DefaultMaxAttempts, job.MaxAttempts)
+
+		q.RunDue(ctx)
+		got := reload(t, db, job.ID)
+		assert.Equal(t, StateDead, got.State)
+		assert.Equal(t, 1, got.Attempts)
+	})
+
+	t.Run("panic counts as a failed attempt", func(t *testing.T) {
+		db, q, _ := setupQueue(t)
+		q.Register("boom", func(ctx context.Context, job models.Job) error { panic("nil map") })
+		job, _ := Enqueue(db, "boom", "1", Options{})
+
+		q.RunDue(ctx)
+		got := reload(t, db, job.ID)
+		assert.Equal(t, StateFailed, got.State)
+		assert.Contains(t, got.LastError, "nil map")
+	})
+
+	t.Run("only registered kinds run", func(t *testing.T) {
+		db, q, _ := setupQueue(t)
+		q.Register("known", func(ctx context.Context, job models.Job) error { return nil })
+		unknown, _ := Enqueue(db, "unknown", "1", Options{})
+		known, _ := Enqueue(db, "known", "1", Options{})
+
+		assert.Equal(t, 1, q.RunDue(ctx))
+		assert.Equal(t, StatePending, reload(t, db, unknown.ID).State)
+		assert.Equal(t, StateSucceeded, reload(t, db, known.ID).State)
+	})
+
+	t.Run("canceled run gives the attempt back", func(t *testing.T) {
+		db, q, _ := setupQueue(t)
+		cctx, cancel := context.WithCancel(ctx)
+		q.Register("slow", func(ctx context.Context, job models.Job) error {
+			cancel()
+			return ctx.Err()
+		})
+		job, _ := Enqueue(db, "slow", "1", Options{})
+
+		q.RunDue(cctx)
+		got := reload(t, db, job.ID)
+		assert.Equal(t, StatePending, got.State)
+		assert.Equal(t, 0, got.Attempts)
+	})
+
+	t.Run("backoff is capped", func(t *testing.T) {
+		_, q, _ := setupQueue(t)
+		assert.Equal(t, time.Minute, q.backoff(1))
+		assert.Equal(t, 8*time.Minute, q.backoff(4))
+		assert.Equal(t, q.MaxBackoff, q.backoff(30))
+	})
+}
+
+func TestQueueStart(t *testing.T) {
+	db, q, _ := setupQueue(t)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1) // every connection to :memory: is a database of its own
+	q.now = time.Now
+	q.PollInterval = time.Hour // only a wake-up can start the jobs in time
+	q.Workers = 2
+
+	// Left running by a previous process.
+	stale, _ := Enqueue(db, "upload", "stale", Options{})
+	db.Model(stale).Update("state", StateRunning)
+
+	release := make(chan struct{})
+	started := make(chan string, 3)
+	q.Register("upload", func(ctx context.Context, job models.Job) error {
+		started <- job.Ref
+		<-release
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	q.Start(ctx)
+
+	waitStarted := func() string {
+		select {
+		case ref := <-started:
+			return ref
+		case <-time.After(5 * time.Second):
+			t.Fatal("job did not start")
+			return ""
+		}
+	}
+	assert.Equal(t, "stale", waitStarted(), "interrupted jobs are requeued")
+
+	// The second worker picks up new work while the first is busy.
+	_, err = Enqueue(db, "upload", "fresh", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "fresh", waitStarted())
+	close(release)
+}
+
+func TestEnqueue(t *testing.T) {
+	db, _, _ := setupQueue(t)
+
+	first, err := Enqueue(db, "receipt_parse", "abc", Options{})
+	require.NoError(t, err)
+	again, err := Enqueue(db, "receipt_parse", "abc", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, first.ID, again.ID, "outstanding job is reused")
+
+	db.Model(first).Update("state", StateDead)
+	again, _ = Enqueue(db, "receipt_parse", "abc", Options{})
+	assert.Equal(t, first.ID, again.ID, "a sweep does not revive a dead job")
+
+	db.Model(first).Update("state", StateSucceeded)
+	again, _ = Enqueue(db, "receipt_parse", "abc", Options{})
+	assert.NotEqual(t, first.ID, again.ID, "finished work can be queued again")
+}
+
+func TestRetry(t *testing.T) {
+	db, _, _ := setupQueue(t)
+	familyID := uuid.New()
+
+	job, _ := Enqueue(db, "receipt_parse", "abc", Options{FamilyID: &familyID})
+
+	_, err := Retry(db, job.ID, &familyID)
+	assert.ErrorIs(t, err, ErrNotRetryable, "pending jobs are already queued")
+
+	db.Model(job).Updates(map[string]interface{}{"state": StateDead, "attempts": 5, "last_error": "boom", "run_at": time.Now().Add(time.Hour)})
+
+	other := uuid.New()
+	_, err = Retry(db, job.ID, &other)
+	assert.ErrorIs(t, err, ErrNotFound, "other families cannot see the job")
+
+	retried, err := Retry(db, job.ID, &familyID)
+	require.NoError(t, err)
+	assert.Equal(t, StatePending, retried.State)
+	assert.Equal(t, 0, retried.Attempts)
+	assert.Equal(t, "boom", retried.LastError, "the last error is kept for reference")
+	assert.Nil(t, retried.FinishedAt)
+	assert.False(t, retried.RunAt.After(time.Now()))
+
+	_, err = Retry(db, 999, nil)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
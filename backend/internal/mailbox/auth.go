@@ -0,0 +1,119 @@
+package mailbox
+
+import (
+	"strings"
+)
+
+// SenderAuthenticated reports whether the receiving mail server vouched for
+// the message's From domain: its Authentication-Results show SPF, DKIM or
+// DMARC passing for that domain or a parent or subdomain of it.
+//
+// Anyone can add Authentication-Results headers to a message they send, even
+// ones naming the receiving server, so only the one the receiving server
+// added on top is trusted: the topmost whose authserv-id is authServID, or,
+// when authServID is empty, the topmost one.
+func SenderAuthenticated(msg *Message, authServID string) bool {
+	at := strings.LastIndex(msg.From, "@")
+	if at < 0 {
+		return false
+	}
+	fromDomain := strings.ToLower(msg.From[at+1:])
+
+	for _, header := range msg.AuthResults {
+		id, results := parseAuthResults(header)
+		if authServID != "" && !strings.EqualFold(id, authServID) {
+			continue
+		}
+		for _, r := range results {
+			if r.result == "pass" && alignedDomain(r.domain, fromDomain) {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+type authResult struct {
+	method string // "spf", "dkim" or "dmarc"
+	result string
+	domain string // the domain the result is for
+}
+
+// parseAuthResults reads an Authentication-Results header (RFC 8601) into its
+// authserv-id and the SPF, DKIM and DMARC results with the domain each is
+// about. Other methods are skipped.
+func parseAuthResults(header string) (string, []authResult) {
+	parts := strings.Split(stripComments(header), ";")
+	id := ""
+	if fields := strings.Fields(parts[0]); len(fields) > 0 {
+		id = fields[0]
+	}
+
+	var results []authResult
+	for _, part := range parts[1:] {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		method, result, ok := strings.Cut(strings.ToLower(fields[0]), "=")
+		if !ok {
+			continue
+		}
+		r := authResult{method: method, result: result}
+		for _, prop := range fields[1:] {
+			key, value, ok := strings.Cut(prop, "=")
+			if !ok {
+				continue
+			}
+			value = strings.ToLower(strings.Trim(value, `"`))
+			switch {
+			case method == "spf" && strings.EqualFold(key, "smtp.mailfrom"),
+				method == "dkim" && strings.EqualFold(key, "header.i"):
+				if at := strings.LastIndex(value, "@"); at >= 0 {
+					value = value[at+1:]
+				}
+				if r.domain == "" {
+					r.domain = value
+				}
+			case method == "dkim" && strings.EqualFold(key, "header.d"),
+				method == "dmarc" && strings.EqualFold(key, "header.from"):
+				r.domain = value
+			}
+		}
+		switch method {
+		case "spf", "dkim", "dmarc":
+			results = append(results, r)
+		}
+	}
+	return id, results
+}
+
+// stripComments drops the parenthesised comments from a header value.
+func stripComments(s string) string {
+	var b strings.Builder
+	depth := 0
+	for _, r := range s {
+		switch {
+		case r == '(':
+			depth++
+		case r == ')' && depth > 0:
+			depth--
+		case depth == 0:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// alignedDomain reports whether a result for domain speaks for fromDomain:
+// they are the same or one is a subdomain of the other. A bare top-level
+// domain speaks for nobody.
+func alignedDomain(domain, fromDomain string) bool {
+	if !strings.Contains(domain, ".") {
+		return false
+	}
+	return domain == fromDomain ||
+		strings.HasSuffix(fromDomain, "."+domain) ||
+		strings.HasSuffix(domain, "."+fromDomain)
+}
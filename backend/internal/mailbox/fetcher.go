@@ -0,0 +1,409 @@
+// Package mailbox reads messages and their attachments from an IMAP mailbox.
+// Messages are identified by UID, which is only stable together with the
+// folder's UIDVALIDITY; callers that remember what they processed should key
+// on both.
+package mailbox
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/quotedprintable"
+	"net/mail"
+	"sort"
+	"strings"
+	"time"
+
+	imap "github.com/emersion/go-imap/v2"
+	"github.com/emersion/go-imap/v2/imapclient"
+)
+
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+type Message struct {
+	UID      uint32
+	Subject  string
+	From     string // sender address, e.g. "newsletter@lidl.cz"
+	FromName string
+	Date     time.Time
+	// Recipients are the addresses the message was sent or delivered to
+	// (To, Cc, Delivered-To, X-Original-To), lowercase.
+	Recipients []string
+	// AuthResults are the message's Authentication-Results headers, topmost
+	// (added last, by the receiving server) first.
+	AuthResults []string
+	Attachments []Attachment
+	// Text is the body as plain text, from its text/plain part or else its
+	// text/html part with the markup stripped. Only filled in for Query.Text.
+	Text string
+}
+
+// Account is an IMAP account and the folder to read in it.
+type Account struct {
+	Server   string `json:"server"` // host:port, over TLS
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Folder   string `json:"folder"` // default INBOX
+}
+
+func (a Account) folder() string {
+	if a.Folder == "" {
+		return "INBOX"
+	}
+	return a.Folder
+}
+
+// Name identifies the account's folder, e.g. to remember which of its
+// messages were handled: "user@server/folder".
+func (a Account) Name() string {
+	return a.User + "@" + a.Server + "/" + a.folder()
+}
+
+// MessageURL is the RFC 5092 IMAP URL of a message in the account's folder.
+func (a Account) MessageURL(uidValidity, uid uint32) string {
+	return fmt.Sprintf("imap://%s@%s/%s;UIDVALIDITY=%d/;UID=%d", a.User, a.Server, a.folder(), uidValidity, uid)
+}
+
+// Fetch reads the account's folder; q.Folder is ignored.
+func (a Account) Fetch(q Query) (uint32, []Message, error) {
+	q.Folder = a.folder()
+	return NewFetcher(a.Server, a.User, a.Password).Fetch(q)
+}
+
+// Query selects the messages Fetch returns.
+type Query struct {
+	Folder string    // default INBOX
+	Since  time.Time // only messages received on or after this day; zero: all
+	// Subjects, when set, keeps messages whose subject contains one of them,
+	// ignoring case.
+	Subjects []string
+	// Limit keeps only the newest messages; zero: all.
+	Limit int
+	// Skip, when set, drops messages before anything is downloaded, e.g.
+	// ones already handled.
+	Skip func(uidValidity, uid uint32) bool
+	// Match, when set, is given each message with its envelope filled in and
+	// decides whether it is kept.
+	Match func(msg *Message) bool
+	// Attachments downloads the attachments of the kept messages.
+	Attachments bool
+	// Text downloads the body text of the kept messages.
+	Text bool
+}
+
+// Fetcher reads one IMAP account over TLS.
+type Fetcher struct {
+	server   string
+	user     string
+	password string
+}
+
+func NewFetcher(server, user, password string) *Fetcher {
+	return &Fetcher{
+		server:   server,
+		user:     user,
+		password: password,
+	}
+}
+
+// Fetch returns the folder's UIDVALIDITY and the messages matching q, oldest
+// first.
+func (f *Fetcher) Fetch(q Query) (uint32, []Message, error) {
+	slog.Info("Connecting to IMAP server", "server", f.server, "folder", q.Folder, "attachments", q.Attachments, "subjects", q.Subjects)
+	c, err := imapclient.DialTLS(f.server, nil)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to dial IMAP: %w", err)
+	}
+	defer c.Close()
+
+	if err = c.Login(f.user, f.password).Wait(); err != nil {
+		return 0, nil, fmt.Errorf("failed to login: %w", err)
+	}
+	defer func() { _ = c.Logout().Wait() }()
+
+	folder := q.Folder
+	if folder == "" {
+		folder = "INBOX"
+	}
+	mbox, err := c.Select(folder, &imap.SelectOptions{ReadOnly: true}).Wait()
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to select %s: %w", folder, err)
+	}
+	if mbox.NumMessages == 0 {
+		return mbox.UIDValidity, nil, nil
+	}
+
+	uids, err := f.searchMessages(c, q)
+	if err != nil {
+		return 0, nil, err
+	}
+	if q.Skip != nil {
+		kept := uids[:0]
+		for _, uid := range uids {
+			if !q.Skip(mbox.UIDValidity, uint32(uid)) {
+				kept = append(kept, uid)
+			}
+		}
+		uids = kept
+	}
+	if q.Limit > 0 && len(uids) > q.Limit {
+		uids = uids[len(uids)-q.Limit:]
+	}
+	if len(uids) == 0 {
+		return mbox.UIDValidity, nil, nil
+	}
+
+	msgs, err := f.fetchMessages(c, uids, q)
+	return mbox.UIDValidity, msgs, err
+}
+
+// searchMessages returns the UIDs matching the date and subject criteria, in
+// ascending order.
+func (f *Fetcher) searchMessages(c *imapclient.Client, q Query) ([]imap.UID, error) {
+	criteria := []imap.SearchCriteria{{Since: q.Since}}
+	if len(q.Subjects) > 0 {
+		criteria = criteria[:0]
+		for _, sub := range q.Subjects {
+			criteria = append(criteria, imap.SearchCriteria{
+				Since:  q.Since,
+				Header: []imap.SearchCriteriaHeaderField{{Key: "Subject", Value: sub}},
+			})
+		}
+	}
+
+	seen := make(map[imap.UID]bool)
+	var uids []imap.UID
+	for _, cr := range criteria {
+		data, err := c.UIDSearch(&cr, nil).Wait()
+		if err != nil {
+			return nil, fmt.Errorf("failed to search: %w", err)
+		}
+		for _, uid := range data.AllUIDs() {
+			if !seen[uid] {
+				seen[uid] = true
+				uids = append(uids, uid)
+			}
+		}
+	}
+	sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+	return uids, nil
+}
+
+func (f *Fetcher) fetchMessages(c *imapclient.Client, uids []imap.UID, q Query) ([]Message, error) {
+	headers := &imap.FetchItemBodySection{
+		Specifier:    imap.PartSpecifierHeader,
+		HeaderFields: []string{"Delivered-To", "X-Original-To", "Authentication-Results"},
+		Peek:         true,
+	}
+	bufs, err := c.Fetch(imap.UIDSetNum(uids...), &imap.FetchOptions{
+		UID:           true,
+		Envelope:      true,
+		BodyStructure: &imap.FetchItemBodyStructure{},
+		BodySection:   []*imap.FetchItemBodySection{headers},
+	}).Collect()
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch messages: %w", err)
+	}
+	sort.Slice(bufs, func(i, j int) bool { return bufs[i].UID < bufs[j].UID })
+
+	var msgs []Message
+	for _, buf := range bufs {
+		if buf.Envelope == nil {
+			slog.Error("Skipping message without envelope", "uid", buf.UID)
+			continue
+		}
+		msg := Message{
+			UID:     uint32(buf.UID),
+			Subject: buf.Envelope.Subject,
+			Date:    buf.Envelope.Date,
+		}
+		if len(buf.Envelope.From) > 0 {
+			msg.From = strings.ToLower(buf.Envelope.From[0].Addr())
+			msg.FromName = buf.Envelope.From[0].Name
+		}
+		for _, addr := range append(append([]imap.Address{}, buf.Envelope.To...), buf.Envelope.Cc...) {
+			if a := addr.Addr(); a != "" {
+				msg.Recipients = append(msg.Recipients, strings.ToLower(a))
+			}
+		}
+		readHeaders(&msg, buf.FindBodySection(headers))
+
+		// Servers match SEARCH loosely; check the subject here too.
+		if len(q.Subjects) > 0 && !containsAny(msg.Subject, q.Subjects) {
+			continue
+		}
+		if q.Match != nil && !q.Match(&msg) {
+			continue
+		}
+
+		if q.Attachments && buf.BodyStructure != nil {
+			for _, part := range findAttachmentParts(buf.BodyStructure, nil) {
+				data, err := f.fetchPart(c, buf.UID, part)
+				if err != nil {
+					slog.Error("Failed to fetch part", "uid", buf.UID, "part", part.path, "error", err)
+					continue
+				}
+				msg.Attachments = append(msg.Attachments, Attachment{
+					Filename:    part.filename,
+					ContentType: part.contentType,
+					Data:        data,
+				})
+			}
+		}
+		if q.Text && buf.BodyStructure != nil {
+			if part, ok := textPart(buf.BodyStructure); ok {
+				data, err := f.fetchPart(c, buf.UID, part)
+				if err != nil {
+					slog.Error("Failed to fetch body", "uid", buf.UID, "part", part.path, "error", err)
+				} else if part.contentType == "text/html" {
+					msg.Text = htmlToText(string(data))
+				} else {
+					msg.Text = strings.TrimSpace(string(data))
+				}
+			}
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+type partInfo struct {
+	path        []int
+	filename    string
+	contentType string
+	encoding    string
+}
+
+// readHeaders adds the delivery addresses and authentication results in the
+// raw header fields to msg.
+func readHeaders(msg *Message, raw []byte) {
+	if len(raw) == 0 {
+		return
+	}
+	m, err := mail.ReadMessage(bytes.NewReader(append(raw, "\r\n"...)))
+	if err != nil {
+		slog.Warn("Failed to read message headers", "uid", msg.UID, "error", err)
+		return
+	}
+	for _, key := range []string{"Delivered-To", "X-Original-To"} {
+		for _, v := range m.Header[key] {
+			if addr, err := mail.ParseAddress(v); err == nil {
+				msg.Recipients = append(msg.Recipients, strings.ToLower(addr.Address))
+			}
+		}
+	}
+	msg.AuthResults = append(msg.AuthResults, m.Header["Authentication-Results"]...)
+}
+
+// findAttachmentParts lists the parts that carry a file name: attachments and
+// named inline parts such as embedded images.
+func findAttachmentParts(bs imap.BodyStructure, path []int) []partInfo {
+	var parts []partInfo
+
+	switch bs := bs.(type) {
+	case *imap.BodyStructureMultiPart:
+		for i, child := range bs.Children {
+			childPath := append(append([]int{}, path...), i+1)
+			parts = append(parts, findAttachmentParts(child, childPath)...)
+		}
+	case *imap.BodyStructureSinglePart:
+		if filename := bs.Filename(); filename != "" {
+			if len(path) == 0 {
+				path = []int{1} // a single-part message's body is part 1
+			}
+			parts = append(parts, partInfo{
+				path:        path,
+				filename:    filename,
+				contentType: strings.ToLower(bs.Type + "/" + bs.Subtype),
+				encoding:    strings.ToLower(bs.Encoding),
+			})
+		}
+	}
+
+	return parts
+}
+
+// findTextParts lists the body parts without a file name that are
+// text/plain or text/html, in message order.
+func findTextParts(bs imap.BodyStructure, path []int) []partInfo {
+	var parts []partInfo
+
+	switch bs := bs.(type) {
+	case *imap.BodyStructureMultiPart:
+		for i, child := range bs.Children {
+			childPath := append(append([]int{}, path...), i+1)
+			parts = append(parts, findTextParts(child, childPath)...)
+		}
+	case *imap.BodyStructureSinglePart:
+		contentType := strings.ToLower(bs.Type + "/" + bs.Subtype)
+		if bs.Filename() == "" && (contentType == "text/plain" || contentType == "text/html") {
+			if len(path) == 0 {
+				path = []int{1}
+			}
+			parts = append(parts, partInfo{
+				path:        path,
+				contentType: contentType,
+				encoding:    strings.ToLower(bs.Encoding),
+			})
+		}
+	}
+
+	return parts
+}
+
+// textPart picks the part Message.Text is read from: the first text/plain
+// one, else the first text/html one.
+func textPart(bs imap.BodyStructure) (partInfo, bool) {
+	parts := findTextParts(bs, nil)
+	for _, p := range parts {
+		if p.contentType == "text/plain" {
+			return p, true
+		}
+	}
+	if len(parts) > 0 {
+		return parts[0], true
+	}
+	return partInfo{}, false
+}
+
+// fetchPart downloads one part and undoes its transfer encoding.
+func (f *Fetcher) fetchPart(c *imapclient.Client, uid imap.UID, part partInfo) ([]byte, error) {
+	section := &imap.FetchItemBodySection{Part: part.path, Peek: true}
+	bufs, err := c.Fetch(imap.UIDSetNum(uid), &imap.FetchOptions{
+		BodySection: []*imap.FetchItemBodySection{section},
+	}).Collect()
+	if err != nil {
+		return nil, err
+	}
+	if len(bufs) == 0 || len(bufs[0].BodySection) == 0 {
+		return nil, fmt.Errorf("part not found")
+	}
+	return decodePart(bufs[0].BodySection[0].Bytes, part.encoding)
+}
+
+func decodePart(data []byte, encoding string) ([]byte, error) {
+	switch encoding {
+	case "base64":
+		return io.ReadAll(base64.NewDecoder(base64.StdEncoding, bytes.NewReader(data)))
+	case "quoted-printable":
+		return io.ReadAll(quotedprintable.NewReader(bytes.NewReader(data)))
+	default:
+		return data, nil
+	}
+}
+
+func containsAny(s string, substrs []string) bool {
+	s = strings.ToLower(s)
+	for _, sub := range substrs {
+		if strings.Contains(s, strings.ToLower(sub)) {
+			return true
+		}
+	}
+	return false
+}
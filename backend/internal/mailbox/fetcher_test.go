@@ -0,0 +1,139 @@
+package mailbox
+
+import (
+	"testing"
+
+	imap "github.com/emersion/go-imap/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindAttachmentParts(t *testing.T) {
+	bs := &imap.BodyStructureMultiPart{
+		Subtype: "mixed",
+		Children: []imap.BodyStructure{
+			&imap.BodyStructureMultiPart{
+				Subtype: "alternative",
+				Children: []imap.BodyStructure{
+					&imap.BodyStructureSinglePart{Type: "text", Subtype: "plain"},
+					&imap.BodyStructureSinglePart{Type: "image", Subtype: "png", Params: map[string]string{"name": "logo.png"}},
+				},
+			},
+			&imap.BodyStructureSinglePart{
+				Type: "application", Subtype: "PDF", Encoding: "BASE64",
+				Extended: &imap.BodyStructureSinglePartExt{
+					Disposition: &imap.BodyStructureDisposition{Value: "attachment", Params: map[string]string{"filename": "letak.pdf"}},
+				},
+			},
+		},
+	}
+
+	parts := findAttachmentParts(bs, nil)
+	require.Len(t, parts, 2)
+	assert.Equal(t, partInfo{path: []int{1, 2}, filename: "logo.png", contentType: "image/png"}, parts[0])
+	assert.Equal(t, partInfo{path: []int{2}, filename: "letak.pdf", contentType: "application/pdf", encoding: "base64"}, parts[1])
+
+	single := &imap.BodyStructureSinglePart{Type: "application", Subtype: "pdf", Params: map[string]string{"name": "a.pdf"}}
+	assert.Equal(t, []int{1}, findAttachmentParts(single, nil)[0].path, "a single-part body is part 1")
+}
+
+func TestDecodePart(t *testing.T) {
+	got, err := decodePart([]byte("JVBERi0x\r\nLjQK"), "base64")
+	require.NoError(t, err)
+	assert.Equal(t, "%PDF-1.4\n", string(got))
+
+	got, err = decodePart([]byte("Nov=C3=BD let=C3=A1k"), "quoted-printable")
+	require.NoError(t, err)
+	assert.Equal(t, "Nový leták", string(got))
+
+	got, err = decodePart([]byte("plain"), "7bit")
+	require.NoError(t, err)
+	assert.Equal(t, "plain", string(got))
+}
+
+func TestTextPart(t *testing.T) {
+	bs := &imap.BodyStructureMultiPart{
+		Subtype: "mixed",
+		Children: []imap.BodyStructure{
+			&imap.BodyStructureMultiPart{
+				Subtype: "alternative",
+				Children: []imap.BodyStructure{
+					&imap.BodyStructureSinglePart{Type: "text", Subtype: "html", Encoding: "quoted-printable"},
+					&imap.BodyStructureSinglePart{Type: "text", Subtype: "plain"},
+				},
+			},
+			&imap.BodyStructureSinglePart{Type: "text", Subtype: "plain", Params: map[string]string{"name": "terms.txt"}},
+		},
+	}
+
+	part, ok := textPart(bs)
+	require.True(t, ok)
+	assert.Equal(t, partInfo{path: []int{1, 2}, contentType: "text/plain"}, part)
+
+	htmlOnly := &imap.BodyStructureSinglePart{Type: "TEXT", Subtype: "HTML", Encoding: "base64"}
+	part, ok = textPart(htmlOnly)
+	require.True(t, ok)
+	assert.Equal(t, partInfo{path: []int{1}, contentType: "text/html", encoding: "base64"}, part)
+
+	_, ok = textPart(&imap.BodyStructureSinglePart{Type: "application", Subtype: "pdf"})
+	assert.False(t, ok)
+}
+
+func TestHTMLToText(t *testing.T) {
+	body := `<html><head><style>td { color: red }</style></head><body>
+		<p>D&#283;kujeme za n&aacute;kup</p>
+		<table>
+			<tr><td>Rohl&iacute;k</td><td>4 ks</td><td>15,60&nbsp;K&#269;</td></tr>
+			<tr><td>M&aacute;slo</td><td>1 ks</td><td>59,90&nbsp;K&#269;</td></tr>
+		</table>
+		Celkem:<br>75,50 K&#269;
+	</body></html>`
+
+	assert.Equal(t, "Děkujeme za nákup\nRohlík 4 ks 15,60 Kč\nMáslo 1 ks 59,90 Kč\nCelkem:\n75,50 Kč", htmlToText(body))
+}
+
+func TestReadHeaders(t *testing.T) {
+	msg := Message{}
+	readHeaders(&msg, []byte("Delivered-To: receipts+abc@example.test\r\n"+
+		"Authentication-Results: mx.example.test;\r\n dkim=pass header.d=lidl.cz\r\n"+
+		"Authentication-Results: spoofed; spf=pass smtp.mailfrom=lidl.cz\r\n\r\n"))
+
+	assert.Equal(t, []string{"receipts+abc@example.test"}, msg.Recipients)
+	assert.Equal(t, []string{"mx.example.test; dkim=pass header.d=lidl.cz", "spoofed; spf=pass smtp.mailfrom=lidl.cz"}, msg.AuthResults)
+}
+
+func TestSenderAuthenticated(t *testing.T) {
+	for name, tc := range map[string]struct {
+		from       string
+		results    []string
+		authServID string
+		want       bool
+	}{
+		"dkim pass": {"jana@gmail.com", []string{
+			"mx.example.test; dkim=pass (2048-bit key) header.d=gmail.com header.i=@gmail.com; spf=none"}, "", true},
+		"spf pass from a subdomain": {"noreply@lidl.cz", []string{
+			"mx.example.test; spf=pass (sender IP is 1.2.3.4) smtp.mailfrom=bounce@mail.lidl.cz; dkim=none"}, "", true},
+		"dmarc pass": {"info@billa.cz", []string{
+			`mx.example.test; dmarc=pass (p=REJECT) header.from="billa.cz"`}, "", true},
+		"pass for another domain": {"jana@gmail.com", []string{
+			"mx.example.test; dkim=pass header.d=evil.test; spf=pass smtp.mailfrom=evil.test"}, "", false},
+		"fail": {"jana@gmail.com", []string{
+			"mx.example.test; dkim=fail header.d=gmail.com; spf=softfail smtp.mailfrom=gmail.com"}, "", false},
+		"no results": {"jana@gmail.com", nil, "", false},
+		"a forged header below the server's": {"jana@gmail.com", []string{
+			"mx.example.test; spf=fail smtp.mailfrom=gmail.com",
+			"mx.example.test; dkim=pass header.d=gmail.com"}, "", false},
+		"the configured server's header": {"jana@gmail.com", []string{
+			"spoofed; dkim=pass header.d=gmail.com",
+			"mx.example.test; dkim=pass header.d=gmail.com"}, "mx.example.test", true},
+		"a forged header naming the server": {"jana@gmail.com", []string{
+			"mx.example.test; dkim=fail header.d=gmail.com",
+			"mx.example.test; dkim=pass header.d=gmail.com"}, "mx.example.test", false},
+		"only forged headers": {"jana@gmail.com", []string{
+			"spoofed; dkim=pass header.d=gmail.com"}, "mx.example.test", false},
+		"top-level domain": {"x@com", []string{"mx.example.test; spf=pass smtp.mailfrom=com"}, "", false},
+	} {
+		msg := Message{From: tc.from, AuthResults: tc.results}
+		assert.Equal(t, tc.want, SenderAuthenticated(&msg, tc.authServID), name)
+	}
+}
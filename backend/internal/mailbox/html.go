@@ -0,0 +1,36 @@
+package mailbox
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	htmlHidden  = regexp.MustCompile(`(?is)<(style|script|head)\b.*?</(style|script|head)>`)
+	htmlBreak   = regexp.MustCompile(`(?i)<(br|/p|/div|/tr|/li|/h[1-6])\b[^>]*>`)
+	htmlCell    = regexp.MustCompile(`(?i)</t[dh]>`)
+	htmlTag     = regexp.MustCompile(`(?s)<[^>]*>`)
+	blankRuns   = regexp.MustCompile(`[ \t\x{00a0}]+`)
+	newlineRuns = regexp.MustCompile(`\n\s*\n+`)
+)
+
+// htmlToText reduces an HTML mail body to its text, keeping one line per
+// paragraph or table row and separating table cells with a space. That is
+// enough for a receipt: the AI reads it, not a person.
+func htmlToText(s string) string {
+	s = htmlHidden.ReplaceAllString(s, "")
+	s = htmlBreak.ReplaceAllString(s, "\n")
+	s = htmlCell.ReplaceAllString(s, " ")
+	s = htmlTag.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	s = strings.ReplaceAll(s, "\r", "")
+	s = blankRuns.ReplaceAllString(s, " ")
+
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimSpace(l)
+	}
+	s = strings.Join(lines, "\n")
+	return strings.TrimSpace(newlineRuns.ReplaceAllString(s, "\n"))
+}
@@ -12,6 +12,8 @@ import (
 	"github.com/ya-breeze/kin-core/cookies"
 	kinmiddleware "github.com/ya-breeze/kin-core/middleware"
 	"gorm.io/gorm"
+
+	"kincart/internal/models"
 )
 
 func newHourlyTicker() *time.Ticker {
@@ -73,6 +75,11 @@ func CleanupTokens(db *gorm.DB) {
 			if err := authdb.CleanupExpiredRefreshTokens(db); err != nil {
 				slog.Warn("Failed to cleanup expired refresh tokens", "error", err)
 			}
+			// Sessions whose refresh token is gone or revoked are signed out.
+			active := db.Model(&authdb.RefreshToken{}).Select("id").Where("is_revoked = ?", false)
+			if err := db.Where("refresh_token_id NOT IN (?)", active).Delete(&models.Session{}).Error; err != nil {
+				slog.Warn("Failed to cleanup ended sessions", "error", err)
+			}
 		}
 	}()
 }
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"kincart/internal/models"
+)
+
+// RequireRole returns a Gin handler that only lets users holding at least min
+// through. It must run after AuthMiddleware and sets "role" in the context.
+//
+// The role is read from the database on every request rather than carried in
+// the JWT, so demoting or removing a member takes effect immediately instead of
+// when their access token expires.
+func RequireRole(db *gorm.DB, min string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, ok := c.Get("user_id")
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+
+		// Only the role is needed; a previous RequireRole in the chain already
+		// loaded it for this request.
+		role, cached := c.Get("role")
+		if !cached {
+			var user models.User
+			if err := db.Select("id", "role").Where("id = ?", userID.(uuid.UUID)).First(&user).Error; err != nil {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+				return
+			}
+			role = user.Role
+			c.Set("role", role)
+		}
+
+		if !models.RoleAtLeast(role.(string), min) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+			return
+		}
+		c.Next()
+	}
+}
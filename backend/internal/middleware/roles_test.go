@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coremodels "github.com/ya-breeze/kin-core/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"kincart/internal/models"
+)
+
+func TestRequireRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.User{}))
+
+	mkUser := func(name, role string) uuid.UUID {
+		u := models.User{User: coremodels.User{ID: uuid.New(), Username: name, FamilyID: uuid.New()}, Role: role}
+		require.NoError(t, db.Create(&u).Error)
+		return u.ID
+	}
+	shopper := mkUser("kid", models.RoleShopper)
+	manager := mkUser("mum", models.RoleManager)
+	admin := mkUser("dad", models.RoleAdmin)
+
+	// A user created without a role (e.g. before roles existed) is a manager.
+	legacy := models.User{User: coremodels.User{ID: uuid.New(), Username: "legacy"}}
+	require.NoError(t, db.Create(&legacy).Error)
+
+	tests := []struct {
+		name           string
+		userID         *uuid.UUID
+		minRole        string
+		expectedStatus int
+	}{
+		{name: "no user in context", userID: nil, minRole: models.RoleShopper, expectedStatus: http.StatusUnauthorized},
+		{name: "unknown user", userID: ptr(uuid.New()), minRole: models.RoleShopper, expectedStatus: http.StatusUnauthorized},
+		{name: "shopper on shopper route", userID: &shopper, minRole: models.RoleShopper, expectedStatus: http.StatusOK},
+		{name: "shopper on manager route", userID: &shopper, minRole: models.RoleManager, expectedStatus: http.StatusForbidden},
+		{name: "manager on manager route", userID: &manager, minRole: models.RoleManager, expectedStatus: http.StatusOK},
+		{name: "manager on admin route", userID: &manager, minRole: models.RoleAdmin, expectedStatus: http.StatusForbidden},
+		{name: "admin on manager route", userID: &admin, minRole: models.RoleManager, expectedStatus: http.StatusOK},
+		{name: "legacy user defaults to manager", userID: &legacy.ID, minRole: models.RoleManager, expectedStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(func(c *gin.Context) {
+				if tt.userID != nil {
+					c.Set("user_id", *tt.userID)
+				}
+				c.Next()
+			})
+			r.GET("/test", RequireRole(db, tt.minRole), func(c *gin.Context) {
+				c.String(http.StatusOK, c.GetString("role"))
+			})
+
+			req, _ := http.NewRequest(http.MethodGet, "/test", nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
@@ -1,6 +1,10 @@
 package models
 
 import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -16,8 +20,36 @@ type Family struct {
 	Shops    []Shop         `gorm:"foreignKey:FamilyID" json:"shops"`
 }
 
+// User roles, from least to most privileged. Shoppers work through a list in
+// the store (read, tick items bought); managers plan (lists, items, categories,
+// shops, receipts); admins also manage the family's members.
+const (
+	RoleShopper = "shopper"
+	RoleManager = "manager"
+	RoleAdmin   = "admin"
+)
+
+var roleRank = map[string]int{RoleShopper: 1, RoleManager: 2, RoleAdmin: 3}
+
+// ValidRole reports whether role is one of the known roles.
+func ValidRole(role string) bool {
+	_, ok := roleRank[role]
+	return ok
+}
+
+// RoleAtLeast reports whether role grants everything min does. Unknown roles
+// grant nothing.
+func RoleAtLeast(role, min string) bool {
+	return roleRank[role] > 0 && roleRank[role] >= roleRank[min]
+}
+
 type User struct {
 	coremodels.User
+	// Email is optional; it is only used to deliver notifications by SMTP.
+	Email string `json:"email"`
+	// Role defaults to manager, which is what every user could do before roles
+	// existed; admins are assigned explicitly (cmd/admin set-role).
+	Role string `gorm:"default:'manager'" json:"role"`
 }
 
 type ShoppingList struct {
@@ -51,6 +83,44 @@ type Item struct {
 	IsReceiptCreated bool      `gorm:"default:false" json:"is_receipt_created"`
 }
 
+// Recurrence values for ListTemplate. RecurrenceNone templates are only ever
+// instantiated by hand.
+const (
+	RecurrenceNone      = "none"
+	RecurrenceWeekly    = "weekly"
+	RecurrenceEveryDays = "every_n_days"
+)
+
+// ListTemplate is a reusable set of items ("Weekly restock", "BBQ set") that a
+// fresh ShoppingList can be created from, by hand or on a recurring schedule.
+type ListTemplate struct {
+	coremodels.TenantModel
+	Name       string     `gorm:"not null" json:"name"`
+	ShopID     *uuid.UUID `gorm:"type:uuid" json:"shop_id"`
+	Recurrence string     `gorm:"default:'none'" json:"recurrence"` // "none", "weekly", "every_n_days"
+	// IntervalDays is only read for every_n_days; weekly is always 7.
+	IntervalDays int `json:"interval_days"`
+	// NextRunAt is when the scheduler next creates a list; nil for "none".
+	NextRunAt *time.Time         `gorm:"index" json:"next_run_at"`
+	LastRunAt *time.Time         `json:"last_run_at"`
+	Items     []ListTemplateItem `gorm:"foreignKey:TemplateID" json:"items"`
+}
+
+// ListTemplateItem is one line of a template. Unit and category are optional:
+// left empty, they are resolved from alias history each time the template is
+// instantiated, so the list picks up what the family has been buying lately.
+type ListTemplateItem struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	TemplateID  uuid.UUID  `gorm:"type:uuid;not null;index" json:"template_id"`
+	Name        string     `gorm:"not null" json:"name"`
+	Description string     `json:"description"`
+	Quantity    float64    `gorm:"default:1" json:"quantity"`
+	Unit        string     `json:"unit"`
+	CategoryID  *uuid.UUID `gorm:"type:uuid" json:"category_id"`
+	IsUrgent    bool       `gorm:"default:false" json:"is_urgent"`
+	SortOrder   int        `json:"sort_order"`
+}
+
 type Category struct {
 	coremodels.TenantModel
 	Name      string `gorm:"not null" json:"name"`
@@ -92,6 +162,26 @@ type Flyer struct {
 	Pages     []FlyerPage    `gorm:"foreignKey:FlyerID" json:"pages"`
 }
 
+// FlyerRetailer is a retailer page the flyer download reads: a shop's (or one
+// branch's) akcniceny.cz listing, or the retailer's own flyer PDF. Flyers are
+// shared by every family, so the registry is server-wide. A listed flyer is
+// taken when its title contains one of the Include patterns (any title when
+// there are none) and none of the Exclude ones; both are comma-separated and
+// matched ignoring case.
+type FlyerRetailer struct {
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+	Name      string         `gorm:"not null" json:"name"` // shop name given to its flyers
+	URL       string         `gorm:"not null" json:"url"`
+	Source    string         `gorm:"not null;default:akcniceny" json:"source"` // how URL is read: "akcniceny" or "pdf"
+	Region    string         `json:"region"`                                   // where the listed shops are; empty for nationwide, see FLYER_REGION
+	Include   string         `json:"include"`
+	Exclude   string         `json:"exclude"`
+	Enabled   bool           `json:"enabled"`
+}
+
 type FlyerPage struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
 	CreatedAt time.Time `json:"created_at"`
@@ -126,24 +216,195 @@ type FlyerItem struct {
 	ShopName       string         `gorm:"->;column:shop_name" json:"shop_name"`
 }
 
-type JobStatus struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Name      string    `gorm:"uniqueIndex" json:"name"`
-	LastRun   time.Time `json:"last_run"`
+// WatchlistItem is something a family is waiting to see discounted. Matching is
+// against FlyerItem.SearchText, so SearchTerm holds the same normalization.
+type WatchlistItem struct {
+	coremodels.TenantModel
+	Name       string `gorm:"not null" json:"name"`
+	SearchTerm string `gorm:"index" json:"-"` // utils.NormalizeSearchText(Name)
+	// TargetPrice, when set, only matches offers at or below it.
+	TargetPrice *float64 `json:"target_price"`
+	// Shops is a comma-separated list of flyer shop names; empty means any shop.
+	Shops string `json:"shops"`
+}
+
+// WatchlistMatch is a stored "your item is on sale" notification: one flyer
+// offer that satisfied one watchlist entry. The unique index keeps a re-parsed
+// page from notifying twice.
+type WatchlistMatch struct {
+	ID              uint           `gorm:"primaryKey" json:"id"`
+	CreatedAt       time.Time      `json:"created_at"`
+	FamilyID        uuid.UUID      `gorm:"type:uuid;not null;index" json:"family_id"`
+	WatchlistItemID uuid.UUID      `gorm:"type:uuid;not null;uniqueIndex:idx_watch_flyer_item" json:"watchlist_item_id"`
+	WatchlistItem   *WatchlistItem `gorm:"foreignKey:WatchlistItemID" json:"watchlist_item,omitempty"`
+	FlyerItemID     uint           `gorm:"not null;uniqueIndex:idx_watch_flyer_item" json:"flyer_item_id"`
+	FlyerItem       *FlyerItem     `gorm:"foreignKey:FlyerItemID" json:"flyer_item,omitempty"`
+	ShopName        string         `json:"shop_name"`
+	Price           float64        `json:"price"`
+	IsDismissed     bool           `gorm:"default:false" json:"is_dismissed"`
+	AddedToListID   *uuid.UUID     `gorm:"type:uuid" json:"added_to_list_id"`
+	AddedItemID     *uuid.UUID     `gorm:"type:uuid" json:"added_item_id"`
+}
+
+// Budget caps the family's grocery spend for a month, overall (CategoryID nil)
+// or for one category. An empty Month makes it the standing budget for every
+// month; a row for a specific month ("2026-03") overrides it for that month.
+type Budget struct {
+	coremodels.TenantModel
+	Month      string     `gorm:"index" json:"month"`
+	CategoryID *uuid.UUID `gorm:"type:uuid" json:"category_id"`
+	Category   *Category  `gorm:"foreignKey:CategoryID" json:"category,omitempty"`
+	Amount     float64    `gorm:"not null" json:"amount"`
+}
+
+// FamilyInvite lets a family admin bring in a new member without shell access.
+// Only a hash of the code is stored; the code itself is shown once, when the
+// invite is created.
+type FamilyInvite struct {
+	coremodels.TenantModel
+	CodeHash    string    `gorm:"uniqueIndex;not null" json:"-"`
+	Role        string    `gorm:"not null;default:'manager'" json:"role"`
+	ExpiresAt   time.Time `gorm:"not null" json:"expires_at"`
+	SingleUse   bool      `gorm:"default:true" json:"single_use"`
+	UseCount    int       `gorm:"default:0" json:"use_count"`
+	CreatedByID uuid.UUID `gorm:"type:uuid" json:"created_by_id"`
+}
+
+// PasswordResetToken is a single-use token an admin issues from the CLI
+// (cmd/admin reset-password-token). Like invites, only its hash is stored.
+type PasswordResetToken struct {
+	ID        uuid.UUID  `gorm:"type:uuid;primaryKey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	TokenHash string     `gorm:"uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+}
+
+// Session describes one signed-in device. It follows the device's refresh
+// token across rotations (RefreshTokenID always points at the current one), so
+// CreatedAt is when the device signed in and LastUsedAt its latest refresh.
+type Session struct {
+	ID             uuid.UUID `gorm:"type:uuid;primaryKey" json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	UserID         uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	RefreshTokenID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex" json:"-"`
+	UserAgent      string    `json:"user_agent"`
+	IP             string    `json:"ip"`
+	LastUsedAt     time.Time `json:"last_used_at"`
+}
+
+// Notification is one entry in a user's inbox. A family-wide event fans out to
+// one row per member, so each person reads and clears their own copy.
+type Notification struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	FamilyID  uuid.UUID  `gorm:"type:uuid;not null;index" json:"family_id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null;index" json:"user_id"`
+	Kind      string     `gorm:"not null" json:"kind"` // "receipt_review", "urgent_item", "watchlist_deal", ...
+	Title     string     `gorm:"not null" json:"title"`
+	Body      string     `json:"body"`
+	Link      string     `json:"link"` // in-app path to open, e.g. "/lists/<id>"
+	ReadAt    *time.Time `json:"read_at"`
+}
+
+// Job is one unit of background work run by the jobs queue, e.g. parsing a
+// receipt or a flyer page. Ref identifies what to work on; its meaning depends
+// on Kind. Shared work such as flyers has no family.
+type Job struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	FamilyID    *uuid.UUID `gorm:"type:uuid;index" json:"family_id"`
+	Kind        string     `gorm:"not null;index:idx_job_kind_ref" json:"kind"`
+	Ref         string     `gorm:"index:idx_job_kind_ref" json:"ref"`
+	State       string     `gorm:"not null;index" json:"state"` // pending, running, failed, succeeded, dead
+	Attempts    int        `json:"attempts"`
+	MaxAttempts int        `json:"max_attempts"`
+	RunAt       time.Time  `gorm:"index" json:"run_at"` // not before; pushed back after each failure
+	LastError   string     `json:"last_error"`
+	FinishedAt  *time.Time `json:"finished_at"`
+}
+
+// ProcessedEmail records a message an IMAP ingester has handled, so the next
+// run does not handle it again. Mailbox is "user@server/folder"; a UID only
+// identifies a message together with the folder's UIDValidity.
+type ProcessedEmail struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Kind        string    `gorm:"not null;uniqueIndex:idx_processed_email" json:"kind"` // what handled it, e.g. "flyer"
+	Mailbox     string    `gorm:"not null;uniqueIndex:idx_processed_email" json:"mailbox"`
+	UIDValidity uint32    `gorm:"uniqueIndex:idx_processed_email" json:"uid_validity"`
+	UID         uint32    `gorm:"uniqueIndex:idx_processed_email" json:"uid"`
+	Subject     string    `json:"subject"`
+}
+
+// ReceiptAddress is a family's address in the receipt inbox: mail sent to the
+// inbox with the family's token as plus-address tag (receipts+<token>@…)
+// becomes the family's receipts. Like invite codes, only the token's hash is
+// stored. AutoAttach puts each such receipt on the family's list that is being
+// shopped.
+type ReceiptAddress struct {
+	coremodels.TenantModel
+	TokenHash  string `gorm:"uniqueIndex;not null" json:"-"`
+	AutoAttach bool   `json:"auto_attach"`
 }
 
 type Receipt struct {
 	coremodels.TenantModel
-	ListID    *uuid.UUID    `gorm:"type:uuid" json:"list_id"`
-	ShopID    *uuid.UUID    `gorm:"type:uuid" json:"shop_id"` // Optional, if matched to a shop
-	Shop      *Shop         `gorm:"foreignKey:ShopID" json:"shop"`
-	Date      time.Time     `json:"date"`
-	Total     float64       `json:"total"`
-	ImagePath string        `json:"image_path"`                  // Path relative to kincart-data
-	Status    string        `gorm:"default:'new'" json:"status"` // "new", "parsed", "error"
-	Items     []ReceiptItem `gorm:"foreignKey:ReceiptID" json:"items"`
+	ListID    *uuid.UUID     `gorm:"type:uuid" json:"list_id"`
+	ShopID    *uuid.UUID     `gorm:"type:uuid" json:"shop_id"` // Optional, if matched to a shop
+	Shop      *Shop          `gorm:"foreignKey:ShopID" json:"shop"`
+	Date      time.Time      `json:"date"`
+	Total     float64        `json:"total"`
+	ImagePath string         `json:"image_path"`                  // Path relative to kincart-data; the first image when there are several
+	Status    string         `gorm:"default:'new'" json:"status"` // "new", "parsed", "pending_review", "error", "duplicate"
+	Items     []ReceiptItem  `gorm:"foreignKey:ReceiptID" json:"items"`
+	Images    []ReceiptImage `gorm:"foreignKey:ReceiptID" json:"images,omitempty"`
+
+	// Duplicate detection: FileHash is the SHA-256 of the uploaded content,
+	// Fingerprint is ReceiptFingerprint once parsed. A receipt found to repeat
+	// another is left unapplied with status "duplicate" and DuplicateOfID set.
+	// NotDuplicate is set when the user says it is a purchase of its own, and
+	// keeps it from being marked again.
+	FileHash      string     `gorm:"index" json:"-"`
+	Fingerprint   string     `gorm:"index" json:"-"`
+	DuplicateOfID *uuid.UUID `gorm:"type:uuid" json:"duplicate_of_id,omitempty"`
+	NotDuplicate  bool       `gorm:"default:false" json:"not_duplicate"`
+
+	// ParseVersion numbers the current parse; reprocessing keeps the previous
+	// one as a ReceiptVersion. ParseProvider and ParseModel are the AI backend
+	// asked for when reprocessing; empty means the server's configured one.
+	ParseVersion  int    `gorm:"default:1" json:"parse_version"`
+	ParseProvider string `json:"parse_provider,omitempty"`
+	ParseModel    string `json:"parse_model,omitempty"`
+}
+
+// ReceiptFingerprint identifies a purchase by what is printed on the receipt,
+// so the same receipt is recognised even when photographed twice: the shop,
+// date and total, and the name and price of every line, in any order. Empty
+// when there is too little to go by.
+func ReceiptFingerprint(shopID *uuid.UUID, date time.Time, total float64, lines []ReceiptItem) string {
+	if shopID == nil || date.IsZero() || len(lines) == 0 {
+		return ""
+	}
+	keys := make([]string, len(lines))
+	for i, l := range lines {
+		keys[i] = fmt.Sprintf("%s|%.2f", strings.ToLower(strings.TrimSpace(l.Name)), l.Price)
+	}
+	sort.Strings(keys)
+	sum := sha256.Sum256([]byte(strings.Join(keys, "\n")))
+	return fmt.Sprintf("%s|%s|%.2f|%d|%x", shopID, date.Format("2006-01-02"), total, len(lines), sum[:8])
+}
+
+// ReceiptImage is one photo or PDF of a receipt, in order; a long supermarket
+// receipt often takes two or three photos. Text receipts, and receipts uploaded
+// before there could be several images, have none and use Receipt.ImagePath.
+type ReceiptImage struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ReceiptID uuid.UUID `gorm:"type:uuid;not null;index" json:"receipt_id"`
+	Position  int       `json:"position"` // 0-based, top of the receipt first
+	Path      string    `json:"path"`     // relative to kincart-data
 }
 
 type ReceiptItem struct {
@@ -158,6 +419,35 @@ type ReceiptItem struct {
 	MatchStatus    string     `gorm:"default:'unmatched'" json:"match_status"` // "auto","confirmed","manual","unmatched","dismissed"
 	Confidence     int        `json:"confidence"`                              // 0-100
 	SuggestedItems string     `json:"suggested_items"`                         // JSON: [{"item_id":"uuid","item_name":"jogurt","confidence":85}]
+	SearchText     string     `gorm:"index" json:"-"`                          // utils.NormalizeSearchText(Name)
+	HistoryName    string     `json:"-"`                                       // planned name the purchase was counted under in ItemAlias/ItemFrequency; empty if not counted
+}
+
+// ReceiptVersion is a superseded parse of a receipt, kept when the receipt is
+// reprocessed so the results can be compared and the better one restored.
+type ReceiptVersion struct {
+	ID        uint                 `gorm:"primaryKey" json:"id"`
+	ReceiptID uuid.UUID            `gorm:"type:uuid;not null;index" json:"receipt_id"`
+	Version   int                  `json:"version"`
+	Provider  string               `json:"provider,omitempty"` // empty: the server's configured provider and model
+	Model     string               `json:"model,omitempty"`
+	Status    string               `json:"status"`
+	StoreName string               `json:"store_name"`
+	Date      time.Time            `json:"date"`
+	Total     float64              `json:"total"`
+	Items     []ReceiptVersionItem `gorm:"serializer:json" json:"items"`
+	CreatedAt time.Time            `json:"created_at"` // when it was superseded
+}
+
+// ReceiptVersionItem is one line of a ReceiptVersion as it stood when superseded.
+type ReceiptVersionItem struct {
+	Name        string  `json:"name"`
+	Quantity    float64 `json:"quantity"`
+	Unit        string  `json:"unit"`
+	Price       float64 `json:"price"`
+	TotalPrice  float64 `json:"total_price"`
+	MatchStatus string  `json:"match_status"`
+	MatchedItem string  `json:"matched_item,omitempty"` // name of the list item it was matched to
 }
 
 // ItemAlias records the mapping between a generic planned item name and the
@@ -0,0 +1,180 @@
+// Package notify stores in-app notifications and pushes them out through
+// optional delivery channels (webhook, SMTP).
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"kincart/internal/models"
+)
+
+// Notification kinds raised by the backend.
+const (
+	KindReceiptReview = "receipt_review"
+	KindUrgentItem    = "urgent_item"
+	KindWatchlistDeal = "watchlist_deal"
+	KindBudgetWarning = "budget_warning"
+)
+
+// Message is what a caller wants said; the Center turns it into one stored
+// Notification per recipient.
+type Message struct {
+	Kind  string
+	Title string
+	Body  string
+	Link  string
+}
+
+// Recipient is the user a notification is delivered to.
+type Recipient struct {
+	UserID   uuid.UUID
+	Username string
+	Email    string
+}
+
+// Notifier delivers a stored notification outside the app. Implementations must
+// be safe for concurrent use and should skip, not fail, recipients they cannot
+// reach (e.g. SMTP for a user without an email address).
+type Notifier interface {
+	Name() string
+	Send(ctx context.Context, to Recipient, n models.Notification) error
+}
+
+// deliveryTimeout bounds one channel send; a slow webhook must not pile up goroutines.
+const deliveryTimeout = 15 * time.Second
+
+// Center writes inbox rows and fans them out to the configured channels.
+// Delivery runs in the background so a slow SMTP server never holds up the
+// request that raised the notification.
+type Center struct {
+	db       *gorm.DB
+	channels []Notifier
+	wg       sync.WaitGroup
+}
+
+func NewCenter(db *gorm.DB, channels ...Notifier) *Center {
+	return &Center{db: db, channels: channels}
+}
+
+// NotifyFamily stores the message for every member of the family except the
+// user who caused it (exclude may be nil), then delivers it. Returns the stored
+// rows. Only the inbox write can fail; delivery errors are logged.
+func (c *Center) NotifyFamily(ctx context.Context, familyID uuid.UUID, msg Message, exclude *uuid.UUID) ([]models.Notification, error) {
+	var users []models.User
+	q := c.db.WithContext(ctx).Where("family_id = ?", familyID)
+	if exclude != nil {
+		q = q.Where("id <> ?", *exclude)
+	}
+	if err := q.Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to load family members: %w", err)
+	}
+	if len(users) == 0 {
+		return nil, nil
+	}
+
+	rows := make([]models.Notification, len(users))
+	for i, u := range users {
+		rows[i] = models.Notification{
+			FamilyID: familyID,
+			UserID:   u.ID,
+			Kind:     msg.Kind,
+			Title:    msg.Title,
+			Body:     msg.Body,
+			Link:     msg.Link,
+		}
+	}
+	if err := c.db.WithContext(ctx).Create(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to store notifications: %w", err)
+	}
+
+	if len(c.channels) > 0 {
+		for i, u := range users {
+			to := Recipient{UserID: u.ID, Username: u.Username, Email: u.Email}
+			c.deliver(to, rows[i])
+		}
+	}
+	return rows, nil
+}
+
+// deliver sends through every channel on a background goroutine, detached from
+// the caller's context so a finished HTTP request does not cancel it.
+func (c *Center) deliver(to Recipient, n models.Notification) {
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		for _, ch := range c.channels {
+			ctx, cancel := context.WithTimeout(context.Background(), deliveryTimeout)
+			if err := ch.Send(ctx, to, n); err != nil {
+				slog.Warn("Notification delivery failed", "channel", ch.Name(), "user_id", to.UserID,
+					"kind", n.Kind, "error", err)
+			}
+			cancel()
+		}
+	}()
+}
+
+// Wait blocks until background deliveries started so far have finished.
+func (c *Center) Wait() {
+	c.wg.Wait()
+}
+
+var (
+	channelsOnce sync.Once
+	envChannels  []Notifier
+	defaultMu    sync.Mutex
+	defaultByDB  = map[*gorm.DB]*Center{}
+)
+
+// ChannelsFromEnv builds the delivery channels configured in the environment:
+// NOTIFY_WEBHOOK_URL (+ NOTIFY_WEBHOOK_SECRET) and SMTP_HOST (+ SMTP_PORT,
+// SMTP_USERNAME, SMTP_PASSWORD, SMTP_FROM, NOTIFY_BASE_URL for links). With
+// neither set, notifications are inbox-only.
+func ChannelsFromEnv() []Notifier {
+	channelsOnce.Do(func() {
+		if url := os.Getenv("NOTIFY_WEBHOOK_URL"); url != "" {
+			envChannels = append(envChannels, NewWebhookNotifier(url, os.Getenv("NOTIFY_WEBHOOK_SECRET")))
+		}
+		if host := os.Getenv("SMTP_HOST"); host != "" {
+			port, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
+			if err != nil || port == 0 {
+				port = 587
+			}
+			from := os.Getenv("SMTP_FROM")
+			if from == "" {
+				from = "kincart@localhost"
+			}
+			envChannels = append(envChannels, &SMTPNotifier{
+				Host:     host,
+				Port:     port,
+				Username: os.Getenv("SMTP_USERNAME"),
+				Password: os.Getenv("SMTP_PASSWORD"),
+				From:     from,
+				BaseURL:  os.Getenv("NOTIFY_BASE_URL"),
+			})
+		}
+	})
+	return envChannels
+}
+
+// For returns the shared Center for db, with the channels from the environment.
+// Handlers and services call this rather than threading a Center through every
+// constructor, the same way they reach database.DB.
+func For(db *gorm.DB) *Center {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	if c, ok := defaultByDB[db]; ok {
+		return c
+	}
+	c := NewCenter(db, ChannelsFromEnv()...)
+	defaultByDB[db] = c
+	return c
+}
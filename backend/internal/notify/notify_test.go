@@ -0,0 +1,188 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coremodels "github.com/ya-breeze/kin-core/models"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"kincart/internal/models"
+)
+
+func setupNotifyTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.Family{}, &models.User{}, &models.Notification{}))
+	return db
+}
+
+func mkUser(t *testing.T, db *gorm.DB, familyID uuid.UUID, username, email string) models.User {
+	t.Helper()
+	u := models.User{User: coremodels.User{ID: uuid.New(), Username: username, FamilyID: familyID}, Email: email}
+	require.NoError(t, db.Create(&u).Error)
+	return u
+}
+
+// recordingNotifier captures what the Center delivers.
+type recordingNotifier struct {
+	mu   sync.Mutex
+	sent []Recipient
+}
+
+func (r *recordingNotifier) Name() string { return "recording" }
+
+func (r *recordingNotifier) Send(_ context.Context, to Recipient, _ models.Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sent = append(r.sent, to)
+	return nil
+}
+
+func TestCenter_NotifyFamily(t *testing.T) {
+	db := setupNotifyTestDB(t)
+	familyID := uuid.New()
+	actor := mkUser(t, db, familyID, "mum", "")
+	kid := mkUser(t, db, familyID, "kid", "kid@example.test")
+	mkUser(t, db, uuid.New(), "neighbour", "")
+
+	rec := &recordingNotifier{}
+	center := NewCenter(db, rec)
+	rows, err := center.NotifyFamily(context.Background(), familyID,
+		Message{Kind: KindUrgentItem, Title: "Urgent: Milk", Link: "/lists/1"}, &actor.ID)
+	require.NoError(t, err)
+	center.Wait()
+
+	require.Len(t, rows, 1, "only the other family member gets a copy")
+	assert.Equal(t, kid.ID, rows[0].UserID)
+	assert.NotZero(t, rows[0].ID)
+
+	require.Len(t, rec.sent, 1)
+	assert.Equal(t, "kid@example.test", rec.sent[0].Email)
+}
+
+func TestWebhookNotifier_SignsPayload(t *testing.T) {
+	var gotBody []byte
+	var gotSig string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody, _ = io.ReadAll(r.Body)
+		gotSig = r.Header.Get("X-KinCart-Signature")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	n := models.Notification{ID: 7, Kind: KindReceiptReview, Title: "Receipt needs review", FamilyID: uuid.New()}
+	err := NewWebhookNotifier(srv.URL, "s3cret").Send(context.Background(), Recipient{Username: "dad"}, n)
+	require.NoError(t, err)
+
+	var payload map[string]interface{}
+	require.NoError(t, json.Unmarshal(gotBody, &payload))
+	assert.Equal(t, "receipt_review", payload["kind"])
+	assert.Equal(t, "dad", payload["username"])
+
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write(gotBody)
+	assert.Equal(t, hex.EncodeToString(mac.Sum(nil)), gotSig)
+}
+
+func TestWebhookNotifier_ErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	err := NewWebhookNotifier(srv.URL, "").Send(context.Background(), Recipient{}, models.Notification{})
+	assert.Error(t, err)
+}
+
+// fakeSMTPServer accepts one message without TLS or auth and returns its DATA.
+func fakeSMTPServer(t *testing.T) (addr string, received <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	out := make(chan string, 1)
+
+	go func() {
+		defer ln.Close()
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { _, _ = conn.Write([]byte(s + "\r\n")) }
+
+		reply("220 localhost ready")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(cmd, "DATA"):
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				out <- data.String()
+				reply("250 queued")
+			case strings.HasPrefix(cmd, "QUIT"):
+				reply("221 bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return ln.Addr().String(), out
+}
+
+func TestSMTPNotifier_SendsToMemberEmail(t *testing.T) {
+	addr, received := fakeSMTPServer(t)
+	host, portStr, _ := net.SplitHostPort(addr)
+	port, _ := strconv.Atoi(portStr)
+
+	s := &SMTPNotifier{Host: host, Port: port, From: "kincart@example.test", BaseURL: "https://cart.example.test/"}
+	n := models.Notification{Title: "Máslo is on sale", Body: "Máslo 250g at Lidl for 39.90", Link: "/watchlist"}
+	require.NoError(t, s.Send(context.Background(), Recipient{Email: "kid@example.test"}, n))
+
+	msg := <-received
+	assert.Contains(t, msg, "To: kid@example.test")
+	assert.Contains(t, msg, "Subject: =?utf-8?")
+	assert.Contains(t, msg, "Máslo 250g at Lidl for 39.90")
+	assert.Contains(t, msg, "https://cart.example.test/watchlist")
+}
+
+func TestSMTPNotifier_SkipsMemberWithoutEmail(t *testing.T) {
+	// No server: a send attempt would fail to connect.
+	s := &SMTPNotifier{Host: "127.0.0.1", Port: 1, From: "kincart@example.test"}
+	assert.NoError(t, s.Send(context.Background(), Recipient{}, models.Notification{Title: "x"}))
+}
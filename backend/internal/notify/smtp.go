@@ -0,0 +1,70 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"kincart/internal/models"
+)
+
+// SMTPNotifier emails notifications to members that have an email address.
+// net/smtp upgrades to STARTTLS when the server offers it; credentials are only
+// sent over TLS or to localhost.
+type SMTPNotifier struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	// BaseURL, when set, turns the notification's in-app link into a full URL.
+	BaseURL string
+}
+
+func (s *SMTPNotifier) Name() string { return "smtp" }
+
+func (s *SMTPNotifier) Send(ctx context.Context, to Recipient, n models.Notification) error {
+	if to.Email == "" {
+		return nil
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+	addr := net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+	if err := smtp.SendMail(addr, auth, s.From, []string{to.Email}, s.message(to, n)); err != nil {
+		return fmt.Errorf("smtp send failed: %w", err)
+	}
+	return nil
+}
+
+func (s *SMTPNotifier) message(to Recipient, n models.Notification) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", s.From)
+	fmt.Fprintf(&b, "To: %s\r\n", to.Email)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "KinCart: "+n.Title))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+
+	body := n.Body
+	if n.Link != "" && s.BaseURL != "" {
+		body += "\n\n" + strings.TrimRight(s.BaseURL, "/") + n.Link
+	}
+	// SMTP line endings; a bare "." line would end DATA early, which net/smtp's
+	// dot-writer already escapes.
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
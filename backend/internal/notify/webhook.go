@@ -0,0 +1,85 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"kincart/internal/models"
+)
+
+// WebhookNotifier POSTs each notification as JSON. With a secret set, the body
+// is signed (hex HMAC-SHA256 in X-KinCart-Signature) so the receiver can tell
+// it came from this server.
+type WebhookNotifier struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+func NewWebhookNotifier(url, secret string) *WebhookNotifier {
+	return &WebhookNotifier{URL: url, Secret: secret, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+type webhookPayload struct {
+	ID        uint      `json:"id"`
+	Kind      string    `json:"kind"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	Link      string    `json:"link"`
+	Username  string    `json:"username"`
+	FamilyID  string    `json:"family_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (w *WebhookNotifier) Name() string { return "webhook" }
+
+func (w *WebhookNotifier) Send(ctx context.Context, to Recipient, n models.Notification) error {
+	body, err := json.Marshal(webhookPayload{
+		ID:        n.ID,
+		Kind:      n.Kind,
+		Title:     n.Title,
+		Body:      n.Body,
+		Link:      n.Link,
+		Username:  to.Username,
+		FamilyID:  n.FamilyID.String(),
+		CreatedAt: n.CreatedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(w.Secret))
+		mac.Write(body)
+		req.Header.Set("X-KinCart-Signature", hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,480 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"kincart/internal/models"
+)
+
+// spendStatuses are the receipt states whose prices are final enough to report
+// on. "pending_review" only waits on matching; what was paid is already known.
+var spendStatuses = []string{"parsed", "pending_review"}
+
+// uncategorized labels spend that could not be attributed to a category.
+const uncategorized = "Uncategorized"
+
+// AnalyticsRange bounds a report by receipt date. To is exclusive.
+type AnalyticsRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// AmountByName is one slice of a breakdown. ID is nil for the uncategorized
+// bucket and for receipts without a shop.
+type AmountByName struct {
+	ID     *uuid.UUID `json:"id"`
+	Name   string     `json:"name"`
+	Amount float64    `json:"amount"`
+}
+
+type MonthlySpend struct {
+	Month      string         `json:"month"` // "2026-01"
+	Total      float64        `json:"total"`
+	ByShop     []AmountByName `json:"by_shop"`
+	ByCategory []AmountByName `json:"by_category"`
+}
+
+// SpendingReport splits spend by shop (from receipt totals) and by category
+// (from receipt lines). The two can differ slightly: receipt-level discounts
+// and deposits belong to a shop but to no category.
+type SpendingReport struct {
+	From       time.Time      `json:"from"`
+	To         time.Time      `json:"to"`
+	Total      float64        `json:"total"`
+	Receipts   int            `json:"receipts"`
+	ByShop     []AmountByName `json:"by_shop"`
+	ByCategory []AmountByName `json:"by_category"`
+	Months     []MonthlySpend `json:"months"`
+}
+
+type PricePoint struct {
+	Date        time.Time `json:"date"`
+	ReceiptID   uuid.UUID `json:"receipt_id"`
+	ShopName    string    `json:"shop_name"`
+	ReceiptName string    `json:"receipt_name"`
+	Quantity    float64   `json:"quantity"`
+	Unit        string    `json:"unit"`
+	UnitPrice   float64   `json:"unit_price"`
+}
+
+type MonthlyPrice struct {
+	Month   string  `json:"month"`
+	Average float64 `json:"average"`
+	Min     float64 `json:"min"`
+	Max     float64 `json:"max"`
+	Count   int     `json:"count"`
+}
+
+// PriceTrend is what a planned item (e.g. "jogurt") cost over time, across all
+// the receipt names it was bought as. ChangePct compares the first and last
+// month's average and is nil with fewer than two months of data.
+type PriceTrend struct {
+	PlannedName string         `json:"planned_name"`
+	Points      []PricePoint   `json:"points"`
+	Months      []MonthlyPrice `json:"months"`
+	ChangePct   *float64       `json:"change_pct"`
+}
+
+type MonthlyBasket struct {
+	Month        string  `json:"month"`
+	Receipts     int     `json:"receipts"`
+	AverageTotal float64 `json:"average_total"`
+	AverageItems float64 `json:"average_items"`
+}
+
+type BasketReport struct {
+	From         time.Time       `json:"from"`
+	To           time.Time       `json:"to"`
+	Receipts     int             `json:"receipts"`
+	AverageTotal float64         `json:"average_total"`
+	AverageItems float64         `json:"average_items"`
+	Months       []MonthlyBasket `json:"months"`
+}
+
+type analyticsReceipt struct {
+	ID       uuid.UUID
+	Date     time.Time
+	Total    float64
+	ShopID   *uuid.UUID
+	ShopName string
+	Lines    []spendLine `gorm:"-"`
+}
+
+// spendLine is a receipt line attributed to a category and a planned name.
+type spendLine struct {
+	models.ReceiptItem
+	CategoryID   *uuid.UUID
+	CategoryName string
+	PlannedName  string
+}
+
+// loadAnalyticsReceipts returns the family's receipts in the range with their
+// lines attributed. A line's planned name and category come from the planned
+// item it was matched to, else from the family's ItemAlias history for its
+// receipt name (preferring an alias from the same shop).
+func loadAnalyticsReceipts(ctx context.Context, db *gorm.DB, familyID uuid.UUID, r AnalyticsRange) ([]analyticsReceipt, error) {
+	db = db.WithContext(ctx)
+
+	var receipts []analyticsReceipt
+	if err := db.Table("receipts").
+		Select("receipts.id, receipts.date, receipts.total, receipts.shop_id, shops.name AS shop_name").
+		Joins("LEFT JOIN shops ON shops.id = receipts.shop_id").
+		Where("receipts.family_id = ? AND receipts.deleted_at IS NULL AND receipts.status IN ?", familyID, spendStatuses).
+		Where("receipts.date >= ? AND receipts.date < ?", r.From, r.To).
+		Order("receipts.date ASC").
+		Scan(&receipts).Error; err != nil {
+		return nil, fmt.Errorf("failed to load receipts: %w", err)
+	}
+	if len(receipts) == 0 {
+		return nil, nil
+	}
+
+	byID := make(map[uuid.UUID]*analyticsReceipt, len(receipts))
+	ids := make([]uuid.UUID, len(receipts))
+	for i := range receipts {
+		byID[receipts[i].ID] = &receipts[i]
+		ids[i] = receipts[i].ID
+	}
+
+	var items []models.ReceiptItem
+	if err := db.Where("receipt_id IN ?", ids).Order("id ASC").Find(&items).Error; err != nil {
+		return nil, fmt.Errorf("failed to load receipt items: %w", err)
+	}
+
+	var matchedIDs []uuid.UUID
+	for _, it := range items {
+		if it.MatchedItemID != nil {
+			matchedIDs = append(matchedIDs, *it.MatchedItemID)
+		}
+	}
+	planned := map[uuid.UUID]models.Item{}
+	if len(matchedIDs) > 0 {
+		var rows []models.Item
+		// Unscoped: the list (and its items) may have been deleted since.
+		if err := db.Unscoped().Select("id", "name", "category_id").Where("id IN ?", matchedIDs).Find(&rows).Error; err != nil {
+			return nil, fmt.Errorf("failed to load matched items: %w", err)
+		}
+		for _, it := range rows {
+			planned[it.ID] = it
+		}
+	}
+
+	var aliases []models.ItemAlias
+	if err := db.Where("family_id = ?", familyID).Find(&aliases).Error; err != nil {
+		return nil, fmt.Errorf("failed to load aliases: %w", err)
+	}
+	aliasesByReceipt := map[string][]models.ItemAlias{}
+	for _, a := range aliases {
+		aliasesByReceipt[a.ReceiptNameLower] = append(aliasesByReceipt[a.ReceiptNameLower], a)
+	}
+
+	categories, err := LoadFamilyCategories(ctx, db, familyID)
+	if err != nil {
+		return nil, err
+	}
+	categoryNames := make(map[uuid.UUID]string, len(categories))
+	for _, c := range categories {
+		categoryNames[c.ID] = c.Name
+	}
+
+	for _, it := range items {
+		rec := byID[it.ReceiptID]
+		line := spendLine{ReceiptItem: it}
+
+		if it.MatchedItemID != nil {
+			if p, ok := planned[*it.MatchedItemID]; ok {
+				line.PlannedName = p.Name
+				if p.CategoryID != uuid.Nil {
+					line.CategoryID = CategoryIDPtr(p.CategoryID)
+				}
+			}
+		}
+		if alias := bestAlias(aliasesByReceipt[strings.ToLower(it.Name)], rec.ShopID); alias != nil {
+			if line.PlannedName == "" {
+				line.PlannedName = alias.PlannedName
+			}
+			if line.CategoryID == nil {
+				line.CategoryID = alias.CategoryID
+			}
+		}
+
+		// A category deleted since the purchase counts as uncategorized.
+		if line.CategoryID != nil {
+			if name, ok := categoryNames[*line.CategoryID]; ok {
+				line.CategoryName = name
+			} else {
+				line.CategoryID = nil
+			}
+		}
+		if line.CategoryID == nil {
+			line.CategoryName = uncategorized
+		}
+
+		rec.Lines = append(rec.Lines, line)
+	}
+
+	return receipts, nil
+}
+
+// bestAlias picks the alias for a receipt name: one from the same shop first,
+// then the most purchased.
+func bestAlias(group []models.ItemAlias, shopID *uuid.UUID) *models.ItemAlias {
+	var best *models.ItemAlias
+	score := func(a *models.ItemAlias) int {
+		s := a.PurchaseCount
+		if shopID != nil && a.ShopID != nil && *a.ShopID == *shopID {
+			s += 1 << 20
+		}
+		return s
+	}
+	for i := range group {
+		if best == nil || score(&group[i]) > score(best) {
+			best = &group[i]
+		}
+	}
+	return best
+}
+
+func monthKey(t time.Time) string {
+	return t.Format("2006-01")
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func lineAmount(l spendLine) float64 {
+	if l.TotalPrice != 0 {
+		return l.TotalPrice
+	}
+	return l.Price * l.Quantity
+}
+
+func receiptAmount(r *analyticsReceipt) float64 {
+	if r.Total != 0 {
+		return r.Total
+	}
+	var sum float64
+	for _, l := range r.Lines {
+		sum += lineAmount(l)
+	}
+	return sum
+}
+
+// amountBuckets accumulates amounts per key, keeping insertion-independent
+// output (largest first, ties by name).
+type amountBuckets map[string]*AmountByName
+
+func (b amountBuckets) add(id *uuid.UUID, name string, amount float64) {
+	key := name
+	if id != nil {
+		key = id.String()
+	}
+	if e, ok := b[key]; ok {
+		e.Amount += amount
+		return
+	}
+	b[key] = &AmountByName{ID: id, Name: name, Amount: amount}
+}
+
+func (b amountBuckets) sorted() []AmountByName {
+	out := make([]AmountByName, 0, len(b))
+	for _, e := range b {
+		out = append(out, AmountByName{ID: e.ID, Name: e.Name, Amount: round2(e.Amount)})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Amount != out[j].Amount {
+			return out[i].Amount > out[j].Amount
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+func shopLabel(r *analyticsReceipt) string {
+	if r.ShopName != "" {
+		return r.ShopName
+	}
+	return "Unknown shop"
+}
+
+// SpendingAnalytics reports the family's spend in the range, overall and per
+// calendar month, broken down by shop and by category.
+func SpendingAnalytics(ctx context.Context, db *gorm.DB, familyID uuid.UUID, r AnalyticsRange) (*SpendingReport, error) {
+	receipts, err := loadAnalyticsReceipts(ctx, db, familyID, r)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &SpendingReport{From: r.From, To: r.To, Receipts: len(receipts), Months: []MonthlySpend{}}
+	shops, cats := amountBuckets{}, amountBuckets{}
+	type monthAcc struct {
+		total      float64
+		shops      amountBuckets
+		categories amountBuckets
+	}
+	months := map[string]*monthAcc{}
+	var order []string
+
+	for i := range receipts {
+		rec := &receipts[i]
+		m := monthKey(rec.Date)
+		acc, ok := months[m]
+		if !ok {
+			acc = &monthAcc{shops: amountBuckets{}, categories: amountBuckets{}}
+			months[m] = acc
+			order = append(order, m)
+		}
+
+		amount := receiptAmount(rec)
+		report.Total += amount
+		acc.total += amount
+		shops.add(rec.ShopID, shopLabel(rec), amount)
+		acc.shops.add(rec.ShopID, shopLabel(rec), amount)
+
+		for _, l := range rec.Lines {
+			cats.add(l.CategoryID, l.CategoryName, lineAmount(l))
+			acc.categories.add(l.CategoryID, l.CategoryName, lineAmount(l))
+		}
+	}
+
+	report.Total = round2(report.Total)
+	report.ByShop = shops.sorted()
+	report.ByCategory = cats.sorted()
+	for _, m := range order {
+		acc := months[m]
+		report.Months = append(report.Months, MonthlySpend{
+			Month:      m,
+			Total:      round2(acc.total),
+			ByShop:     acc.shops.sorted(),
+			ByCategory: acc.categories.sorted(),
+		})
+	}
+	return report, nil
+}
+
+// PriceTrendAnalytics reports the unit prices paid for plannedName in the range.
+// Matching is case-insensitive on the planned name, so every receipt name the
+// family has linked to it through ItemAlias contributes.
+func PriceTrendAnalytics(ctx context.Context, db *gorm.DB, familyID uuid.UUID, plannedName string, r AnalyticsRange) (*PriceTrend, error) {
+	receipts, err := loadAnalyticsReceipts(ctx, db, familyID, r)
+	if err != nil {
+		return nil, err
+	}
+
+	want := strings.ToLower(strings.TrimSpace(plannedName))
+	trend := &PriceTrend{PlannedName: strings.TrimSpace(plannedName), Points: []PricePoint{}, Months: []MonthlyPrice{}}
+	byMonth := map[string]*MonthlyPrice{}
+	sums := map[string]float64{}
+	var order []string
+
+	for i := range receipts {
+		rec := &receipts[i]
+		for _, l := range rec.Lines {
+			if strings.ToLower(l.PlannedName) != want {
+				continue
+			}
+			unitPrice := l.Price
+			if unitPrice == 0 && l.Quantity > 0 {
+				unitPrice = l.TotalPrice / l.Quantity
+			}
+			if unitPrice <= 0 {
+				continue
+			}
+			trend.Points = append(trend.Points, PricePoint{
+				Date:        rec.Date,
+				ReceiptID:   rec.ID,
+				ShopName:    rec.ShopName,
+				ReceiptName: l.Name,
+				Quantity:    l.Quantity,
+				Unit:        l.Unit,
+				UnitPrice:   round2(unitPrice),
+			})
+
+			m := monthKey(rec.Date)
+			mp, ok := byMonth[m]
+			if !ok {
+				mp = &MonthlyPrice{Month: m, Min: unitPrice, Max: unitPrice}
+				byMonth[m] = mp
+				order = append(order, m)
+			}
+			mp.Count++
+			mp.Min = math.Min(mp.Min, unitPrice)
+			mp.Max = math.Max(mp.Max, unitPrice)
+			sums[m] += unitPrice
+		}
+	}
+
+	for _, m := range order {
+		mp := byMonth[m]
+		mp.Average = round2(sums[m] / float64(mp.Count))
+		mp.Min = round2(mp.Min)
+		mp.Max = round2(mp.Max)
+		trend.Months = append(trend.Months, *mp)
+	}
+	if n := len(trend.Months); n >= 2 && trend.Months[0].Average > 0 {
+		pct := round2((trend.Months[n-1].Average - trend.Months[0].Average) / trend.Months[0].Average * 100)
+		trend.ChangePct = &pct
+	}
+	return trend, nil
+}
+
+// BasketAnalytics reports how much a typical shopping trip costs and how many
+// lines it has, overall and per month.
+func BasketAnalytics(ctx context.Context, db *gorm.DB, familyID uuid.UUID, r AnalyticsRange) (*BasketReport, error) {
+	receipts, err := loadAnalyticsReceipts(ctx, db, familyID, r)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &BasketReport{From: r.From, To: r.To, Receipts: len(receipts), Months: []MonthlyBasket{}}
+	type acc struct {
+		receipts int
+		total    float64
+		items    int
+	}
+	months := map[string]*acc{}
+	var order []string
+	var total float64
+	var items int
+
+	for i := range receipts {
+		rec := &receipts[i]
+		m := monthKey(rec.Date)
+		a, ok := months[m]
+		if !ok {
+			a = &acc{}
+			months[m] = a
+			order = append(order, m)
+		}
+		amount := receiptAmount(rec)
+		a.receipts++
+		a.total += amount
+		a.items += len(rec.Lines)
+		total += amount
+		items += len(rec.Lines)
+	}
+
+	if len(receipts) > 0 {
+		report.AverageTotal = round2(total / float64(len(receipts)))
+		report.AverageItems = round2(float64(items) / float64(len(receipts)))
+	}
+	for _, m := range order {
+		a := months[m]
+		report.Months = append(report.Months, MonthlyBasket{
+			Month:        m,
+			Receipts:     a.receipts,
+			AverageTotal: round2(a.total / float64(a.receipts)),
+			AverageItems: round2(float64(a.items) / float64(a.receipts)),
+		})
+	}
+	return report, nil
+}
@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coremodels "github.com/ya-breeze/kin-core/models"
+	"gorm.io/gorm"
+
+	"kincart/internal/models"
+)
+
+func mkAnalyticsReceipt(t *testing.T, db *gorm.DB, familyID uuid.UUID, shopID *uuid.UUID, date time.Time,
+	status string, total float64, items ...models.ReceiptItem) models.Receipt {
+	t.Helper()
+	r := models.Receipt{
+		TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID},
+		ShopID:      shopID,
+		Date:        date,
+		Total:       total,
+		Status:      status,
+		Items:       items,
+	}
+	require.NoError(t, db.Create(&r).Error)
+	return r
+}
+
+func day(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestAnalytics(t *testing.T) {
+	db := setupTestDB()
+	ctx := context.Background()
+	familyID := uuid.New()
+
+	dairy := mkCategory(t, db, familyID, "Dairy")
+	bakery := mkCategory(t, db, familyID, "Bakery")
+	lidl := mkShop(t, db, familyID, "Lidl")
+	albert := mkShop(t, db, familyID, "Albert")
+
+	// Two receipt names for the same planned "jogurt"; the alias carries the category.
+	_, err := UpsertItemAlias(db, familyID, "jogurt", "Selský jogurt 2%", 20, &lidl.ID, "pcs", CategoryIDPtr(dairy.ID))
+	require.NoError(t, err)
+	_, err = UpsertItemAlias(db, familyID, "jogurt", "Jogurt bílý", 22, &albert.ID, "pcs", CategoryIDPtr(dairy.ID))
+	require.NoError(t, err)
+
+	// A matched planned item overrides the alias for its line.
+	bread := models.Item{TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID}, Name: "chleba", CategoryID: bakery.ID}
+	require.NoError(t, db.Create(&bread).Error)
+
+	mkAnalyticsReceipt(t, db, familyID, &lidl.ID, day(2026, 1, 10), "parsed", 100,
+		models.ReceiptItem{Name: "Selský jogurt 2%", Quantity: 2, Price: 20, TotalPrice: 40},
+		models.ReceiptItem{Name: "Chléb kmínový", Quantity: 1, Price: 45, TotalPrice: 45, MatchedItemID: &bread.ID},
+		models.ReceiptItem{Name: "Baterie AA", Quantity: 1, Price: 15, TotalPrice: 15},
+	)
+	mkAnalyticsReceipt(t, db, familyID, &albert.ID, day(2026, 2, 3), "pending_review", 50,
+		models.ReceiptItem{Name: "Jogurt bílý", Quantity: 2, Price: 25, TotalPrice: 50},
+	)
+	// Not reported: still unparsed, another family, outside the range.
+	mkAnalyticsReceipt(t, db, familyID, &lidl.ID, day(2026, 2, 4), "new", 999)
+	mkAnalyticsReceipt(t, db, uuid.New(), nil, day(2026, 2, 4), "parsed", 999)
+	mkAnalyticsReceipt(t, db, familyID, &lidl.ID, day(2025, 12, 31), "parsed", 999)
+
+	r := AnalyticsRange{From: day(2026, 1, 1), To: day(2026, 3, 1)}
+
+	t.Run("spending by shop and category", func(t *testing.T) {
+		report, err := SpendingAnalytics(ctx, db, familyID, r)
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, report.Receipts)
+		assert.Equal(t, 150.0, report.Total)
+		require.Len(t, report.ByShop, 2)
+		assert.Equal(t, "Lidl", report.ByShop[0].Name)
+		assert.Equal(t, 100.0, report.ByShop[0].Amount)
+
+		cats := map[string]float64{}
+		for _, c := range report.ByCategory {
+			cats[c.Name] = c.Amount
+		}
+		assert.Equal(t, map[string]float64{"Dairy": 90, "Bakery": 45, "Uncategorized": 15}, cats)
+
+		require.Len(t, report.Months, 2)
+		assert.Equal(t, "2026-01", report.Months[0].Month)
+		assert.Equal(t, 100.0, report.Months[0].Total)
+		assert.Equal(t, "2026-02", report.Months[1].Month)
+		assert.Equal(t, 50.0, report.Months[1].Total)
+	})
+
+	t.Run("price trend groups receipt names by alias", func(t *testing.T) {
+		trend, err := PriceTrendAnalytics(ctx, db, familyID, "Jogurt", r)
+		require.NoError(t, err)
+
+		require.Len(t, trend.Points, 2)
+		assert.Equal(t, "Selský jogurt 2%", trend.Points[0].ReceiptName)
+		assert.Equal(t, 20.0, trend.Points[0].UnitPrice)
+		assert.Equal(t, "Albert", trend.Points[1].ShopName)
+		require.Len(t, trend.Months, 2)
+		require.NotNil(t, trend.ChangePct)
+		assert.Equal(t, 25.0, *trend.ChangePct)
+	})
+
+	t.Run("basket size", func(t *testing.T) {
+		report, err := BasketAnalytics(ctx, db, familyID, r)
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, report.Receipts)
+		assert.Equal(t, 75.0, report.AverageTotal)
+		assert.Equal(t, 2.0, report.AverageItems)
+		require.Len(t, report.Months, 2)
+		assert.Equal(t, 3.0, report.Months[0].AverageItems)
+	})
+
+	t.Run("empty range", func(t *testing.T) {
+		report, err := SpendingAnalytics(ctx, db, familyID, AnalyticsRange{From: day(2030, 1, 1), To: day(2030, 2, 1)})
+		require.NoError(t, err)
+		assert.Zero(t, report.Receipts)
+		assert.Empty(t, report.Months)
+	})
+}
@@ -0,0 +1,335 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"kincart/internal/models"
+)
+
+// ErrInvalidMonth is returned for a month not in "2006-01" form.
+var ErrInvalidMonth = errors.New("month must be in YYYY-MM format")
+
+// ParseBudgetMonth parses "2006-01" into the first instant of that month (UTC).
+func ParseBudgetMonth(month string) (time.Time, error) {
+	t, err := time.Parse("2006-01", month)
+	if err != nil {
+		return time.Time{}, ErrInvalidMonth
+	}
+	return t, nil
+}
+
+// BudgetLine is one budget's standing for a month. Spent comes from parsed
+// receipts, plus completed lists that have none; Planned is the estimate of
+// lists not yet completed (current month only). ProjectedSpend is the larger of
+// the month-to-date pace and Spent+Planned.
+type BudgetLine struct {
+	BudgetID           uuid.UUID  `json:"budget_id"`
+	CategoryID         *uuid.UUID `json:"category_id"`
+	CategoryName       string     `json:"category_name,omitempty"`
+	Amount             float64    `json:"amount"`
+	Spent              float64    `json:"spent"`
+	Planned            float64    `json:"planned"`
+	Remaining          float64    `json:"remaining"`
+	ProjectedSpend     float64    `json:"projected_spend"`
+	ProjectedOverspend float64    `json:"projected_overspend"`
+}
+
+type BudgetStatus struct {
+	Month      string       `json:"month"`
+	Overall    *BudgetLine  `json:"overall"`
+	Categories []BudgetLine `json:"categories"`
+}
+
+// ListBudgetLine compares a list's estimate with what a budget has left.
+type ListBudgetLine struct {
+	CategoryID   *uuid.UUID `json:"category_id"`
+	CategoryName string     `json:"category_name,omitempty"`
+	Remaining    float64    `json:"remaining"`
+	Estimate     float64    `json:"estimate"`
+	Over         float64    `json:"over"`
+}
+
+// ListBudgetCheck is the outcome of CheckListBudget. Fits is false when any
+// budget would be exceeded; Lines lists only the budgets the list touches.
+type ListBudgetCheck struct {
+	Month string           `json:"month"`
+	Fits  bool             `json:"fits"`
+	Lines []ListBudgetLine `json:"lines"`
+}
+
+// EffectiveBudgets returns the budgets in force for month: month-specific rows,
+// and standing rows for every scope (overall or category) without one.
+func EffectiveBudgets(ctx context.Context, db *gorm.DB, familyID uuid.UUID, month string) ([]models.Budget, error) {
+	var rows []models.Budget
+	if err := db.WithContext(ctx).Preload("Category").
+		Where("family_id = ? AND (month = ? OR month = '')", familyID, month).
+		Order("created_at ASC").
+		Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to load budgets: %w", err)
+	}
+
+	scope := func(b models.Budget) uuid.UUID {
+		if b.CategoryID == nil {
+			return uuid.Nil
+		}
+		return *b.CategoryID
+	}
+	chosen := map[uuid.UUID]models.Budget{}
+	var order []uuid.UUID
+	for _, b := range rows {
+		key := scope(b)
+		prev, ok := chosen[key]
+		if !ok {
+			order = append(order, key)
+		}
+		if !ok || (prev.Month == "" && b.Month != "") {
+			chosen[key] = b
+		}
+	}
+
+	out := make([]models.Budget, 0, len(order))
+	for _, key := range order {
+		out = append(out, chosen[key])
+	}
+	return out, nil
+}
+
+// monthSpend is what the family spent in a month, overall and per category
+// (uuid.Nil for uncategorized).
+type monthSpend struct {
+	total      float64
+	byCategory map[uuid.UUID]float64
+}
+
+func (m *monthSpend) add(categoryID *uuid.UUID, amount float64) {
+	key := uuid.Nil
+	if categoryID != nil {
+		key = *categoryID
+	}
+	m.byCategory[key] += amount
+}
+
+func itemAmount(item models.Item) float64 {
+	qty := item.Quantity
+	if qty == 0 {
+		qty = 1
+	}
+	return item.Price * qty
+}
+
+func itemCategory(item models.Item) *uuid.UUID {
+	if item.CategoryID == uuid.Nil {
+		return nil
+	}
+	return CategoryIDPtr(item.CategoryID)
+}
+
+// loadMonthSpend adds up receipts dated in the month and completed lists
+// finished in it that have no parsed receipt (so nothing is counted twice).
+func loadMonthSpend(ctx context.Context, db *gorm.DB, familyID uuid.UUID, r AnalyticsRange) (*monthSpend, error) {
+	spend := &monthSpend{byCategory: map[uuid.UUID]float64{}}
+
+	receipts, err := loadAnalyticsReceipts(ctx, db, familyID, r)
+	if err != nil {
+		return nil, err
+	}
+	for i := range receipts {
+		spend.total += receiptAmount(&receipts[i])
+		for _, l := range receipts[i].Lines {
+			spend.add(l.CategoryID, lineAmount(l))
+		}
+	}
+
+	var lists []models.ShoppingList
+	if err := db.WithContext(ctx).
+		Preload("Items", "is_bought = ?", true).
+		Where("family_id = ? AND status = ? AND completed_at >= ? AND completed_at < ?", familyID, "completed", r.From, r.To).
+		Where("NOT EXISTS (SELECT 1 FROM receipts WHERE receipts.list_id = shopping_lists.id AND receipts.deleted_at IS NULL AND receipts.status IN ?)", spendStatuses).
+		Find(&lists).Error; err != nil {
+		return nil, fmt.Errorf("failed to load completed lists: %w", err)
+	}
+	for _, list := range lists {
+		var itemsTotal float64
+		for _, item := range list.Items {
+			itemsTotal += itemAmount(item)
+			spend.add(itemCategory(item), itemAmount(item))
+		}
+		if list.ActualAmount > 0 {
+			spend.total += list.ActualAmount
+		} else {
+			spend.total += itemsTotal
+		}
+	}
+	return spend, nil
+}
+
+// loadPlannedSpend estimates the lists still open: their EstimatedAmount
+// overall, and unbought items by category.
+func loadPlannedSpend(ctx context.Context, db *gorm.DB, familyID uuid.UUID) (*monthSpend, error) {
+	planned := &monthSpend{byCategory: map[uuid.UUID]float64{}}
+
+	var lists []models.ShoppingList
+	if err := db.WithContext(ctx).
+		Preload("Items", "is_bought = ?", false).
+		Where("family_id = ? AND (status IS NULL OR status <> ?)", familyID, "completed").
+		Find(&lists).Error; err != nil {
+		return nil, fmt.Errorf("failed to load open lists: %w", err)
+	}
+	for _, list := range lists {
+		total := list.EstimatedAmount
+		var itemsTotal float64
+		for _, item := range list.Items {
+			itemsTotal += itemAmount(item)
+			planned.add(itemCategory(item), itemAmount(item))
+		}
+		if total == 0 {
+			total = itemsTotal
+		}
+		planned.total += total
+	}
+	return planned, nil
+}
+
+func monthRange(month time.Time) AnalyticsRange {
+	start := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return AnalyticsRange{From: start, To: start.AddDate(0, 1, 0)}
+}
+
+// ComputeBudgetStatus reports every budget in force for month as of now.
+func ComputeBudgetStatus(ctx context.Context, db *gorm.DB, familyID uuid.UUID, month, now time.Time) (*BudgetStatus, error) {
+	r := monthRange(month)
+	key := monthKey(r.From)
+	status := &BudgetStatus{Month: key, Categories: []BudgetLine{}}
+
+	budgets, err := EffectiveBudgets(ctx, db, familyID, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(budgets) == 0 {
+		return status, nil
+	}
+
+	spent, err := loadMonthSpend(ctx, db, familyID, r)
+	if err != nil {
+		return nil, err
+	}
+
+	// Open lists are undated; they are expected to be shopped this month.
+	current := !now.Before(r.From) && now.Before(r.To)
+	planned := &monthSpend{byCategory: map[uuid.UUID]float64{}}
+	if current {
+		if planned, err = loadPlannedSpend(ctx, db, familyID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Share of the month elapsed, for the pace projection.
+	elapsed := 1.0
+	if current {
+		elapsed = now.Sub(r.From).Hours() / r.To.Sub(r.From).Hours()
+	}
+
+	line := func(b models.Budget, spentAmount, plannedAmount float64) BudgetLine {
+		projected := spentAmount + plannedAmount
+		if current && elapsed > 0 {
+			projected = math.Max(projected, spentAmount/elapsed)
+		}
+		l := BudgetLine{
+			BudgetID:       b.ID,
+			CategoryID:     b.CategoryID,
+			Amount:         b.Amount,
+			Spent:          round2(spentAmount),
+			Planned:        round2(plannedAmount),
+			Remaining:      round2(b.Amount - spentAmount),
+			ProjectedSpend: round2(projected),
+		}
+		if b.Category != nil {
+			l.CategoryName = b.Category.Name
+		}
+		if projected > b.Amount {
+			l.ProjectedOverspend = round2(projected - b.Amount)
+		}
+		return l
+	}
+
+	for _, b := range budgets {
+		if b.CategoryID == nil {
+			l := line(b, spent.total, planned.total)
+			status.Overall = &l
+			continue
+		}
+		status.Categories = append(status.Categories, line(b, spent.byCategory[*b.CategoryID], planned.byCategory[*b.CategoryID]))
+	}
+	sort.SliceStable(status.Categories, func(i, j int) bool {
+		return status.Categories[i].CategoryName < status.Categories[j].CategoryName
+	})
+	return status, nil
+}
+
+// CheckListBudget tells whether shopping the list this month would exceed what
+// is left of the overall budget or of any category budget it touches. The
+// list's estimate is its EstimatedAmount (or its unbought items when unset).
+func CheckListBudget(ctx context.Context, db *gorm.DB, familyID, listID uuid.UUID, now time.Time) (*ListBudgetCheck, error) {
+	var list models.ShoppingList
+	if err := db.WithContext(ctx).Preload("Items", "is_bought = ?", false).
+		Where("id = ? AND family_id = ?", listID, familyID).First(&list).Error; err != nil {
+		return nil, err
+	}
+
+	r := monthRange(now)
+	check := &ListBudgetCheck{Month: monthKey(r.From), Fits: true, Lines: []ListBudgetLine{}}
+
+	budgets, err := EffectiveBudgets(ctx, db, familyID, check.Month)
+	if err != nil {
+		return nil, err
+	}
+	if len(budgets) == 0 {
+		return check, nil
+	}
+
+	spent, err := loadMonthSpend(ctx, db, familyID, r)
+	if err != nil {
+		return nil, err
+	}
+
+	estimate := &monthSpend{byCategory: map[uuid.UUID]float64{}}
+	for _, item := range list.Items {
+		estimate.total += itemAmount(item)
+		estimate.add(itemCategory(item), itemAmount(item))
+	}
+	if list.EstimatedAmount > 0 {
+		estimate.total = list.EstimatedAmount
+	}
+
+	for _, b := range budgets {
+		l := ListBudgetLine{CategoryID: b.CategoryID}
+		if b.Category != nil {
+			l.CategoryName = b.Category.Name
+		}
+		var spentAmount float64
+		if b.CategoryID == nil {
+			spentAmount, l.Estimate = spent.total, estimate.total
+		} else {
+			spentAmount, l.Estimate = spent.byCategory[*b.CategoryID], estimate.byCategory[*b.CategoryID]
+			if l.Estimate == 0 {
+				continue
+			}
+		}
+		l.Remaining = round2(b.Amount - spentAmount)
+		l.Estimate = round2(l.Estimate)
+		if l.Estimate > l.Remaining {
+			l.Over = round2(l.Estimate - l.Remaining)
+			check.Fits = false
+		}
+		check.Lines = append(check.Lines, l)
+	}
+	return check, nil
+}
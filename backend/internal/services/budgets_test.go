@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coremodels "github.com/ya-breeze/kin-core/models"
+	"gorm.io/gorm"
+
+	"kincart/internal/models"
+)
+
+func mkBudget(t *testing.T, db *gorm.DB, familyID uuid.UUID, month string, categoryID *uuid.UUID, amount float64) models.Budget {
+	t.Helper()
+	b := models.Budget{
+		TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID},
+		Month:       month,
+		CategoryID:  categoryID,
+		Amount:      amount,
+	}
+	require.NoError(t, db.Create(&b).Error)
+	return b
+}
+
+func mkBudgetList(t *testing.T, db *gorm.DB, familyID uuid.UUID, status string, completedAt *time.Time,
+	actual, estimated float64, items ...models.Item) models.ShoppingList {
+	t.Helper()
+	list := models.ShoppingList{
+		TenantModel:     coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID},
+		Title:           "List",
+		Status:          status,
+		CompletedAt:     completedAt,
+		ActualAmount:    actual,
+		EstimatedAmount: estimated,
+	}
+	require.NoError(t, db.Create(&list).Error)
+	for _, item := range items {
+		item.TenantModel = coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID}
+		item.ListID = list.ID
+		require.NoError(t, db.Create(&item).Error)
+	}
+	return list
+}
+
+func TestBudgets(t *testing.T) {
+	db := setupTestDB()
+	require.NoError(t, db.AutoMigrate(&models.Budget{}))
+	ctx := context.Background()
+	familyID := uuid.New()
+
+	dairy := mkCategory(t, db, familyID, "Dairy")
+	snacks := mkCategory(t, db, familyID, "Snacks")
+	lidl := mkShop(t, db, familyID, "Lidl")
+	_, err := UpsertItemAlias(db, familyID, "mléko", "Mléko polotučné", 20, &lidl.ID, "pcs", CategoryIDPtr(dairy.ID))
+	require.NoError(t, err)
+
+	// Standing budget of 1000, raised to 1200 for March; 100 for dairy every month.
+	mkBudget(t, db, familyID, "", nil, 1000)
+	mkBudget(t, db, familyID, "2026-03", nil, 1200)
+	mkBudget(t, db, familyID, "", CategoryIDPtr(dairy.ID), 100)
+
+	// March spend: a 300 receipt (80 of it dairy) and a completed list without a
+	// receipt (200, 50 of it dairy). A completed list that has a receipt only
+	// counts through the receipt.
+	mkAnalyticsReceipt(t, db, familyID, &lidl.ID, day(2026, 3, 2), "parsed", 300,
+		models.ReceiptItem{Name: "Mléko polotučné", Quantity: 4, Price: 20, TotalPrice: 80},
+		models.ReceiptItem{Name: "Houska", Quantity: 10, Price: 3, TotalPrice: 30},
+	)
+	completed := day(2026, 3, 5)
+	mkBudgetList(t, db, familyID, "completed", &completed, 200, 0,
+		models.Item{Name: "Sýr", Price: 50, Quantity: 1, IsBought: true, CategoryID: dairy.ID},
+		models.Item{Name: "Chips", Price: 150, Quantity: 1, IsBought: true, CategoryID: snacks.ID},
+	)
+	withReceipt := mkBudgetList(t, db, familyID, "completed", &completed, 999, 0)
+	r := mkAnalyticsReceipt(t, db, familyID, &lidl.ID, day(2026, 2, 20), "parsed", 40)
+	db.Model(&r).Update("list_id", withReceipt.ID)
+
+	// An open list planned for this month: 120 estimated, 60 of it dairy.
+	open := mkBudgetList(t, db, familyID, "preparing", nil, 0, 120,
+		models.Item{Name: "Jogurt", Price: 15, Quantity: 4, CategoryID: dairy.ID},
+		models.Item{Name: "Pivo", Price: 60, Quantity: 1},
+	)
+
+	t.Run("effective budgets prefer the month override", func(t *testing.T) {
+		budgets, err := EffectiveBudgets(ctx, db, familyID, "2026-03")
+		require.NoError(t, err)
+		require.Len(t, budgets, 2)
+		for _, b := range budgets {
+			if b.CategoryID == nil {
+				assert.Equal(t, 1200.0, b.Amount)
+			}
+		}
+
+		budgets, err = EffectiveBudgets(ctx, db, familyID, "2026-04")
+		require.NoError(t, err)
+		for _, b := range budgets {
+			if b.CategoryID == nil {
+				assert.Equal(t, 1000.0, b.Amount)
+			}
+		}
+	})
+
+	t.Run("status for the current month", func(t *testing.T) {
+		now := day(2026, 3, 16) // roughly halfway
+		status, err := ComputeBudgetStatus(ctx, db, familyID, day(2026, 3, 1), now)
+		require.NoError(t, err)
+
+		require.NotNil(t, status.Overall)
+		assert.Equal(t, "2026-03", status.Month)
+		assert.Equal(t, 500.0, status.Overall.Spent)
+		assert.Equal(t, 120.0, status.Overall.Planned)
+		assert.Equal(t, 700.0, status.Overall.Remaining)
+		// Pace (500 over 15 of 31 days ≈ 1033) beats spent+planned (620).
+		assert.InDelta(t, 1033.33, status.Overall.ProjectedSpend, 0.01)
+		assert.Zero(t, status.Overall.ProjectedOverspend)
+
+		require.Len(t, status.Categories, 1)
+		d := status.Categories[0]
+		assert.Equal(t, "Dairy", d.CategoryName)
+		assert.Equal(t, 130.0, d.Spent)
+		assert.Equal(t, 60.0, d.Planned)
+		assert.Equal(t, -30.0, d.Remaining)
+		assert.Greater(t, d.ProjectedOverspend, 0.0)
+	})
+
+	t.Run("past month projects what was spent", func(t *testing.T) {
+		status, err := ComputeBudgetStatus(ctx, db, familyID, day(2026, 2, 1), day(2026, 3, 16))
+		require.NoError(t, err)
+		require.NotNil(t, status.Overall)
+		assert.Equal(t, 40.0, status.Overall.Spent)
+		assert.Zero(t, status.Overall.Planned)
+		assert.Equal(t, 40.0, status.Overall.ProjectedSpend)
+	})
+
+	t.Run("list check flags the category it would push over", func(t *testing.T) {
+		check, err := CheckListBudget(ctx, db, familyID, open.ID, day(2026, 3, 16))
+		require.NoError(t, err)
+
+		assert.False(t, check.Fits)
+		require.Len(t, check.Lines, 2)
+		for _, l := range check.Lines {
+			if l.CategoryID == nil {
+				assert.Equal(t, 120.0, l.Estimate)
+				assert.Equal(t, 700.0, l.Remaining)
+				assert.Zero(t, l.Over)
+			} else {
+				assert.Equal(t, 60.0, l.Estimate)
+				assert.Equal(t, 90.0, l.Over)
+			}
+		}
+	})
+
+	t.Run("no budgets means the list fits", func(t *testing.T) {
+		other := uuid.New()
+		list := mkBudgetList(t, db, other, "preparing", nil, 0, 5000)
+		check, err := CheckListBudget(ctx, db, other, list.ID, day(2026, 3, 16))
+		require.NoError(t, err)
+		assert.True(t, check.Fits)
+		assert.Empty(t, check.Lines)
+	})
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	coremodels "github.com/ya-breeze/kin-core/models"
+	"gorm.io/gorm"
+
+	"kincart/internal/models"
+)
+
+// ErrTemplateAlreadyRan is returned when another instance claimed the same
+// scheduled run first.
+var ErrTemplateAlreadyRan = errors.New("template run already claimed")
+
+// TemplateInterval returns how far apart scheduled runs are, or zero for a
+// template that does not recur.
+func TemplateInterval(t *models.ListTemplate) time.Duration {
+	switch t.Recurrence {
+	case models.RecurrenceWeekly:
+		return 7 * 24 * time.Hour
+	case models.RecurrenceEveryDays:
+		if t.IntervalDays > 0 {
+			return time.Duration(t.IntervalDays) * 24 * time.Hour
+		}
+	}
+	return 0
+}
+
+// NextTemplateRun advances from the last scheduled time until it is past now.
+// Runs missed while the server was down are skipped rather than replayed: after
+// a week offline the family wants one fresh "Weekly restock", not a stack of them.
+func NextTemplateRun(t *models.ListTemplate, from, now time.Time) *time.Time {
+	interval := TemplateInterval(t)
+	if interval == 0 {
+		return nil
+	}
+	next := from.Add(interval)
+	for !next.After(now) {
+		next = next.Add(interval)
+	}
+	return &next
+}
+
+// InstantiateTemplate creates a new "preparing" list from the template. Items
+// that leave unit or category unset get them from alias history via
+// ResolveItemDefaultsBatch, for the template's shop.
+func InstantiateTemplate(ctx context.Context, db *gorm.DB, tmpl *models.ListTemplate, now time.Time) (*models.ShoppingList, error) {
+	return instantiateTemplate(ctx, db, tmpl, now, nil)
+}
+
+// instantiateTemplate does the work for both the manual and scheduled paths.
+// claim, when set, runs first inside the transaction; the scheduler uses it to
+// move next_run_at forward so two instances cannot create the same list twice.
+func instantiateTemplate(ctx context.Context, db *gorm.DB, tmpl *models.ListTemplate, now time.Time,
+	claim func(tx *gorm.DB) error) (*models.ShoppingList, error) {
+	names := make([]string, 0, len(tmpl.Items))
+	for _, ti := range tmpl.Items {
+		if ti.Unit == "" || ti.CategoryID == nil {
+			names = append(names, ti.Name)
+		}
+	}
+	defaults, err := ResolveItemDefaultsBatch(ctx, db, tmpl.FamilyID, names, tmpl.ShopID)
+	if err != nil {
+		// Same stance as the synchronous add paths: a missing hint is not worth
+		// failing the list over.
+		slog.Warn("Could not resolve item defaults for template", "template_id", tmpl.ID, "error", err)
+		defaults = map[string]ItemDefaults{}
+	}
+
+	list := models.ShoppingList{
+		TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: tmpl.FamilyID},
+		Title:       fmt.Sprintf("%s (%s)", tmpl.Name, now.Format("2 Jan")),
+		ShopID:      tmpl.ShopID,
+		Status:      "preparing",
+	}
+
+	for _, ti := range tmpl.Items {
+		item := models.Item{
+			TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: tmpl.FamilyID},
+			Name:        ti.Name,
+			Description: ti.Description,
+			Quantity:    ti.Quantity,
+			Unit:        ti.Unit,
+			IsUrgent:    ti.IsUrgent,
+			ListID:      list.ID,
+		}
+		if item.Quantity <= 0 {
+			item.Quantity = 1
+		}
+		if ti.CategoryID != nil {
+			item.CategoryID = *ti.CategoryID
+		}
+		d := defaults[strings.ToLower(ti.Name)]
+		if item.Unit == "" {
+			item.Unit = d.Unit
+		}
+		if item.Unit == "" {
+			item.Unit = "pcs"
+		}
+		if item.CategoryID == uuid.Nil && d.CategoryID != nil {
+			item.CategoryID = *d.CategoryID
+		}
+		list.Items = append(list.Items, item)
+	}
+
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if claim != nil {
+			if err := claim(tx); err != nil {
+				return err
+			}
+		}
+		return tx.Create(&list).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// ProcessDueTemplates creates lists for every recurring template whose next run
+// is due. It returns how many lists were created; a failing template is logged
+// and retried on the next tick without holding back the others.
+func ProcessDueTemplates(ctx context.Context, db *gorm.DB, now time.Time) (int, error) {
+	var due []models.ListTemplate
+	if err := db.WithContext(ctx).Preload("Items", func(tx *gorm.DB) *gorm.DB {
+		return tx.Order("sort_order ASC, id ASC")
+	}).Where("recurrence <> ? AND next_run_at IS NOT NULL AND next_run_at <= ?", models.RecurrenceNone, now).
+		Find(&due).Error; err != nil {
+		return 0, fmt.Errorf("failed to load due templates: %w", err)
+	}
+
+	created := 0
+	for i := range due {
+		tmpl := &due[i]
+		scheduled := *tmpl.NextRunAt
+		next := NextTemplateRun(tmpl, scheduled, now)
+
+		claim := func(tx *gorm.DB) error {
+			// Conditional on the next_run_at we read, so a concurrent runner that
+			// already advanced it makes this a no-op instead of a duplicate list.
+			res := tx.Model(&models.ListTemplate{}).
+				Where("id = ? AND next_run_at = ?", tmpl.ID, scheduled).
+				Updates(map[string]interface{}{"next_run_at": next, "last_run_at": now})
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return ErrTemplateAlreadyRan
+			}
+			return nil
+		}
+
+		list, err := instantiateTemplate(ctx, db, tmpl, now, claim)
+		if errors.Is(err, ErrTemplateAlreadyRan) {
+			continue
+		}
+		if err != nil {
+			slog.Error("Failed to instantiate scheduled template", "template_id", tmpl.ID, "error", err)
+			continue
+		}
+		slog.Info("Created list from template", "template_id", tmpl.ID, "list_id", list.ID, "family_id", tmpl.FamilyID)
+		created++
+	}
+	return created, nil
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coremodels "github.com/ya-breeze/kin-core/models"
+	"gorm.io/gorm"
+
+	"kincart/internal/models"
+)
+
+func setupTemplateTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db := setupTestDB()
+	require.NoError(t, db.AutoMigrate(&models.ListTemplate{}, &models.ListTemplateItem{}))
+	return db
+}
+
+func mkTemplate(t *testing.T, db *gorm.DB, familyID uuid.UUID, recurrence string, nextRun *time.Time,
+	items ...models.ListTemplateItem) models.ListTemplate {
+	t.Helper()
+	tmpl := models.ListTemplate{
+		TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID},
+		Name:        "Weekly restock",
+		Recurrence:  recurrence,
+		NextRunAt:   nextRun,
+		Items:       items,
+	}
+	require.NoError(t, db.Create(&tmpl).Error)
+	return tmpl
+}
+
+func TestInstantiateTemplate_ResolvesDefaultsFromHistory(t *testing.T) {
+	db := setupTemplateTestDB(t)
+	familyID := uuid.New()
+	dairy := mkCategory(t, db, familyID, "Dairy")
+	produce := mkCategory(t, db, familyID, "Produce")
+	mkAlias(t, db, familyID, "Milk", nil, "l", &dairy.ID, 3, time.Now())
+
+	tmpl := mkTemplate(t, db, familyID, models.RecurrenceNone, nil,
+		models.ListTemplateItem{Name: "Milk", Quantity: 2},
+		models.ListTemplateItem{Name: "Apples", Unit: "kg", CategoryID: &produce.ID},
+		models.ListTemplateItem{Name: "Candles"},
+	)
+
+	now := time.Date(2026, 3, 7, 9, 0, 0, 0, time.UTC)
+	list, err := InstantiateTemplate(context.Background(), db, &tmpl, now)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Weekly restock (7 Mar)", list.Title)
+	assert.Equal(t, "preparing", list.Status)
+	assert.Equal(t, familyID, list.FamilyID)
+
+	var items []models.Item
+	db.Where("list_id = ?", list.ID).Find(&items)
+	require.Len(t, items, 3)
+	byName := map[string]models.Item{}
+	for _, it := range items {
+		byName[it.Name] = it
+	}
+	assert.Equal(t, "l", byName["Milk"].Unit, "unit comes from alias history")
+	assert.Equal(t, dairy.ID, byName["Milk"].CategoryID, "category comes from alias history")
+	assert.Equal(t, 2.0, byName["Milk"].Quantity)
+	assert.Equal(t, "kg", byName["Apples"].Unit, "template's own unit wins")
+	assert.Equal(t, produce.ID, byName["Apples"].CategoryID)
+	assert.Equal(t, "pcs", byName["Candles"].Unit, "unknown item keeps the default unit")
+	assert.Equal(t, uuid.Nil, byName["Candles"].CategoryID)
+}
+
+func TestProcessDueTemplates(t *testing.T) {
+	db := setupTemplateTestDB(t)
+	familyID := uuid.New()
+	now := time.Date(2026, 3, 20, 12, 0, 0, 0, time.UTC)
+
+	// Due since two weeks ago: one list, next run skips the missed weeks.
+	overdue := now.Add(-14*24*time.Hour - time.Hour)
+	due := mkTemplate(t, db, familyID, models.RecurrenceWeekly, &overdue, models.ListTemplateItem{Name: "Bread"})
+	// Not due yet.
+	future := now.Add(time.Hour)
+	mkTemplate(t, db, familyID, models.RecurrenceWeekly, &future)
+	// Manual-only template with a stale next_run_at must be ignored.
+	mkTemplate(t, db, familyID, models.RecurrenceNone, &overdue)
+
+	created, err := ProcessDueTemplates(context.Background(), db, now)
+	require.NoError(t, err)
+	assert.Equal(t, 1, created)
+
+	var reloaded models.ListTemplate
+	db.Where("id = ?", due.ID).First(&reloaded)
+	require.NotNil(t, reloaded.NextRunAt)
+	assert.True(t, reloaded.NextRunAt.After(now))
+	assert.True(t, reloaded.NextRunAt.Before(now.Add(7*24*time.Hour)))
+	require.NotNil(t, reloaded.LastRunAt)
+
+	// A second pass at the same instant creates nothing more.
+	created, err = ProcessDueTemplates(context.Background(), db, now)
+	require.NoError(t, err)
+	assert.Equal(t, 0, created)
+
+	var lists int64
+	db.Model(&models.ShoppingList{}).Where("family_id = ?", familyID).Count(&lists)
+	assert.Equal(t, int64(1), lists)
+}
+
+func TestNextTemplateRun_EveryNDays(t *testing.T) {
+	tmpl := &models.ListTemplate{Recurrence: models.RecurrenceEveryDays, IntervalDays: 3}
+	from := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+
+	next := NextTemplateRun(tmpl, from, from)
+	require.NotNil(t, next)
+	assert.Equal(t, from.AddDate(0, 0, 3), *next)
+
+	assert.Nil(t, NextTemplateRun(&models.ListTemplate{Recurrence: models.RecurrenceEveryDays}, from, from),
+		"every_n_days without an interval never runs")
+}
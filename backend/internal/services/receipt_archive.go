@@ -0,0 +1,85 @@
+package services
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"kincart/internal/models"
+	"kincart/internal/utils"
+)
+
+// ReceiptFilter narrows the receipt archive. Zero fields do not filter.
+type ReceiptFilter struct {
+	ShopID   *uuid.UUID
+	ShopName string // case-insensitive, exact
+	From     time.Time
+	To       time.Time // inclusive: the whole day counts
+	MinTotal *float64
+	MaxTotal *float64
+	Status   string
+	Item     string // substring of a receipt line, ignoring case and diacritics
+	Limit    int
+	Offset   int
+}
+
+// SearchReceipts returns a page of the family's receipts, newest first, with
+// their shop, and the total number matching the filter. When the filter names
+// an item, each receipt carries only the lines that matched it — enough to
+// answer "when did we last buy X and for how much".
+func SearchReceipts(db *gorm.DB, familyID uuid.UUID, f ReceiptFilter) ([]models.Receipt, int64, error) {
+	q := db.Model(&models.Receipt{}).Where("receipts.family_id = ?", familyID)
+
+	if f.ShopID != nil {
+		q = q.Where("receipts.shop_id = ?", *f.ShopID)
+	}
+	if f.ShopName != "" {
+		q = q.Joins("JOIN shops ON shops.id = receipts.shop_id").
+			Where("LOWER(shops.name) = ?", strings.ToLower(f.ShopName))
+	}
+	if !f.From.IsZero() {
+		q = q.Where("receipts.date >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		q = q.Where("receipts.date < ?", f.To.AddDate(0, 0, 1))
+	}
+	if f.MinTotal != nil {
+		q = q.Where("receipts.total >= ?", *f.MinTotal)
+	}
+	if f.MaxTotal != nil {
+		q = q.Where("receipts.total <= ?", *f.MaxTotal)
+	}
+	if f.Status != "" {
+		q = q.Where("receipts.status = ?", f.Status)
+	}
+
+	itemPattern := ""
+	if term := utils.NormalizeSearchText(strings.TrimSpace(f.Item)); term != "" {
+		itemPattern = "%" + term + "%"
+		q = q.Where("EXISTS (SELECT 1 FROM receipt_items WHERE receipt_items.receipt_id = receipts.id AND receipt_items.search_text LIKE ?)", itemPattern)
+	}
+
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	q = q.Preload("Shop")
+	if itemPattern != "" {
+		q = q.Preload("Items", "search_text LIKE ?", itemPattern)
+	}
+
+	limit := f.Limit
+	if limit <= 0 {
+		limit = -1 // no limit
+	}
+	var receipts []models.Receipt
+	err := q.Order("receipts.date DESC, receipts.created_at DESC").
+		Limit(limit).Offset(f.Offset).Find(&receipts).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return receipts, total, nil
+}
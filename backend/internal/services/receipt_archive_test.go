@@ -0,0 +1,82 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"kincart/internal/models"
+	"kincart/internal/utils"
+)
+
+func TestSearchReceipts(t *testing.T) {
+	db := setupTestDB()
+	familyID := uuid.New()
+
+	lidl := mkShop(t, db, familyID, "Lidl")
+	albert := mkShop(t, db, familyID, "Albert")
+
+	line := func(name string, price float64) models.ReceiptItem {
+		return models.ReceiptItem{Name: name, Quantity: 1, Price: price, TotalPrice: price, SearchText: utils.NormalizeSearchText(name)}
+	}
+	jan := mkAnalyticsReceipt(t, db, familyID, &lidl.ID, day(2026, 1, 10), "parsed", 120,
+		line("Selský jogurt 2%", 20), line("Chléb kmínový", 40))
+	feb := mkAnalyticsReceipt(t, db, familyID, &albert.ID, day(2026, 2, 3), "pending_review", 55,
+		line("JOGURT BILY", 22))
+	mar := mkAnalyticsReceipt(t, db, familyID, &lidl.ID, day(2026, 3, 1), "parsed", 300,
+		line("Máslo", 55))
+	mkAnalyticsReceipt(t, db, uuid.New(), &lidl.ID, day(2026, 3, 2), "parsed", 10, line("jogurt", 10))
+
+	ids := func(rs []models.Receipt) []uuid.UUID {
+		out := make([]uuid.UUID, len(rs))
+		for i, r := range rs {
+			out[i] = r.ID
+		}
+		return out
+	}
+	f64 := func(v float64) *float64 { return &v }
+
+	tests := []struct {
+		name   string
+		filter ReceiptFilter
+		want   []uuid.UUID
+	}{
+		{"everything, newest first", ReceiptFilter{}, []uuid.UUID{mar.ID, feb.ID, jan.ID}},
+		{"shop by id", ReceiptFilter{ShopID: &albert.ID}, []uuid.UUID{feb.ID}},
+		{"shop by name", ReceiptFilter{ShopName: "LIDL"}, []uuid.UUID{mar.ID, jan.ID}},
+		{"date range is inclusive", ReceiptFilter{From: day(2026, 2, 3), To: day(2026, 3, 1)}, []uuid.UUID{mar.ID, feb.ID}},
+		{"total range", ReceiptFilter{MinTotal: f64(50), MaxTotal: f64(120)}, []uuid.UUID{feb.ID, jan.ID}},
+		{"status", ReceiptFilter{Status: "pending_review"}, []uuid.UUID{feb.ID}},
+		{"item ignores case and diacritics", ReceiptFilter{Item: "Jógurt"}, []uuid.UUID{feb.ID, jan.ID}},
+		{"combined", ReceiptFilter{Item: "jogurt", ShopName: "lidl"}, []uuid.UUID{jan.ID}},
+		{"page", ReceiptFilter{Limit: 2, Offset: 2}, []uuid.UUID{jan.ID}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, total, err := SearchReceipts(db, familyID, tt.filter)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ids(got))
+			if tt.filter.Limit == 0 {
+				assert.Equal(t, int64(len(tt.want)), total)
+			}
+		})
+	}
+
+	t.Run("item search returns the matching lines with the shop", func(t *testing.T) {
+		got, total, err := SearchReceipts(db, familyID, ReceiptFilter{Item: "jogurt", Limit: 1})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), total)
+		require.Len(t, got, 1)
+		require.NotNil(t, got[0].Shop)
+		assert.Equal(t, "Albert", got[0].Shop.Name)
+		require.Len(t, got[0].Items, 1)
+		assert.Equal(t, "JOGURT BILY", got[0].Items[0].Name)
+		assert.Equal(t, 22.0, got[0].Items[0].Price)
+
+		got, _, _ = SearchReceipts(db, familyID, ReceiptFilter{Item: "jogurt", ShopID: &lidl.ID})
+		require.Len(t, got, 1)
+		assert.Len(t, got[0].Items, 1, "the bread on the same receipt is left out")
+	})
+}
@@ -0,0 +1,130 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coremodels "github.com/ya-breeze/kin-core/models"
+
+	"kincart/internal/ai"
+	"kincart/internal/models"
+)
+
+func TestReceiptDuplicates(t *testing.T) {
+	db := setupTestDB()
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+	familyID := uuid.New()
+
+	t.Run("same content is refused at upload", func(t *testing.T) {
+		svc := NewReceiptService(db, nil, NewFileStorageService(tmpDir), tmpDir)
+		first, err := svc.CreateReceiptFromText(familyID, "LIDL\nMleko 24,90\n")
+		require.NoError(t, err)
+
+		_, err = svc.CreateReceiptFromText(familyID, "\xef\xbb\xbfLIDL\r\nMleko 24,90")
+		var dup *DuplicateReceiptError
+		require.ErrorAs(t, err, &dup)
+		assert.Equal(t, first.ID, dup.ExistingID)
+
+		_, err = svc.CreateReceiptFromText(uuid.New(), "LIDL\nMleko 24,90\n")
+		assert.NoError(t, err, "another family's receipt is not a duplicate")
+	})
+
+	t.Run("same purchase is not applied twice", func(t *testing.T) {
+		list := models.ShoppingList{TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID}, Title: "Weekly"}
+		require.NoError(t, db.Create(&list).Error)
+		milk := models.Item{TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID}, Name: "Milk", ListID: list.ID}
+		require.NoError(t, db.Create(&milk).Error)
+		_, err := UpsertItemAlias(db, familyID, "Milk", "MLEKO", 20, nil, "", nil)
+		require.NoError(t, err)
+
+		// The same receipt photographed by each parent: different files, same content.
+		mock := &MockParser{
+			ParseFunc: func(ctx context.Context, imagePaths []string, knownItems []string) (*ai.ParsedReceipt, error) {
+				return &ai.ParsedReceipt{StoreName: "Lidl", Date: "2026-04-02", Total: 24.9, Items: []ai.ParsedReceiptItem{
+					{Name: "MLEKO", Quantity: 1, Price: 24.9, TotalPrice: 24.9},
+				}}, nil
+			},
+		}
+		svc := NewReceiptService(db, mock, nil, tmpDir)
+		mkReceipt := func() models.Receipt {
+			r := models.Receipt{TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID}, ListID: &list.ID, ImagePath: "photo.jpg", Status: "new"}
+			require.NoError(t, db.Create(&r).Error)
+			return r
+		}
+
+		first, second := mkReceipt(), mkReceipt()
+		require.NoError(t, svc.ProcessReceipt(ctx, first.ID, list.ID))
+		require.NoError(t, svc.ProcessReceipt(ctx, second.ID, list.ID))
+
+		var got models.Receipt
+		require.NoError(t, db.Preload("Items").First(&got, "id = ?", second.ID).Error)
+		assert.Equal(t, "duplicate", got.Status)
+		require.NotNil(t, got.DuplicateOfID)
+		assert.Equal(t, first.ID, *got.DuplicateOfID)
+		assert.Empty(t, got.Items, "nothing is applied from a duplicate")
+
+		require.NoError(t, db.First(&list, "id = ?", list.ID).Error)
+		assert.InDelta(t, 24.9, list.ActualAmount, 0.001)
+		var alias models.ItemAlias
+		require.NoError(t, db.Where("family_id = ? AND receipt_name = ? AND shop_id IS NOT NULL", familyID, "MLEKO").First(&alias).Error)
+		assert.Equal(t, 1, alias.PurchaseCount)
+
+		progress, err := GetReceiptProgress(db, familyID, second.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "duplicate", progress.Status)
+		assert.Equal(t, &first.ID, progress.DuplicateOf)
+
+		// A third copy points at the original, not at the other copy.
+		third := mkReceipt()
+		require.NoError(t, svc.ProcessStandaloneReceipt(ctx, third.ID))
+		var copy3 models.Receipt
+		require.NoError(t, db.First(&copy3, "id = ?", third.ID).Error)
+		assert.Equal(t, "duplicate", copy3.Status)
+		assert.Equal(t, first.ID, *copy3.DuplicateOfID)
+
+		t.Run("other lines with the same total are not a duplicate", func(t *testing.T) {
+			mock.ParseFunc = func(ctx context.Context, imagePaths []string, knownItems []string) (*ai.ParsedReceipt, error) {
+				return &ai.ParsedReceipt{StoreName: "Lidl", Date: "2026-04-02", Total: 24.9, Items: []ai.ParsedReceiptItem{
+					{Name: "MASLO", Quantity: 1, Price: 24.9, TotalPrice: 24.9},
+				}}, nil
+			}
+			other := mkReceipt()
+			require.NoError(t, svc.ProcessReceipt(ctx, other.ID, list.ID))
+			var got models.Receipt
+			require.NoError(t, db.First(&got, "id = ?", other.ID).Error)
+			assert.NotEqual(t, "duplicate", got.Status)
+		})
+
+		t.Run("a receipt marked not a duplicate is applied", func(t *testing.T) {
+			_, err := svc.MarkNotDuplicate(first.ID, familyID)
+			assert.ErrorIs(t, err, ErrReceiptNotDuplicate)
+			_, err = svc.MarkNotDuplicate(second.ID, uuid.New())
+			assert.ErrorIs(t, err, ErrReceiptNotFound)
+
+			job, err := svc.MarkNotDuplicate(second.ID, familyID)
+			require.NoError(t, err)
+			assert.Equal(t, second.ID.String(), job.Ref)
+
+			mock.ParseFunc = func(ctx context.Context, imagePaths []string, knownItems []string) (*ai.ParsedReceipt, error) {
+				return &ai.ParsedReceipt{StoreName: "Lidl", Date: "2026-04-02", Total: 24.9, Items: []ai.ParsedReceiptItem{
+					{Name: "MLEKO", Quantity: 1, Price: 24.9, TotalPrice: 24.9},
+				}}, nil
+			}
+			require.NoError(t, svc.ProcessReceipt(ctx, second.ID, list.ID))
+
+			var got models.Receipt
+			require.NoError(t, db.Preload("Items").First(&got, "id = ?", second.ID).Error)
+			assert.True(t, got.NotDuplicate)
+			assert.NotEqual(t, "duplicate", got.Status)
+			assert.Nil(t, got.DuplicateOfID)
+			assert.Len(t, got.Items, 1)
+			var original models.Receipt
+			require.NoError(t, db.First(&original, "id = ?", first.ID).Error)
+			assert.Equal(t, original.Fingerprint, got.Fingerprint, "it keeps the same fingerprint")
+		})
+	})
+}
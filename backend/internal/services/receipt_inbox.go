@@ -0,0 +1,251 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"kincart/internal/mailbox"
+	"kincart/internal/models"
+	"kincart/internal/utils"
+)
+
+// receiptEmailKind marks the messages ReceiptInbox handled in ProcessedEmail.
+const receiptEmailKind = "receipt"
+
+// receiptEmailLookback bounds how far back ReceiptInbox looks. A message to
+// an address no family has is left unread, so a family that sets its address
+// up within this window still gets what was sent to it.
+const receiptEmailLookback = 14 * 24 * time.Hour
+
+// minReceiptImageSize keeps logos and tracking pixels in HTML mail from
+// becoming receipts; a photo of a receipt is far bigger.
+const minReceiptImageSize = 30 * 1024
+
+// maxReceiptEmailText matches the limit on pasted receipt text.
+const maxReceiptEmailText = 100 * 1024
+
+// ReceiptInbox turns mail sent to an IMAP folder into receipts. A message to
+// a family's ReceiptAddress becomes receipts of that family: one per attached
+// PDF, else one from the attached photos, else one from the body text. The
+// receipts are queued for parsing like uploaded ones. A message whose sender
+// the receiving server could not authenticate is dropped.
+type ReceiptInbox struct {
+	svc     *ReceiptService
+	account mailbox.Account
+	address string // the inbox's address, which family addresses are plus-addresses of
+	// AuthServID is the authserv-id of the receiving server's
+	// Authentication-Results; see mailbox.SenderAuthenticated.
+	AuthServID string
+	fetch      func(q mailbox.Query) (uint32, []mailbox.Message, error) // replaces the IMAP fetch in tests
+}
+
+func NewReceiptInbox(svc *ReceiptService, account mailbox.Account, address string) *ReceiptInbox {
+	return &ReceiptInbox{svc: svc, account: account, address: strings.ToLower(address), fetch: account.Fetch}
+}
+
+// ReceiptInboxAddress is a family's address in the inbox at inbox: the inbox
+// address with the family's token as plus-address tag.
+func ReceiptInboxAddress(inbox, token string) string {
+	at := strings.LastIndex(inbox, "@")
+	if at < 0 {
+		return ""
+	}
+	return inbox[:at] + "+" + token + inbox[at:]
+}
+
+// receiptToken returns the plus-address tag of recipient when it is an
+// address of inbox, else "".
+func receiptToken(inbox, recipient string) string {
+	at := strings.LastIndex(inbox, "@")
+	if at < 0 || !strings.HasSuffix(recipient, inbox[at:]) {
+		return ""
+	}
+	token, ok := strings.CutPrefix(strings.TrimSuffix(recipient, inbox[at:]), inbox[:at]+"+")
+	if !ok {
+		return ""
+	}
+	return token
+}
+
+// Fetch handles the new messages in the inbox. A handled message is recorded
+// in ProcessedEmail and not looked at again; one whose receipt could not be
+// saved is tried again next time.
+func (in *ReceiptInbox) Fetch(ctx context.Context) error {
+	db := in.svc.db
+	mbox := in.account.Name()
+
+	var addresses []models.ReceiptAddress
+	if err := db.Find(&addresses).Error; err != nil {
+		return fmt.Errorf("failed to load receipt addresses: %w", err)
+	}
+	if len(addresses) == 0 {
+		return nil
+	}
+	byHash := make(map[string]models.ReceiptAddress, len(addresses))
+	for _, a := range addresses {
+		byHash[a.TokenHash] = a
+	}
+	addressOf := func(msg *mailbox.Message) (models.ReceiptAddress, bool) {
+		for _, r := range msg.Recipients {
+			if token := receiptToken(in.address, r); token != "" {
+				if a, ok := byHash[utils.HashSecretToken(token)]; ok {
+					return a, true
+				}
+			}
+		}
+		return models.ReceiptAddress{}, false
+	}
+
+	var done []models.ProcessedEmail
+	if err := db.Select("uid_validity", "uid").Where("kind = ? AND mailbox = ?", receiptEmailKind, mbox).
+		Find(&done).Error; err != nil {
+		return fmt.Errorf("failed to load processed emails: %w", err)
+	}
+	seen := make(map[[2]uint32]bool, len(done))
+	for _, d := range done {
+		seen[[2]uint32{d.UIDValidity, d.UID}] = true
+	}
+
+	validity, msgs, err := in.fetch(mailbox.Query{
+		Since: time.Now().Add(-receiptEmailLookback),
+		Skip:  func(validity, uid uint32) bool { return seen[[2]uint32{validity, uid}] },
+		Match: func(msg *mailbox.Message) bool {
+			_, ok := addressOf(msg)
+			return ok
+		},
+		Attachments: true,
+		Text:        true,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, msg := range msgs {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		addr, _ := addressOf(&msg)
+		created := 0
+		if mailbox.SenderAuthenticated(&msg, in.AuthServID) {
+			if created, err = in.createReceipts(addr, &msg); err != nil {
+				slog.Error("Failed to save emailed receipt", "from", msg.From, "subject", msg.Subject, "error", err)
+				continue
+			}
+		} else {
+			slog.Warn("Dropped receipt email failing sender authentication", "family_id", addr.FamilyID, "from", msg.From, "subject", msg.Subject)
+		}
+
+		if err := db.Create(&models.ProcessedEmail{
+			Kind:        receiptEmailKind,
+			Mailbox:     mbox,
+			UIDValidity: validity,
+			UID:         msg.UID,
+			Subject:     msg.Subject,
+		}).Error; err != nil {
+			return fmt.Errorf("failed to record processed email: %w", err)
+		}
+		slog.Info("Processed receipt email", "family_id", addr.FamilyID, "subject", msg.Subject, "receipts", created)
+	}
+	return nil
+}
+
+// createReceipts saves and queues the receipts in msg and returns how many
+// there were. A receipt the family already has is skipped.
+func (in *ReceiptInbox) createReceipts(addr models.ReceiptAddress, msg *mailbox.Message) (int, error) {
+	var pdfs, photos []ReceiptFile
+	for _, a := range msg.Attachments {
+		file := ReceiptFile{Filename: a.Filename, Data: a.Data}
+		switch {
+		case bytes.HasPrefix(a.Data, []byte("%PDF-")):
+			pdfs = append(pdfs, file)
+		case strings.HasPrefix(a.ContentType, "image/") && len(a.Data) >= minReceiptImageSize:
+			photos = append(photos, file)
+		}
+	}
+
+	// A PDF is a whole receipt; photos are the parts of one, in order.
+	var receipts [][]ReceiptFile
+	for _, pdf := range pdfs {
+		receipts = append(receipts, []ReceiptFile{pdf})
+	}
+	if len(pdfs) == 0 && len(photos) > 0 {
+		receipts = append(receipts, photos)
+	}
+
+	created := 0
+	save := func(receipt *models.Receipt, err error) error {
+		var dup *DuplicateReceiptError
+		if errors.As(err, &dup) {
+			slog.Info("Emailed receipt already uploaded", "family_id", addr.FamilyID, "existing_receipt_id", dup.ExistingID)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		created++
+		return in.queue(addr, receipt)
+	}
+
+	for _, files := range receipts {
+		if err := save(in.svc.CreateReceiptFromFiles(addr.FamilyID, files)); err != nil {
+			return created, err
+		}
+	}
+	if len(receipts) == 0 {
+		switch text := strings.TrimSpace(msg.Text); {
+		case text == "":
+			slog.Info("Receipt email has no receipt in it", "from", msg.From, "subject", msg.Subject)
+		case len(text) > maxReceiptEmailText:
+			slog.Info("Receipt email text too long", "from", msg.From, "subject", msg.Subject, "bytes", len(text))
+		default:
+			if err := save(in.svc.CreateReceiptFromText(addr.FamilyID, text)); err != nil {
+				return created, err
+			}
+		}
+	}
+	return created, nil
+}
+
+// queue puts a new receipt on the family's list being shopped when the family
+// asks for it, then queues it for parsing. Without such a list it is parsed
+// as a standalone receipt.
+func (in *ReceiptInbox) queue(addr models.ReceiptAddress, receipt *models.Receipt) error {
+	db := in.svc.db
+	if addr.AutoAttach {
+		listID, err := shoppingListID(db, addr.FamilyID)
+		if err != nil {
+			return err
+		}
+		if listID != nil {
+			if err := db.Model(receipt).Updates(map[string]interface{}{"list_id": *listID}).Error; err != nil {
+				return fmt.Errorf("failed to attach receipt: %w", err)
+			}
+		}
+	}
+
+	_, err := EnqueueReceiptJob(db, receipt)
+	return err
+}
+
+// shoppingListID returns the family's list that is out for shopping, the most
+// recently changed one if there are several, or nil.
+func shoppingListID(db *gorm.DB, familyID uuid.UUID) (*uuid.UUID, error) {
+	var list models.ShoppingList
+	err := db.Select("id").Where("family_id = ? AND status = ?", familyID, "ready for shopping").
+		Order("updated_at DESC").First(&list).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find list being shopped: %w", err)
+	}
+	return &list.ID, nil
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coremodels "github.com/ya-breeze/kin-core/models"
+
+	"kincart/internal/mailbox"
+	"kincart/internal/models"
+	"kincart/internal/utils"
+)
+
+func TestReceiptInbox(t *testing.T) {
+	db := setupTestDB()
+	require.NoError(t, db.AutoMigrate(&models.ReceiptAddress{}, &models.ProcessedEmail{}))
+	svc := NewReceiptService(db, nil, NewFileStorageService(t.TempDir()), t.TempDir())
+
+	attaching, standalone := uuid.New(), uuid.New()
+	require.NoError(t, db.Create(&models.ReceiptAddress{
+		TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: attaching},
+		TokenHash:   utils.HashSecretToken("jana"),
+		AutoAttach:  true,
+	}).Error)
+	require.NoError(t, db.Create(&models.ReceiptAddress{
+		TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: standalone},
+		TokenHash:   utils.HashSecretToken("petr"),
+	}).Error)
+	preparing := models.ShoppingList{TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: attaching}, Title: "Next week", Status: "preparing"}
+	shopping := models.ShoppingList{TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: attaching}, Title: "Today", Status: "ready for shopping"}
+	require.NoError(t, db.Create(&preparing).Error)
+	require.NoError(t, db.Create(&shopping).Error)
+
+	pdf := []byte("%PDF-1.4 receipt")
+	photo := bytes.Repeat([]byte{0xff}, minReceiptImageSize)
+	inbox := NewReceiptInbox(svc, mailbox.Account{Server: "imap.example.test:993", User: "receipts"}, "Receipts@example.test")
+	inbox.AuthServID = "mx.example.test"
+	passed := func(domain string) []string {
+		return []string{"mx.example.test; dkim=pass header.d=" + domain}
+	}
+	inbox.fetch = func(q mailbox.Query) (uint32, []mailbox.Message, error) {
+		assert.True(t, q.Attachments)
+		assert.True(t, q.Text)
+		var out []mailbox.Message
+		for _, msg := range []mailbox.Message{
+			{UID: 1, From: "noreply@albert.cz", Recipients: []string{"receipts+jana@example.test"}, AuthResults: passed("albert.cz"),
+				Subject: "Váš nákup", Attachments: []mailbox.Attachment{
+					{Filename: "uctenka.pdf", ContentType: "application/octet-stream", Data: pdf},
+					{Filename: "logo.png", ContentType: "image/png", Data: []byte("tiny")},
+				}, Text: "Děkujeme za nákup"},
+			{UID: 2, From: "petr@gmail.com", Recipients: []string{"petr@gmail.com", "receipts+petr@example.test"}, AuthResults: passed("gmail.com"),
+				Subject: "Fwd: účtenka", Text: "Rohlík 4 ks 15,60\nCelkem 15,60"},
+			{UID: 3, From: "petr@gmail.com", Recipients: []string{"receipts+petr@example.test"}, AuthResults: passed("gmail.com"),
+				Subject: "Fotky", Attachments: []mailbox.Attachment{
+					{Filename: "top.jpg", ContentType: "image/jpeg", Data: photo},
+					{Filename: "bottom.jpg", ContentType: "image/jpeg", Data: append(photo, 1)},
+				}},
+			{UID: 4, From: "petr@gmail.com", Recipients: []string{"receipts+guess@example.test"}, AuthResults: passed("gmail.com"),
+				Subject: "Receipt", Text: "Celkem 10"},
+			{UID: 5, From: "petr@gmail.com", Recipients: []string{"receipts+petr@example.test"},
+				AuthResults: []string{"mx.example.test; dkim=fail header.d=gmail.com", "mx.example.test; dkim=pass header.d=gmail.com"},
+				Subject:     "Forged", Text: "Celkem 99999"},
+		} {
+			if q.Skip != nil && q.Skip(7, msg.UID) {
+				continue
+			}
+			if q.Match != nil && !q.Match(&msg) {
+				continue
+			}
+			out = append(out, msg)
+		}
+		return 7, out, nil
+	}
+
+	ctx := context.Background()
+	require.NoError(t, inbox.Fetch(ctx))
+
+	var attached models.Receipt
+	require.NoError(t, db.Where("family_id = ?", attaching).First(&attached).Error)
+	require.NotNil(t, attached.ListID)
+	assert.Equal(t, shopping.ID, *attached.ListID, "the receipt goes to the list being shopped")
+	assert.Equal(t, ".pdf", attached.ImagePath[len(attached.ImagePath)-4:])
+
+	var own []models.Receipt
+	require.NoError(t, db.Preload("Images").Where("family_id = ?", standalone).Order("created_at").Find(&own).Error)
+	require.Len(t, own, 2)
+	assert.Nil(t, own[0].ListID)
+	assert.Equal(t, ".txt", own[0].ImagePath[len(own[0].ImagePath)-4:], "a body without attachments is a text receipt")
+	assert.Len(t, own[1].Images, 2, "photos in one message are one receipt")
+
+	var queued int64
+	db.Model(&models.Job{}).Where("kind = ?", JobReceiptParse).Count(&queued)
+	assert.Equal(t, int64(3), queued)
+
+	var processed []models.ProcessedEmail
+	require.NoError(t, db.Order("uid").Find(&processed).Error)
+	require.Len(t, processed, 4, "mail to an unknown address is left alone; forged mail is dropped")
+	assert.Equal(t, receiptEmailKind, processed[0].Kind)
+	assert.Equal(t, uint32(5), processed[3].UID)
+
+	t.Run("nothing is saved twice", func(t *testing.T) {
+		require.NoError(t, inbox.Fetch(ctx))
+		var count int64
+		db.Model(&models.Receipt{}).Count(&count)
+		assert.Equal(t, int64(3), count)
+	})
+}
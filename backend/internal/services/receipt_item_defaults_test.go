@@ -223,7 +223,7 @@ func TestProcessReceipt_AutoMatchRecordsCategoryToHistory(t *testing.T) {
 	require.NoError(t, db.Create(&receipt).Error)
 
 	mock := &MockParser{
-		ParseFunc: func(_ context.Context, _ string, _ []string) (*ai.ParsedReceipt, error) {
+		ParseFunc: func(_ context.Context, _ []string, _ []string) (*ai.ParsedReceipt, error) {
 			return &ai.ParsedReceipt{
 				StoreName: "Shop", Date: "2024-01-30", Total: 2.5,
 				Items: []ai.ParsedReceiptItem{{Name: "Milk", Price: 2.5, Quantity: 1, TotalPrice: 2.5}},
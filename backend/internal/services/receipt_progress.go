@@ -0,0 +1,111 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"kincart/internal/jobs"
+	"kincart/internal/models"
+)
+
+// ReceiptProgress is a receipt with how far it has got through parsing, for
+// clients polling after an upload and for the receipt detail view.
+type ReceiptProgress struct {
+	ReceiptID uuid.UUID `json:"receipt_id"`
+	// Status is "queued" or "processing" while the receipt waits for or runs
+	// its parse job, then the receipt's own "parsed", "pending_review", "error"
+	// or "duplicate".
+	Status        string          `json:"status"`
+	DuplicateOf   *uuid.UUID      `json:"duplicate_of,omitempty"` // the receipt this one repeats
+	Attempts      int             `json:"attempts"`
+	LastError     string          `json:"last_error,omitempty"`
+	NextAttemptAt *time.Time      `json:"next_attempt_at,omitempty"` // set while a failed attempt waits to be retried
+	Summary       *ReceiptSummary `json:"summary,omitempty"`         // set once parsed
+	Receipt       *models.Receipt `json:"receipt"`                   // with shop, items and images, in receipt order
+}
+
+// ReceiptSummary is the outcome of parsing and matching a receipt.
+type ReceiptSummary struct {
+	ShopName  string    `json:"shop_name"`
+	Date      time.Time `json:"date"`
+	Total     float64   `json:"total"`
+	Items     int       `json:"items"`
+	Matched   int       `json:"matched"`   // auto-matched or confirmed against the list
+	Unmatched int       `json:"unmatched"` // still waiting for review
+	Dismissed int       `json:"dismissed"`
+}
+
+// GetReceiptProgress loads one of the family's receipts and reports its processing state.
+func GetReceiptProgress(db *gorm.DB, familyID, receiptID uuid.UUID) (*ReceiptProgress, error) {
+	var receipt models.Receipt
+	err := db.Preload("Shop").Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Preload("Images", func(db *gorm.DB) *gorm.DB { return db.Order("position") }).
+		Where("id = ? AND family_id = ?", receiptID, familyID).First(&receipt).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrReceiptNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var job *models.Job
+	var latest models.Job
+	err = db.Where("kind = ? AND ref = ?", JobReceiptParse, receipt.ID.String()).Order("id DESC").First(&latest).Error
+	if err == nil {
+		job = &latest
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	p := &ReceiptProgress{ReceiptID: receipt.ID, Status: receipt.Status, Receipt: &receipt}
+	if job != nil {
+		p.Attempts = job.Attempts
+		p.LastError = job.LastError
+		if job.State == jobs.StateFailed {
+			p.NextAttemptAt = &job.RunAt
+		}
+	}
+
+	switch receipt.Status {
+	case "parsed", "pending_review":
+		p.Summary = summarizeReceipt(&receipt)
+	case "duplicate":
+		p.DuplicateOf = receipt.DuplicateOfID
+	case "error":
+		// A failed parse that the queue is going to retry is still on its way.
+		if job != nil && job.State != jobs.StateDead && job.State != jobs.StateSucceeded {
+			p.Status = queueStatus(job)
+		}
+	default:
+		p.Status = queueStatus(job)
+	}
+	return p, nil
+}
+
+func queueStatus(job *models.Job) string {
+	if job != nil && job.State == jobs.StateRunning {
+		return "processing"
+	}
+	return "queued"
+}
+
+func summarizeReceipt(receipt *models.Receipt) *ReceiptSummary {
+	sum := &ReceiptSummary{Date: receipt.Date, Total: receipt.Total, Items: len(receipt.Items)}
+	if receipt.Shop != nil {
+		sum.ShopName = receipt.Shop.Name
+	}
+	for _, ri := range receipt.Items {
+		switch ri.MatchStatus {
+		case matchStatusUnmatched, "":
+			sum.Unmatched++
+		case "dismissed":
+			sum.Dismissed++
+		default:
+			sum.Matched++
+		}
+	}
+	return sum
+}
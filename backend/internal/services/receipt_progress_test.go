@@ -0,0 +1,84 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coremodels "github.com/ya-breeze/kin-core/models"
+
+	"kincart/internal/jobs"
+	"kincart/internal/models"
+)
+
+func TestGetReceiptProgress(t *testing.T) {
+	db := setupTestDB()
+	familyID := uuid.New()
+
+	mkReceipt := func(status string) *models.Receipt {
+		r := &models.Receipt{TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID}, Status: status}
+		require.NoError(t, db.Create(r).Error)
+		return r
+	}
+	setJob := func(r *models.Receipt, updates map[string]interface{}) {
+		job, err := EnqueueReceiptJob(db, r)
+		require.NoError(t, err)
+		require.NoError(t, db.Model(job).Updates(updates).Error)
+	}
+
+	t.Run("waiting and running", func(t *testing.T) {
+		r := mkReceipt("new")
+		setJob(r, map[string]interface{}{})
+		p, err := GetReceiptProgress(db, familyID, r.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "queued", p.Status)
+		assert.Nil(t, p.Summary)
+
+		db.Model(&models.Job{}).Where("ref = ?", r.ID.String()).Updates(map[string]interface{}{"state": jobs.StateRunning, "attempts": 1})
+		p, _ = GetReceiptProgress(db, familyID, r.ID)
+		assert.Equal(t, "processing", p.Status)
+		assert.Equal(t, 1, p.Attempts)
+	})
+
+	t.Run("failed attempt waiting for retry", func(t *testing.T) {
+		r := mkReceipt("error")
+		setJob(r, map[string]interface{}{"state": jobs.StateFailed, "attempts": 1, "last_error": "quota exceeded"})
+		p, _ := GetReceiptProgress(db, familyID, r.ID)
+		assert.Equal(t, "queued", p.Status)
+		assert.Equal(t, "quota exceeded", p.LastError)
+		assert.NotNil(t, p.NextAttemptAt)
+	})
+
+	t.Run("given up", func(t *testing.T) {
+		r := mkReceipt("error")
+		setJob(r, map[string]interface{}{"state": jobs.StateDead, "attempts": 5, "last_error": "quota exceeded"})
+		p, _ := GetReceiptProgress(db, familyID, r.ID)
+		assert.Equal(t, "error", p.Status)
+		assert.Equal(t, 5, p.Attempts)
+		assert.Nil(t, p.NextAttemptAt)
+	})
+
+	t.Run("parsed with summary", func(t *testing.T) {
+		shop := models.Shop{TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID}, Name: "Lidl"}
+		db.Create(&shop)
+		r := mkReceipt("pending_review")
+		db.Model(r).Updates(map[string]interface{}{"shop_id": shop.ID, "total": 61.8})
+		for _, status := range []string{"auto", "confirmed", "unmatched", "unmatched", "dismissed"} {
+			db.Create(&models.ReceiptItem{ReceiptID: r.ID, Name: "x", MatchStatus: status})
+		}
+		setJob(r, map[string]interface{}{"state": jobs.StateSucceeded, "attempts": 1})
+
+		p, err := GetReceiptProgress(db, familyID, r.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "pending_review", p.Status)
+		require.NotNil(t, p.Summary)
+		assert.Equal(t, ReceiptSummary{ShopName: "Lidl", Total: 61.8, Items: 5, Matched: 2, Unmatched: 2, Dismissed: 1}, *p.Summary)
+	})
+
+	t.Run("other family", func(t *testing.T) {
+		r := mkReceipt("new")
+		_, err := GetReceiptProgress(db, uuid.New(), r.ID)
+		assert.ErrorIs(t, err, ErrReceiptNotFound)
+	})
+}
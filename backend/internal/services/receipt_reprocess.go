@@ -0,0 +1,277 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"kincart/internal/ai"
+	"kincart/internal/jobs"
+	"kincart/internal/models"
+)
+
+// ErrReceiptVersionNotFound is returned when restoring a version the receipt does not have.
+var ErrReceiptVersionNotFound = fmt.Errorf("receipt version not found")
+
+// ReprocessReceipt discards the receipt's current parse and queues it to be
+// parsed again, with the given provider and model when set (empty: the
+// server's own). The current result is kept as a ReceiptVersion so the two
+// can be compared and the better one restored with RestoreReceiptVersion.
+func (s *ReceiptService) ReprocessReceipt(ctx context.Context, receiptID, familyID uuid.UUID, provider, model string) (*models.Job, error) {
+	receipt, err := s.loadForReparse(receiptID, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Numbers are not reused, so a restored version keeps the one it had.
+		var last int
+		if err := tx.Model(&models.ReceiptVersion{}).Where("receipt_id = ?", receipt.ID).
+			Select("COALESCE(MAX(version), 0)").Scan(&last).Error; err != nil {
+			return err
+		}
+
+		if err := s.supersedeParse(tx, receipt); err != nil {
+			return err
+		}
+		return tx.Model(&models.Receipt{}).Where("id = ?", receipt.ID).Updates(map[string]interface{}{
+			"status":          "new",
+			"parse_version":   max(last, receipt.ParseVersion) + 1,
+			"parse_provider":  provider,
+			"parse_model":     model,
+			"fingerprint":     "",
+			"duplicate_of_id": nil,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	job, err := EnqueueReceiptJob(s.db, receipt)
+	if err != nil {
+		return nil, err
+	}
+	if job.State == jobs.StateDead {
+		// The parse that failed for good; Enqueue leaves dead jobs alone.
+		return jobs.Retry(s.db, job.ID, &familyID)
+	}
+	return job, nil
+}
+
+// RestoreReceiptVersion makes an earlier parse of the receipt current again.
+// The current one is kept as a version in turn, and the restored lines are
+// matched against the list afresh, so nothing is counted twice.
+func (s *ReceiptService) RestoreReceiptVersion(ctx context.Context, receiptID, familyID uuid.UUID, version int) error {
+	receipt, err := s.loadForReparse(receiptID, familyID)
+	if err != nil {
+		return err
+	}
+	var v models.ReceiptVersion
+	if err := s.db.Where("receipt_id = ? AND version = ?", receipt.ID, version).First(&v).Error; err != nil {
+		return fmt.Errorf("%w: %v", ErrReceiptVersionNotFound, err)
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := s.supersedeParse(tx, receipt); err != nil {
+			return err
+		}
+		if err := tx.Delete(&v).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Receipt{}).Where("id = ?", receipt.ID).Updates(map[string]interface{}{
+			"parse_version":   v.Version,
+			"parse_provider":  v.Provider,
+			"parse_model":     v.Model,
+			"fingerprint":     "",
+			"duplicate_of_id": nil,
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	parsed := &ai.ParsedReceipt{StoreName: v.StoreName, Total: v.Total}
+	if !v.Date.IsZero() {
+		parsed.Date = v.Date.Format("2006-01-02")
+	}
+	for _, it := range v.Items {
+		parsed.Items = append(parsed.Items, ai.ParsedReceiptItem{
+			Name: it.Name, Quantity: it.Quantity, Unit: it.Unit, Price: it.Price, TotalPrice: it.TotalPrice,
+		})
+	}
+
+	// Applied like a fresh parse; the superseded lines and shop are gone.
+	receipt.Items, receipt.Shop, receipt.ShopID = nil, nil, nil
+	receipt.Fingerprint, receipt.DuplicateOfID = "", nil
+	receipt.ParseVersion, receipt.ParseProvider, receipt.ParseModel = v.Version, v.Provider, v.Model
+	if receipt.ListID == nil {
+		err = s.applyStandaloneReceipt(receipt, parsed)
+	} else {
+		var listItems []models.Item
+		if err = s.db.Where("list_id = ? AND family_id = ?", *receipt.ListID, familyID).Find(&listItems).Error; err == nil {
+			err = s.applyParsedReceipt(ctx, receipt, *receipt.ListID, listItems, parsed)
+		}
+	}
+	if err != nil {
+		s.db.Model(receipt).Update("status", "error")
+		return err
+	}
+	return nil
+}
+
+// GetReceiptVersions returns the number of the receipt's current parse and
+// its superseded ones, newest first.
+func GetReceiptVersions(db *gorm.DB, familyID, receiptID uuid.UUID) (int, []models.ReceiptVersion, error) {
+	var receipt models.Receipt
+	err := db.Select("id", "parse_version").Where("id = ? AND family_id = ?", receiptID, familyID).First(&receipt).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil, ErrReceiptNotFound
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+
+	versions := []models.ReceiptVersion{}
+	if err := db.Where("receipt_id = ?", receiptID).Order("version DESC").Find(&versions).Error; err != nil {
+		return 0, nil, err
+	}
+	return receipt.ParseVersion, versions, nil
+}
+
+// loadForReparse loads a receipt with its lines and shop, refusing one whose
+// parse is still queued or running.
+func (s *ReceiptService) loadForReparse(receiptID, familyID uuid.UUID) (*models.Receipt, error) {
+	var receipt models.Receipt
+	if err := s.db.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).Preload("Shop").
+		Where("id = ? AND family_id = ?", receiptID, familyID).First(&receipt).Error; err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrReceiptNotFound, err)
+	}
+	if receipt.Status == "new" {
+		return nil, ErrReceiptNotParsed
+	}
+	var running int64
+	if err := s.db.Model(&models.Job{}).Where("kind = ? AND ref = ? AND state = ?", JobReceiptParse, receipt.ID.String(), jobs.StateRunning).
+		Count(&running).Error; err != nil {
+		return nil, err
+	}
+	if running > 0 {
+		return nil, ErrReceiptNotParsed
+	}
+	return &receipt, nil
+}
+
+// supersedeParse keeps the receipt's current result as a ReceiptVersion and
+// undoes everything it did: list items it matched are unlinked (and those it
+// created removed), the purchases it counted are taken back out of the alias
+// and frequency history, and its lines are deleted. The list total is
+// recalculated. Items and Shop must be loaded.
+func (s *ReceiptService) supersedeParse(tx *gorm.DB, receipt *models.Receipt) error {
+	lineIDs := make([]uint, len(receipt.Items))
+	for i, line := range receipt.Items {
+		lineIDs[i] = line.ID
+	}
+	var linked []models.Item
+	if len(lineIDs) > 0 {
+		if err := tx.Where("family_id = ? AND receipt_item_id IN ?", receipt.FamilyID, lineIDs).Find(&linked).Error; err != nil {
+			return err
+		}
+	}
+	itemByLine := map[uint]models.Item{}
+	for _, item := range linked {
+		itemByLine[*item.ReceiptItemID] = item
+	}
+
+	if receipt.Status == "parsed" || receipt.Status == "pending_review" {
+		v := models.ReceiptVersion{
+			ReceiptID: receipt.ID,
+			Version:   receipt.ParseVersion,
+			Provider:  receipt.ParseProvider,
+			Model:     receipt.ParseModel,
+			Status:    receipt.Status,
+			Date:      receipt.Date,
+			Total:     receipt.Total,
+			Items:     make([]models.ReceiptVersionItem, 0, len(receipt.Items)),
+		}
+		if receipt.Shop != nil {
+			v.StoreName = receipt.Shop.Name
+		}
+		for _, line := range receipt.Items {
+			v.Items = append(v.Items, models.ReceiptVersionItem{
+				Name:        line.Name,
+				Quantity:    line.Quantity,
+				Unit:        line.Unit,
+				Price:       line.Price,
+				TotalPrice:  line.TotalPrice,
+				MatchStatus: line.MatchStatus,
+				MatchedItem: itemByLine[line.ID].Name,
+			})
+		}
+		if err := tx.Create(&v).Error; err != nil {
+			return fmt.Errorf("failed to keep receipt version: %w", err)
+		}
+	}
+
+	for _, item := range linked {
+		if item.IsReceiptCreated {
+			if err := tx.Delete(&item).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		item.ReceiptItemID = nil
+		item.IsBought = false
+		if err := tx.Save(&item).Error; err != nil {
+			return err
+		}
+	}
+	for _, line := range receipt.Items {
+		if line.HistoryName != "" {
+			s.uncountPurchase(tx, receipt.FamilyID, line.HistoryName, line.Name, receipt.ShopID)
+		}
+	}
+	if len(lineIDs) > 0 {
+		if err := tx.Where("receipt_id = ?", receipt.ID).Delete(&models.ReceiptItem{}).Error; err != nil {
+			return err
+		}
+	}
+
+	if receipt.ListID != nil {
+		return s.recalculateListTotal(tx, *receipt.ListID, receipt.FamilyID)
+	}
+	return nil
+}
+
+// uncountPurchase takes one purchase back out of the history that
+// upsertItemAlias and updateItemFrequency recorded. An alias or frequency left
+// with no purchases is removed, so a misread line does not linger as a match
+// for future receipts. The last price is not rolled back.
+func (s *ReceiptService) uncountPurchase(tx *gorm.DB, familyID uuid.UUID, plannedName, receiptName string, shopID *uuid.UUID) {
+	q := tx.Where("family_id = ? AND planned_name_lower = ? AND receipt_name_lower = ?",
+		familyID, strings.ToLower(plannedName), strings.ToLower(receiptName))
+	if shopID != nil {
+		q = q.Where("shop_id = ?", *shopID)
+	} else {
+		q = q.Where("shop_id IS NULL")
+	}
+	var alias models.ItemAlias
+	if q.First(&alias).Error == nil {
+		if alias.PurchaseCount <= 1 {
+			tx.Delete(&alias)
+		} else {
+			tx.Model(&alias).Update("purchase_count", alias.PurchaseCount-1)
+		}
+	}
+
+	var freq models.ItemFrequency
+	if tx.Where("family_id = ? AND LOWER(item_name) = ?", familyID, strings.ToLower(plannedName)).First(&freq).Error == nil && !freq.IsHidden {
+		if freq.Frequency <= 1 {
+			tx.Delete(&freq)
+		} else {
+			tx.Model(&freq).Update("frequency", freq.Frequency-1)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coremodels "github.com/ya-breeze/kin-core/models"
+
+	"kincart/internal/ai"
+	"kincart/internal/models"
+)
+
+func TestReprocessReceipt(t *testing.T) {
+	db := setupTestDB()
+	ctx := context.Background()
+	familyID := uuid.New()
+
+	list := models.ShoppingList{TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID}, Title: "Weekly"}
+	require.NoError(t, db.Create(&list).Error)
+	milk := models.Item{TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID}, Name: "Milk", ListID: list.ID}
+	bread := models.Item{TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID}, Name: "Bread", ListID: list.ID}
+	require.NoError(t, db.Create(&milk).Error)
+	require.NoError(t, db.Create(&bread).Error)
+
+	parserFor := func(milkLine string, milkPrice float64) *MockParser {
+		return &MockParser{
+			ParseFunc: func(ctx context.Context, imagePaths []string, knownItems []string) (*ai.ParsedReceipt, error) {
+				return &ai.ParsedReceipt{StoreName: "Lidl", Date: "2026-04-02", Total: milkPrice + 30, Items: []ai.ParsedReceiptItem{
+					{Name: milkLine, Quantity: 1, Price: milkPrice, TotalPrice: milkPrice},
+					{Name: "CHLEB", Quantity: 1, Price: 30, TotalPrice: 30},
+				}}, nil
+			},
+			MatchItemsFunc: func(ctx context.Context, receiptItems []string, plannedItems []string) (*ai.MatchResult, error) {
+				res := &ai.MatchResult{}
+				for _, name := range receiptItems {
+					planned := "Bread"
+					if strings.HasPrefix(name, "ML") {
+						planned = "Milk"
+					}
+					res.Suggestions = append(res.Suggestions, ai.MatchSuggestion{ReceiptItemName: name, Matches: []ai.MatchCandidate{{PlannedItemName: planned, Confidence: 95}}})
+				}
+				return res, nil
+			},
+		}
+	}
+
+	// The configured model misreads the milk line and its price.
+	svc := NewReceiptService(db, parserFor("MLFKO", 249), nil, t.TempDir())
+	var requested []string
+	svc.newParser = func(ctx context.Context, provider, model string) (ReceiptParser, error) {
+		requested = []string{provider, model}
+		return parserFor("MLEKO", 24.9), nil
+	}
+
+	receipt := models.Receipt{TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID}, ListID: &list.ID, ImagePath: "photo.jpg", Status: "new"}
+	require.NoError(t, db.Create(&receipt).Error)
+	require.NoError(t, svc.ProcessReceipt(ctx, receipt.ID, list.ID))
+
+	reload := func() (models.Receipt, models.ShoppingList, models.Item) {
+		var r models.Receipt
+		require.NoError(t, db.Preload("Items").First(&r, "id = ?", receipt.ID).Error)
+		var l models.ShoppingList
+		require.NoError(t, db.First(&l, "id = ?", list.ID).Error)
+		var m models.Item
+		require.NoError(t, db.First(&m, "id = ?", milk.ID).Error)
+		return r, l, m
+	}
+	aliasCount := func(receiptName string) int {
+		var a models.ItemAlias
+		if err := db.Where("family_id = ? AND receipt_name = ?", familyID, receiptName).First(&a).Error; err != nil {
+			return 0
+		}
+		return a.PurchaseCount
+	}
+	frequency := func(name string) int {
+		var f models.ItemFrequency
+		if err := db.Where("family_id = ? AND item_name = ?", familyID, name).First(&f).Error; err != nil {
+			return 0
+		}
+		return f.Frequency
+	}
+
+	_, l, m := reload()
+	assert.InDelta(t, 279, l.ActualAmount, 0.001)
+	assert.InDelta(t, 249, m.Price, 0.001)
+	assert.Equal(t, 1, aliasCount("MLFKO"))
+
+	t.Run("reprocess undoes the superseded parse", func(t *testing.T) {
+		job, err := svc.ReprocessReceipt(ctx, receipt.ID, familyID, "openai", "better")
+		require.NoError(t, err)
+
+		r, l, m := reload()
+		assert.Equal(t, "new", r.Status)
+		assert.Equal(t, 2, r.ParseVersion)
+		assert.Empty(t, r.Items)
+		assert.False(t, m.IsBought)
+		assert.Nil(t, m.ReceiptItemID)
+		assert.Zero(t, l.ActualAmount)
+		assert.Zero(t, aliasCount("MLFKO"), "the misread alias is gone")
+		assert.Zero(t, frequency("Milk"))
+
+		_, versions, err := GetReceiptVersions(db, familyID, receipt.ID)
+		require.NoError(t, err)
+		require.Len(t, versions, 1)
+		assert.Equal(t, 1, versions[0].Version)
+		assert.Equal(t, "Lidl", versions[0].StoreName)
+		require.Len(t, versions[0].Items, 2)
+		assert.Equal(t, "MLFKO", versions[0].Items[0].Name)
+		assert.Equal(t, "Milk", versions[0].Items[0].MatchedItem)
+
+		require.NoError(t, svc.ProcessReceiptJob(ctx, *job))
+		assert.Equal(t, []string{"openai", "better"}, requested)
+
+		r, l, m = reload()
+		assert.Equal(t, "parsed", r.Status)
+		assert.InDelta(t, 54.9, l.ActualAmount, 0.001)
+		assert.InDelta(t, 24.9, m.Price, 0.001)
+		assert.Equal(t, 1, aliasCount("MLEKO"))
+		assert.Equal(t, 1, aliasCount("CHLEB"), "counted once across both parses")
+		assert.Equal(t, 1, frequency("Bread"))
+	})
+
+	t.Run("restore an earlier version", func(t *testing.T) {
+		require.NoError(t, svc.RestoreReceiptVersion(ctx, receipt.ID, familyID, 1))
+
+		r, l, m := reload()
+		assert.Equal(t, 1, r.ParseVersion)
+		assert.Empty(t, r.ParseProvider)
+		assert.Equal(t, "parsed", r.Status)
+		require.Len(t, r.Items, 2)
+		assert.Equal(t, "MLFKO", r.Items[0].Name)
+		assert.InDelta(t, 249, m.Price, 0.001)
+		assert.InDelta(t, 279, l.ActualAmount, 0.001)
+		assert.Zero(t, aliasCount("MLEKO"))
+		assert.Equal(t, 1, aliasCount("MLFKO"))
+		assert.Equal(t, 1, frequency("Milk"))
+
+		current, versions, err := GetReceiptVersions(db, familyID, receipt.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, current)
+		require.Len(t, versions, 1)
+		assert.Equal(t, 2, versions[0].Version)
+		assert.Equal(t, "openai", versions[0].Provider)
+
+		assert.ErrorIs(t, svc.RestoreReceiptVersion(ctx, receipt.ID, familyID, 1), ErrReceiptVersionNotFound)
+	})
+
+	t.Run("refused while queued or for another family", func(t *testing.T) {
+		_, err := svc.ReprocessReceipt(ctx, receipt.ID, uuid.New(), "", "")
+		assert.ErrorIs(t, err, ErrReceiptNotFound)
+
+		db.Model(&models.Receipt{}).Where("id = ?", receipt.ID).Update("status", "new")
+		_, err = svc.ReprocessReceipt(ctx, receipt.ID, familyID, "", "")
+		assert.ErrorIs(t, err, ErrReceiptNotParsed)
+	})
+}
@@ -2,9 +2,12 @@ package services
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"mime/multipart"
 	"os"
@@ -18,7 +21,10 @@ import (
 	"gorm.io/gorm"
 
 	"kincart/internal/ai"
+	"kincart/internal/jobs"
 	"kincart/internal/models"
+	"kincart/internal/notify"
+	"kincart/internal/utils"
 
 	coremodels "github.com/ya-breeze/kin-core/models"
 )
@@ -34,7 +40,7 @@ const (
 	geminiCategorizeTimeout = 10 * time.Second
 )
 
-// ErrGeminiUnavailable is returned when the Gemini AI client is not configured.
+// ErrGeminiUnavailable is returned when no AI provider is configured.
 var ErrGeminiUnavailable = fmt.Errorf("gemini client not available")
 
 // ErrReceiptItemNotFound is returned when a receipt item cannot be found.
@@ -49,9 +55,31 @@ var ErrPlannedItemNotFound = fmt.Errorf("planned item not found or not on this l
 // ErrNoAssociatedList is returned when a receipt has no linked list.
 var ErrNoAssociatedList = fmt.Errorf("receipt has no associated list")
 
+// ErrReceiptAttached is returned when attaching a receipt that already belongs to a list.
+var ErrReceiptAttached = fmt.Errorf("receipt is already attached to a list")
+
+// ErrReceiptNotParsed is returned when attaching a receipt that has not been parsed yet.
+var ErrReceiptNotParsed = fmt.Errorf("receipt has not been parsed yet")
+
+// ErrReceiptNotDuplicate is returned when clearing the duplicate mark of a receipt that has none.
+var ErrReceiptNotDuplicate = fmt.Errorf("receipt is not marked as a duplicate")
+
+// ErrListNotFound is returned when a shopping list cannot be found or access is denied.
+var ErrListNotFound = fmt.Errorf("list not found or access denied")
+
+// DuplicateReceiptError is returned when the uploaded receipt is already in
+// the family's receipts.
+type DuplicateReceiptError struct {
+	ExistingID uuid.UUID
+}
+
+func (e *DuplicateReceiptError) Error() string {
+	return fmt.Sprintf("receipt already uploaded as %s", e.ExistingID)
+}
+
 // ReceiptParser is the AI client interface used by the service.
 type ReceiptParser interface {
-	ParseReceipt(ctx context.Context, imagePath string, knownItems []string) (*ai.ParsedReceipt, error)
+	ParseReceipt(ctx context.Context, imagePaths []string, knownItems []string) (*ai.ParsedReceipt, error)
 	ParseReceiptText(ctx context.Context, receiptText string, knownItems []string) (*ai.ParsedReceipt, error)
 	MatchReceiptItems(ctx context.Context, receiptItems []string, plannedItems []string) (*ai.MatchResult, error)
 	SuggestItemDefaults(ctx context.Context, name string, categories []string) (ai.SuggestedItemDefaults, error)
@@ -108,6 +136,10 @@ type ReceiptService struct {
 	gemini       ReceiptParser
 	fileStorage  *FileStorageService
 	receiptsPath string
+
+	// newParser builds the parser for a receipt reprocessed with another
+	// provider or model.
+	newParser func(ctx context.Context, provider, model string) (ReceiptParser, error)
 }
 
 func NewReceiptService(db *gorm.DB, gemini ReceiptParser, fileStorage *FileStorageService, receiptsPath string) *ReceiptService {
@@ -116,30 +148,204 @@ func NewReceiptService(db *gorm.DB, gemini ReceiptParser, fileStorage *FileStora
 		gemini:       gemini,
 		fileStorage:  fileStorage,
 		receiptsPath: receiptsPath,
+		newParser: func(ctx context.Context, provider, model string) (ReceiptParser, error) {
+			return ai.NewProviderWith(ctx, provider, model)
+		},
 	}
 }
 
-func (s *ReceiptService) CreateReceipt(familyID uuid.UUID, file *multipart.FileHeader) (*models.Receipt, error) {
-	path, err := s.fileStorage.SaveReceipt(familyID, file)
-	if err != nil {
-		return nil, fmt.Errorf("storage error: %w", err)
+// ReceiptFile is one photo or PDF of a receipt.
+type ReceiptFile struct {
+	Filename string
+	Data     []byte
+}
+
+// CreateReceipt saves the uploaded photos or PDFs of one receipt, top of the
+// receipt first, and creates a Receipt DB record with an image per file.
+func (s *ReceiptService) CreateReceipt(familyID uuid.UUID, files []*multipart.FileHeader) (*models.Receipt, error) {
+	receiptFiles := make([]ReceiptFile, 0, len(files))
+	for _, file := range files {
+		src, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(src)
+		src.Close()
+		if err != nil {
+			return nil, err
+		}
+		receiptFiles = append(receiptFiles, ReceiptFile{Filename: file.Filename, Data: data})
+	}
+	return s.CreateReceiptFromFiles(familyID, receiptFiles)
+}
+
+// CreateReceiptFromFiles is CreateReceipt for files that did not come in an
+// upload, e.g. e-mail attachments.
+func (s *ReceiptService) CreateReceiptFromFiles(familyID uuid.UUID, files []ReceiptFile) (*models.Receipt, error) {
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no receipt files")
+	}
+
+	hash := hashReceiptFiles(files)
+	if err := s.checkDuplicateUpload(familyID, hash); err != nil {
+		return nil, err
 	}
 
 	receipt := models.Receipt{
 		TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID},
-		ImagePath:   path,
 		Date:        time.Now(),
+		FileHash:    hash,
 	}
+	for i, file := range files {
+		path, err := s.fileStorage.SaveReceipt(familyID, file.Filename, file.Data)
+		if err != nil {
+			s.removeReceiptImages(receipt.Images)
+			return nil, fmt.Errorf("storage error: %w", err)
+		}
+		receipt.Images = append(receipt.Images, models.ReceiptImage{Position: i, Path: path})
+	}
+	receipt.ImagePath = receipt.Images[0].Path
 
 	if err := s.db.Create(&receipt).Error; err != nil {
+		s.removeReceiptImages(receipt.Images)
 		return nil, err
 	}
 
 	return &receipt, nil
 }
 
+// hashReceiptFiles hashes the content of a receipt's files, in order.
+func hashReceiptFiles(files []ReceiptFile) string {
+	h := sha256.New()
+	for _, file := range files {
+		h.Write(file.Data)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// checkDuplicateUpload returns a DuplicateReceiptError when the family already
+// has a receipt with the same content.
+func (s *ReceiptService) checkDuplicateUpload(familyID uuid.UUID, hash string) error {
+	var existing models.Receipt
+	err := s.db.Select("id", "duplicate_of_id").Where("family_id = ? AND file_hash = ?", familyID, hash).
+		Order("created_at").First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check for duplicate receipt: %w", err)
+	}
+	return &DuplicateReceiptError{ExistingID: originalReceiptID(&existing)}
+}
+
+// originalReceiptID points past a receipt already marked as a duplicate to the one it repeats.
+func originalReceiptID(r *models.Receipt) uuid.UUID {
+	if r.DuplicateOfID != nil {
+		return *r.DuplicateOfID
+	}
+	return r.ID
+}
+
+// markIfDuplicate fingerprints a freshly parsed receipt and, when the family
+// already has a receipt with the same shop, date, total and lines, marks it as
+// a duplicate of that one. The caller then stops: applying it again would
+// count the purchases and the spend twice. A receipt the user said is not a
+// duplicate is only fingerprinted.
+func (s *ReceiptService) markIfDuplicate(tx *gorm.DB, receipt *models.Receipt, items []ai.ParsedReceiptItem) (bool, error) {
+	lines := make([]models.ReceiptItem, len(items))
+	for i, it := range items {
+		lines[i] = models.ReceiptItem{Name: it.Name, Price: it.Price}
+	}
+	receipt.Fingerprint = models.ReceiptFingerprint(receipt.ShopID, receipt.Date, receipt.Total, lines)
+	if receipt.Fingerprint == "" || receipt.NotDuplicate {
+		return false, nil
+	}
+
+	var existing models.Receipt
+	err := tx.Select("id", "duplicate_of_id").
+		Where("family_id = ? AND fingerprint = ? AND id <> ? AND status <> ?", receipt.FamilyID, receipt.Fingerprint, receipt.ID, "duplicate").
+		Order("created_at").First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	originalID := originalReceiptID(&existing)
+	receipt.Status = "duplicate"
+	receipt.DuplicateOfID = &originalID
+	slog.Info("Receipt is a duplicate", "receipt_id", receipt.ID, "duplicate_of", originalID)
+	return true, tx.Save(receipt).Error
+}
+
+// MarkNotDuplicate is for a receipt wrongly taken for a duplicate, e.g. the
+// same shopping done twice on one day: it is remembered as a purchase of its
+// own and queued to be parsed and applied like a new upload.
+func (s *ReceiptService) MarkNotDuplicate(receiptID, familyID uuid.UUID) (*models.Job, error) {
+	var receipt models.Receipt
+	if err := s.db.Where("id = ? AND family_id = ?", receiptID, familyID).First(&receipt).Error; err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrReceiptNotFound, err)
+	}
+	if receipt.Status != "duplicate" {
+		return nil, ErrReceiptNotDuplicate
+	}
+
+	if err := s.db.Model(&receipt).Updates(map[string]interface{}{
+		"status":          "new",
+		"not_duplicate":   true,
+		"fingerprint":     "",
+		"duplicate_of_id": nil,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	job, err := EnqueueReceiptJob(s.db, &receipt)
+	if err != nil {
+		return nil, err
+	}
+	if job.State == jobs.StateDead {
+		return jobs.Retry(s.db, job.ID, &familyID)
+	}
+	return job, nil
+}
+
+// removeReceiptImages deletes files saved for a receipt that could not be created.
+func (s *ReceiptService) removeReceiptImages(images []models.ReceiptImage) {
+	for _, img := range images {
+		if err := os.Remove(filepath.Join(s.fileStorage.BaseDir, img.Path)); err != nil {
+			slog.Warn("Failed to remove receipt image", "path", img.Path, "error", err)
+		}
+	}
+}
+
+// receiptImagePaths returns the absolute paths of a receipt's images in order.
+func (s *ReceiptService) receiptImagePaths(receipt *models.Receipt) ([]string, error) {
+	var images []models.ReceiptImage
+	if err := s.db.Where("receipt_id = ?", receipt.ID).Order("position").Find(&images).Error; err != nil {
+		return nil, fmt.Errorf("failed to load receipt images: %w", err)
+	}
+	if len(images) == 0 {
+		return []string{filepath.Join(s.receiptsPath, receipt.ImagePath)}, nil
+	}
+	paths := make([]string, len(images))
+	for i, img := range images {
+		paths[i] = filepath.Join(s.receiptsPath, img.Path)
+	}
+	return paths, nil
+}
+
 // CreateReceiptFromText saves the plain text as a .txt file and creates a Receipt DB record.
 func (s *ReceiptService) CreateReceiptFromText(familyID uuid.UUID, text string) (*models.Receipt, error) {
+	// Pasting the same e-receipt again should be caught even if the copy
+	// picked up different line endings or surrounding blank lines.
+	normalized := strings.TrimSpace(strings.ReplaceAll(strings.TrimPrefix(text, "\xef\xbb\xbf"), "\r\n", "\n"))
+	sum := sha256.Sum256([]byte(normalized))
+	hash := hex.EncodeToString(sum[:])
+	if err := s.checkDuplicateUpload(familyID, hash); err != nil {
+		return nil, err
+	}
+
 	path, err := s.fileStorage.SaveReceiptText(familyID, text)
 	if err != nil {
 		return nil, fmt.Errorf("storage error: %w", err)
@@ -149,6 +355,7 @@ func (s *ReceiptService) CreateReceiptFromText(familyID uuid.UUID, text string)
 		TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID},
 		ImagePath:   path,
 		Date:        time.Now(),
+		FileHash:    hash,
 	}
 
 	if err := s.db.Create(&receipt).Error; err != nil {
@@ -164,10 +371,6 @@ func (s *ReceiptService) ProcessReceipt(ctx context.Context, receiptID uuid.UUID
 		return fmt.Errorf("receipt not found: %w", err)
 	}
 
-	if s.gemini == nil {
-		return ErrGeminiUnavailable
-	}
-
 	// 1. Get list items for context and matching — scope by family_id for tenant isolation
 	var listItems []models.Item
 	if err := s.db.Where("list_id = ? AND family_id = ?", listID, receipt.FamilyID).Find(&listItems).Error; err != nil {
@@ -179,35 +382,26 @@ func (s *ReceiptService) ProcessReceipt(ctx context.Context, receiptID uuid.UUID
 		knownItemNames[i] = item.Name
 	}
 
-	// 2. Parse with Gemini — branch on .txt vs image/PDF
-	var parsed *ai.ParsedReceipt
-	var parseErr error
-
-	if strings.HasSuffix(strings.ToLower(receipt.ImagePath), ".txt") {
-		fullPath := filepath.Join(s.receiptsPath, receipt.ImagePath)
-		textContent, err := os.ReadFile(fullPath)
-		if err != nil {
-			s.db.Model(&receipt).Update("status", "error")
-			return fmt.Errorf("failed to read text receipt: %w", err)
-		}
-		parsed, parseErr = s.gemini.ParseReceiptText(ctx, string(textContent), knownItemNames)
-	} else {
-		fullPath := filepath.Join(s.receiptsPath, receipt.ImagePath)
-		parsed, parseErr = s.gemini.ParseReceipt(ctx, fullPath, knownItemNames)
-	}
-
-	if parseErr != nil {
-		s.db.Model(&receipt).Update("status", "error")
-		return fmt.Errorf("gemini parsing failed: %w", parseErr)
+	// 2. Parse
+	parsed, err := s.parseReceiptFile(ctx, &receipt, knownItemNames)
+	if err != nil {
+		return err
 	}
 
 	normalizePackItems(parsed)
 
+	return s.applyParsedReceipt(ctx, &receipt, listID, listItems, parsed)
+}
+
+// applyParsedReceipt matches a parsed receipt against the list's items and
+// records the result: receipt lines, bought items, purchase history and the
+// list total.
+func (s *ReceiptService) applyParsedReceipt(ctx context.Context, receipt *models.Receipt, listID uuid.UUID, listItems []models.Item, parsed *ai.ParsedReceipt) error {
 	// 3. Pre-compute item matches outside the transaction (AI call is network I/O)
 	matchPlans := s.buildItemMatches(ctx, receipt.FamilyID, listItems, parsed.Items)
 
 	// 4. Transaction to apply everything
-	return s.db.Transaction(func(tx *gorm.DB) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
 		date, _ := time.Parse("2006-01-02", parsed.Date)
 		receipt.Date = date
 		receipt.Total = parsed.Total
@@ -220,6 +414,10 @@ func (s *ReceiptService) ProcessReceipt(ctx context.Context, receiptID uuid.UUID
 			receipt.ShopID = shopID
 		}
 
+		if dup, err := s.markIfDuplicate(tx, receipt, parsed.Items); dup || err != nil {
+			return err
+		}
+
 		s.updateListTitle(tx, listID, receipt.Date)
 
 		needsReview, err := s.applyItemMatches(tx, receipt.ID, receipt.FamilyID, parsed.Items, matchPlans, listItems, receipt.ShopID)
@@ -244,12 +442,271 @@ func (s *ReceiptService) ProcessReceipt(ctx context.Context, receiptID uuid.UUID
 			receipt.Status = "parsed"
 		}
 
-		if err := tx.Save(&receipt).Error; err != nil {
+		if err := tx.Save(receipt).Error; err != nil {
 			return err
 		}
 
 		return s.recalculateListTotal(tx, listID, receipt.FamilyID)
 	})
+	if err != nil {
+		return err
+	}
+
+	if receipt.Status == "pending_review" {
+		s.notifyReceiptReview(ctx, receipt, listID)
+	}
+	return nil
+}
+
+// parseReceiptFile reads the receipt into structured data, branching on .txt
+// vs images/PDF, which all go to the AI in one request. Text goes through the
+// retailer rules first and only reaches the AI when they cannot make sense of
+// it. knownItemNames help the AI spell lines the way the family does.
+func (s *ReceiptService) parseReceiptFile(ctx context.Context, receipt *models.Receipt, knownItemNames []string) (*ai.ParsedReceipt, error) {
+	isText := strings.HasSuffix(strings.ToLower(receipt.ImagePath), ".txt")
+	// Text receipts from known retailers can be parsed by rules alone.
+	if s.gemini == nil && !isText {
+		return nil, ErrGeminiUnavailable
+	}
+
+	var parsed *ai.ParsedReceipt
+	var parseErr error
+
+	if isText {
+		fullPath := filepath.Join(s.receiptsPath, receipt.ImagePath)
+		textContent, err := os.ReadFile(fullPath)
+		if err != nil {
+			s.db.Model(receipt).Update("status", "error")
+			return nil, fmt.Errorf("failed to read text receipt: %w", err)
+		}
+		if byRules, ok := ai.ParseReceiptTextRules(string(textContent)); ok {
+			slog.Info("Receipt text parsed by retailer rules", "receipt_id", receipt.ID, "store", byRules.StoreName)
+			parsed = byRules
+		} else if s.gemini == nil {
+			return nil, ErrGeminiUnavailable
+		} else {
+			parsed, parseErr = s.gemini.ParseReceiptText(ctx, string(textContent), knownItemNames)
+		}
+	} else {
+		paths, err := s.receiptImagePaths(receipt)
+		if err != nil {
+			return nil, err
+		}
+		parsed, parseErr = s.gemini.ParseReceipt(ctx, paths, knownItemNames)
+	}
+
+	if parseErr != nil {
+		s.db.Model(receipt).Update("status", "error")
+		return nil, fmt.Errorf("gemini parsing failed: %w", parseErr)
+	}
+	// Photos of a long receipt overlap; the model is asked to list each line
+	// once, but a repeat across the seam would double-count a purchase.
+	parsed.Items = ai.DropOverlappingLines(parsed.Items)
+	return parsed, nil
+}
+
+// ProcessStandaloneReceipt parses a receipt that is not tied to a list, e.g. an
+// unplanned trip. There is nothing to match against, so every line is recorded
+// as bought — aliases, item frequencies and per-shop prices are updated — and
+// the lines stay unmatched until the receipt is attached to a list.
+func (s *ReceiptService) ProcessStandaloneReceipt(ctx context.Context, receiptID uuid.UUID) error {
+	var receipt models.Receipt
+	if err := s.db.First(&receipt, "id = ?", receiptID).Error; err != nil {
+		return fmt.Errorf("receipt not found: %w", err)
+	}
+
+	parsed, err := s.parseReceiptFile(ctx, &receipt, nil)
+	if err != nil {
+		return err
+	}
+	normalizePackItems(parsed)
+
+	return s.applyStandaloneReceipt(&receipt, parsed)
+}
+
+// applyStandaloneReceipt records a parsed receipt that has no list.
+func (s *ReceiptService) applyStandaloneReceipt(receipt *models.Receipt, parsed *ai.ParsedReceipt) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		date, _ := time.Parse("2006-01-02", parsed.Date)
+		receipt.Date = date
+		receipt.Total = parsed.Total
+
+		if parsed.StoreName != "" {
+			shopID, err := s.findOrCreateShop(tx, receipt.FamilyID, parsed.StoreName)
+			if err != nil {
+				return err
+			}
+			receipt.ShopID = shopID
+		}
+
+		if dup, err := s.markIfDuplicate(tx, receipt, parsed.Items); dup || err != nil {
+			return err
+		}
+
+		for _, parsedItem := range parsed.Items {
+			receiptItem := models.ReceiptItem{
+				ReceiptID:      receipt.ID,
+				Name:           parsedItem.Name,
+				Quantity:       parsedItem.Quantity,
+				Unit:           parsedItem.Unit,
+				Price:          parsedItem.Price,
+				TotalPrice:     parsedItem.TotalPrice,
+				MatchStatus:    matchStatusUnmatched,
+				SuggestedItems: "[]",
+				SearchText:     utils.NormalizeSearchText(parsedItem.Name),
+				HistoryName:    s.recordUnplannedPurchase(tx, receipt.FamilyID, parsedItem, receipt.ShopID),
+			}
+			if err := tx.Create(&receiptItem).Error; err != nil {
+				return err
+			}
+		}
+
+		receipt.Status = "parsed"
+		return tx.Save(receipt).Error
+	})
+}
+
+// recordUnplannedPurchase updates purchase history for a receipt line bought
+// without a list. It counts toward the planned name the line was last bought
+// as, so "Jogurt bílý 150g" keeps feeding "jogurt"; a line never seen before
+// is recorded under its own name, the same as an extra buy. Returns the
+// planned name it was counted under.
+func (s *ReceiptService) recordUnplannedPurchase(tx *gorm.DB, familyID uuid.UUID, item ai.ParsedReceiptItem, shopID *uuid.UUID) string {
+	plannedName := item.Name
+	var alias models.ItemAlias
+	err := tx.Where("family_id = ? AND receipt_name_lower = ?", familyID, strings.ToLower(item.Name)).
+		Order("purchase_count DESC, last_used_at DESC").First(&alias).Error
+	if err == nil {
+		plannedName = alias.PlannedName
+	}
+
+	s.upsertItemAlias(tx, familyID, plannedName, item.Name, item.Price, shopID, item.Unit, nil)
+	s.updateItemFrequency(tx, familyID, plannedName, item.Price)
+	return plannedName
+}
+
+// AttachReceiptToList links a parsed standalone receipt to one of the family's
+// lists and matches its lines against the list's items, as if it had been
+// uploaded there. The purchases were already counted when the receipt was
+// parsed, so auto-matches do not touch the history again.
+//
+//nolint:gocognit
+func (s *ReceiptService) AttachReceiptToList(ctx context.Context, receiptID uuid.UUID, listID uuid.UUID, familyID uuid.UUID) error {
+	var receipt models.Receipt
+	if err := s.db.Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("id") }).
+		Where("id = ? AND family_id = ?", receiptID, familyID).First(&receipt).Error; err != nil {
+		return fmt.Errorf("%w: %v", ErrReceiptNotFound, err)
+	}
+	if receipt.ListID != nil {
+		return ErrReceiptAttached
+	}
+	if receipt.Status != "parsed" {
+		return ErrReceiptNotParsed
+	}
+
+	var list models.ShoppingList
+	if err := s.db.Where("id = ? AND family_id = ?", listID, familyID).First(&list).Error; err != nil {
+		return fmt.Errorf("%w: %v", ErrListNotFound, err)
+	}
+	var listItems []models.Item
+	if err := s.db.Where("list_id = ? AND family_id = ?", listID, familyID).Find(&listItems).Error; err != nil {
+		return fmt.Errorf("failed to fetch list items: %w", err)
+	}
+
+	var lines []models.ReceiptItem
+	var parsedItems []ai.ParsedReceiptItem
+	for _, ri := range receipt.Items {
+		if ri.MatchStatus != matchStatusUnmatched {
+			continue
+		}
+		lines = append(lines, ri)
+		parsedItems = append(parsedItems, ai.ParsedReceiptItem{
+			Name: ri.Name, Quantity: ri.Quantity, Unit: ri.Unit, Price: ri.Price, TotalPrice: ri.TotalPrice,
+		})
+	}
+
+	// Outside the transaction: matching may call the AI.
+	plans := s.buildItemMatches(ctx, familyID, listItems, parsedItems)
+
+	itemByID := map[uuid.UUID]models.Item{}
+	for _, item := range listItems {
+		itemByID[item.ID] = item
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		needsReview := false
+		for i, line := range lines {
+			plan := plans[i]
+			sugJSON := "[]"
+			if b, err := json.Marshal(plan.Suggestions); err == nil {
+				sugJSON = string(b)
+			}
+			line.MatchStatus = plan.MatchStatus
+			line.Confidence = plan.Confidence
+			line.SuggestedItems = sugJSON
+
+			if plan.MatchStatus == matchStatusAuto && plan.PlannedItemID != nil {
+				item := itemByID[*plan.PlannedItemID]
+				item.IsBought = true
+				item.IsAbsent = false
+				item.Price = line.Price
+				item.Quantity = line.Quantity
+				item.ReceiptItemID = &line.ID
+				if err := tx.Save(&item).Error; err != nil {
+					return err
+				}
+				line.MatchedItemID = plan.PlannedItemID
+			} else {
+				needsReview = true
+			}
+			if err := tx.Save(&line).Error; err != nil {
+				return err
+			}
+		}
+
+		if !needsReview {
+			matchedIDs := collectMatchedItemIDs(plans)
+			for _, item := range listItems {
+				if !item.IsBought && !matchedIDs[item.ID] {
+					needsReview = true
+					break
+				}
+			}
+		}
+		if needsReview {
+			receipt.Status = "pending_review"
+		}
+
+		s.updateListTitle(tx, listID, receipt.Date)
+		if err := tx.Model(&receipt).Updates(map[string]interface{}{"list_id": listID, "status": receipt.Status}).Error; err != nil {
+			return err
+		}
+		return s.recalculateListTotal(tx, listID, familyID)
+	})
+	if err != nil {
+		return err
+	}
+
+	if receipt.Status == "pending_review" {
+		s.notifyReceiptReview(ctx, &receipt, listID)
+	}
+	return nil
+}
+
+// notifyReceiptReview tells the family a receipt has matches waiting for a human.
+// Processing usually happens in the background, so without this nobody would
+// know to look.
+func (s *ReceiptService) notifyReceiptReview(ctx context.Context, receipt *models.Receipt, listID uuid.UUID) {
+	body := fmt.Sprintf("Receipt from %s has items that need to be confirmed.", receipt.Date.Format("2 Jan 2006"))
+	msg := notify.Message{
+		Kind:  notify.KindReceiptReview,
+		Title: "Receipt needs review",
+		Body:  body,
+		Link:  "/lists/" + listID.String(),
+	}
+	if _, err := notify.For(s.db).NotifyFamily(ctx, receipt.FamilyID, msg, nil); err != nil {
+		slog.Warn("Failed to notify about receipt review", "receipt_id", receipt.ID, "error", err)
+	}
 }
 
 // buildItemMatches computes how each parsed receipt item should be matched.
@@ -316,8 +773,8 @@ func (s *ReceiptService) buildItemMatches(ctx context.Context, familyID uuid.UUI
 		return plans
 	}
 
-	// If context was canceled or no AI client is available, mark remaining as unmatched
-	if ctx.Err() != nil || s.gemini == nil {
+	// If context was canceled, mark remaining as unmatched
+	if ctx.Err() != nil {
 		for _, idx := range unresolvedIdxs {
 			plans[idx] = receiptItemMatchPlan{
 				MatchStatus: matchStatusUnmatched,
@@ -327,7 +784,8 @@ func (s *ReceiptService) buildItemMatches(ctx context.Context, familyID uuid.UUI
 		return plans
 	}
 
-	// Step 2: Call AI for unresolved items
+	// Step 2: Call AI for unresolved items, or score them locally when there is
+	// no AI client or the call fails.
 	unresolvedNames := make([]string, len(unresolvedIdxs))
 	for j, idx := range unresolvedIdxs {
 		unresolvedNames[j] = parsedItems[idx].Name
@@ -340,16 +798,16 @@ func (s *ReceiptService) buildItemMatches(ctx context.Context, familyID uuid.UUI
 		}
 	}
 
-	aiResult, err := s.gemini.MatchReceiptItems(ctx, unresolvedNames, plannedNames)
-	if err != nil {
-		slog.Warn("AI item matching failed, leaving items unmatched", "error", err)
-		for _, idx := range unresolvedIdxs {
-			plans[idx] = receiptItemMatchPlan{
-				MatchStatus: matchStatusUnmatched,
-				Suggestions: []MatchSuggestionItem{},
-			}
+	var aiResult *ai.MatchResult
+	if s.gemini == nil {
+		aiResult = ai.MatchReceiptItemsFallback(unresolvedNames, plannedNames)
+	} else {
+		var err error
+		aiResult, err = s.gemini.MatchReceiptItems(ctx, unresolvedNames, plannedNames)
+		if err != nil {
+			slog.Warn("AI item matching failed, using local matcher", "error", err)
+			aiResult = ai.MatchReceiptItemsFallback(unresolvedNames, plannedNames)
 		}
-		return plans
 	}
 
 	// Build a lookup from AI results: receipt name (lower) → suggestion list
@@ -452,6 +910,7 @@ func (s *ReceiptService) applyItemMatches(tx *gorm.DB, receiptID uuid.UUID, fami
 			MatchStatus:    plan.MatchStatus,
 			Confidence:     plan.Confidence,
 			SuggestedItems: sugJSON,
+			SearchText:     utils.NormalizeSearchText(parsedItem.Name),
 		}
 
 		if plan.MatchStatus == matchStatusAuto && plan.PlannedItemID != nil {
@@ -464,6 +923,7 @@ func (s *ReceiptService) applyItemMatches(tx *gorm.DB, receiptID uuid.UUID, fami
 			item.Price = parsedItem.Price
 			item.Quantity = parsedItem.Quantity
 
+			receiptItem.HistoryName = item.Name
 			if err := tx.Create(&receiptItem).Error; err != nil {
 				return false, err
 			}
@@ -676,8 +1136,7 @@ func (s *ReceiptService) ConfirmMatch(ctx context.Context, receiptItemID uint, p
 			}
 
 			// Upsert ItemAlias for future auto-matching
-			s.upsertItemAlias(tx, familyID, item.Name, receiptItem.Name, receiptItem.Price, receipt.ShopID, item.Unit, CategoryIDPtr(item.CategoryID))
-			s.updateItemFrequency(tx, familyID, item.Name, receiptItem.Price)
+			s.countConfirmedPurchase(tx, familyID, &receiptItem, item.Name, receipt.ShopID, item.Unit, CategoryIDPtr(item.CategoryID))
 
 			receiptItem.MatchedItemID = plannedItemID
 			receiptItem.MatchStatus = matchStatusConfirmed
@@ -705,8 +1164,7 @@ func (s *ReceiptService) ConfirmMatch(ctx context.Context, receiptItemID uint, p
 			}
 
 			// Self-alias so it's recognized in future receipts
-			s.upsertItemAlias(tx, familyID, receiptItem.Name, receiptItem.Name, receiptItem.Price, receipt.ShopID, newItem.Unit, CategoryIDPtr(newItem.CategoryID))
-			s.updateItemFrequency(tx, familyID, receiptItem.Name, receiptItem.Price)
+			s.countConfirmedPurchase(tx, familyID, &receiptItem, receiptItem.Name, receipt.ShopID, newItem.Unit, CategoryIDPtr(newItem.CategoryID))
 
 			receiptItem.MatchedItemID = &newItem.ID
 			receiptItem.MatchStatus = matchStatusConfirmed
@@ -721,6 +1179,23 @@ func (s *ReceiptService) ConfirmMatch(ctx context.Context, receiptItemID uint, p
 	})
 }
 
+// countConfirmedPurchase counts a confirmed receipt line in the purchase
+// history as bought as plannedName. A line already counted, e.g. by a
+// standalone receipt before it was attached or by an auto-match the user
+// changed, keeps a single count: one counted under plannedName is left as it
+// is, one counted under another name is moved.
+func (s *ReceiptService) countConfirmedPurchase(tx *gorm.DB, familyID uuid.UUID, line *models.ReceiptItem, plannedName string, shopID *uuid.UUID, unit string, categoryID *uuid.UUID) {
+	if line.HistoryName != "" {
+		if strings.EqualFold(line.HistoryName, plannedName) {
+			return
+		}
+		s.uncountPurchase(tx, familyID, line.HistoryName, line.Name, shopID)
+	}
+	s.upsertItemAlias(tx, familyID, plannedName, line.Name, line.Price, shopID, unit, categoryID)
+	s.updateItemFrequency(tx, familyID, plannedName, line.Price)
+	line.HistoryName = plannedName
+}
+
 // DismissReceiptItem marks a receipt item as dismissed — not relevant to the list.
 func (s *ReceiptService) DismissReceiptItem(receiptItemID uint, familyID uuid.UUID) error {
 	var receiptItem models.ReceiptItem
@@ -797,8 +1272,7 @@ func (s *ReceiptService) ConfirmAllMatches(ctx context.Context, receiptID uuid.U
 				if err := tx.Create(&newItem).Error; err != nil {
 					return fmt.Errorf("failed to create item from receipt in confirm-all for %q: %w", ri.Name, err)
 				}
-				s.upsertItemAlias(tx, familyID, ri.Name, ri.Name, ri.Price, receipt.ShopID, newItem.Unit, CategoryIDPtr(newItem.CategoryID))
-				s.updateItemFrequency(tx, familyID, ri.Name, ri.Price)
+				s.countConfirmedPurchase(tx, familyID, &ri, ri.Name, receipt.ShopID, newItem.Unit, CategoryIDPtr(newItem.CategoryID))
 
 				ri.MatchedItemID = &newItem.ID
 				ri.MatchStatus = matchStatusConfirmed
@@ -1033,35 +1507,76 @@ func (s *ReceiptService) recalculateListTotal(tx *gorm.DB, listID uuid.UUID, fam
 	return nil
 }
 
-// ProcessPendingReceipts finds 'new' receipts and tries to parse them.
-func (s *ReceiptService) ProcessPendingReceipts(ctx context.Context) error {
-	if s.gemini == nil {
-		return nil
-	}
+// JobReceiptParse is the job kind that parses one receipt; the job ref is the receipt ID.
+const JobReceiptParse = "receipt_parse"
 
+// EnqueueReceiptJob queues parsing of a receipt, e.g. one saved while no AI
+// provider was available.
+func EnqueueReceiptJob(db *gorm.DB, receipt *models.Receipt) (*models.Job, error) {
+	familyID := receipt.FamilyID
+	return jobs.Enqueue(db, JobReceiptParse, receipt.ID.String(), jobs.Options{FamilyID: &familyID})
+}
+
+// EnqueuePendingReceipts queues a parse job for every 'new' receipt. Receipts
+// that already have a job are left to it.
+func (s *ReceiptService) EnqueuePendingReceipts(ctx context.Context) error {
 	var pending []models.Receipt
-	if err := s.db.Where("status = ?", "new").Find(&pending).Error; err != nil {
+	if err := s.db.WithContext(ctx).Where("status = ?", "new").Find(&pending).Error; err != nil {
 		return err
 	}
 
-	count := 0
-	for _, r := range pending {
-		if r.ListID == nil {
-			slog.Warn("Skipping pending receipt with no ListID", "id", r.ID)
-			continue
+	for i := range pending {
+		if _, err := EnqueueReceiptJob(s.db, &pending[i]); err != nil {
+			return err
 		}
+	}
+	return nil
+}
 
-		if err := s.ProcessReceipt(ctx, r.ID, *r.ListID); err != nil {
-			slog.Error("Failed to process pending receipt", "id", r.ID, "error", err)
-		} else {
-			count++
+// ProcessReceiptJob is the JobReceiptParse worker. A receipt that was parsed
+// in the meantime (e.g. re-uploaded while its job waited) counts as done; one
+// without a list is parsed as a standalone receipt. A receipt reprocessed with
+// another provider or model is parsed with that one.
+func (s *ReceiptService) ProcessReceiptJob(ctx context.Context, job models.Job) error {
+	id, err := uuid.Parse(job.Ref)
+	if err != nil {
+		return jobs.Permanent(fmt.Errorf("invalid receipt id %q", job.Ref))
+	}
+
+	var receipt models.Receipt
+	if err := s.db.Select("id", "family_id", "list_id", "status", "parse_provider", "parse_model").First(&receipt, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return jobs.Permanent(ErrReceiptNotFound)
+		}
+		return err
+	}
+	if receipt.Status != "new" && receipt.Status != "error" {
+		return nil
+	}
+
+	svc := s
+	if receipt.ParseProvider != "" || receipt.ParseModel != "" {
+		parser, err := s.newParser(ctx, receipt.ParseProvider, receipt.ParseModel)
+		if err != nil {
+			return jobs.Permanent(fmt.Errorf("AI provider %q model %q: %w", receipt.ParseProvider, receipt.ParseModel, err))
 		}
+		copied := *s
+		copied.gemini = parser
+		svc = &copied
 	}
 
-	if count > 0 {
-		slog.Info("Processed pending receipts", "count", count)
+	if receipt.ListID == nil {
+		err = svc.ProcessStandaloneReceipt(ctx, receipt.ID)
+	} else {
+		err = svc.ProcessReceipt(ctx, receipt.ID, *receipt.ListID)
 	}
-	return nil
+	if errors.Is(err, ErrGeminiUnavailable) {
+		// Nothing to retry until an AI provider is configured. The receipt stays
+		// 'new', and the periodic sweep queues it again once there is one.
+		slog.Info("Receipt needs AI, left queued", "receipt_id", receipt.ID)
+		return nil
+	}
+	return err
 }
 
 func (s *ReceiptService) updateItemFrequency(tx *gorm.DB, familyID uuid.UUID, name string, price float64) {
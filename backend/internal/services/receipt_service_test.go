@@ -1,26 +1,31 @@
 package services
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"mime/multipart"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
 	"kincart/internal/ai"
+	"kincart/internal/jobs"
 	"kincart/internal/models"
 
 	"github.com/google/uuid"
 	coremodels "github.com/ya-breeze/kin-core/models"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
 // MockParser implements ReceiptParser
 type MockParser struct {
-	ParseFunc          func(ctx context.Context, imagePath string, knownItems []string) (*ai.ParsedReceipt, error)
+	ParseFunc          func(ctx context.Context, imagePaths []string, knownItems []string) (*ai.ParsedReceipt, error)
 	ParseTextFunc      func(ctx context.Context, receiptText string, knownItems []string) (*ai.ParsedReceipt, error)
 	MatchItemsFunc     func(ctx context.Context, receiptItems []string, plannedItems []string) (*ai.MatchResult, error)
 	SuggestDefaultFunc func(ctx context.Context, name string, categories []string) (ai.SuggestedItemDefaults, error)
@@ -33,9 +38,9 @@ func (m *MockParser) SuggestItemDefaults(ctx context.Context, name string, categ
 	return ai.SuggestedItemDefaults{}, nil
 }
 
-func (m *MockParser) ParseReceipt(ctx context.Context, imagePath string, knownItems []string) (*ai.ParsedReceipt, error) {
+func (m *MockParser) ParseReceipt(ctx context.Context, imagePaths []string, knownItems []string) (*ai.ParsedReceipt, error) {
 	if m.ParseFunc != nil {
-		return m.ParseFunc(ctx, imagePath, knownItems)
+		return m.ParseFunc(ctx, imagePaths, knownItems)
 	}
 	return nil, nil
 }
@@ -56,7 +61,7 @@ func (m *MockParser) MatchReceiptItems(ctx context.Context, receiptItems []strin
 
 func setupTestDB() *gorm.DB {
 	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
-	db.AutoMigrate(&models.ShoppingList{}, &models.Item{}, &models.Family{}, &models.Receipt{}, &models.ReceiptItem{}, &models.ItemFrequency{}, &models.Category{}, &models.Shop{}, &models.ItemAlias{})
+	db.AutoMigrate(&models.ShoppingList{}, &models.Item{}, &models.Family{}, &models.Receipt{}, &models.ReceiptItem{}, &models.ItemFrequency{}, &models.Category{}, &models.Shop{}, &models.ItemAlias{}, &models.Job{}, &models.ReceiptImage{}, &models.ReceiptVersion{})
 	return db
 }
 
@@ -91,7 +96,7 @@ func TestProcessReceipt_Success(t *testing.T) {
 
 	// Setup Mock — Milk gets auto-matched via AI; Bread is unmatched (not on planned list)
 	mock := &MockParser{
-		ParseFunc: func(ctx context.Context, imagePath string, knownItems []string) (*ai.ParsedReceipt, error) {
+		ParseFunc: func(ctx context.Context, imagePaths []string, knownItems []string) (*ai.ParsedReceipt, error) {
 			return &ai.ParsedReceipt{
 				StoreName: "SuperMart",
 				Date:      "2024-01-30",
@@ -155,7 +160,66 @@ func TestProcessReceipt_Success(t *testing.T) {
 	assert.Equal(t, 2.5, updatedList.ActualAmount)
 }
 
-func TestProcessPendingReceipts(t *testing.T) {
+// TestProcessReceipt_LocalMatchOnAIError verifies the local matcher takes over
+// when AI matching fails: confident matches are applied, weaker ones suggested.
+func TestProcessReceipt_LocalMatchOnAIError(t *testing.T) {
+	db := setupTestDB()
+
+	family := models.Family{Family: coremodels.Family{ID: uuid.New(), Name: "TestFam"}}
+	db.Create(&family)
+	list := models.ShoppingList{
+		TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: family.ID},
+		Title:       "Weekly",
+	}
+	db.Create(&list)
+	milk := models.Item{TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: family.ID}, Name: "Mléko", ListID: list.ID}
+	rolls := models.Item{TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: family.ID}, Name: "Rohlíky", ListID: list.ID}
+	db.Create(&milk)
+	db.Create(&rolls)
+
+	receipt := models.Receipt{
+		TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: family.ID},
+		ListID:      &list.ID,
+		ImagePath:   "test.jpg",
+		Status:      "new",
+	}
+	db.Create(&receipt)
+
+	mock := &MockParser{
+		ParseFunc: func(ctx context.Context, imagePaths []string, knownItems []string) (*ai.ParsedReceipt, error) {
+			return &ai.ParsedReceipt{
+				StoreName: "Albert",
+				Date:      "2026-01-30",
+				Total:     48.9,
+				Items: []ai.ParsedReceiptItem{
+					{Name: "MLEKO POLOTUC. 1L", Price: 19.9, Quantity: 1, TotalPrice: 19.9},
+					{Name: "Rohlík tukový 43g", Price: 2.9, Quantity: 10, TotalPrice: 29},
+				},
+			}, nil
+		},
+		MatchItemsFunc: func(ctx context.Context, receiptItems []string, plannedItems []string) (*ai.MatchResult, error) {
+			return nil, errors.New("quota exceeded")
+		},
+	}
+
+	svc := NewReceiptService(db, mock, nil, "/tmp")
+	assert.NoError(t, svc.ProcessReceipt(context.Background(), receipt.ID, list.ID))
+
+	var milkLine, rollsLine models.ReceiptItem
+	db.Where("name = ?", "MLEKO POLOTUC. 1L").First(&milkLine)
+	db.Where("name = ?", "Rohlík tukový 43g").First(&rollsLine)
+
+	assert.Equal(t, "auto", milkLine.MatchStatus)
+	assert.GreaterOrEqual(t, milkLine.Confidence, 90)
+	db.First(&milk, "id = ?", milk.ID)
+	assert.True(t, milk.IsBought)
+	assert.Equal(t, &milkLine.ID, milk.ReceiptItemID)
+
+	assert.Equal(t, "unmatched", rollsLine.MatchStatus)
+	assert.Contains(t, rollsLine.SuggestedItems, rolls.ID.String())
+}
+
+func TestEnqueuePendingReceipts(t *testing.T) {
 	db := setupTestDB()
 
 	// Setup
@@ -179,25 +243,57 @@ func TestProcessPendingReceipts(t *testing.T) {
 		ImagePath:   "r2.jpg",
 		Status:      "parsed",
 	}
+	receipt3 := models.Receipt{
+		TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: family.ID},
+		ImagePath:   "r3.jpg",
+		Status:      "new",
+	}
 	db.Create(&receipt1)
 	db.Create(&receipt2)
+	db.Create(&receipt3)
 
+	calls := 0
 	mock := &MockParser{
-		ParseFunc: func(ctx context.Context, imagePath string, knownItems []string) (*ai.ParsedReceipt, error) {
+		ParseFunc: func(ctx context.Context, imagePaths []string, knownItems []string) (*ai.ParsedReceipt, error) {
+			calls++
+			if calls == 1 {
+				return nil, errors.New("quota exceeded")
+			}
 			return &ai.ParsedReceipt{Date: "2024-01-30"}, nil
 		},
 	}
 
 	svc := NewReceiptService(db, mock, nil, "/tmp")
+	queue := jobs.NewQueue(db)
+	queue.BaseBackoff = 0
+	queue.Register(JobReceiptParse, svc.ProcessReceiptJob)
 
-	// Act
-	err := svc.ProcessPendingReceipts(context.Background())
-	assert.NoError(t, err)
+	// Act: sweeping twice must not queue the receipt twice
+	require.NoError(t, svc.EnqueuePendingReceipts(context.Background()))
+	require.NoError(t, svc.EnqueuePendingReceipts(context.Background()))
+
+	var queued []models.Job
+	db.Order("id").Find(&queued)
+	require.Len(t, queued, 2, "only 'new' receipts are queued, with or without a list")
+	assert.Equal(t, receipt1.ID.String(), queued[0].Ref)
+	assert.Equal(t, receipt3.ID.String(), queued[1].Ref)
+	assert.Equal(t, &family.ID, queued[0].FamilyID)
+
+	// The first attempt fails and is retried (no backoff in the test).
+	assert.Equal(t, 3, queue.RunDue(context.Background()))
 
 	// Assert
-	var r1 models.Receipt
+	var r1, r3 models.Receipt
 	db.First(&r1, "id = ?", receipt1.ID)
 	assert.Equal(t, "parsed", r1.Status)
+	db.First(&r3, "id = ?", receipt3.ID)
+	assert.Equal(t, "parsed", r3.Status, "a receipt without a list is parsed standalone")
+
+	var job models.Job
+	db.First(&job, queued[0].ID)
+	assert.Equal(t, jobs.StateSucceeded, job.State)
+	assert.Equal(t, 2, job.Attempts)
+	assert.Empty(t, job.LastError)
 }
 
 // TestProcessReceipt_TextFile verifies that .txt receipts are routed to ParseReceiptText.
@@ -259,6 +355,64 @@ func TestProcessReceipt_TextFile(t *testing.T) {
 	assert.Equal(t, "pending_review", updated.Status)
 }
 
+// TestProcessReceipt_TextFileRules verifies that a known retailer's text receipt
+// is parsed by the rules, with no AI client at all.
+func TestProcessReceipt_TextFileRules(t *testing.T) {
+	db := setupTestDB()
+	tmpDir := t.TempDir()
+
+	family := models.Family{Family: coremodels.Family{ID: uuid.New(), Name: "TestFam"}}
+	db.Create(&family)
+
+	list := models.ShoppingList{
+		TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: family.ID},
+		Title:       "Weekly",
+	}
+	db.Create(&list)
+
+	textContent := "Lidl Česká republika v.o.s.\n30.01.2026\nChléb kváskový 39,90 A\nK PLATBĚ 39,90"
+	relPath := filepath.Join("families", "test", "receipts", "2026", "01", "lidl.txt")
+	fullPath := filepath.Join(tmpDir, relPath)
+	assert.NoError(t, os.MkdirAll(filepath.Dir(fullPath), 0755))
+	assert.NoError(t, os.WriteFile(fullPath, []byte(textContent), 0644))
+
+	receipt := models.Receipt{
+		TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: family.ID},
+		ListID:      &list.ID,
+		ImagePath:   relPath,
+		Status:      "new",
+	}
+	db.Create(&receipt)
+
+	svc := NewReceiptService(db, nil, nil, tmpDir)
+	assert.NoError(t, svc.ProcessReceipt(context.Background(), receipt.ID, list.ID))
+
+	var updated models.Receipt
+	db.Preload("Shop").Preload("Items").First(&updated, "id = ?", receipt.ID)
+	// Nothing planned to match against → the item is unmatched → pending_review
+	assert.Equal(t, "pending_review", updated.Status)
+	assert.Equal(t, 39.90, updated.Total)
+	assert.Equal(t, "2026-01-30", updated.Date.Format("2006-01-02"))
+	if assert.NotNil(t, updated.Shop) {
+		assert.Equal(t, "Lidl", updated.Shop.Name)
+	}
+	if assert.Len(t, updated.Items, 1) {
+		assert.Equal(t, "Chléb kváskový", updated.Items[0].Name)
+	}
+
+	// Text the rules cannot read still needs the AI.
+	relPath = filepath.Join("families", "test", "receipts", "2026", "01", "other.txt")
+	assert.NoError(t, os.WriteFile(filepath.Join(tmpDir, relPath), []byte("Store: Lidl\nMilk 1,99\nTotal 1,99"), 0644))
+	other := models.Receipt{
+		TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: family.ID},
+		ListID:      &list.ID,
+		ImagePath:   relPath,
+		Status:      "new",
+	}
+	db.Create(&other)
+	assert.ErrorIs(t, svc.ProcessReceipt(context.Background(), other.ID, list.ID), ErrGeminiUnavailable)
+}
+
 // TestCreateReceiptFromText verifies text is saved to filesystem and receipt record created.
 func TestCreateReceiptFromText(t *testing.T) {
 	db := setupTestDB()
@@ -291,6 +445,64 @@ func TestCreateReceiptFromText(t *testing.T) {
 	assert.Equal(t, family.ID, dbReceipt.FamilyID)
 }
 
+// TestCreateReceipt_MultipleImages verifies that the photos of a long receipt are
+// kept in order and parsed together, with lines repeated across the overlap dropped.
+func TestCreateReceipt_MultipleImages(t *testing.T) {
+	db := setupTestDB()
+	tmpDir := t.TempDir()
+	familyID := uuid.New()
+
+	// A multipart form with the receipt photographed in two parts.
+	buf := &bytes.Buffer{}
+	w := multipart.NewWriter(buf)
+	for _, part := range []struct{ name, content string }{{"top.jpg", "top"}, {"bottom.jpg", "bottom"}} {
+		fw, err := w.CreateFormFile("receipt", part.name)
+		require.NoError(t, err)
+		_, _ = fw.Write([]byte(part.content))
+	}
+	require.NoError(t, w.Close())
+	form, err := multipart.NewReader(buf, w.Boundary()).ReadForm(1 << 20)
+	require.NoError(t, err)
+
+	var sent []string
+	mock := &MockParser{
+		ParseFunc: func(ctx context.Context, imagePaths []string, knownItems []string) (*ai.ParsedReceipt, error) {
+			for _, p := range imagePaths {
+				data, err := os.ReadFile(p)
+				require.NoError(t, err)
+				sent = append(sent, string(data))
+			}
+			return &ai.ParsedReceipt{StoreName: "Albert", Date: "2026-02-01", Total: 60.7, Items: []ai.ParsedReceiptItem{
+				{Name: "Mléko", Quantity: 1, Price: 24.9, TotalPrice: 24.9, Page: 1},
+				{Name: "Rohlík", Quantity: 1, Price: 3.9, TotalPrice: 3.9, Page: 1},
+				{Name: "Rohlík", Quantity: 1, Price: 3.9, TotalPrice: 3.9, Page: 2},
+				{Name: "Sýr", Quantity: 1, Price: 31.9, TotalPrice: 31.9, Page: 2},
+			}}, nil
+		},
+	}
+	svc := NewReceiptService(db, mock, NewFileStorageService(tmpDir), tmpDir)
+
+	receipt, err := svc.CreateReceipt(familyID, form.File["receipt"])
+	require.NoError(t, err)
+
+	var images []models.ReceiptImage
+	db.Where("receipt_id = ?", receipt.ID).Order("position").Find(&images)
+	require.Len(t, images, 2)
+	assert.NotEqual(t, images[0].Path, images[1].Path, "each part gets its own file")
+	assert.Equal(t, images[0].Path, receipt.ImagePath)
+
+	require.NoError(t, svc.ProcessStandaloneReceipt(context.Background(), receipt.ID))
+	assert.Equal(t, []string{"top", "bottom"}, sent, "all parts go to the parser, in order")
+
+	var items []models.ReceiptItem
+	db.Where("receipt_id = ?", receipt.ID).Order("id").Find(&items)
+	names := make([]string, len(items))
+	for i, it := range items {
+		names[i] = it.Name
+	}
+	assert.Equal(t, []string{"Mléko", "Rohlík", "Sýr"}, names)
+}
+
 // TestProcessReceipt_TextFile_ParseError verifies error path sets status to "error".
 func TestProcessReceipt_TextFile_ParseError(t *testing.T) {
 	db := setupTestDB()
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coremodels "github.com/ya-breeze/kin-core/models"
+
+	"kincart/internal/ai"
+	"kincart/internal/models"
+)
+
+func TestStandaloneReceipt(t *testing.T) {
+	db := setupTestDB()
+	ctx := context.Background()
+	familyID := uuid.New()
+
+	// Bought as "JOGURT BILY" before, when "jogurt" was on a list.
+	_, err := UpsertItemAlias(db, familyID, "jogurt", "JOGURT BILY", 19.9, nil, "pcs", nil)
+	require.NoError(t, err)
+
+	mock := &MockParser{
+		ParseFunc: func(ctx context.Context, imagePaths []string, knownItems []string) (*ai.ParsedReceipt, error) {
+			assert.Empty(t, knownItems, "there is no list to take names from")
+			return &ai.ParsedReceipt{
+				StoreName: "Lidl",
+				Date:      "2026-03-14",
+				Total:     64.4,
+				Items: []ai.ParsedReceiptItem{
+					{Name: "JOGURT BILY", Price: 21.9, Quantity: 2, TotalPrice: 43.8},
+					{Name: "ROHLIK", Price: 20.6, Quantity: 1, TotalPrice: 20.6},
+				},
+			}, nil
+		},
+	}
+	svc := NewReceiptService(db, mock, nil, t.TempDir())
+
+	receipt := models.Receipt{
+		TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID},
+		ImagePath:   "r.jpg",
+		Status:      "new",
+	}
+	require.NoError(t, db.Create(&receipt).Error)
+
+	require.NoError(t, svc.ProcessStandaloneReceipt(ctx, receipt.ID))
+
+	var got models.Receipt
+	require.NoError(t, db.Preload("Shop").Preload("Items").First(&got, "id = ?", receipt.ID).Error)
+	assert.Equal(t, "parsed", got.Status, "nothing to review without a list")
+	assert.Nil(t, got.ListID)
+	require.NotNil(t, got.Shop)
+	assert.Equal(t, "Lidl", got.Shop.Name)
+	require.Len(t, got.Items, 2)
+	for _, ri := range got.Items {
+		assert.Equal(t, matchStatusUnmatched, ri.MatchStatus)
+		assert.Nil(t, ri.MatchedItemID)
+		assert.NotEmpty(t, ri.SearchText)
+	}
+
+	t.Run("history is updated", func(t *testing.T) {
+		var freq models.ItemFrequency
+		require.NoError(t, db.Where("family_id = ? AND item_name = ?", familyID, "jogurt").First(&freq).Error,
+			"a known line counts toward the name it was planned as")
+		assert.Equal(t, 21.9, freq.LastPrice)
+		var roll models.ItemFrequency
+		require.NoError(t, db.Where("family_id = ? AND item_name = ?", familyID, "ROHLIK").First(&roll).Error,
+			"a new line is recorded under its own name")
+
+		var alias models.ItemAlias
+		require.NoError(t, db.Where("family_id = ? AND receipt_name = ? AND shop_id = ?", familyID, "JOGURT BILY", *got.ShopID).First(&alias).Error,
+			"the price is remembered for the shop")
+		assert.Equal(t, "jogurt", alias.PlannedName)
+		assert.Equal(t, 21.9, alias.LastPrice)
+	})
+
+	t.Run("attach to a list", func(t *testing.T) {
+		list := models.ShoppingList{TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID}, Title: "Weekend"}
+		require.NoError(t, db.Create(&list).Error)
+		jogurt := models.Item{TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID}, Name: "jogurt", ListID: list.ID, IsAbsent: true}
+		require.NoError(t, db.Create(&jogurt).Error)
+
+		var before models.ItemFrequency
+		db.Where("family_id = ? AND item_name = ?", familyID, "jogurt").First(&before)
+
+		assert.ErrorIs(t, svc.AttachReceiptToList(ctx, receipt.ID, list.ID, uuid.New()), ErrReceiptNotFound)
+		assert.ErrorIs(t, svc.AttachReceiptToList(ctx, receipt.ID, uuid.New(), familyID), ErrListNotFound)
+
+		require.NoError(t, svc.AttachReceiptToList(ctx, receipt.ID, list.ID, familyID))
+
+		var attached models.Receipt
+		require.NoError(t, db.Preload("Items").First(&attached, "id = ?", receipt.ID).Error)
+		require.NotNil(t, attached.ListID)
+		assert.Equal(t, list.ID, *attached.ListID)
+		assert.Equal(t, "pending_review", attached.Status, "the roll is left to review")
+
+		require.NoError(t, db.First(&jogurt, "id = ?", jogurt.ID).Error)
+		assert.True(t, jogurt.IsBought)
+		assert.False(t, jogurt.IsAbsent)
+		assert.Equal(t, 21.9, jogurt.Price)
+		require.NotNil(t, jogurt.ReceiptItemID)
+
+		require.NoError(t, db.First(&list, "id = ?", list.ID).Error)
+		assert.InDelta(t, 43.8, list.ActualAmount, 0.001)
+		assert.Contains(t, list.Title, "(2026-03-14)")
+
+		var after models.ItemFrequency
+		db.Where("family_id = ? AND item_name = ?", familyID, "jogurt").First(&after)
+		assert.Equal(t, before.Frequency, after.Frequency, "the purchase is not counted twice")
+
+		assert.ErrorIs(t, svc.AttachReceiptToList(ctx, receipt.ID, list.ID, familyID), ErrReceiptAttached)
+	})
+
+	countOf := func(name string) (int, int) {
+		var freq models.ItemFrequency
+		db.Where("family_id = ? AND item_name = ?", familyID, name).First(&freq)
+		var alias models.ItemAlias
+		db.Where("family_id = ? AND planned_name = ? AND receipt_name = ?", familyID, name, "ROHLIK").First(&alias)
+		return freq.Frequency, alias.PurchaseCount
+	}
+
+	t.Run("confirming the attached lines does not count them again", func(t *testing.T) {
+		freqBefore, aliasBefore := countOf("ROHLIK")
+		require.Equal(t, 1, freqBefore)
+
+		require.NoError(t, svc.ConfirmAllMatches(ctx, receipt.ID, familyID))
+
+		freq, alias := countOf("ROHLIK")
+		assert.Equal(t, freqBefore, freq)
+		assert.Equal(t, aliasBefore, alias)
+
+		var roll models.ReceiptItem
+		require.NoError(t, db.Where("receipt_id = ? AND name = ?", receipt.ID, "ROHLIK").First(&roll).Error)
+		assert.Equal(t, matchStatusConfirmed, roll.MatchStatus)
+		assert.Equal(t, "ROHLIK", roll.HistoryName)
+	})
+
+	t.Run("matching a line to another item moves its count", func(t *testing.T) {
+		var attached models.Receipt
+		require.NoError(t, db.First(&attached, "id = ?", receipt.ID).Error)
+		bread := models.Item{TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID}, Name: "pečivo", ListID: *attached.ListID}
+		require.NoError(t, db.Create(&bread).Error)
+		var roll models.ReceiptItem
+		require.NoError(t, db.Where("receipt_id = ? AND name = ?", receipt.ID, "ROHLIK").First(&roll).Error)
+
+		require.NoError(t, svc.ConfirmMatch(ctx, roll.ID, &bread.ID, familyID))
+
+		freq, alias := countOf("ROHLIK")
+		assert.Zero(t, freq, "no longer counted under its own name")
+		assert.Zero(t, alias)
+		freq, alias = countOf("pečivo")
+		assert.Equal(t, 1, freq)
+		assert.Equal(t, 1, alias)
+		require.NoError(t, db.First(&roll, roll.ID).Error)
+		assert.Equal(t, "pečivo", roll.HistoryName, "reprocessing uncounts it from where it is now")
+	})
+
+	t.Run("unparsed receipts cannot be attached", func(t *testing.T) {
+		pending := models.Receipt{TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID}, Status: "new"}
+		require.NoError(t, db.Create(&pending).Error)
+		assert.ErrorIs(t, svc.AttachReceiptToList(ctx, pending.ID, uuid.New(), familyID), ErrReceiptNotParsed)
+	})
+}
@@ -2,8 +2,6 @@ package services
 
 import (
 	"fmt"
-	"io"
-	"mime/multipart"
 	"os"
 	"path/filepath"
 	"time"
@@ -19,14 +17,9 @@ func NewFileStorageService(baseDir string) *FileStorageService {
 	return &FileStorageService{BaseDir: baseDir}
 }
 
-// SaveReceipt saves a receipt file to families/{familyID}/receipts/YYYY/MM/filename
-func (s *FileStorageService) SaveReceipt(familyID uuid.UUID, file *multipart.FileHeader) (string, error) {
-	src, err := file.Open()
-	if err != nil {
-		return "", err
-	}
-	defer src.Close()
-
+// SaveReceipt saves a receipt file to families/{familyID}/receipts/YYYY/MM/filename,
+// keeping the extension of the given file name.
+func (s *FileStorageService) SaveReceipt(familyID uuid.UUID, name string, data []byte) (string, error) {
 	now := time.Now()
 	relDir := filepath.Join("families", familyID.String(), "receipts", now.Format("2006"), now.Format("01"))
 	fullDir := filepath.Join(s.BaseDir, relDir)
@@ -35,18 +28,14 @@ func (s *FileStorageService) SaveReceipt(familyID uuid.UUID, file *multipart.Fil
 		return "", mkdirErr
 	}
 
-	ext := filepath.Ext(file.Filename)
-	filename := fmt.Sprintf("%s%s", now.Format("20060102_150405"), ext)
+	// Nanoseconds keep the parts of a multi-image receipt, saved within the
+	// same second, from overwriting each other
+	ext := filepath.Ext(name)
+	filename := fmt.Sprintf("%s_%d%s", now.Format("20060102_150405"), now.UnixNano()%1_000_000_000, ext)
 	fullPath := filepath.Join(fullDir, filename)
 	relPath := filepath.Join(relDir, filename)
 
-	dst, err := os.Create(fullPath)
-	if err != nil {
-		return "", err
-	}
-	defer dst.Close()
-
-	if _, err := io.Copy(dst, src); err != nil {
+	if err := os.WriteFile(fullPath, data, 0644); err != nil {
 		return "", err
 	}
 
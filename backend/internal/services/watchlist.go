@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"kincart/internal/models"
+	"kincart/internal/notify"
+	"kincart/internal/utils"
+)
+
+// watchMatches reports whether a flyer offer satisfies a watchlist entry: every
+// word of the watched name appears in the offer's search text, the shop is one
+// the family accepts, and the price is at or under the target.
+//
+// Word-wise rather than one substring so "pilsner urquell" still finds
+// "Urquell Pilsner 0,5l"; both sides carry the diacritic-free normalization, so
+// "mleko" finds "Mléko".
+func watchMatches(w *models.WatchlistItem, item *models.FlyerItem, shopName string) bool {
+	terms := strings.Fields(w.SearchTerm)
+	if len(terms) == 0 {
+		return false
+	}
+	for _, term := range terms {
+		if !strings.Contains(item.SearchText, term) {
+			return false
+		}
+	}
+	if w.TargetPrice != nil && item.Price > *w.TargetPrice {
+		return false
+	}
+	return shopAccepted(w.Shops, shopName)
+}
+
+// shopAccepted checks the comma-separated shop list; empty accepts any shop.
+func shopAccepted(shops, shopName string) bool {
+	if strings.TrimSpace(shops) == "" {
+		return true
+	}
+	for _, s := range strings.Split(shops, ",") {
+		if strings.EqualFold(strings.TrimSpace(s), shopName) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchWatchlist runs every family's watchlist against freshly stored flyer
+// items and records a WatchlistMatch per hit. Flyers are shared across families,
+// so one page can notify many of them. Items must carry ShopName; the flyer
+// manager fills it in before calling. Returns the matches created.
+func MatchWatchlist(ctx context.Context, db *gorm.DB, items []models.FlyerItem) ([]models.WatchlistMatch, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+
+	var watches []models.WatchlistItem
+	if err := db.WithContext(ctx).Find(&watches).Error; err != nil {
+		return nil, fmt.Errorf("failed to load watchlist: %w", err)
+	}
+
+	now := time.Now()
+	var created []models.WatchlistMatch
+	for i := range items {
+		item := &items[i]
+		if !item.EndDate.IsZero() && item.EndDate.Before(now.Truncate(24*time.Hour)) {
+			continue
+		}
+		for j := range watches {
+			if !watchMatches(&watches[j], item, item.ShopName) {
+				continue
+			}
+			match, ok, err := recordWatchMatch(ctx, db, &watches[j], item, item.ShopName)
+			if err != nil {
+				slog.Error("Failed to record watchlist match", "watch_id", watches[j].ID, "flyer_item_id", item.ID, "error", err)
+				continue
+			}
+			// Attached after the insert so GORM does not try to upsert them.
+			match.WatchlistItem = &watches[j]
+			match.FlyerItem = item
+			if ok {
+				created = append(created, match)
+			}
+		}
+	}
+
+	if len(created) > 0 {
+		slog.Info("Watchlist matched new flyer items", "matches", len(created))
+	}
+	return created, nil
+}
+
+// MatchWatchlistEntry runs one (new or edited) watchlist entry against the
+// offers that are still valid, so adding "coffee" on Wednesday finds the deal
+// that was parsed on Monday.
+func MatchWatchlistEntry(ctx context.Context, db *gorm.DB, watch *models.WatchlistItem) ([]models.WatchlistMatch, error) {
+	terms := strings.Fields(watch.SearchTerm)
+	if len(terms) == 0 {
+		return nil, nil
+	}
+
+	q := db.WithContext(ctx).Table("flyer_items").
+		Select("flyer_items.*, flyers.shop_name").
+		Joins("JOIN flyers ON flyers.id = flyer_items.flyer_id").
+		Where("flyer_items.deleted_at IS NULL").
+		Where("date(flyer_items.end_date) >= ?", time.Now().Format("2006-01-02"))
+	for _, term := range terms {
+		q = q.Where("flyer_items.search_text LIKE ?", "%"+term+"%")
+	}
+
+	var items []models.FlyerItem
+	if err := q.Find(&items).Error; err != nil {
+		return nil, fmt.Errorf("failed to search flyer items: %w", err)
+	}
+
+	var created []models.WatchlistMatch
+	for i := range items {
+		if !watchMatches(watch, &items[i], items[i].ShopName) {
+			continue
+		}
+		match, ok, err := recordWatchMatch(ctx, db, watch, &items[i], items[i].ShopName)
+		if err != nil {
+			return created, err
+		}
+		if ok {
+			created = append(created, match)
+		}
+	}
+	return created, nil
+}
+
+// recordWatchMatch inserts the match unless that pair was already recorded.
+// ok is false for the duplicate case.
+func recordWatchMatch(ctx context.Context, db *gorm.DB, watch *models.WatchlistItem, item *models.FlyerItem,
+	shopName string) (models.WatchlistMatch, bool, error) {
+	match := models.WatchlistMatch{
+		FamilyID:        watch.FamilyID,
+		WatchlistItemID: watch.ID,
+		FlyerItemID:     item.ID,
+		ShopName:        shopName,
+		Price:           item.Price,
+	}
+	res := db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&match)
+	if res.Error != nil {
+		return match, false, res.Error
+	}
+	return match, res.RowsAffected > 0, nil
+}
+
+// NormalizeWatchTerm is the SearchTerm stored for a watchlist name.
+func NormalizeWatchTerm(name string) string {
+	return utils.NormalizeSearchText(strings.TrimSpace(name))
+}
+
+// WatchlistHook adapts MatchWatchlist to flyers.Manager.OnNewItems and tells
+// each family about its new deals. Matching is best-effort: a failure is logged
+// and must not fail the page that was parsed.
+func WatchlistHook(db *gorm.DB) func(ctx context.Context, items []models.FlyerItem) {
+	return func(ctx context.Context, items []models.FlyerItem) {
+		matches, err := MatchWatchlist(ctx, db, items)
+		if err != nil {
+			slog.Error("Watchlist matching failed", "error", err)
+			return
+		}
+		notifyWatchlistMatches(ctx, notify.For(db), matches)
+	}
+}
+
+// notifyWatchlistMatches sends one notification per family per page rather than
+// one per offer, so a flyer full of coffee does not flood the inbox.
+func notifyWatchlistMatches(ctx context.Context, center *notify.Center, matches []models.WatchlistMatch) {
+	byFamily := map[uuid.UUID][]models.WatchlistMatch{}
+	var order []uuid.UUID
+	for _, m := range matches {
+		if _, seen := byFamily[m.FamilyID]; !seen {
+			order = append(order, m.FamilyID)
+		}
+		byFamily[m.FamilyID] = append(byFamily[m.FamilyID], m)
+	}
+
+	for _, familyID := range order {
+		group := byFamily[familyID]
+		msg := notify.Message{Kind: notify.KindWatchlistDeal, Link: "/watchlist"}
+		first := group[0]
+		if len(group) == 1 && first.WatchlistItem != nil && first.FlyerItem != nil {
+			msg.Title = fmt.Sprintf("%s is on sale", first.WatchlistItem.Name)
+			msg.Body = fmt.Sprintf("%s at %s for %.2f", first.FlyerItem.Name, first.ShopName, first.Price)
+		} else {
+			msg.Title = fmt.Sprintf("%d watched items are on sale", len(group))
+			names := make([]string, 0, len(group))
+			for _, m := range group {
+				if m.FlyerItem != nil {
+					names = append(names, fmt.Sprintf("%s (%s, %.2f)", m.FlyerItem.Name, m.ShopName, m.Price))
+				}
+			}
+			msg.Body = strings.Join(names, "\n")
+		}
+		if _, err := center.NotifyFamily(ctx, familyID, msg, nil); err != nil {
+			slog.Error("Failed to notify family about watchlist deals", "family_id", familyID, "error", err)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	coremodels "github.com/ya-breeze/kin-core/models"
+	"gorm.io/gorm"
+
+	"kincart/internal/models"
+	"kincart/internal/utils"
+)
+
+func setupWatchlistTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db := setupTestDB()
+	require.NoError(t, db.AutoMigrate(&models.Flyer{}, &models.FlyerItem{}, &models.WatchlistItem{}, &models.WatchlistMatch{}))
+	return db
+}
+
+func mkWatch(t *testing.T, db *gorm.DB, familyID uuid.UUID, name string, target *float64, shops string) models.WatchlistItem {
+	t.Helper()
+	w := models.WatchlistItem{
+		TenantModel: coremodels.TenantModel{ID: uuid.New(), FamilyID: familyID},
+		Name:        name,
+		SearchTerm:  NormalizeWatchTerm(name),
+		TargetPrice: target,
+		Shops:       shops,
+	}
+	require.NoError(t, db.Create(&w).Error)
+	return w
+}
+
+func mkFlyerItem(t *testing.T, db *gorm.DB, flyer models.Flyer, name string, price float64) models.FlyerItem {
+	t.Helper()
+	item := models.FlyerItem{
+		FlyerID:    flyer.ID,
+		Name:       name,
+		Price:      price,
+		StartDate:  time.Now().AddDate(0, 0, -1),
+		EndDate:    time.Now().AddDate(0, 0, 5),
+		SearchText: utils.NormalizeSearchText(name),
+	}
+	require.NoError(t, db.Create(&item).Error)
+	item.ShopName = flyer.ShopName
+	return item
+}
+
+func TestMatchWatchlist(t *testing.T) {
+	db := setupWatchlistTestDB(t)
+	familyA, familyB := uuid.New(), uuid.New()
+
+	flyer := models.Flyer{ShopName: "Lidl", URL: "https://example.test/lidl"}
+	db.Create(&flyer)
+
+	cheap := 30.0
+	coffee := mkWatch(t, db, familyA, "kava", nil, "")
+	milk := mkWatch(t, db, familyA, "mléko", &cheap, "")
+	beerAtBilla := mkWatch(t, db, familyB, "pilsner urquell", nil, "Billa")
+	beerAnywhere := mkWatch(t, db, familyB, "pilsner urquell", nil, "lidl, Albert")
+
+	items := []models.FlyerItem{
+		mkFlyerItem(t, db, flyer, "Káva zrnková 1kg", 299),
+		mkFlyerItem(t, db, flyer, "Mléko polotučné 1l", 34.9),
+		mkFlyerItem(t, db, flyer, "Urquell Pilsner 0,5l", 29.9),
+	}
+
+	matches, err := MatchWatchlist(context.Background(), db, items)
+	require.NoError(t, err)
+
+	byWatch := map[uuid.UUID]int{}
+	for _, m := range matches {
+		byWatch[m.WatchlistItemID]++
+		assert.Equal(t, "Lidl", m.ShopName)
+	}
+	assert.Equal(t, 1, byWatch[coffee.ID], "diacritics are ignored")
+	assert.Equal(t, 0, byWatch[milk.ID], "price above target does not match")
+	assert.Equal(t, 0, byWatch[beerAtBilla.ID], "shop outside the preferred list does not match")
+	assert.Equal(t, 1, byWatch[beerAnywhere.ID], "word order and shop case do not matter")
+
+	var stored []models.WatchlistMatch
+	db.Where("family_id = ?", familyB).Find(&stored)
+	assert.Len(t, stored, 1, "matches are stored per family")
+
+	// Re-running over the same items (a re-parse) must not notify twice.
+	again, err := MatchWatchlist(context.Background(), db, items)
+	require.NoError(t, err)
+	assert.Empty(t, again)
+}
+
+func TestMatchWatchlistEntry_FindsRunningOffers(t *testing.T) {
+	db := setupWatchlistTestDB(t)
+	familyID := uuid.New()
+
+	flyer := models.Flyer{ShopName: "Albert", URL: "https://example.test/albert"}
+	db.Create(&flyer)
+	mkFlyerItem(t, db, flyer, "Máslo 250g", 44.9)
+	expired := mkFlyerItem(t, db, flyer, "Máslo tradiční", 39.9)
+	db.Model(&expired).Update("end_date", time.Now().AddDate(0, 0, -3))
+
+	watch := mkWatch(t, db, familyID, "maslo", nil, "albert")
+	matches, err := MatchWatchlistEntry(context.Background(), db, &watch)
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	assert.Equal(t, 44.9, matches[0].Price)
+}
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"strings"
+)
+
+// NewSecretToken returns a random, URL-safe token for invites and password
+// resets, and the hash under which it is stored. Only the hash is persisted;
+// the token itself is shown once.
+func NewSecretToken() (token, hash string, err error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))
+	return token, HashSecretToken(token), nil
+}
+
+// HashSecretToken returns the stored form of a token. Surrounding whitespace
+// from copy-pasting is ignored.
+func HashSecretToken(token string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(token)))
+	return hex.EncodeToString(sum[:])
+}
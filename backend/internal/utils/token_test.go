@@ -0,0 +1,24 @@
+package utils
+
+import "testing"
+
+func TestNewSecretToken(t *testing.T) {
+	token, hash, err := NewSecretToken()
+	if err != nil {
+		t.Fatalf("NewSecretToken() error = %v", err)
+	}
+	if len(token) != 32 {
+		t.Errorf("token length = %d, want 32", len(token))
+	}
+	if hash != HashSecretToken(token) {
+		t.Errorf("hash does not match HashSecretToken(token)")
+	}
+	if hash != HashSecretToken(" "+token+"\n") {
+		t.Errorf("HashSecretToken should ignore surrounding whitespace")
+	}
+
+	other, _, _ := NewSecretToken()
+	if other == token {
+		t.Errorf("two tokens should differ")
+	}
+}
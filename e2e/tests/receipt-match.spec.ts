@@ -62,7 +62,7 @@ async function createAliasViaAPI(page: Page, plannedName: string, receiptName: s
     expect(resp.ok()).toBeTruthy();
 }
 
-/** Upload receipt as text and wait for the upload response. Returns the fetch response. */
+/** Upload receipt as text and wait until it is processed. Returns the receipt's final progress. */
 async function uploadReceiptText(page: Page, text: string) {
     await page.locator('[data-testid="tab-paste"]').click();
     await page.locator('textarea').fill(text);
@@ -70,7 +70,13 @@ async function uploadReceiptText(page: Page, text: string) {
         page.waitForResponse(resp => resp.url().includes('/receipts'), { timeout: 60000 }),
         page.locator('button:has-text("Process Receipt")').click(),
     ]);
-    return response;
+    // Parsing runs in the background; poll like the upload modal does.
+    let data = await response.json();
+    for (let i = 0; i < 60 && (data.status === 'queued' || data.status === 'processing'); i++) {
+        await page.waitForTimeout(1000);
+        data = await (await page.request.get(`/api/receipts/${data.receipt_id}`)).json();
+    }
+    return data;
 }
 
 // ---------------------------------------------------------------------------
@@ -180,8 +186,7 @@ test.describe('Receipt matching', () => {
         await page.locator('button[title="Upload receipt"]').click();
         await expect(page.locator('text=Upload Receipt')).toBeVisible({ timeout: 5000 });
 
-        const uploadResp = await uploadReceiptText(page, `${appleReceipt} 3.50`);
-        const uploadData = await uploadResp.json();
+        const uploadData = await uploadReceiptText(page, `${appleReceipt} 3.50`);
 
         if (uploadData.status === 'pending_review') {
             // Match modal appeared: confirm immediately (should not block)
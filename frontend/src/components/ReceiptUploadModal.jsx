@@ -3,9 +3,18 @@ import { X, Upload, Check, Loader, FileText, Clock } from 'lucide-react';
 import { API_BASE_URL } from '../config';
 import ReceiptMatchModal from './ReceiptMatchModal';
 
+// Receipts are parsed in the background after upload. The modal polls for the
+// result for a while; past that the receipt stays queued and its items show up
+// on the list once the server gets to it.
+const POLL_INTERVAL_MS = 2000;
+const POLL_TIMEOUT_MS = 90000;
+
+const sleep = (ms) => new Promise((resolve) => setTimeout(resolve, ms));
+
 const ReceiptUploadModal = ({ isOpen, onClose, listId, onUploadSuccess }) => {
     const [inputMode, setInputMode] = useState('upload'); // 'upload' | 'paste'
-    const [file, setFile] = useState(null);
+    // Photos of one receipt, top first; a long receipt may need several.
+    const [files, setFiles] = useState([]);
     const [previewUrl, setPreviewUrl] = useState(null);
     const [receiptText, setReceiptText] = useState('');
     const [isUploading, setIsUploading] = useState(false);
@@ -14,6 +23,7 @@ const ReceiptUploadModal = ({ isOpen, onClose, listId, onUploadSuccess }) => {
     const [successStatus, setSuccessStatus] = useState(null);
     const [pendingReceiptId, setPendingReceiptId] = useState(null);
     const autoCloseTimerRef = useRef(null);
+    const pollCancelledRef = useRef(false);
 
     // Clean up blob URL when file changes or component unmounts
     useEffect(() => {
@@ -27,10 +37,11 @@ const ReceiptUploadModal = ({ isOpen, onClose, listId, onUploadSuccess }) => {
 
     const handleFileChange = (e) => {
         if (e.target.files && e.target.files[0]) {
-            const newFile = e.target.files[0];
+            const newFiles = Array.from(e.target.files);
+            const newFile = newFiles[0];
             // Revoke previous blob URL to prevent memory leak
             if (previewUrl) URL.revokeObjectURL(previewUrl);
-            setFile(newFile);
+            setFiles(newFiles);
             // Create preview URL for image files
             if (newFile.type.startsWith('image/')) {
                 setPreviewUrl(URL.createObjectURL(newFile));
@@ -41,9 +52,30 @@ const ReceiptUploadModal = ({ isOpen, onClose, listId, onUploadSuccess }) => {
         }
     };
 
+    // Returns the receipt's progress once parsing is over, or { status: 'queued' }
+    // when it takes too long or the status cannot be fetched.
+    const waitForProcessing = async (receiptId) => {
+        const deadline = Date.now() + POLL_TIMEOUT_MS;
+        while (!pollCancelledRef.current && Date.now() < deadline) {
+            try {
+                const resp = await fetch(`${API_BASE_URL}/api/receipts/${receiptId}`);
+                if (!resp.ok) break;
+                const progress = await resp.json();
+                if (progress.status !== 'queued' && progress.status !== 'processing') {
+                    return progress;
+                }
+            } catch {
+                break;
+            }
+            await sleep(POLL_INTERVAL_MS);
+        }
+        return { status: 'queued' };
+    };
+
     const handleUpload = async () => {
         setIsUploading(true);
         setError(null);
+        pollCancelledRef.current = false;
 
         try {
             let resp;
@@ -57,7 +89,7 @@ const ReceiptUploadModal = ({ isOpen, onClose, listId, onUploadSuccess }) => {
                 });
             } else {
                 const formData = new FormData();
-                formData.append('receipt', file);
+                files.forEach((f) => formData.append('receipt', f));
                 resp = await fetch(`${API_BASE_URL}/api/lists/${listId}/receipts`, {
                     method: 'POST',
                     headers: {
@@ -72,7 +104,18 @@ const ReceiptUploadModal = ({ isOpen, onClose, listId, onUploadSuccess }) => {
             }
 
             const data = await resp.json();
-            const status = data.status;
+            let status = data.status;
+            if (status === 'queued') {
+                const progress = await waitForProcessing(data.receipt_id);
+                if (pollCancelledRef.current) return;
+                if (progress.status === 'error') {
+                    throw new Error(progress.last_error || 'Receipt could not be processed');
+                }
+                if (progress.status === 'duplicate') {
+                    throw new Error('This receipt has already been uploaded; nothing was counted twice');
+                }
+                status = progress.status;
+            }
             setSuccessStatus(status);
 
             if (status === 'parsed') {
@@ -94,13 +137,14 @@ const ReceiptUploadModal = ({ isOpen, onClose, listId, onUploadSuccess }) => {
     };
 
     const handleClose = () => {
+        pollCancelledRef.current = true;
         if (autoCloseTimerRef.current) {
             clearTimeout(autoCloseTimerRef.current);
             autoCloseTimerRef.current = null;
         }
         if (previewUrl) URL.revokeObjectURL(previewUrl);
         setInputMode('upload');
-        setFile(null);
+        setFiles([]);
         setPreviewUrl(null);
         setReceiptText('');
         setIsUploading(false);
@@ -110,7 +154,7 @@ const ReceiptUploadModal = ({ isOpen, onClose, listId, onUploadSuccess }) => {
         onClose();
     };
 
-    const isSubmitDisabled = isUploading || !!successStatus || (inputMode === 'upload' ? !file : !receiptText.trim());
+    const isSubmitDisabled = isUploading || !!successStatus || (inputMode === 'upload' ? files.length === 0 : !receiptText.trim());
 
     // When pending_review: hand off to ReceiptMatchModal
     if (successStatus === 'pending_review' && pendingReceiptId) {
@@ -239,10 +283,11 @@ const ReceiptUploadModal = ({ isOpen, onClose, listId, onUploadSuccess }) => {
                                     id="receipt-input"
                                     type="file"
                                     accept="image/*,application/pdf,.txt"
+                                    multiple
                                     onChange={handleFileChange}
                                     style={{ display: 'none' }}
                                 />
-                                {file ? (
+                                {files.length > 0 ? (
                                     <div style={{ textAlign: 'center' }}>
                                         <div style={{ width: '64px', height: '64px', margin: '0 auto 1rem', borderRadius: '8px', overflow: 'hidden', display: 'flex', alignItems: 'center', justifyContent: 'center', background: '#f3f4f6' }}>
                                             {previewUrl ? (
@@ -251,8 +296,8 @@ const ReceiptUploadModal = ({ isOpen, onClose, listId, onUploadSuccess }) => {
                                                 <FileText size={32} color="var(--text-muted)" />
                                             )}
                                         </div>
-                                        <p style={{ fontWeight: 600 }}>{file.name}</p>
-                                        <p style={{ fontSize: '0.8rem', color: 'var(--text-muted)' }}>{(file.size / 1024 / 1024).toFixed(2)} MB</p>
+                                        <p style={{ fontWeight: 600 }}>{files.length > 1 ? `${files.length} photos of one receipt` : files[0].name}</p>
+                                        <p style={{ fontSize: '0.8rem', color: 'var(--text-muted)' }}>{(files.reduce((sum, f) => sum + f.size, 0) / 1024 / 1024).toFixed(2)} MB</p>
                                     </div>
                                 ) : (
                                     <>
@@ -260,7 +305,7 @@ const ReceiptUploadModal = ({ isOpen, onClose, listId, onUploadSuccess }) => {
                                             <Upload size={32} color="var(--primary)" />
                                         </div>
                                         <p style={{ fontWeight: 600, color: 'var(--primary)' }}>Click to select file</p>
-                                        <p style={{ fontSize: '0.8rem', color: 'var(--text-muted)' }}>Image, PDF, or .txt</p>
+                                        <p style={{ fontSize: '0.8rem', color: 'var(--text-muted)' }}>Image, PDF, or .txt — pick several photos for a long receipt, top first</p>
                                     </>
                                 )}
                             </div>
@@ -341,7 +386,7 @@ const ReceiptUploadModal = ({ isOpen, onClose, listId, onUploadSuccess }) => {
                         </div>
                         <h3 style={{ fontSize: '1.25rem', fontWeight: 800, color: '#d97706', marginBottom: '0.5rem' }}>Uploaded</h3>
                         <p style={{ color: 'var(--text-muted)', marginBottom: '1.5rem' }}>
-                            Receipt saved. Items will appear once AI processing completes — usually within 10 minutes.
+                            Receipt saved. Items will appear once AI processing completes — you can close this window.
                         </p>
                         <button onClick={handleClose} className="primary-btn" style={{ width: '100%', padding: '0.75rem' }}>
                             Got it
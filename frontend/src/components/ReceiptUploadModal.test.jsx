@@ -116,6 +116,26 @@ describe('ReceiptUploadModal', () => {
         expect(options.headers['Content-Type']).toBeUndefined();
     });
 
+    it('Upload mode sends every selected photo', async () => {
+        fetch.mockResolvedValueOnce({
+            ok: true,
+            json: async () => ({ status: 'parsed', receipt_id: 1 }),
+        });
+
+        render(<ReceiptUploadModal {...defaultProps} />);
+
+        const top = new File(['top'], 'top.jpg', { type: 'image/jpeg' });
+        const bottom = new File(['bottom'], 'bottom.jpg', { type: 'image/jpeg' });
+        fireEvent.change(document.getElementById('receipt-input'), { target: { files: [top, bottom] } });
+        expect(screen.getByText('2 photos of one receipt')).toBeInTheDocument();
+
+        fireEvent.click(screen.getByRole('button', { name: /upload & process/i }));
+
+        await waitFor(() => expect(fetch).toHaveBeenCalledOnce());
+        const [, options] = fetch.mock.calls[0];
+        expect(options.body.getAll('receipt').map((f) => f.name)).toEqual(['top.jpg', 'bottom.jpg']);
+    });
+
     it('shows green success state when server returns parsed', async () => {
         fetch.mockResolvedValueOnce({
             ok: true,
@@ -172,6 +192,49 @@ describe('ReceiptUploadModal', () => {
         expect(defaultProps.onClose).toHaveBeenCalled();
     });
 
+    it('polls a queued receipt until it is parsed', async () => {
+        fetch
+            .mockResolvedValueOnce({
+                ok: true,
+                json: async () => ({ status: 'queued', receipt_id: 'r1' }),
+            })
+            .mockResolvedValueOnce({
+                ok: true,
+                json: async () => ({ status: 'parsed', receipt_id: 'r1' }),
+            });
+
+        render(<ReceiptUploadModal {...defaultProps} />);
+        fireEvent.click(screen.getByTestId('tab-paste'));
+        fireEvent.change(screen.getByTestId('receipt-textarea'), { target: { value: 'Store: Lidl\nTotal: 5.00' } });
+        fireEvent.click(screen.getByRole('button', { name: /process receipt/i }));
+
+        await waitFor(() => {
+            expect(screen.getByText('Done!')).toBeInTheDocument();
+        });
+        expect(fetch.mock.calls[1][0]).toMatch(/\/api\/receipts\/r1$/);
+    });
+
+    it('shows the processing error of a queued receipt', async () => {
+        fetch
+            .mockResolvedValueOnce({
+                ok: true,
+                json: async () => ({ status: 'queued', receipt_id: 'r1' }),
+            })
+            .mockResolvedValueOnce({
+                ok: true,
+                json: async () => ({ status: 'error', receipt_id: 'r1', last_error: 'gemini parsing failed' }),
+            });
+
+        render(<ReceiptUploadModal {...defaultProps} />);
+        fireEvent.click(screen.getByTestId('tab-paste'));
+        fireEvent.change(screen.getByTestId('receipt-textarea'), { target: { value: 'Store: Lidl\nTotal: 5.00' } });
+        fireEvent.click(screen.getByRole('button', { name: /process receipt/i }));
+
+        await waitFor(() => {
+            expect(screen.getByText('gemini parsing failed')).toBeInTheDocument();
+        });
+    });
+
     it('shows backend error message in Paste mode', async () => {
         fetch.mockResolvedValueOnce({
             ok: false,